@@ -0,0 +1,87 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAlignCheck(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(depth.Pair, int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly())
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.Empty(t, loader.AlignCheck(nil))
+
+	// BTC-BUSD has no data for the second day, which is dropped
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, pair != "BTC-BUSD" || day.Equal(start)
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/align") })
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("align"))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	misalignments := loader.AlignCheck(nil)
+	assert.Len(t, misalignments, 1)
+	assert.Equal(t, depth.Pair("BTC-BUSD"), misalignments[0].Pair)
+	assert.Equal(t, 24*60, misalignments[0].Minute)
+	assert.True(t, misalignments[0].Time.Equal(start.Add(24*time.Hour)))
+	assert.Contains(t, misalignments[0].Reason, "1 days missing of 2")
+
+	// a partial day
+	loader = depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader("#,ETH-BUSD,BTC-BUSD\nETH-BUSD,10,1,11,1\nBTC-BUSD,100,1,101,1,100,1,101,1\n"), start)
+	misalignments = loader.AlignCheck(nil)
+	assert.Len(t, misalignments, 1)
+	assert.Equal(t, depth.Pair("ETH-BUSD"), misalignments[0].Pair)
+	assert.Contains(t, misalignments[0].Reason, "not whole days")
+}
+
+func TestAlignment(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020")
+	path := "data/binance/2020-01-01_2020-01-04_depth.csv"
+	// ETH-BUSD has no data for the first day, and BTC-BUSD for the last one
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if pair == "ETH-BUSD" && day.Equal(start) || pair == "BTC-BUSD" && day.Equal(end.AddDate(0, 0, -1)) {
+			return Quote{}, false
+		}
+		return Quote{float64(day.Day()), 1, float64(day.Day()) + 1, 1}, true
+	})
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+		_ = os.Remove(path + ".versions")
+	})
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD"}
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithAlignment(depth.AlignPad))
+	loader.Load(pairs, start, end)
+	assert.Empty(t, loader.AlignCheck(nil))
+	assert.True(t, math.IsNaN(loader.GetDepth("ETH-BUSD").BidPrice))
+	assert.Equal(t, 1.0, loader.GetDepth("BTC-BUSD").BidPrice)
+
+	// the padded days are stored, and trimmed by the next loads
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly(), depth.WithAlignment(depth.AlignTrim))
+	result := loader.Load(pairs, start, end)
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+	assert.Len(t, result["ETH-BUSD"], 24*60*4)
+	assert.Equal(t, 2.0, loader.GetDepth("BTC-BUSD").BidPrice)
+	assert.Equal(t, 2.0, loader.GetDepth("ETH-BUSD").BidPrice)
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, nil))
+	assert.True(t, strings.HasPrefix(strings.Split(out.String(), "\n")[1], "2020-01-02T00:00:00Z,"))
+
+	// the rows without padding can't be aligned
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithAlignment(depth.AlignTrim))
+	assert.Panics(t, func() {
+		loader.LoadFrom(strings.NewReader("#,ETH-BUSD,BTC-BUSD\nETH-BUSD,10,1,11,1\nBTC-BUSD,100,1,101,1,100,1,101,1\n"), start)
+	})
+	assert.Panics(t, func() { depth.WithAlignment("shift") })
+}
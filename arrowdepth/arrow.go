@@ -0,0 +1,38 @@
+// Package arrowdepth converts the loaded depth data to Apache Arrow, serves it over Arrow Flight, and stores it as Parquet,
+// so that notebooks and analytical tools can consume it without CSV round-trips.
+package arrowdepth
+
+import (
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+)
+
+// Schema is the Arrow schema of the depth records of a pair.
+var Schema = arrow.NewSchema([]arrow.Field{
+	{Name: "time", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+	{Name: "bid_price", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "bid_size", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "ask_price", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "ask_size", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// ToArrow returns the loaded records of the pair as an Arrow record batch with the Schema.
+// The caller must Release the record.
+func ToArrow(loader depth.ColumnSource, pair depth.Pair) arrow.RecordBatch {
+	columns := loader.Columns(pair)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, Schema)
+	defer builder.Release()
+
+	times := builder.Field(0).(*array.TimestampBuilder)
+	times.Reserve(columns.Len())
+	for _, t := range columns.Time {
+		times.UnsafeAppend(arrow.Timestamp(t.UnixMilli()))
+	}
+	builder.Field(1).(*array.Float64Builder).AppendValues(columns.BidPrice, nil)
+	builder.Field(2).(*array.Float64Builder).AppendValues(columns.BidSize, nil)
+	builder.Field(3).(*array.Float64Builder).AppendValues(columns.AskPrice, nil)
+	builder.Field(4).(*array.Float64Builder).AppendValues(columns.AskSize, nil)
+	return builder.NewRecordBatch()
+}
@@ -0,0 +1,63 @@
+// Command depthflight loads the depth data, and serves it as Arrow tables over Arrow Flight:
+//
+//	depthflight -addr localhost:8815 -market binance -pairs BTC-BUSD -start 2022-11-24 -end 2022-11-25
+//
+// Download progress is written to the standard error.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/arrowdepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+func main() {
+	addr := flag.String("addr", "localhost:8815", "Arrow Flight address")
+	market := flag.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	pairs := flag.String("pairs", "", "comma-separated pairs to load, all known pairs if empty")
+	start := flag.String("start", "", "start date, like 2022-11-24")
+	end := flag.String("end", "", "end date, exclusive, like 2022-11-25")
+	flag.Parse()
+
+	var pairsToServe []depth.Pair
+	if *pairs != "" {
+		for _, pair := range strings.Split(*pairs, ",") {
+			pairsToServe = append(pairsToServe, depth.Pair(pair))
+		}
+	}
+	loader := depth.NewCCDepthLoader(depth.Market(*market), depth.WithProgress(os.Stderr))
+	records := loader.Load(pairsToServe, mustParseDate(*start), mustParseDate(*end))
+	if len(pairsToServe) == 0 {
+		for pair := range records {
+			pairsToServe = append(pairsToServe, pair)
+		}
+		sort.Slice(pairsToServe, func(i, j int) bool {
+			return pairsToServe[i] < pairsToServe[j]
+		})
+	}
+
+	fmt.Fprintln(os.Stderr, "Serving Arrow Flight on", *addr)
+	if err := arrowdepth.ListenAndServe(*addr, loader, pairsToServe); err != nil {
+		fail(err)
+	}
+}
+
+func mustParseDate(s string) time.Time {
+	t, err := time.Parse(dateFormat, s)
+	if err != nil {
+		fail(err)
+	}
+	return t
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
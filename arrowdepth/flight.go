@@ -0,0 +1,100 @@
+package arrowdepth
+
+import (
+	"context"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/life4/genesis/slices"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// FlightService serves the loaded depth data over Arrow Flight, one flight per pair.
+// Both the flight descriptor path and the ticket are the pair name, so a notebook pulls a pair as
+// an Arrow table with a single call:
+//
+//	client = pyarrow.flight.connect("grpc://localhost:8815")
+//	table = client.do_get(pyarrow.flight.Ticket(b"BTC-BUSD")).read_all()
+type FlightService struct {
+	flight.BaseFlightServer
+	loader depth.ColumnSource
+	pairs  []depth.Pair
+}
+
+// NewFlightService returns a FlightService of the given loaded pairs.
+func NewFlightService(loader depth.ColumnSource, pairs []depth.Pair) *FlightService {
+	return &FlightService{loader: loader, pairs: pairs}
+}
+
+// ListenAndServe serves the given loaded pairs over Arrow Flight on the address, like "localhost:8815".
+// It blocks until the server stops.
+func ListenAndServe(addr string, loader depth.ColumnSource, pairs []depth.Pair) error {
+	server := flight.NewFlightServer()
+	if err := server.Init(addr); err != nil {
+		return err
+	}
+	server.RegisterFlightService(NewFlightService(loader, pairs))
+	return server.Serve()
+}
+
+func (s *FlightService) ListFlights(criteria *flight.Criteria, stream flight.FlightService_ListFlightsServer) error {
+	for _, pair := range s.pairs {
+		if err := stream.Send(s.flightInfo(pair)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *FlightService) GetFlightInfo(ctx context.Context, descriptor *flight.FlightDescriptor) (*flight.FlightInfo, error) {
+	if descriptor.Type != flight.DescriptorPATH || len(descriptor.Path) != 1 {
+		return nil, status.Error(codes.InvalidArgument, "the flight descriptor must be a path with the pair name")
+	}
+	pair, err := s.pair(descriptor.Path[0])
+	if err != nil {
+		return nil, err
+	}
+	return s.flightInfo(pair), nil
+}
+
+func (s *FlightService) GetSchema(ctx context.Context, descriptor *flight.FlightDescriptor) (*flight.SchemaResult, error) {
+	return &flight.SchemaResult{Schema: flight.SerializeSchema(Schema, memory.DefaultAllocator)}, nil
+}
+
+func (s *FlightService) DoGet(ticket *flight.Ticket, stream flight.FlightService_DoGetServer) error {
+	pair, err := s.pair(string(ticket.Ticket))
+	if err != nil {
+		return err
+	}
+	record := ToArrow(s.loader, pair)
+	defer record.Release()
+
+	writer := flight.NewRecordWriter(stream, ipc.WithSchema(Schema))
+	defer writer.Close()
+	return writer.Write(record)
+}
+
+func (s *FlightService) pair(name string) (depth.Pair, error) {
+	pair := depth.Pair(name)
+	if !slices.Contains(s.pairs, pair) {
+		return pair, status.Errorf(codes.NotFound, "pair is not loaded: %s", name)
+	}
+	return pair, nil
+}
+
+func (s *FlightService) flightInfo(pair depth.Pair) *flight.FlightInfo {
+	return &flight.FlightInfo{
+		Schema: flight.SerializeSchema(Schema, memory.DefaultAllocator),
+		FlightDescriptor: &flight.FlightDescriptor{
+			Type: flight.DescriptorPATH,
+			Path: []string{pair.String()},
+		},
+		Endpoint: []*flight.FlightEndpoint{{
+			Ticket: &flight.Ticket{Ticket: []byte(pair.String())},
+		}},
+		TotalRecords: int64(s.loader.Columns(pair).Len()),
+		TotalBytes:   -1,
+	}
+}
@@ -0,0 +1,56 @@
+package arrowdepth_test
+
+import (
+	"context"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/flight"
+	"github.com/bogdantimes/order-book-depth-loader/arrowdepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFlightService(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	server := flight.NewFlightServer()
+	assert.NoError(t, server.Init("localhost:0"))
+	server.RegisterFlightService(arrowdepth.NewFlightService(loader, []depth.Pair{"BTC-BUSD"}))
+	go func() {
+		_ = server.Serve()
+	}()
+	defer server.Shutdown()
+
+	client, err := flight.NewClientWithMiddleware(server.Addr().String(), nil, nil, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	defer client.Close()
+
+	flights, err := client.ListFlights(context.Background(), &flight.Criteria{})
+	assert.NoError(t, err)
+	info, err := flights.Recv()
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), info.TotalRecords)
+
+	stream, err := client.DoGet(context.Background(), info.Endpoint[0].Ticket)
+	assert.NoError(t, err)
+	reader, err := flight.NewRecordReader(stream)
+	assert.NoError(t, err)
+	defer reader.Release()
+	assert.True(t, reader.Schema().Equal(arrowdepth.Schema))
+	assert.True(t, reader.Next())
+	record := reader.RecordBatch()
+	assert.Equal(t, int64(2), record.NumRows())
+	assert.Equal(t, 102.0, record.Column(1).(*array.Float64).Value(1))
+
+	stream, err = client.DoGet(context.Background(), &flight.Ticket{Ticket: []byte("ETH-BUSD")})
+	if err == nil {
+		_, err = flight.NewRecordReader(stream)
+	}
+	assert.Error(t, err)
+}
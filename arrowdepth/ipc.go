@@ -0,0 +1,81 @@
+package arrowdepth
+
+import (
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+)
+
+// Tables keeps the loaded records of the pairs as Arrow record batches with the Schema, converted once
+// when the tables are created, so that the analytical tools read their typed columns without converting
+// the records of the loader again:
+//
+//	tables := arrowdepth.NewTables(loader, pairs)
+//	defer tables.Release()
+//	err := tables.WriteIPC(file)
+type Tables struct {
+	pairs   []depth.Pair
+	records map[depth.Pair]arrow.RecordBatch
+}
+
+// NewTables converts the loaded records of the pairs of the loader. The caller must Release the tables.
+func NewTables(loader depth.ColumnSource, pairs []depth.Pair) *Tables {
+	t := &Tables{pairs: pairs, records: make(map[depth.Pair]arrow.RecordBatch, len(pairs))}
+	for _, pair := range pairs {
+		if _, ok := t.records[pair]; !ok {
+			t.records[pair] = ToArrow(loader, pair)
+		}
+	}
+	return t
+}
+
+// ToArrow returns the record batch of the pair, nil if the tables have none. The record is released
+// with the tables, the caller must Retain it to keep it longer.
+func (t *Tables) ToArrow(pair depth.Pair) arrow.RecordBatch {
+	return t.records[pair]
+}
+
+// WriteIPC writes the records of the pairs to w as an Arrow IPC file with the PairSchema, a record batch per pair
+// in the order of the tables, which is also the Feather V2 format of pandas.read_feather.
+func (t *Tables) WriteIPC(w io.Writer) error {
+	writer, err := ipc.NewFileWriter(w, ipc.WithSchema(PairSchema))
+	if err != nil {
+		return err
+	}
+	for _, pair := range t.pairs {
+		record := t.withPair(pair)
+		err := writer.Write(record)
+		record.Release()
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// withPair returns the record batch of the pair with the PairSchema, sharing the columns of its record.
+// The caller must Release the record.
+func (t *Tables) withPair(pair depth.Pair) arrow.RecordBatch {
+	record := t.records[pair]
+	builder := array.NewStringBuilder(memory.DefaultAllocator)
+	defer builder.Release()
+	builder.Reserve(int(record.NumRows()))
+	for i := int64(0); i < record.NumRows(); i++ {
+		builder.Append(pair.String())
+	}
+	pairs := builder.NewArray()
+	defer pairs.Release()
+	columns := append([]arrow.Array{record.Column(0), pairs}, record.Columns()[1:]...)
+	return array.NewRecordBatch(PairSchema, columns, record.NumRows())
+}
+
+// Release releases the record batches of the tables.
+func (t *Tables) Release() {
+	for _, record := range t.records {
+		record.Release()
+	}
+}
@@ -0,0 +1,38 @@
+package arrowdepth_test
+
+import (
+	"bytes"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/ipc"
+	"github.com/bogdantimes/order-book-depth-loader/arrowdepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTablesWriteIPC(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+	tables := arrowdepth.NewTables(loader, []depth.Pair{"BTC-BUSD", "ETH-BUSD"})
+	defer tables.Release()
+
+	record := tables.ToArrow("ETH-BUSD")
+	assert.True(t, record.Schema().Equal(arrowdepth.Schema))
+	assert.Equal(t, 12.0, record.Column(1).(*array.Float64).Value(1))
+	assert.Nil(t, tables.ToArrow("XRP-BUSD"))
+
+	var b bytes.Buffer
+	assert.NoError(t, tables.WriteIPC(&b))
+	reader, err := ipc.NewFileReader(bytes.NewReader(b.Bytes()))
+	assert.NoError(t, err)
+	defer reader.Close()
+	assert.True(t, reader.Schema().Equal(arrowdepth.PairSchema))
+	assert.Equal(t, 2, reader.NumRecords())
+	second, err := reader.RecordBatch(1)
+	assert.NoError(t, err)
+	assert.Equal(t, "ETH-BUSD", second.Column(1).(*array.String).Value(0))
+	assert.Equal(t, 13.0, second.Column(4).(*array.Float64).Value(1))
+}
@@ -0,0 +1,70 @@
+package arrowdepth
+
+import (
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet"
+	"github.com/apache/arrow-go/v18/parquet/compress"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+)
+
+// PairSchema is the Arrow schema of the files of the records of several pairs, see WriteParquet and Tables.WriteIPC,
+// with the pair of each record, so that they are read as a single table by pandas, polars or Spark.
+var PairSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "timestamp", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+	{Name: "pair", Type: arrow.BinaryTypes.String},
+	{Name: "bid_price", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "bid_size", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "ask_price", Type: arrow.PrimitiveTypes.Float64},
+	{Name: "ask_size", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// parquetProperties are the properties of the written Parquet files, compressed with Snappy, the default of pandas.
+func parquetProperties() (*parquet.WriterProperties, pqarrow.ArrowWriterProperties) {
+	return parquet.NewWriterProperties(parquet.WithCompression(compress.Codecs.Snappy)),
+		pqarrow.NewArrowWriterProperties(pqarrow.WithStoreSchema())
+}
+
+// WriteParquet writes the loaded records of the pairs to w as a Parquet file with the PairSchema,
+// a row group per pair in the given order.
+func WriteParquet(w io.Writer, loader depth.ColumnSource, pairs []depth.Pair) error {
+	props, arrowProps := parquetProperties()
+	writer, err := pqarrow.NewFileWriter(PairSchema, w, props, arrowProps)
+	if err != nil {
+		return err
+	}
+	for _, pair := range pairs {
+		record := pairRecord(loader.Columns(pair), pair)
+		err := writer.Write(record)
+		record.Release()
+		if err != nil {
+			_ = writer.Close()
+			return err
+		}
+	}
+	return writer.Close()
+}
+
+// pairRecord returns the columns of the pair as a record batch with the PairSchema.
+// The caller must Release the record.
+func pairRecord(columns depth.Columns, pair depth.Pair) arrow.RecordBatch {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, PairSchema)
+	defer builder.Release()
+
+	times := builder.Field(0).(*array.TimestampBuilder)
+	pairs := builder.Field(1).(*array.StringBuilder)
+	times.Reserve(columns.Len())
+	pairs.Reserve(columns.Len())
+	for _, t := range columns.Time {
+		times.UnsafeAppend(arrow.Timestamp(t.UnixMilli()))
+		pairs.Append(pair.String())
+	}
+	builder.Field(2).(*array.Float64Builder).AppendValues(columns.BidPrice, nil)
+	builder.Field(3).(*array.Float64Builder).AppendValues(columns.BidSize, nil)
+	builder.Field(4).(*array.Float64Builder).AppendValues(columns.AskPrice, nil)
+	builder.Field(5).(*array.Float64Builder).AppendValues(columns.AskSize, nil)
+	return builder.NewRecordBatch()
+}
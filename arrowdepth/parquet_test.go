@@ -0,0 +1,62 @@
+package arrowdepth_test
+
+import (
+	"bytes"
+	"context"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/bogdantimes/order-book-depth-loader/arrowdepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteParquet(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	var b bytes.Buffer
+	assert.NoError(t, arrowdepth.WriteParquet(&b, loader, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}))
+	table, err := pqarrow.ReadTable(context.Background(), bytes.NewReader(b.Bytes()), nil, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+	assert.NoError(t, err)
+	defer table.Release()
+	for i, field := range arrowdepth.PairSchema.Fields() {
+		assert.Equal(t, field.Name, table.Schema().Field(i).Name)
+	}
+	assert.Equal(t, int64(4), table.NumRows())
+	reader := array.NewTableReader(table, table.NumRows())
+	defer reader.Release()
+	assert.True(t, reader.Next())
+	record := reader.RecordBatch()
+	assert.Equal(t, "ETH-BUSD", record.Column(1).(*array.String).Value(3))
+	assert.Equal(t, 13.0, record.Column(4).(*array.Float64).Value(3))
+	assert.Equal(t, int64(1577836860000), int64(record.Column(0).(*array.Timestamp).Value(1)))
+}
+
+func TestParquetStore(t *testing.T) {
+	dir := t.TempDir()
+	store := arrowdepth.NewParquetStore(dir)
+	day := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.NoError(t, store.Open(depth.DefaultSchema))
+
+	stored, err := store.Has("BTC-BUSD", day)
+	assert.NoError(t, err)
+	assert.False(t, stored)
+	assert.NoError(t, store.WriteDay("BTC-BUSD", day, []string{"100", "1", "101", "NaN", "102", "3", "103", "4"}))
+	assert.NoError(t, store.WriteDay("BTC-BUSD", day.AddDate(0, 0, 1), nil))
+	assert.Error(t, store.WriteDay("BTC-BUSD", day, []string{"100"}))
+	stored, err = store.Has("BTC-BUSD", day.AddDate(0, 0, 1))
+	assert.NoError(t, err)
+	assert.True(t, stored)
+
+	days, err := store.ReadRange("BTC-BUSD", day, day.AddDate(0, 0, 3))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"100", "1", "101", "NaN", "102", "3", "103", "4"}, {}, nil}, days)
+
+	// the files of another schema are not read
+	assert.Error(t, arrowdepth.NewParquetStore(dir).Open(depth.Schema{depth.FieldMid}))
+}
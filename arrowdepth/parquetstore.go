@@ -0,0 +1,195 @@
+package arrowdepth
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"github.com/apache/arrow-go/v18/arrow"
+	"github.com/apache/arrow-go/v18/arrow/array"
+	"github.com/apache/arrow-go/v18/arrow/memory"
+	"github.com/apache/arrow-go/v18/parquet/pqarrow"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// ParquetStore is a depth.Store keeping each day of a pair in a Parquet file of its own,
+// <dir>/<pair>/<yyyy-mm-dd>.parquet, with the timestamp and the pair of each minute, and a column per field
+// of the schema, like the PairSchema for the depth.DefaultSchema, so that the cache is read directly
+// by pandas, polars or Spark:
+//
+//	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithStore(arrowdepth.NewParquetStore("data/binance")))
+//
+// A day without vendor data is a file without rows. Once opened, it is safe for concurrent use.
+type ParquetStore struct {
+	dir    string
+	schema depth.Schema
+}
+
+// NewParquetStore returns the ParquetStore of the directory, the one of a market.
+func NewParquetStore(dir string) *ParquetStore {
+	return &ParquetStore{dir: dir}
+}
+
+// path returns the path of the file of the day of the pair.
+func (s *ParquetStore) path(pair depth.Pair, day time.Time) string {
+	return filepath.Join(s.dir, pair.String(), day.Format("2006-01-02")+".parquet")
+}
+
+// arrowSchema returns the Arrow schema of the files of the schema.
+func arrowSchema(schema depth.Schema) *arrow.Schema {
+	fields := []arrow.Field{
+		{Name: "timestamp", Type: &arrow.TimestampType{Unit: arrow.Millisecond, TimeZone: "UTC"}},
+		{Name: "pair", Type: arrow.BinaryTypes.String},
+	}
+	for _, field := range schema {
+		fields = append(fields, arrow.Field{Name: string(field), Type: arrow.PrimitiveTypes.Float64})
+	}
+	return arrow.NewSchema(fields, nil)
+}
+
+// Open checks that the stored files, if any, have the columns of the schema.
+func (s *ParquetStore) Open(schema depth.Schema) error {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*", "*.parquet"))
+	if err != nil {
+		return err
+	}
+	if len(files) > 0 {
+		table, err := readParquet(files[0])
+		if err != nil {
+			return err
+		}
+		defer table.Release()
+		if !sameColumns(table.Schema(), arrowSchema(schema)) {
+			return fmt.Errorf("%s columns %s do not match the loader schema %s", files[0], table.Schema(), schema)
+		}
+	}
+	s.schema = schema
+	return nil
+}
+
+// Has reports whether the file of the day of the pair exists.
+func (s *ParquetStore) Has(pair depth.Pair, day time.Time) (bool, error) {
+	_, err := os.Stat(s.path(pair, day))
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// WriteDay writes the file of the day of the pair atomically, without rows for a day without values.
+func (s *ParquetStore) WriteDay(pair depth.Pair, day time.Time, values []string) error {
+	width := s.schema.Width()
+	if width == 0 || len(values)%width != 0 {
+		return fmt.Errorf("%d values of %s on %s are not minutes of the schema %s", len(values), pair, day.Format("2006-01-02"), s.schema)
+	}
+	schema := arrowSchema(s.schema)
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, schema)
+	defer builder.Release()
+	for minute := 0; minute < len(values)/width; minute++ {
+		builder.Field(0).(*array.TimestampBuilder).Append(arrow.Timestamp(day.Add(time.Duration(minute) * time.Minute).UnixMilli()))
+		builder.Field(1).(*array.StringBuilder).Append(pair.String())
+		for i, value := range values[minute*width : (minute+1)*width] {
+			v, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				return fmt.Errorf("%s of %s on %s: %w", s.schema[i], pair, day.Format("2006-01-02"), err)
+			}
+			builder.Field(2 + i).(*array.Float64Builder).Append(v)
+		}
+	}
+	record := builder.NewRecordBatch()
+	defer record.Release()
+
+	var b bytes.Buffer
+	props, arrowProps := parquetProperties()
+	writer, err := pqarrow.NewFileWriter(schema, &b, props, arrowProps)
+	if err != nil {
+		return err
+	}
+	if err := writer.Write(record); err != nil {
+		_ = writer.Close()
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	path := s.path(pair, day)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	// write the day atomically, so that a concurrent load never reads a partial one
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, b.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// ReadRange reads the files of the days of the pair in the time range, failing with an error wrapping
+// depth.ErrCorrupted if one does not have the columns of the schema.
+func (s *ParquetStore) ReadRange(pair depth.Pair, startDate time.Time, endDate time.Time) ([][]string, error) {
+	schema := arrowSchema(s.schema)
+	var days [][]string
+	for day := startDate; day.Before(endDate); day = day.AddDate(0, 0, 1) {
+		path := s.path(pair, day)
+		table, err := readParquet(path)
+		if os.IsNotExist(err) {
+			days = append(days, nil)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", depth.ErrCorrupted, path, err)
+		}
+		values, err := tableValues(table, schema)
+		table.Release()
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", depth.ErrCorrupted, path, err)
+		}
+		days = append(days, values)
+	}
+	return days, nil
+}
+
+// readParquet reads the Parquet file into a table. The caller must Release the table.
+func readParquet(path string) (arrow.Table, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return pqarrow.ReadTable(context.Background(), file, nil, pqarrow.ArrowReadProperties{}, memory.DefaultAllocator)
+}
+
+// sameColumns checks if the schemas have the same columns, ignoring the field metadata of the Parquet files.
+func sameColumns(a *arrow.Schema, b *arrow.Schema) bool {
+	if a.NumFields() != b.NumFields() {
+		return false
+	}
+	for i := 0; i < a.NumFields(); i++ {
+		if a.Field(i).Name != b.Field(i).Name || !arrow.TypeEqual(a.Field(i).Type, b.Field(i).Type) {
+			return false
+		}
+	}
+	return true
+}
+
+// tableValues returns the values of the fields of each row of the table, checking it has the schema.
+func tableValues(table arrow.Table, schema *arrow.Schema) ([]string, error) {
+	if !sameColumns(table.Schema(), schema) {
+		return nil, fmt.Errorf("columns %s, not %s", table.Schema(), schema)
+	}
+	width := int(table.NumCols()) - 2
+	values := make([]string, int(table.NumRows())*width)
+	for i := 0; i < width; i++ {
+		row := 0
+		for _, chunk := range table.Column(2 + i).Data().Chunks() {
+			for _, v := range chunk.(*array.Float64).Float64Values() {
+				values[row*width+i] = strconv.FormatFloat(v, 'f', -1, 64)
+				row++
+			}
+		}
+	}
+	return values, nil
+}
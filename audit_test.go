@@ -0,0 +1,34 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestAudit(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	run := func(skip bool, opts ...depth.Option) map[depth.Pair]string {
+		loader := depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+		loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+		for i := 0; i < 2; i++ {
+			if !skip || i > 0 {
+				loader.GetDepth("BTC-BUSD")
+			}
+			loader.GetDepth("ETH-BUSD")
+			loader.Tick()
+		}
+		return loader.Digests()
+	}
+
+	digests := run(false, depth.WithAudit())
+	assert.Len(t, digests, 2)
+	assert.Len(t, digests["BTC-BUSD"], 64)
+	assert.Equal(t, digests, run(false, depth.WithAudit(), depth.WithOffHeap()))
+	// a skipped minute changes the digest of its pair only
+	skipped := run(true, depth.WithAudit())
+	assert.NotEqual(t, digests["BTC-BUSD"], skipped["BTC-BUSD"])
+	assert.Equal(t, digests["ETH-BUSD"], skipped["ETH-BUSD"])
+	assert.Nil(t, run(false))
+}
@@ -0,0 +1,62 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAvailability(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-06-2020")
+	// the vendor has no data for the third day
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, !day.Equal(start.AddDate(0, 0, 2))
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/availability") })
+
+	var progress bytes.Buffer
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithBaseURL(url), depth.WithNamespace("availability"), depth.WithProgress(&progress))
+	ranges := loader.Availability("BTC-BUSD", start, end)
+	assert.Equal(t, []depth.DateRange{
+		{Start: start, End: start.AddDate(0, 0, 2)},
+		{Start: start.AddDate(0, 0, 3), End: end},
+	}, ranges)
+	assert.Equal(t, 5, strings.Count(progress.String(), "Probing"))
+
+	// the probes are cached, only the new days are probed
+	progress.Reset()
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithBaseURL(url), depth.WithNamespace("availability"), depth.WithProgress(&progress))
+	ranges = loader.Availability("BTC-BUSD", start, end.AddDate(0, 0, 1))
+	assert.Equal(t, []depth.DateRange{
+		{Start: start, End: start.AddDate(0, 0, 2)},
+		{Start: start.AddDate(0, 0, 3), End: end.AddDate(0, 0, 1)},
+	}, ranges)
+	assert.Equal(t, 1, strings.Count(progress.String(), "Probing"))
+}
+
+func TestCoverage(t *testing.T) {
+	start := ParseOrDie("01-01-2020")
+	// the vendor has no data for the fourth day
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, !day.Equal(start.AddDate(0, 0, 3))
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/coverage") })
+	// BTC-BUSD is cached for the first two days
+	path := "data/coverage/binance/2020-01-01_2020-01-03_depth.csv"
+	assert.NoError(t, os.MkdirAll("data/coverage/binance", 0755))
+	assert.NoError(t, os.WriteFile(path, []byte("#,BTC-BUSD\nBTC-BUSD"+strings.Repeat(",100,1,101,1", 2*24*60)+"\n"), 0644))
+
+	var progress bytes.Buffer
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithBaseURL(url), depth.WithNamespace("coverage"), depth.WithProgress(&progress))
+	var report bytes.Buffer
+	assert.NoError(t, loader.WriteCoverage(&report, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, start.AddDate(0, 0, 4)))
+	assert.Equal(t, "pair,2020-01-01,2020-01-02,2020-01-03,2020-01-04\n"+
+		"BTC-BUSD,cached,cached,downloadable,missing\n"+
+		"ETH-BUSD,downloadable,downloadable,downloadable,missing\n", report.String())
+	// the cached days are not probed
+	assert.Equal(t, 6, strings.Count(progress.String(), "Probing"))
+}
@@ -0,0 +1,73 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPairConcurrency(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/backfill-test") })
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	downloads := map[string]int{}
+	fail := true
+	hook := depth.WithDownloadHook(func(pair depth.Pair, date time.Time, records []depth.Record) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		key := pair.String() + " " + date.Format("2006-01-02")
+		downloads[key]++
+		failed := fail && key == "ETH-USDT 2020-01-03"
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		running--
+		mu.Unlock()
+		if failed {
+			return errors.New("archive is down")
+		}
+		return nil
+	})
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("backfill-test"), depth.WithProgress(io.Discard),
+			depth.WithBaseURL(url), depth.WithConcurrency(2), depth.WithPairConcurrency(3), hook)
+	}
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD", "ETH-USDT"}
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020")
+
+	// the days downloaded concurrently are bounded across the pairs
+	assert.PanicsWithError(t, "failed days: ETH-USDT on 2020-01-03: download hook of ETH-USDT on 2020-01-03: archive is down", func() {
+		newLoader().Load(pairs, start, end)
+	})
+	assert.LessOrEqual(t, maxRunning, 2)
+	assert.Len(t, downloads, 9)
+
+	// the downloaded days of the failed pair are checkpointed, and the other pairs are written
+	checkpoint := "data/backfill-test/binance/checkpoints/ETH-USDT"
+	assert.DirExists(t, checkpoint)
+	fail = false
+	records := newLoader().Load(pairs, start, end)
+	for _, pair := range pairs {
+		assert.Len(t, records[pair], 3*24*60*4, pair)
+	}
+	assert.Equal(t, 2, downloads["ETH-USDT 2020-01-03"])
+	assert.Equal(t, 1, downloads["ETH-USDT 2020-01-01"])
+	assert.Equal(t, 1, downloads["BTC-BUSD 2020-01-01"])
+	// the checkpoint is removed once the pair is written
+	assert.NoDirExists(t, checkpoint)
+
+	assert.Panics(t, func() {
+		depth.WithPairConcurrency(0)
+	})
+}
@@ -0,0 +1,65 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestLoadBase(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	// BTC-USDT is as expensive as BTC-BUSD, at 1 USDT = 2 BUSD
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "BTC-USDT", "USDT-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		switch pair {
+		case "BTC-BUSD":
+			return Quote{99, 1, 101, 1}
+		case "BTC-USDT":
+			return Quote{49, 1, 51, 1}
+		}
+		return Quote{2, 1, 2, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly())
+	series := loader.LoadBase("BTC", []string{"BUSD", "USDT"}, start, end)
+	assert.Equal(t, "BUSD", series.Quote)
+	assert.Len(t, series.Raw["BTC-USDT"], 24*60)
+	assert.Equal(t, 50.0, series.Raw["BTC-USDT"][0])
+	assert.Equal(t, 100.0, series.Converted["BTC-USDT"][0])
+	assert.Equal(t, 100.0, series.Converted["BTC-BUSD"][24*60-1])
+	assert.Equal(t, 1.0, series.Rates["BUSD"][0])
+	// the cross rate is the inverse of USDT-BUSD
+	series = loader.LoadBase("BTC", []string{"USDT", "BUSD"}, start, end)
+	assert.Equal(t, 0.5, series.Rates["BUSD"][0])
+	assert.Equal(t, 50.0, series.Converted["BTC-BUSD"][0])
+	assert.Equal(t, 49.0, loader.GetDepth("BTC-USDT").BidPrice)
+
+	assert.Panics(t, func() { loader.LoadBase("BTC", []string{"BUSD", "USDC"}, start, end) })
+}
+
+func TestDepegs(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	// USDC loses its peg for 10 minutes
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-USDT", "BTC-USDC", "USDC-USDT"}, start, end, func(pair depth.Pair, minute int) Quote {
+		if pair == "USDC-USDT" && minute >= 60 && minute < 70 {
+			return Quote{0.9, 1, 0.9, 1}
+		}
+		if pair == "USDC-USDT" {
+			return Quote{0.999, 1, 1.001, 1}
+		}
+		return Quote{100, 1, 100, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly())
+	series := loader.LoadBase("BTC", []string{"USDT", "USDC"}, start, end)
+	depegs := series.Depegs(0.005)
+	assert.Len(t, depegs, 10)
+	assert.Equal(t, "USDC", depegs[0].Quote)
+	assert.Equal(t, 60, depegs[0].Minute)
+	assert.True(t, depegs[0].Time.Equal(start.Add(time.Hour)))
+	assert.Equal(t, 0.9, depegs[0].Rate)
+	// the converted prices follow the loaded rate, not the peg
+	assert.InDelta(t, 90, series.Converted["BTC-USDC"][60], 1e-9)
+	assert.InDelta(t, 100, series.Converted["BTC-USDC"][0], 1e-9)
+}
@@ -0,0 +1,56 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadBasis(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-USDT"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{99, 1, 101, 1}
+	})
+	WriteFixture(t, depth.MarketBinanceUsdsFutures, []depth.Pair{"BTC-USDT"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 102, 1}
+	})
+
+	basis := depth.LoadBasis("BTC", start, end)
+	assert.Len(t, basis, 24*60)
+	assert.InDelta(t, 100, basis[0], 1e-9)
+	assert.InDelta(t, 100, basis[len(basis)-1], 1e-9)
+}
+
+func TestLoadPairBasis(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 100, 1}
+	})
+	WriteFixture(t, depth.MarketBinanceUsdsFutures, []depth.Pair{"BTC-USDT"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{99, 1, 99, 1}
+	})
+
+	basis := depth.LoadPairBasis(depth.MarketBinance, "BTC-BUSD", depth.MarketBinanceUsdsFutures, "BTC-USDT", start, end)
+	assert.Len(t, basis, 24*60)
+	assert.InDelta(t, -100, basis[0], 1e-9)
+}
+
+func TestLoadBasisLoaderOptions(t *testing.T) {
+	// the options are the ones of the loaders, here of an empty data directory not downloaded to
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	opts := []depth.Option{depth.WithDataDir(t.TempDir()), depth.WithReadOnly()}
+	readOnly := func(load func()) {
+		defer func() {
+			err, _ := recover().(error)
+			assert.True(t, errors.Is(err, depth.ErrReadOnly), err)
+		}()
+		load()
+	}
+	readOnly(func() {
+		depth.LoadBasis("BTC", start, end, depth.WithBasisLoaderOptions(opts...))
+	})
+	readOnly(func() {
+		depth.LoadPairBasis(depth.MarketBinance, "BTC-BUSD", depth.MarketBinanceUsdsFutures, "BTC-USDT", start, end, opts...)
+	})
+}
@@ -0,0 +1,74 @@
+package bbgodepth_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/bbgodepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/c9s/bbgo/pkg/types"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBbgoStream(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	stream := bbgodepth.NewStream(loader, types.ExchangeBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"})
+	stream.Subscribe(types.BookTickerChannel, "BTCBUSD", types.SubscribeOptions{})
+	stream.Subscribe(types.KLineChannel, "ETHBUSD", types.SubscribeOptions{Interval: types.Interval1m})
+
+	var tickers []types.BookTicker
+	var klines []types.KLine
+	stream.OnBookTickerUpdate(func(ticker types.BookTicker) {
+		tickers = append(tickers, ticker)
+	})
+	stream.OnKLineClosed(func(kline types.KLine) {
+		klines = append(klines, kline)
+	})
+	assert.NoError(t, stream.Connect(context.Background()))
+	assert.NoError(t, stream.Wait())
+
+	assert.Len(t, tickers, 2)
+	assert.Equal(t, "BTCBUSD", tickers[1].Symbol)
+	assert.Equal(t, 102.0, tickers[1].Buy.Float64())
+	assert.Equal(t, 4.0, tickers[1].SellSize.Float64())
+
+	assert.Len(t, klines, 2)
+	assert.Equal(t, 10.5, klines[0].Close.Float64())
+	assert.Equal(t, 1, klines[1].StartTime.Time().Minute())
+}
+
+func TestBbgoStreamInterval(t *testing.T) {
+	// 7 minutes with the mid prices 100, 101, ... 106
+	input := "#,BTC-BUSD\nBTC-BUSD"
+	for minute := 0; minute < 7; minute++ {
+		input += fmt.Sprintf(",%d,1,%d,1", 99+minute, 101+minute)
+	}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input+"\n"), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	stream := bbgodepth.NewStream(loader, types.ExchangeBinance, []depth.Pair{"BTC-BUSD"})
+	stream.Subscribe(types.KLineChannel, "BTCBUSD", types.SubscribeOptions{Interval: types.Interval5m})
+	var klines []types.KLine
+	stream.OnKLineClosed(func(kline types.KLine) {
+		klines = append(klines, kline)
+	})
+	assert.NoError(t, stream.Connect(context.Background()))
+	assert.NoError(t, stream.Wait())
+
+	assert.Len(t, klines, 2)
+	assert.Equal(t, types.Interval5m, klines[0].Interval)
+	assert.Equal(t, 100.0, klines[0].Open.Float64())
+	assert.Equal(t, 104.0, klines[0].High.Float64())
+	assert.Equal(t, 104.0, klines[0].Close.Float64())
+	assert.Equal(t, 5, klines[1].StartTime.Time().Minute())
+	assert.Equal(t, 106.0, klines[1].Close.Float64())
+
+	stream = bbgodepth.NewStream(loader, types.ExchangeBinance, []depth.Pair{"BTC-BUSD"})
+	stream.Subscribe(types.KLineChannel, "BTCBUSD", types.SubscribeOptions{Interval: types.Interval1s})
+	assert.Error(t, stream.Connect(context.Background()))
+}
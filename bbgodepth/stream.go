@@ -0,0 +1,213 @@
+// Package bbgodepth adapts the loaded depth data to the market data interfaces of the bbgo trading framework,
+// so that bbgo strategies and backtests can consume this package's datasets as L1 quotes.
+package bbgodepth
+
+import (
+	"context"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/c9s/bbgo/pkg/fixedpoint"
+	"github.com/c9s/bbgo/pkg/types"
+	"strings"
+	"time"
+)
+
+// Symbol returns the bbgo symbol of the pair, like BTCBUSD for BTC-BUSD.
+func Symbol(pair depth.Pair) string {
+	return strings.ToUpper(pair.Base() + pair.Quote())
+}
+
+// ToBookTicker converts a depth record to a bbgo book ticker.
+func ToBookTicker(pair depth.Pair, record depth.Record) types.BookTicker {
+	return types.BookTicker{
+		Symbol:   Symbol(pair),
+		Buy:      fixedpoint.NewFromFloat(record.BidPrice),
+		BuySize:  fixedpoint.NewFromFloat(record.BidSize),
+		Sell:     fixedpoint.NewFromFloat(record.AskPrice),
+		SellSize: fixedpoint.NewFromFloat(record.AskSize),
+	}
+}
+
+// Stream is a bbgo market data stream replaying the loaded depth data.
+// For each minute it emits a book ticker update, and closed klines of the mid price, for each pair.
+// When symbols are subscribed, only the subscribed channels and symbols are emitted, and the klines
+// are aggregated to the subscribed interval, 1m if none is given.
+type Stream struct {
+	types.StandardStream
+	loader   depth.ColumnSource
+	exchange types.ExchangeName
+	pairs    []depth.Pair
+
+	cancel context.CancelFunc
+	done   chan struct{}
+	err    error
+}
+
+// NewStream returns a Stream replaying the loaded pairs as if they came from the exchange.
+func NewStream(loader depth.ColumnSource, exchange types.ExchangeName, pairs []depth.Pair) *Stream {
+	s := &Stream{
+		StandardStream: types.NewStandardStream(),
+		loader:         loader,
+		exchange:       exchange,
+		pairs:          pairs,
+	}
+	s.SetPublicOnly()
+	return s
+}
+
+// klineSeries aggregates the mid prices of a pair into the klines of a subscribed interval.
+type klineSeries struct {
+	pair     int
+	symbol   string
+	interval types.Interval
+	current  *types.KLine
+}
+
+// Connect checks the subscriptions, and starts replaying the whole loaded time range in the background,
+// like a live stream receiving the market data after it is connected. It returns an error for subscriptions
+// to kline intervals that are not whole minutes, like 1s, or not of a fixed length, like 1mo.
+// Use Wait to block until the replay is done, and Close to stop it.
+func (s *Stream) Connect(ctx context.Context) error {
+	series, err := s.klineSeries()
+	if err != nil {
+		return err
+	}
+	ctx, s.cancel = context.WithCancel(ctx)
+	s.done = make(chan struct{})
+	go func() {
+		defer close(s.done)
+		s.err = s.replay(ctx, series)
+	}()
+	return nil
+}
+
+// Wait blocks until the replay started by Connect is done, and returns the context error if it was stopped.
+func (s *Stream) Wait() error {
+	if s.done == nil {
+		return nil
+	}
+	<-s.done
+	return s.err
+}
+
+// Close stops the replay, and waits for it to return.
+func (s *Stream) Close() error {
+	if s.cancel != nil {
+		s.cancel()
+	}
+	_ = s.Wait()
+	return nil
+}
+
+// Reconnect does nothing, the replay can't be disconnected.
+func (s *Stream) Reconnect() {}
+
+// replay emits the book tickers and klines of all minutes.
+func (s *Stream) replay(ctx context.Context, series []*klineSeries) error {
+	columns := make([]depth.Columns, len(s.pairs))
+	length := 0
+	for i, pair := range s.pairs {
+		columns[i] = s.loader.Columns(pair)
+		if columns[i].Len() > length {
+			length = columns[i].Len()
+		}
+	}
+
+	s.EmitConnect()
+	s.EmitStart()
+	for minute := 0; minute < length; minute++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for i, pair := range s.pairs {
+			if minute >= columns[i].Len() {
+				continue
+			}
+			c := columns[i]
+			record := depth.Record{BidPrice: c.BidPrice[minute], BidSize: c.BidSize[minute], AskPrice: c.AskPrice[minute], AskSize: c.AskSize[minute]}
+			if s.subscribed(types.BookTickerChannel, Symbol(pair)) {
+				s.EmitBookTickerUpdate(ToBookTicker(pair, record))
+			}
+			for _, k := range series {
+				if k.pair == i {
+					s.aggregate(k, c.Time[minute], record.Mid())
+				}
+			}
+		}
+	}
+	// the last klines are emitted even if the loaded time range ends before their interval does
+	for _, k := range series {
+		if k.current != nil {
+			s.EmitKLineClosed(*k.current)
+		}
+	}
+	return nil
+}
+
+// klineSeries returns the kline series of the subscriptions, or 1m klines of all pairs if there are no subscriptions.
+func (s *Stream) klineSeries() ([]*klineSeries, error) {
+	subscriptions := s.GetSubscriptions()
+	var series []*klineSeries
+	for i, pair := range s.pairs {
+		symbol := Symbol(pair)
+		if len(subscriptions) == 0 {
+			series = append(series, &klineSeries{pair: i, symbol: symbol, interval: types.Interval1m})
+		}
+		for _, subscription := range subscriptions {
+			if subscription.Channel != types.KLineChannel || subscription.Symbol != symbol {
+				continue
+			}
+			interval := subscription.Options.Interval
+			if interval == "" {
+				interval = types.Interval1m
+			}
+			seconds, ok := types.SupportedIntervals[interval]
+			if !ok || seconds%60 != 0 || interval == types.Interval1mo {
+				return nil, fmt.Errorf("unsupported kline interval %s of %s, the depth data has 1 minute frequency", interval, symbol)
+			}
+			series = append(series, &klineSeries{pair: i, symbol: symbol, interval: interval})
+		}
+	}
+	return series, nil
+}
+
+// subscribed checks if the channel of the symbol should be emitted.
+func (s *Stream) subscribed(channel types.Channel, symbol string) bool {
+	subscriptions := s.GetSubscriptions()
+	if len(subscriptions) == 0 {
+		return true
+	}
+	for _, subscription := range subscriptions {
+		if subscription.Channel == channel && subscription.Symbol == symbol {
+			return true
+		}
+	}
+	return false
+}
+
+// aggregate adds the mid price of the minute to the current kline of the series, and emits the previous kline
+// when the minute starts a new interval. The intervals are aligned to the UTC midnight, like the exchange klines.
+func (s *Stream) aggregate(k *klineSeries, t time.Time, mid float64) {
+	start := t.UTC().Truncate(k.interval.Duration())
+	price := fixedpoint.NewFromFloat(mid)
+	if k.current != nil && !k.current.StartTime.Time().Equal(start) {
+		s.EmitKLineClosed(*k.current)
+		k.current = nil
+	}
+	if k.current == nil {
+		k.current = &types.KLine{
+			Exchange:  s.exchange,
+			Symbol:    k.symbol,
+			StartTime: types.Time(start),
+			EndTime:   types.Time(start.Add(k.interval.Duration() - time.Millisecond)),
+			Interval:  k.interval,
+			Open:      price,
+			High:      price,
+			Low:       price,
+			Closed:    true,
+		}
+	}
+	k.current.Close = price
+	k.current.High = fixedpoint.Max(k.current.High, price)
+	k.current.Low = fixedpoint.Min(k.current.Low, price)
+}
@@ -0,0 +1,110 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadBlocks(t *testing.T) {
+	var downloads int64
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		if minute == 0 {
+			atomic.AddInt64(&downloads, 1)
+		}
+		// every day of a pair has the same quotes
+		price := 10.0
+		if pair == "BTC-BUSD" {
+			price = 100
+		}
+		return Quote{price, 1, price + float64(minute), 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/blocks-test") })
+	newLoader := func(opts ...depth.Option) *depth.CCDepthLoader {
+		opts = append(opts, depth.WithNamespace("blocks-test"), depth.WithBlocks(), depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+		return depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	}
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-USDT"}
+
+	result := newLoader().Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Equal(t, "10", result["ETH-USDT"][2*24*60*4-4*24*60+2])
+	assert.Equal(t, int64(4), downloads)
+	assert.FileExists(t, "data/blocks-test/binance/2020-01-01_2020-01-03_depth.blocks")
+
+	// the identical days are stored once
+	blocks, err := filepath.Glob("data/blocks-test/binance/blocks/*/*")
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+
+	// the overlapping time range downloads only its new day
+	loader := newLoader()
+	result = loader.Load(pairs, ParseOrDie("01-02-2020"), ParseOrDie("01-04-2020"))
+	assert.Len(t, result["ETH-USDT"], 2*24*60*4)
+	assert.Equal(t, int64(6), downloads)
+	loader.Tick()
+	assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	// the stored time range is read from its blocks
+	result = newLoader(depth.WithReadOnly()).Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Equal(t, int64(6), downloads)
+	assert.Panics(t, func() {
+		newLoader(depth.WithReadOnly()).Load([]depth.Pair{"XRP-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	})
+	assert.Panics(t, func() {
+		newLoader(depth.WithSchema(depth.FieldMid)).Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	})
+}
+
+func TestCollectGarbage(t *testing.T) {
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		if pair == "BTC-BUSD" {
+			return Quote{100, 1, 101, 1}
+		}
+		return Quote{10, 1, 11, 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/gc-test") })
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("gc-test"), depth.WithBlocks(),
+			depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	}
+	loader := newLoader()
+	loader.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"))
+	newLoader().Load([]depth.Pair{"ETH-BUSD"}, ParseOrDie("01-02-2020"), ParseOrDie("01-03-2020"))
+	assert.NoError(t, os.Remove("data/gc-test/binance/2020-01-02_2020-01-03_depth.blocks"))
+
+	// the recent blocks are kept
+	removed, err := loader.CollectGarbage(false)
+	assert.NoError(t, err)
+	assert.Empty(t, removed)
+
+	blocks, err := filepath.Glob("data/gc-test/binance/blocks/*/*")
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 2)
+	old := time.Now().Add(-2 * time.Hour)
+	for _, path := range blocks {
+		assert.NoError(t, os.Chtimes(path, old, old))
+	}
+
+	removed, err = loader.CollectGarbage(true)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.FileExists(t, removed[0])
+
+	removed, err = loader.CollectGarbage(false)
+	assert.NoError(t, err)
+	assert.Len(t, removed, 1)
+	assert.NoFileExists(t, removed[0])
+	assert.NoDirExists(t, filepath.Dir(removed[0]))
+
+	// the referenced blocks are still read
+	result := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("gc-test"), depth.WithBlocks(), depth.WithReadOnly()).
+		Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"))
+	assert.Equal(t, "101", result["BTC-BUSD"][2])
+}
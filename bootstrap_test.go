@@ -0,0 +1,70 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestBootstrap(t *testing.T) {
+	// the bid price tells the day and the minute of the day of the record
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		bid := float64(day.Day()*10000 + minute)
+		if pair == "ETH-BUSD" {
+			bid += 0.5
+		}
+		return Quote{bid, 1, bid + 1, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/bootstrap") })
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-04-2022")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("bootstrap"))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+
+	bids := func(l *depth.CCDepthLoader, pair depth.Pair) []float64 {
+		bids := make([]float64, 3*24*60)
+		for m := range bids {
+			bids[m] = l.GetDepth(pair).BidPrice
+			l.Tick()
+		}
+		return bids
+	}
+	model := depth.BootstrapModel{BlockLength: 60, SameTimeOfDay: true}
+	resample := loader.Bootstrap(1, model)
+	btc, eth := bids(resample, "BTC-BUSD"), bids(loader.Bootstrap(1, model), "ETH-BUSD")
+	var drawn []float64
+	for m, bid := range btc {
+		// the pairs are resampled with the same blocks, of consecutive minutes at the same time of day
+		assert.Equal(t, bid+0.5, eth[m])
+		assert.Equal(t, m%(24*60), int(bid)%10000)
+		if m%60 == 0 {
+			drawn = append(drawn, bid)
+		} else {
+			assert.Equal(t, btc[m-1]+1, bid)
+		}
+	}
+	// the blocks are drawn from all the days, with replacement
+	var days []int
+	for _, bid := range drawn {
+		days = append(days, int(bid)/10000)
+	}
+	assert.Subset(t, days, []int{1, 2, 3})
+	assert.NotEqual(t, btc, bids(loader.Bootstrap(2, model), "BTC-BUSD"))
+
+	// the stitched blocks start at the price the previous one ended
+	stitched := loader.Bootstrap(1, depth.BootstrapModel{BlockLength: 60, Stitch: true})
+	mid := stitched.GetDepth("BTC-BUSD").Mid()
+	for m := 1; m < 3*24*60; m++ {
+		stitched.Tick()
+		if m%60 == 0 {
+			assert.InDelta(t, mid, stitched.GetDepth("BTC-BUSD").Mid(), 1e-6)
+		}
+		mid = stitched.GetDepth("BTC-BUSD").Mid()
+	}
+
+	assert.Panics(t, func() {
+		loader.Bootstrap(1, depth.BootstrapModel{})
+	})
+}
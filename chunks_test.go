@@ -0,0 +1,68 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportChunks(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(minute), float64(minute % 7), float64(minute) + 1, float64(minute % 13)}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+
+	dir := t.TempDir()
+	manifest, err := loader.ExportChunks(dir, nil, 4096)
+	assert.NoError(t, err)
+	assert.Greater(t, len(manifest.Parts), 1)
+
+	var fromFile depth.Manifest
+	content, err := os.ReadFile(filepath.Join(dir, depth.ManifestFile))
+	assert.NoError(t, err)
+	assert.NoError(t, json.Unmarshal(content, &fromFile))
+	assert.Equal(t, len(manifest.Parts), len(fromFile.Parts))
+
+	rows := 0
+	for _, part := range manifest.Parts {
+		file, err := os.Open(filepath.Join(dir, part.File))
+		assert.NoError(t, err)
+		stat, _ := file.Stat()
+		assert.Equal(t, stat.Size(), part.Bytes)
+		gz, err := gzip.NewReader(file)
+		assert.NoError(t, err)
+		records, err := csv.NewReader(gz).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, "time", records[0][0])
+		assert.Equal(t, part.Rows, len(records)-1)
+		rows += part.Rows
+		_ = file.Close()
+	}
+	assert.Equal(t, 2*24*60, rows)
+	assert.Equal(t, depth.Pair("BTC-BUSD"), manifest.Parts[0].Pairs[0])
+}
+
+func TestExportChunksErrors(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	dir := t.TempDir()
+	_, err := loader.ExportChunks(dir, nil, 0)
+	assert.EqualError(t, err, "the part size must be positive, got 0")
+
+	_, err = loader.ExportChunks(dir, nil, 4096, depth.WithFormat("xml"))
+	assert.Error(t, err)
+	// no incomplete part is left behind
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	assert.Empty(t, entries)
+}
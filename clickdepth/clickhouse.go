@@ -0,0 +1,68 @@
+// Package clickdepth bulk loads the depth data into ClickHouse with native batch inserts.
+package clickdepth
+
+import (
+	"context"
+	"fmt"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"time"
+)
+
+// Conn is the part of the ClickHouse connection used by the loader, implemented by the driver.Conn of clickhouse.Open.
+type Conn interface {
+	Exec(ctx context.Context, query string, args ...any) error
+	PrepareBatch(ctx context.Context, query string, opts ...driver.PrepareBatchOption) (driver.Batch, error)
+}
+
+// CreateTable creates the depth table if it does not exist. The table is partitioned by month, to keep the
+// number of parts low with many pairs, and sorted by pair and time. A month is reloaded by dropping its partition,
+// and a day of a pair by deleting its rows:
+//
+//	ALTER TABLE depth DROP PARTITION 202211
+//	ALTER TABLE depth DELETE WHERE pair = 'BTC-BUSD' AND toDate(time) = '2022-11-24'
+func CreateTable(ctx context.Context, conn Conn, table string) error {
+	return conn.Exec(ctx, fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	time DateTime('UTC'),
+	pair LowCardinality(String),
+	bid_price Float64,
+	bid_size Float64,
+	ask_price Float64,
+	ask_size Float64
+) ENGINE = MergeTree
+PARTITION BY toYYYYMM(time)
+ORDER BY (pair, time)`, table))
+}
+
+// Insert inserts the loaded records of the given pairs into the table, sending one batch per pair and day,
+// so that each batch falls into a single partition and stays small.
+func Insert(ctx context.Context, conn Conn, table string, loader depth.ColumnSource, pairs []depth.Pair) error {
+	query := fmt.Sprintf("INSERT INTO %s (time, pair, bid_price, bid_size, ask_price, ask_size)", table)
+	for _, pair := range pairs {
+		columns := loader.Columns(pair)
+		for start := 0; start < columns.Len(); {
+			end := start + 1
+			day := columns.Time[start].UTC().Truncate(24 * time.Hour)
+			for end < columns.Len() && columns.Time[end].UTC().Truncate(24*time.Hour).Equal(day) {
+				end++
+			}
+			batch, err := conn.PrepareBatch(ctx, query)
+			if err != nil {
+				return err
+			}
+			for i := start; i < end; i++ {
+				err := batch.Append(columns.Time[i], pair.String(),
+					columns.BidPrice[i], columns.BidSize[i], columns.AskPrice[i], columns.AskSize[i])
+				if err != nil {
+					_ = batch.Abort()
+					return err
+				}
+			}
+			if err := batch.Send(); err != nil {
+				return err
+			}
+			start = end
+		}
+	}
+	return nil
+}
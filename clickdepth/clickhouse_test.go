@@ -0,0 +1,64 @@
+package clickdepth_test
+
+import (
+	"context"
+	"github.com/ClickHouse/clickhouse-go/v2/lib/driver"
+	"github.com/bogdantimes/order-book-depth-loader/clickdepth"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+type fakeClickHouse struct {
+	queries []string
+	batches [][][]any
+}
+
+func (c *fakeClickHouse) Exec(_ context.Context, query string, _ ...any) error {
+	c.queries = append(c.queries, query)
+	return nil
+}
+
+func (c *fakeClickHouse) PrepareBatch(_ context.Context, query string, _ ...driver.PrepareBatchOption) (driver.Batch, error) {
+	c.queries = append(c.queries, query)
+	c.batches = append(c.batches, nil)
+	return &fakeBatch{conn: c, index: len(c.batches) - 1}, nil
+}
+
+type fakeBatch struct {
+	driver.Batch
+	conn  *fakeClickHouse
+	index int
+}
+
+func (b *fakeBatch) Append(v ...any) error {
+	b.conn.batches[b.index] = append(b.conn.batches[b.index], v)
+	return nil
+}
+
+func (b *fakeBatch) Send() error {
+	return nil
+}
+
+func TestClickHouseInsert(t *testing.T) {
+	// 1441 minutes: a full day and the first minute of the next one
+	input := "#,BTC-BUSD\nBTC-BUSD" + strings.Repeat(",100,1,101,2", 24*60+1) + "\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	conn := &fakeClickHouse{}
+	ctx := context.Background()
+	assert.NoError(t, clickdepth.CreateTable(ctx, conn, "depth"))
+	assert.Contains(t, conn.queries[0], "PARTITION BY toYYYYMM(time)")
+	assert.Contains(t, conn.queries[0], "ORDER BY (pair, time)")
+
+	assert.NoError(t, clickdepth.Insert(ctx, conn, "depth", loader, []depth.Pair{"BTC-BUSD"}))
+	assert.Len(t, conn.batches, 2)
+	assert.Len(t, conn.batches[0], 24*60)
+	assert.Len(t, conn.batches[1], 1)
+	row := conn.batches[1][0]
+	assert.True(t, time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC).Equal(row[0].(time.Time)))
+	assert.Equal(t, []any{"BTC-BUSD", 100.0, 1.0, 101.0, 2.0}, row[1:])
+}
@@ -0,0 +1,56 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestClock(t *testing.T) {
+	spotURL := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		if pair == "ETH-USDT" {
+			return Quote{float64(10 + minute), 1, float64(11 + minute), 1}
+		}
+		return Quote{float64(100 + minute), 1, float64(101 + minute), 1}
+	})
+	perpURL := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(110 + minute), 1, float64(111 + minute), 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/clock") })
+	var fed []int
+	spot := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(spotURL), depth.WithNamespace("clock"),
+		depth.WithWarmup(2*time.Minute))
+	perp := depth.NewCCDepthLoader(depth.MarketBinanceUsdsFutures, depth.WithProgress(io.Discard), depth.WithBaseURL(perpURL), depth.WithNamespace("clock"),
+		depth.WithConsumer(func(pair depth.Pair, minute int, record depth.Record) { fed = append(fed, minute) }))
+
+	clock := depth.NewClock(spot, perp)
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	clock.Load([]depth.Instrument{
+		{Market: depth.MarketBinance, Pair: "BTC-USDT"},
+		{Market: depth.MarketBinance, Pair: "ETH-USDT"},
+		{Market: depth.MarketBinanceUsdsFutures, Pair: "BTC-USDT"},
+	}, start, end)
+
+	// the clock starts past the warmup of the spot loader, and the perp loader follows it
+	for minute := 2; minute < 24*60; minute++ {
+		assert.Equal(t, start.Add(time.Duration(minute)*time.Minute), clock.Time())
+		assert.Equal(t, float64(100+minute), clock.GetDepth(depth.MarketBinance, "BTC-USDT").BidPrice)
+		assert.Equal(t, float64(10+minute), clock.GetDepth(depth.MarketBinance, "ETH-USDT").BidPrice)
+		assert.Equal(t, float64(110+minute), clock.GetDepth(depth.MarketBinanceUsdsFutures, "BTC-USDT").BidPrice)
+		clock.Tick()
+	}
+	assert.Equal(t, []int{0, 1, 2, 3}, fed[:4])
+	assert.True(t, math.IsNaN(clock.GetDepth(depth.MarketBinance, "BTC-USDT").BidPrice))
+	assert.True(t, math.IsNaN(clock.GetDepth(depth.MarketBinanceCoinFutures, "BTC-USD").BidPrice))
+
+	assert.Panics(t, func() {
+		clock.Load([]depth.Instrument{{Market: depth.MarketBinanceCoinFutures, Pair: "BTC-USD"}}, start, end)
+	})
+	assert.Panics(t, func() {
+		depth.NewClock(spot, depth.NewCCDepthLoader(depth.MarketBinance))
+	})
+}
@@ -0,0 +1,659 @@
+// Command depthloader streams order book depth records to the standard output, so that the loader
+// composes with Unix pipelines.
+//
+// Load the depth data of a time range, and stream it as CSV or JSONL:
+//
+//	depthloader load -market binance -pairs BTC-BUSD,ETH-BUSD -start 2022-11-24 -end 2022-11-25 -format jsonl
+//
+// Convert a depth data file read from the standard input:
+//
+//	depthloader convert -start 2022-11-24 -format jsonl < data/binance/2022-11-24_2022-11-25_depth.csv
+//
+// Both commands accept a -filter expression, like "spread_bps > 10", and -spread-only to write
+// only the spread in basis points of each minute. The CSV -delimiter and -decimal separator can be set
+// for the locales where the comma is the decimal separator, like -delimiter ";" -decimal ",".
+//
+// Load the depth data, and replay it to each process connecting to a Unix socket:
+//
+//	depthloader serve -socket /tmp/depth.sock -pairs BTC-BUSD -start 2022-11-24 -end 2022-11-25
+//
+// The replay is written as fast as the client reads it, unless paced with -pace 10ms, or requested
+// minute by minute with -step, where the client writes a line for each minute. With -changes-only,
+// only the minutes where the book changed are replayed. A paced replay keeps its pace with a slow client
+// with -buffer 1000 -overflow drop-oldest, dropping the minutes the client can't keep up with.
+// Each minute is delivered late with -latency 50ms -jitter 20ms, to test an execution logic with slow data.
+//
+// Run the backfill on cron-style schedules, configured by a JSON list of daemon.Job:
+//
+//	depthloader daemon -config jobs.json -state daemon-state.json
+//
+//	[{"name": "binance-majors", "market": "binance", "pairs": ["BTC-BUSD", "ETH-BUSD"], "schedule": "15 0 * * *"}]
+//
+// The jobs load with the loader flags of load, like -data-dir, -namespace and -provider.
+//
+// With -status localhost:8080, the queue of each job is served over HTTP, and failed loads can be retried:
+//
+//	curl localhost:8080/jobs
+//	curl -X POST localhost:8080/jobs/binance-majors/retry
+//
+// Write the quality of each loaded day as CSV, with the hash of its values, so that two researchers can check
+// that they load the identical data:
+//
+//	depthloader quality -pairs BTC-BUSD -start 2022-11-01 -end 2022-12-01
+//
+// List the pairs that are not aligned on the minutes of the time range, like those missing days,
+// which shifts their following days:
+//
+//	depthloader align -pairs BTC-BUSD,ETH-BUSD -start 2022-11-01 -end 2022-12-01
+//
+// List the continuous ranges of days the vendor has data for, probing each day once, to plan the time ranges to load:
+//
+//	depthloader availability -market binance -pairs BTC-BUSD,ETH-BUSD -start 2022-01-01 -end 2023-01-01
+//
+// Write the coverage of the cache of a market as CSV, with a row of each pair and a column of each day,
+// marking the days cached, downloadable, or missing at the vendor, to see what still needs to be backfilled:
+//
+//	depthloader coverage -market binance -pairs BTC-BUSD,ETH-BUSD -start 2022-01-01 -end 2023-01-01 > coverage.csv
+//
+// All commands loading the depth data accept -align pad, to keep the missing days of the downloaded pairs
+// as NaN values, or -align trim, to also trim the pairs to the days all of them have data for.
+// The days are downloaded from crypto-chassis, from the daily dumps of the Binance futures markets
+// with -provider binance-vision, or from Tardis.dev with -provider tardis and the API key in TARDIS_API_KEY.
+// The days of each provider should be kept in their own -namespace.
+// With -day-files, each day of a pair is stored in a file of its own, so that the overlapping time ranges
+// only download the days not stored yet.
+// With -store-url http://depth-cache:8081/binance, the days are also shared with the other loaders
+// through a central store, fetched from it before downloading them from the vendor, and uploaded to it after:
+//
+//	depthloader store-server -listen :8081 -markets binance,binance-usds-futures
+//
+// With -sampler median, the record of each minute is the median book of its snapshots, instead of the snapshot at its start.
+// With -levels 10, the snapshots of the first 10 levels of each side of the book are loaded.
+// The cache files are checked against their versions on open, or not at all with -self-check none,
+// or by parsing all their rows with -self-check full.
+//
+// Download again a sample of the cached days, and list those the vendor revised since, marking them as bad
+// with -mark, so that a load with -refetch-bad refreshes them:
+//
+//	depthloader revisions -pairs BTC-BUSD -start 2022-11-01 -end 2022-12-01 -sample 3 -mark
+//
+// Write the untouched per-second snapshots of a pair during a day as CSV, to measure what the records
+// of the minutes hide:
+//
+//	depthloader raw -market binance -pair BTC-BUSD -day 2022-11-24 > seconds.csv
+//
+// Rename a pair in the stored data of a market, like after the exchange renamed its ticker,
+// so that it is not downloaded again under the new name:
+//
+//	depthloader rename -market binance -from BTC-BUSD -to BTC-FDUSD
+//
+// Remove the blocks of a market stored with -blocks that no time range references anymore,
+// or only list them with -dry-run:
+//
+//	depthloader gc -market binance -dry-run
+//
+// The cache files are kept in the data directory, data by default, or the one of -data-dir, with the modes
+// of -dir-mode 0775 and -file-mode 0664 regardless of the umask, and the owner of -owner 1000:1000, for shared volumes.
+// The loads download 30 days concurrently, or -concurrency days, of one pair at a time, or of -pair-concurrency
+// pairs, each downloaded day being checkpointed until its pair is written, and -rate-limit 5 limits
+// the HTTP requests to 5 per second. With -stats stats.json, the statistics of the load are written as JSON,
+// like the days downloaded and reused of each pair, and the bytes downloaded, see depth.LoadStats.
+//
+// Download progress is written to the standard error.
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/daemon"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "load":
+		load(os.Args[2:])
+	case "convert":
+		convert(os.Args[2:])
+	case "serve":
+		serve(os.Args[2:])
+	case "daemon":
+		runDaemon(os.Args[2:])
+	case "gc":
+		collectGarbage(os.Args[2:])
+	case "store-server":
+		storeServer(os.Args[2:])
+	case "raw":
+		raw(os.Args[2:])
+	case "rename":
+		rename(os.Args[2:])
+	case "revisions":
+		revisions(os.Args[2:])
+	case "quality":
+		quality(os.Args[2:])
+	case "align":
+		align(os.Args[2:])
+	case "availability":
+		availability(os.Args[2:])
+	case "coverage":
+		coverage(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: depthloader load|convert|serve|daemon|quality|align|availability|coverage|revisions|rename|gc|store-server|raw [flags]")
+	os.Exit(2)
+}
+
+func load(args []string) {
+	flags := flag.NewFlagSet("load", flag.ExitOnError)
+	loadPairs := loadFlags(flags)
+	exportOpts := exportFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs := loadPairs()
+	export(loader, pairs, exportOpts())
+}
+
+func serve(args []string) {
+	flags := flag.NewFlagSet("serve", flag.ExitOnError)
+	socket := flags.String("socket", "depth.sock", "Unix socket path")
+	pace := flags.Duration("pace", 0, "time to wait between the replayed minutes, like 10ms")
+	step := flags.Bool("step", false, "replay a minute for each line written by the client")
+	changesOnly := flags.Bool("changes-only", false, "replay only the minutes where the book changed")
+	buffer := flags.Int("buffer", 0, "number of minutes buffered for a slow client, none by default")
+	overflow := flags.String("overflow", string(depth.Park), "what to do when the buffer is full: park, drop-oldest or drop-newest")
+	latency := flags.Duration("latency", 0, "delay of the delivery of each replayed minute, like 50ms")
+	jitter := flags.Duration("jitter", 0, "maximum random delay added to the latency, like 20ms")
+	seed := flags.Int64("seed", 1, "seed of the random jitter")
+	loadPairs := loadFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs := loadPairs()
+	// remove the socket left by a previous run
+	_ = os.Remove(*socket)
+	listener, err := net.Listen("unix", *socket)
+	if err != nil {
+		fail(err)
+	}
+	fmt.Fprintln(os.Stderr, "Replaying depth on", *socket)
+	serveOpts := []depth.ServeOption{depth.WithPace(*pace)}
+	if *step {
+		serveOpts = append(serveOpts, depth.WithStep())
+	}
+	if *changesOnly {
+		serveOpts = append(serveOpts, depth.WithChangesOnly())
+	}
+	if *buffer > 0 {
+		serveOpts = append(serveOpts, depth.WithBuffer(*buffer, depth.Overflow(*overflow)))
+	}
+	if *latency > 0 || *jitter > 0 {
+		serveOpts = append(serveOpts, depth.WithLatency(*latency, *jitter, *seed))
+	}
+	if err = loader.Serve(listener, pairs, serveOpts...); err != nil {
+		fail(err)
+	}
+}
+
+func runDaemon(args []string) {
+	flags := flag.NewFlagSet("daemon", flag.ExitOnError)
+	config := flags.String("config", "jobs.json", "JSON file with the list of jobs")
+	state := flags.String("state", "daemon-state.json", "file keeping the last run of each job, to catch up missed runs")
+	status := flags.String("status", "", "address of the HTTP status API, like localhost:8080, disabled if empty")
+	loaderOpts := loaderFlags(flags)
+	_ = flags.Parse(args)
+
+	content, err := os.ReadFile(*config)
+	if err != nil {
+		fail(err)
+	}
+	var jobs []daemon.Job
+	if err = json.Unmarshal(content, &jobs); err != nil {
+		fail(err)
+	}
+	d, err := daemon.New(jobs, daemon.WithStateFile(*state), daemon.WithProgress(os.Stderr), daemon.WithLoaderOptions(loaderOpts))
+	if err != nil {
+		fail(err)
+	}
+	if *status != "" {
+		go func() {
+			fmt.Fprintln(os.Stderr, "Serving the daemon status on", *status)
+			if err := http.ListenAndServe(*status, d.Handler()); err != nil {
+				fail(err)
+			}
+		}()
+	}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	_ = d.Run(ctx)
+}
+
+func quality(args []string) {
+	flags := flag.NewFlagSet("quality", flag.ExitOnError)
+	loadPairs := loadFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs := loadPairs()
+	if err := loader.WriteQualityReport(os.Stdout, pairs); err != nil {
+		fail(err)
+	}
+}
+
+func align(args []string) {
+	flags := flag.NewFlagSet("align", flag.ExitOnError)
+	loadPairs := loadFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs := loadPairs()
+	misalignments := loader.AlignCheck(pairs)
+	for _, m := range misalignments {
+		fmt.Println(m.Pair, m.Time.Format(time.RFC3339), m.Reason)
+	}
+	fmt.Fprintln(os.Stderr, len(misalignments), "misaligned pairs")
+	if len(misalignments) > 0 {
+		os.Exit(1)
+	}
+}
+
+func availability(args []string) {
+	flags := flag.NewFlagSet("availability", flag.ExitOnError)
+	probe := probeFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs, startDate, endDate := probe()
+	for _, pair := range pairs {
+		for _, r := range loader.Availability(pair, startDate, endDate) {
+			fmt.Println(pair, r.Start.Format(dateFormat), r.End.Format(dateFormat))
+		}
+	}
+}
+
+func coverage(args []string) {
+	flags := flag.NewFlagSet("coverage", flag.ExitOnError)
+	probe := probeFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs, startDate, endDate := probe()
+	if err := loader.WriteCoverage(os.Stdout, pairs, startDate, endDate); err != nil {
+		fail(err)
+	}
+}
+
+// probeFlags defines the flags of the commands probing the vendor availability, and returns a function building
+// the loader once they are parsed.
+func probeFlags(flags *flag.FlagSet) func() (*depth.CCDepthLoader, []depth.Pair, time.Time, time.Time) {
+	market := flags.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	namespace := flags.String("namespace", "", "directory of the data directory keeping the cache files apart from other projects")
+	dataDir := flags.String("data-dir", "data", "data directory of the cache files")
+	provider := flags.String("provider", "crypto-chassis", "source of the downloaded days: crypto-chassis, binance-vision, or tardis")
+	pairs := flags.String("pairs", "", "comma-separated pairs to probe")
+	start := flags.String("start", "", "start date, like 2022-11-24")
+	end := flags.String("end", "", "end date, exclusive, like 2022-11-25")
+	return func() (*depth.CCDepthLoader, []depth.Pair, time.Time, time.Time) {
+		if *pairs == "" {
+			usage()
+		}
+		opts := []depth.Option{depth.WithProgress(os.Stderr)}
+		if *namespace != "" {
+			opts = append(opts, depth.WithNamespace(*namespace))
+		}
+		opts = append(opts, depth.WithDataDir(*dataDir))
+		opts = append(opts, providerOptions(*provider)...)
+		var probed []depth.Pair
+		for _, pair := range strings.Split(*pairs, ",") {
+			probed = append(probed, depth.Pair(pair))
+		}
+		return depth.NewCCDepthLoader(depth.Market(*market), opts...), probed, mustParseDate(*start), mustParseDate(*end)
+	}
+}
+
+// providerOptions returns the options of the named provider of the downloaded days.
+func providerOptions(provider string) []depth.Option {
+	switch provider {
+	case "crypto-chassis":
+		return nil
+	case "binance-vision":
+		return []depth.Option{depth.WithProvider(depth.NewBinanceVisionProvider())}
+	case "tardis":
+		return []depth.Option{depth.WithProvider(depth.NewTardisProvider(os.Getenv("TARDIS_API_KEY")))}
+	}
+	fail(fmt.Errorf("unknown provider %q", provider))
+	return nil
+}
+
+func revisions(args []string) {
+	flags := flag.NewFlagSet("revisions", flag.ExitOnError)
+	sample := flags.Int("sample", 3, "number of days of each pair to check, all days if not positive")
+	mark := flags.Bool("mark", false, "mark the revised days as bad")
+	loadPairs := loadFlags(flags)
+	_ = flags.Parse(args)
+
+	loader, pairs := loadPairs()
+	revised := loader.DetectRevisions(pairs, *sample)
+	for _, revision := range revised {
+		fmt.Println(revision.Pair, revision.Day.Format(dateFormat), revision.CachedHash, revision.VendorHash)
+		if *mark {
+			if err := loader.MarkBad(revision.Pair, revision.Day, "vendor revision"); err != nil {
+				fail(err)
+			}
+		}
+	}
+	fmt.Fprintln(os.Stderr, len(revised), "revised days")
+}
+
+func rename(args []string) {
+	flags := flag.NewFlagSet("rename", flag.ExitOnError)
+	market := flags.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	namespace := flags.String("namespace", "", "directory of the data directory keeping the cache files apart from other projects")
+	dataDir := flags.String("data-dir", "data", "data directory of the cache files")
+	from := flags.String("from", "", "pair to rename, like BTC-BUSD")
+	to := flags.String("to", "", "new name of the pair")
+	_ = flags.Parse(args)
+
+	if *from == "" || *to == "" {
+		usage()
+	}
+	opts := []depth.Option{depth.WithProgress(os.Stderr)}
+	if *namespace != "" {
+		opts = append(opts, depth.WithNamespace(*namespace))
+	}
+	opts = append(opts, depth.WithDataDir(*dataDir))
+	if err := depth.NewCCDepthLoader(depth.Market(*market), opts...).RenamePair(depth.Pair(*from), depth.Pair(*to)); err != nil {
+		fail(err)
+	}
+}
+
+func collectGarbage(args []string) {
+	flags := flag.NewFlagSet("gc", flag.ExitOnError)
+	market := flags.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	namespace := flags.String("namespace", "", "directory of the data directory keeping the cache files apart from other projects")
+	dataDir := flags.String("data-dir", "data", "data directory of the cache files")
+	dryRun := flags.Bool("dry-run", false, "only list the unreferenced blocks")
+	_ = flags.Parse(args)
+
+	var opts []depth.Option
+	if *namespace != "" {
+		opts = append(opts, depth.WithNamespace(*namespace))
+	}
+	opts = append(opts, depth.WithDataDir(*dataDir))
+	removed, err := depth.NewCCDepthLoader(depth.Market(*market), opts...).CollectGarbage(*dryRun)
+	for _, path := range removed {
+		fmt.Println(path)
+	}
+	if err != nil {
+		fail(err)
+	}
+	fmt.Fprintln(os.Stderr, len(removed), "unreferenced blocks")
+}
+
+func raw(args []string) {
+	flags := flag.NewFlagSet("raw", flag.ExitOnError)
+	market := flags.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	pair := flags.String("pair", "", "pair of the snapshots, like BTC-BUSD")
+	day := flags.String("day", "", "day of the snapshots, like 2022-11-24")
+	_ = flags.Parse(args)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	loader := depth.NewCCDepthLoader(depth.Market(*market), depth.WithProgress(os.Stderr))
+	snapshots, err := loader.LoadRaw(ctx, depth.Pair(*pair), mustParseDate(*day))
+	if err != nil {
+		fail(err)
+	}
+	out := bufio.NewWriter(os.Stdout)
+	fmt.Fprintln(out, "time,bid_price,bid_size,ask_price,ask_size")
+	for _, s := range snapshots {
+		fmt.Fprintf(out, "%d,%v,%v,%v,%v\n", s.Time.Unix(), s.Record.BidPrice, s.Record.BidSize, s.Record.AskPrice, s.Record.AskSize)
+	}
+	if err := out.Flush(); err != nil {
+		fail(err)
+	}
+}
+
+func storeServer(args []string) {
+	flags := flag.NewFlagSet("store-server", flag.ExitOnError)
+	listen := flags.String("listen", "localhost:8081", "address of the HTTP server")
+	markets := flags.String("markets", string(depth.MarketBinance), "comma-separated markets of the served day files")
+	namespace := flags.String("namespace", "", "directory of the data directory keeping the cache files apart from other projects")
+	dataDir := flags.String("data-dir", "data", "data directory of the cache files")
+	_ = flags.Parse(args)
+
+	mux := http.NewServeMux()
+	for _, market := range strings.Split(*markets, ",") {
+		store := depth.NewDayFileStore(filepath.Join(*dataDir, *namespace, market))
+		mux.Handle("/"+market+"/", http.StripPrefix("/"+market, depth.StoreHandler(store)))
+	}
+	fmt.Fprintln(os.Stderr, "Serving the day files on", *listen)
+	if err := http.ListenAndServe(*listen, mux); err != nil {
+		fail(err)
+	}
+}
+
+// loadFlags defines the load flags, and returns a function loading the depth data once they are parsed.
+func loadFlags(flags *flag.FlagSet) func() (*depth.CCDepthLoader, []depth.Pair) {
+	market := flags.String("market", string(depth.MarketBinance), "crypto-chassis market")
+	pairs := flags.String("pairs", "", "comma-separated pairs to load, all known pairs if empty")
+	start := flags.String("start", "", "start date, like 2022-11-24")
+	end := flags.String("end", "", "end date, exclusive, like 2022-11-25")
+	stats := flags.String("stats", "", "file to write the statistics of the load to as JSON, like the bytes downloaded")
+	loaderOpts := loaderFlags(flags)
+	return func() (*depth.CCDepthLoader, []depth.Pair) {
+		var pairsToLoad []depth.Pair
+		if *pairs != "" {
+			for _, pair := range strings.Split(*pairs, ",") {
+				pairsToLoad = append(pairsToLoad, depth.Pair(pair))
+			}
+		}
+		opts := append([]depth.Option{depth.WithProgress(os.Stderr)}, loaderOpts(depth.Market(*market))...)
+		loader := depth.NewCCDepthLoader(depth.Market(*market), opts...)
+		// an interrupted download keeps the pairs downloaded before
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		records, err := loader.LoadContext(ctx, pairsToLoad, mustParseDate(*start), mustParseDate(*end))
+		if *stats != "" {
+			writeStats(*stats, loader.Stats())
+		}
+		if err != nil {
+			fail(err)
+		}
+		if len(pairsToLoad) == 0 {
+			for pair := range records {
+				pairsToLoad = append(pairsToLoad, pair)
+			}
+			sort.Slice(pairsToLoad, func(i, j int) bool {
+				return pairsToLoad[i] < pairsToLoad[j]
+			})
+		}
+		return loader, pairsToLoad
+	}
+}
+
+// loaderFlags defines the flags of the options of the loaders, and returns a function returning the options
+// of the loader of a market once they are parsed.
+func loaderFlags(flags *flag.FlagSet) func(market depth.Market) []depth.Option {
+	readOnly := flags.Bool("readonly", false, "only read the cache files, fail instead of downloading missing data")
+	namespace := flags.String("namespace", "", "directory of the data directory keeping the cache files apart from other projects")
+	dataDir := flags.String("data-dir", "data", "data directory of the cache files")
+	provider := flags.String("provider", "crypto-chassis", "source of the downloaded days: crypto-chassis, binance-vision, or tardis")
+	blocks := flags.Bool("blocks", false, "store the days in content-addressed blocks shared by the time ranges")
+	dayFiles := flags.Bool("day-files", false, "store each day of a pair in a file of its own shared by the time ranges")
+	storeURL := flags.String("store-url", "", "URL of the central store of the market served by store-server, implies -day-files")
+	refetchBad := flags.Bool("refetch-bad", false, "download the days marked as bad again")
+	alignment := flags.String("align", "", "align the pairs with missing days: pad, or trim to their common days")
+	movePrice := flags.Float64("move-price", 0, "price move of a book yielded by the event-driven replay, like 0.01")
+	moveSize := flags.Float64("move-size", 0, "size move of a book yielded by the event-driven replay")
+	concurrency := flags.Int("concurrency", 30, "number of days downloaded concurrently, across the pairs")
+	pairConcurrency := flags.Int("pair-concurrency", 1, "number of pairs downloaded concurrently")
+	rateLimit := flags.Float64("rate-limit", 0, "maximum number of HTTP requests per second, unlimited if not positive")
+	sampler := flags.String("sampler", "snapshot", "record of each minute from its snapshots: snapshot, or median")
+	levels := flags.Int("levels", 1, "number of levels of each side of the book to load, like 10")
+	selfCheck := flags.String("self-check", string(depth.SelfCheckManifest), "check of the cache files on open: none, manifest, or full")
+	dirMode := flags.String("dir-mode", "", "octal mode of the created directories of the cache regardless of the umask, like 0775")
+	fileMode := flags.String("file-mode", "", "octal mode of the written files of the cache regardless of the umask, like 0664")
+	owner := flags.String("owner", "", "uid:gid owning the written files of the cache, like 1000:1000")
+	return func(market depth.Market) []depth.Option {
+		var opts []depth.Option
+		if *namespace != "" {
+			opts = append(opts, depth.WithNamespace(*namespace))
+		}
+		opts = append(opts, depth.WithDataDir(*dataDir))
+		if *dirMode != "" || *fileMode != "" {
+			opts = append(opts, depth.WithFileModes(parseMode(*dirMode, 0755), parseMode(*fileMode, 0644)))
+		}
+		if *owner != "" {
+			opts = append(opts, parseOwner(*owner))
+		}
+		opts = append(opts, providerOptions(*provider)...)
+		if *readOnly {
+			opts = append(opts, depth.WithReadOnly())
+		}
+		if *blocks {
+			opts = append(opts, depth.WithBlocks())
+		}
+		if *dayFiles {
+			opts = append(opts, depth.WithDayFiles())
+		}
+		if *storeURL != "" {
+			local := depth.NewDayFileStore(filepath.Join(*dataDir, *namespace, string(market)))
+			opts = append(opts, depth.WithStore(depth.NewRemoteStore(*storeURL, local, http.DefaultClient)))
+		}
+		if *refetchBad {
+			opts = append(opts, depth.WithRefetchBad())
+		}
+		if *alignment != "" {
+			opts = append(opts, depth.WithAlignment(depth.Alignment(*alignment)))
+		}
+		if *movePrice != 0 || *moveSize != 0 {
+			opts = append(opts, depth.WithMoveThreshold(*movePrice, *moveSize))
+		}
+		opts = append(opts, depth.WithSelfCheck(depth.SelfCheck(*selfCheck)))
+		switch *sampler {
+		case "snapshot":
+		case "median":
+			opts = append(opts, depth.WithSampler(depth.MedianSampler))
+		default:
+			fail(fmt.Errorf("unknown sampler %q", *sampler))
+		}
+		if *levels > 1 {
+			opts = append(opts, depth.WithDepthLevels(*levels))
+		}
+		opts = append(opts, depth.WithConcurrency(*concurrency), depth.WithPairConcurrency(*pairConcurrency))
+		if *rateLimit > 0 {
+			opts = append(opts, depth.WithRateLimit(*rateLimit))
+		}
+		return opts
+	}
+}
+
+// parseMode parses an octal file mode, or returns the default mode if it is empty.
+func parseMode(s string, mode os.FileMode) os.FileMode {
+	if s == "" {
+		return mode
+	}
+	m, err := strconv.ParseUint(s, 8, 32)
+	if err != nil {
+		fail(fmt.Errorf("invalid file mode %q: %w", s, err))
+	}
+	return os.FileMode(m)
+}
+
+// parseOwner parses the uid:gid of the owner of the cache files.
+func parseOwner(s string) depth.Option {
+	uid, gid, ok := strings.Cut(s, ":")
+	u, uidErr := strconv.Atoi(uid)
+	g, gidErr := strconv.Atoi(gid)
+	if !ok || uidErr != nil || gidErr != nil {
+		fail(fmt.Errorf("the owner must be uid:gid, got %q", s))
+	}
+	return depth.WithFileOwner(u, g)
+}
+
+// writeStats writes the statistics of a load to the file as JSON.
+func writeStats(path string, stats depth.LoadStats) {
+	b, err := json.MarshalIndent(stats, "", "  ")
+	if err != nil {
+		fail(err)
+	}
+	if err := os.WriteFile(path, append(b, '\n'), 0644); err != nil {
+		fail(err)
+	}
+}
+
+func convert(args []string) {
+	flags := flag.NewFlagSet("convert", flag.ExitOnError)
+	start := flags.String("start", "", "start date of the depth data, like 2022-11-24")
+	exportOpts := exportFlags(flags)
+	_ = flags.Parse(args)
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(os.Stderr))
+	loader.LoadFrom(os.Stdin, mustParseDate(*start))
+	export(loader, nil, exportOpts())
+}
+
+// exportFlags defines the export flags, and returns a function building the export options once they are parsed.
+func exportFlags(flags *flag.FlagSet) func() []depth.ExportOption {
+	format := flags.String("format", string(depth.FormatCSV), "output format: csv or jsonl")
+	filter := flags.String("filter", "", `filter expression, like "spread_bps > 10"`)
+	spreadOnly := flags.Bool("spread-only", false, "export only the time, pair and spread_bps columns")
+	delimiter := flags.String("delimiter", "", `CSV field delimiter, like ";", a comma by default`)
+	decimal := flags.String("decimal", "", `CSV decimal separator, like ",", a dot by default`)
+	return func() []depth.ExportOption {
+		opts := []depth.ExportOption{depth.WithFormat(depth.Format(*format))}
+		if *delimiter != "" {
+			opts = append(opts, depth.WithDelimiter([]rune(*delimiter)[0]))
+		}
+		if *decimal != "" {
+			opts = append(opts, depth.WithDecimalSeparator([]rune(*decimal)[0]))
+		}
+		if *spreadOnly {
+			opts = append(opts, depth.WithSpreadOnly())
+		}
+		if *filter != "" {
+			f, err := depth.ParseFilter(*filter)
+			if err != nil {
+				fail(err)
+			}
+			opts = append(opts, depth.WithFilter(f))
+		}
+		return opts
+	}
+}
+
+func export(loader *depth.CCDepthLoader, pairs []depth.Pair, opts []depth.ExportOption) {
+	if err := loader.Export(os.Stdout, pairs, opts...); err != nil {
+		fail(err)
+	}
+}
+
+func mustParseDate(s string) time.Time {
+	t, err := time.Parse(dateFormat, s)
+	if err != nil {
+		fail(err)
+	}
+	return t
+}
+
+func fail(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
+}
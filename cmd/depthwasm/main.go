@@ -0,0 +1,83 @@
+//go:build js && wasm
+
+// Command depthwasm exposes the read and replay path of the depth loader to JavaScript,
+// so that browser tools can replay an exported depth data file for visualization without a backend.
+//
+// Build it with:
+//
+//	GOOS=js GOARCH=wasm go build -o depth.wasm ./cmd/depthwasm
+//
+// and load it with the wasm_exec.js from the Go distribution. It registers a global depth object:
+//
+//	const pairs = depth.load(await (await fetch("2022-11-24_2022-11-25_depth.csv")).text(), "2022-11-24")
+//	for (let record = depth.getDepth("BTC-BUSD"); record; record = depth.getDepth("BTC-BUSD")) {
+//	  draw(record.bidPrice, record.askPrice)
+//	  depth.tick()
+//	}
+//
+// getDepth returns null once the data is exhausted. Nothing is downloaded, the data is only read from the given text.
+package main
+
+import (
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+	"strings"
+	"syscall/js"
+	"time"
+)
+
+var loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+
+func main() {
+	js.Global().Set("depth", js.ValueOf(map[string]interface{}{
+		"load":     js.FuncOf(load),
+		"tick":     js.FuncOf(tick),
+		"getDepth": js.FuncOf(getDepth),
+	}))
+	// keep the exported functions available
+	select {}
+}
+
+// load reads the depth data file content, and returns the names of the loaded pairs.
+func load(this js.Value, args []js.Value) (result interface{}) {
+	defer recoverError(&result)
+	startDate, err := time.Parse("2006-01-02", args[1].String())
+	if err != nil {
+		panic(err)
+	}
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	var pairs []interface{}
+	for pair := range loader.LoadFrom(strings.NewReader(args[0].String()), startDate) {
+		pairs = append(pairs, pair.String())
+	}
+	return js.ValueOf(pairs)
+}
+
+func tick(this js.Value, args []js.Value) interface{} {
+	loader.Tick()
+	return nil
+}
+
+// getDepth returns the record of the pair at the current minute, or null when the data is exhausted.
+func getDepth(this js.Value, args []js.Value) (result interface{}) {
+	defer func() {
+		if recover() != nil {
+			result = js.Null()
+		}
+	}()
+	record := loader.GetDepth(depth.Pair(args[0].String()))
+	return js.ValueOf(map[string]interface{}{
+		"bidPrice": record.BidPrice,
+		"bidSize":  record.BidSize,
+		"askPrice": record.AskPrice,
+		"askSize":  record.AskSize,
+	})
+}
+
+// recoverError turns a panic of the loader into a returned JavaScript Error, since panics would stop the module.
+func recoverError(result *interface{}) {
+	if r := recover(); r != nil {
+		*result = js.Global().Get("Error").New(fmt.Sprint(r))
+	}
+}
@@ -0,0 +1,149 @@
+// Command libdepth is a C shared library exposing the depth loader to other languages,
+// so that Python or Rust research code can reuse the cache and the replay logic of this package.
+//
+// Build it with:
+//
+//	go build -buildmode=c-shared -o libdepth.so ./cmd/libdepth
+//
+// which also generates the libdepth.h header. Example usage from Python:
+//
+//	import ctypes
+//	lib = ctypes.CDLL("./libdepth.so")
+//	h = lib.depth_new(b"binance")
+//	lib.depth_load(h, b"BTC-BUSD", b"2022-11-24", b"2022-11-25")
+//	out = (ctypes.c_double * 4)()
+//	while lib.depth_get_depth(h, b"BTC-BUSD", out) == 0:
+//	    bid_price, bid_size, ask_price, ask_size = out
+//	    lib.depth_tick(h)
+//	lib.depth_free(h)
+//
+// The functions returning an int return a negative value on failure, see depth_last_error.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unsafe"
+)
+
+const dateFormat = "2006-01-02"
+
+var (
+	mu        sync.Mutex
+	loaders   = make(map[C.int]*depth.CCDepthLoader)
+	nextID    C.int
+	lastError *C.char
+)
+
+func main() {}
+
+// depth_new creates a loader for the market, and returns its handle.
+//
+//export depth_new
+func depth_new(market *C.char) C.int {
+	mu.Lock()
+	defer mu.Unlock()
+	nextID++
+	loaders[nextID] = depth.NewCCDepthLoader(depth.Market(C.GoString(market)), depth.WithProgress(io.Discard))
+	return nextID
+}
+
+// depth_free releases the loader.
+//
+//export depth_free
+func depth_free(handle C.int) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(loaders, handle)
+}
+
+// depth_load loads the comma-separated pairs for the [start, end) range of dates, formatted like 2022-11-24.
+// It returns the number of loaded pairs.
+//
+//export depth_load
+func depth_load(handle C.int, pairs *C.char, start *C.char, end *C.char) (n C.int) {
+	defer recoverError(&n)
+	loader := get(handle)
+	startDate, err := time.Parse(dateFormat, C.GoString(start))
+	if err != nil {
+		panic(err)
+	}
+	endDate, err := time.Parse(dateFormat, C.GoString(end))
+	if err != nil {
+		panic(err)
+	}
+	var pairsToLoad []depth.Pair
+	if s := C.GoString(pairs); s != "" {
+		for _, pair := range strings.Split(s, ",") {
+			pairsToLoad = append(pairsToLoad, depth.Pair(pair))
+		}
+	}
+	return C.int(len(loader.Load(pairsToLoad, startDate, endDate)))
+}
+
+// depth_tick moves the loader to the next minute.
+//
+//export depth_tick
+func depth_tick(handle C.int) (n C.int) {
+	defer recoverError(&n)
+	get(handle).Tick()
+	return 0
+}
+
+// depth_get_depth writes the bid price, bid size, ask price and ask size of the pair at the current minute
+// into the out array of 4 doubles. It fails when the data is exhausted.
+//
+//export depth_get_depth
+func depth_get_depth(handle C.int, pair *C.char, out *C.double) (n C.int) {
+	defer recoverError(&n)
+	record := get(handle).GetDepth(depth.Pair(C.GoString(pair)))
+	values := unsafe.Slice(out, 4)
+	values[0] = C.double(record.BidPrice)
+	values[1] = C.double(record.BidSize)
+	values[2] = C.double(record.AskPrice)
+	values[3] = C.double(record.AskSize)
+	return 0
+}
+
+// depth_last_error returns the message of the last failure. The string is owned by the library.
+//
+//export depth_last_error
+func depth_last_error() *C.char {
+	mu.Lock()
+	defer mu.Unlock()
+	return lastError
+}
+
+func get(handle C.int) *depth.CCDepthLoader {
+	mu.Lock()
+	defer mu.Unlock()
+	loader, ok := loaders[handle]
+	if !ok {
+		panic(fmt.Sprintf("unknown loader handle %d", handle))
+	}
+	return loader
+}
+
+// recoverError turns a panic of the loader into a -1 result and the last error, since panics must not cross the C boundary.
+func recoverError(n *C.int) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if lastError != nil {
+		C.free(unsafe.Pointer(lastError))
+	}
+	lastError = C.CString(fmt.Sprint(r))
+	*n = -1
+}
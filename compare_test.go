@@ -0,0 +1,30 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompare(t *testing.T) {
+	a := depth.NewCCDepthLoader(depth.MarketBinance)
+	a.LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,100,1,100,1,100,1,100,1,100,1,100,1\n"), ParseOrDie("01-01-2020"))
+	// the other source starts a minute later, and diverges by 1% at its first minute
+	b := depth.NewCCDepthLoader(depth.MarketBinance)
+	b.LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,101,1,101,1,100,1,100,1\n"), ParseOrDie("01-01-2020").Add(time.Minute))
+
+	var divergences []depth.Divergence
+	assert.NoError(t, depth.Compare(a, b, nil, 10, func(d depth.Divergence) error {
+		divergences = append(divergences, d)
+		return nil
+	}))
+	assert.Len(t, divergences, 2)
+	assert.True(t, divergences[0].Missing)
+	assert.Equal(t, 0, divergences[0].Time.Minute())
+	assert.False(t, divergences[1].Missing)
+	assert.Equal(t, 1, divergences[1].Time.Minute())
+	assert.InDelta(t, 100, divergences[1].MidBps, 1e-9)
+	assert.Equal(t, 101.0, divergences[1].B.BidPrice)
+}
@@ -0,0 +1,96 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadContext(t *testing.T) {
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-03-2022")
+	path := "data/context/binance/2022-01-01_2022-01-03_depth.csv"
+	// the second day of ETH-BUSD is served only once the load was cancelled
+	release := make(chan struct{})
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if pair == "ETH-BUSD" && day.Equal(end.AddDate(0, 0, -1)) && minute == 0 {
+			<-release
+		}
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() {
+		close(release)
+		_ = os.RemoveAll("data/context")
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("context"))
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	records, err := loader.LoadContext(ctx, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Nil(t, records)
+
+	// BTC-BUSD is kept, without a partial row of ETH-BUSD
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "\nBTC-BUSD,")
+	assert.NotContains(t, string(content), "\nETH-BUSD,")
+	assert.NoError(t, loader.Verify(start, end))
+
+	// a cancelled context downloads nothing
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = loader.LoadContext(ctx, []depth.Pair{"ETH-BUSD"}, start, end)
+	assert.ErrorIs(t, err, context.Canceled)
+	content, err = os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(string(content), "\nBTC-BUSD,"))
+	assert.NotContains(t, string(content), "\nETH-BUSD,")
+}
+
+func TestLoadDedup(t *testing.T) {
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	// ETH-BUSD has no data
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, pair == "BTC-BUSD"
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/dedup") })
+
+	var progress strings.Builder
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(&progress), depth.WithBaseURL(url), depth.WithNamespace("dedup"))
+	records := loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 2, strings.Count(progress.String(), "Downloading"))
+
+	// the pairs are not read, nor downloaded again, even without data
+	progress.Reset()
+	again := loader.Load([]depth.Pair{"ETH-BUSD", "BTC-BUSD"}, start, end)
+	assert.Equal(t, records, again)
+	assert.Empty(t, progress.String())
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Empty(t, progress.String())
+
+	// another time range needs another loader
+	_, err := loader.LoadContext(context.Background(), []depth.Pair{"BTC-BUSD"}, start, end.AddDate(0, 0, 1))
+	assert.ErrorIs(t, err, depth.ErrRangeMismatch)
+	assert.Panics(t, func() {
+		loader.Load([]depth.Pair{"BTC-BUSD"}, start.AddDate(0, 0, -1), end)
+	})
+}
+
+func TestLoadAfterClose(t *testing.T) {
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/reload") })
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("reload"))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.NoError(t, loader.Close())
+	records := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end.AddDate(0, 0, 1))
+	assert.Len(t, records["BTC-BUSD"], 2*24*60*4)
+}
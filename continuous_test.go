@@ -0,0 +1,83 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestLoadContinuous(t *testing.T) {
+	start, roll, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"), ParseOrDie("01-04-2020")
+	WriteFixture(t, depth.MarketDeribit, []depth.Pair{"BTC-3JAN20"}, start, roll, func(pair depth.Pair, minute int) Quote {
+		return Quote{99, 1, 101, 1}
+	})
+	// the next contract is also loaded for the day before the roll
+	WriteFixture(t, depth.MarketDeribit, []depth.Pair{"BTC-10JAN20"}, start, end, func(pair depth.Pair, minute int) Quote {
+		if minute == 24*60-1 {
+			// the last minute before the roll, compared to the same minute of the previous contract
+			return Quote{119, 1, 121, 1}
+		}
+		return Quote{109, 1, 111, 1}
+	})
+
+	schedule := depth.RollSchedule{
+		Contracts: []depth.Contract{
+			{Pair: "BTC-10JAN20", Expiry: ParseOrDie("01-10-2020")},
+			{Pair: "BTC-3JAN20", Expiry: ParseOrDie("01-03-2020")},
+		},
+		Rule:       depth.RollDaysBeforeExpiry(1),
+		Adjustment: depth.AdjustDifference,
+	}
+	segments := schedule.Segments(start, end)
+	assert.Len(t, segments, 2)
+	assert.Equal(t, depth.Pair("BTC-3JAN20"), segments[0].Contract.Pair)
+	assert.True(t, roll.Equal(segments[0].EndDate))
+	assert.True(t, roll.Equal(segments[1].StartDate))
+
+	records := depth.LoadContinuous(depth.MarketDeribit, schedule, start, end)
+	assert.Len(t, records, 3*24*60)
+	assert.Equal(t, 120.0, records[0].Mid())
+	assert.Equal(t, 110.0, records[len(records)-1].Mid())
+
+	schedule.Adjustment = depth.AdjustRatio
+	records = depth.LoadContinuous(depth.MarketDeribit, schedule, start, end)
+	assert.InDelta(t, 99*1.2, records[0].BidPrice, 1e-9)
+
+	schedule.Adjustment = depth.AdjustNone
+	records = depth.LoadContinuous(depth.MarketDeribit, schedule, start, end)
+	assert.Equal(t, 100.0, records[0].Mid())
+}
+
+func TestLoadContinuousMissingMinutes(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	// an hour of the segment is missing
+	row := "BTC-3JAN20" + strings.Repeat(",99,1,101,1", 2*24*60-60)
+	path := "data/deribit/2020-01-01_2020-01-03_depth.csv"
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte("#,BTC-3JAN20\n"+row+"\n"), 0644))
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+	})
+
+	schedule := depth.RollSchedule{Contracts: []depth.Contract{{Pair: "BTC-3JAN20", Expiry: ParseOrDie("01-03-2020")}}}
+	assert.PanicsWithValue(t, "contract BTC-3JAN20 has 2820 minutes of data for 2020-01-01 - 2020-01-03, expected 2880", func() {
+		depth.LoadContinuous(depth.MarketDeribit, schedule, start, end)
+	})
+}
+
+func TestLoadContinuousLoaderOptions(t *testing.T) {
+	// the options are the ones of the loader of each contract, here of an empty data directory not downloaded to
+	schedule := depth.RollSchedule{Contracts: []depth.Contract{{Pair: "BTC-3JAN20", Expiry: ParseOrDie("01-03-2020")}}}
+	defer func() {
+		err, _ := recover().(error)
+		assert.True(t, errors.Is(err, depth.ErrReadOnly), err)
+	}()
+	depth.LoadContinuous(depth.MarketDeribit, schedule, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"),
+		depth.WithDataDir(t.TempDir()), depth.WithReadOnly())
+	t.Fatal("the loader downloaded to a read-only data directory")
+}
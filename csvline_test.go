@@ -0,0 +1,68 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQuotedPairs(t *testing.T) {
+	downloads := 0
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if minute == 0 {
+			downloads++
+		}
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/quote-test") })
+	// a pair name with a comma and a quote must not shift the columns of its row
+	odd := depth.Pair(`BTC,"X"`)
+	pairs := []depth.Pair{odd, "ETH-BUSD"}
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("quote-test"), depth.WithProgress(io.Discard),
+			depth.WithBaseURL(url), depth.WithSchema(depth.FieldMid, depth.FieldSpread))
+	}
+	records := newLoader().Load(pairs, start, end)
+	assert.Len(t, records[odd], 24*60*2)
+	assert.Equal(t, 2, downloads)
+
+	path := "data/quote-test/binance/2020-01-01_2020-01-02_depth.csv"
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.True(t, strings.Contains(string(content), "\n\"BTC,\"\"X\"\"\",100.5,1,"))
+
+	// the rows are read back by their index, and by a full read
+	loader := newLoader()
+	records = loader.Load([]depth.Pair{odd}, start, end)
+	assert.Len(t, records[odd], 24*60*2)
+	assert.Equal(t, 100.5, loader.GetDepth(odd).Mid())
+	file, err := os.Open(path)
+	assert.NoError(t, err)
+	defer file.Close()
+	records = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard)).LoadFrom(file, start)
+	assert.Len(t, records[odd], 24*60*2)
+	assert.Len(t, records["ETH-BUSD"], 24*60*2)
+	assert.Equal(t, 2, downloads)
+
+	assert.NoError(t, newLoader().RenamePair(odd, "BTC-X"))
+	records = newLoader().Load([]depth.Pair{"BTC-X"}, start, end)
+	assert.Len(t, records["BTC-X"], 24*60*2)
+	assert.Equal(t, 2, downloads)
+}
+
+func TestLoadFromShiftedRow(t *testing.T) {
+	// a value more than the minutes of the schema fails the row, instead of shifting the fields of the minutes
+	data := "#,BTC-BUSD\nBTC-BUSD,100,1,101,1,100,1,101,1,7\n"
+	defer func() {
+		err, _ := recover().(error)
+		assert.True(t, errors.Is(err, depth.ErrCorrupted), err)
+	}()
+	depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard)).LoadFrom(strings.NewReader(data), ParseOrDie("01-01-2020"))
+	t.Fatal("the shifted row was loaded")
+}
@@ -0,0 +1,51 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestCursor(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	fed := 0
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithWarmup(time.Minute),
+		depth.WithConsumer(func(pair depth.Pair, minute int, record depth.Record) {
+			fed++
+		}))
+	assert.True(t, loader.NewCursor().Done())
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	fed = 0
+
+	// the cursors start past the warmup, and move independently of each other and of the loader
+	first, second := loader.NewCursor(), loader.NewCursor()
+	assert.Equal(t, start.Add(time.Minute), first.CurrentTime())
+	first.Tick()
+	first.Tick()
+	second.Tick()
+	assert.Equal(t, float64(104), first.GetDepth("BTC-BUSD").AskPrice)
+	assert.Equal(t, float64(103), second.GetDepth("ETH-BUSD").AskPrice)
+	assert.Equal(t, float64(102), loader.GetDepth("BTC-BUSD").AskPrice)
+	assert.Equal(t, start.Add(3*time.Minute), first.GetDepth("BTC-BUSD").Time)
+	assert.Equal(t, 0, fed)
+
+	ticks := 0
+	for !first.Done() {
+		first.Tick()
+		ticks++
+	}
+	assert.Equal(t, 24*60-3, ticks)
+	assert.Panics(t, func() {
+		first.GetDepth("BTC-BUSD")
+	})
+	first.Rewind()
+	assert.False(t, first.Done())
+	assert.Equal(t, start.Add(time.Minute), first.CurrentTime())
+	assert.Equal(t, float64(102), first.GetDepth("BTC-BUSD").AskPrice)
+	assert.Equal(t, start.Add(2*time.Minute), second.CurrentTime())
+}
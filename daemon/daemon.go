@@ -0,0 +1,372 @@
+// Package daemon runs the depth backfill on cron-style schedules, so that the data directory is kept
+// up to date without an external scheduler.
+package daemon
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const dateFormat = "2006-01-02"
+
+// Job is a scheduled backfill of a group of pairs of a market.
+type Job struct {
+	// Name identifies the job in the progress output and the state file.
+	Name   string       `json:"name"`
+	Market depth.Market `json:"market"`
+	Pairs  []depth.Pair `json:"pairs"`
+	// Schedule is a standard 5-field cron expression evaluated in UTC, see ParseSchedule.
+	// For example, "15 0 * * *" runs the job every day at 00:15.
+	Schedule string `json:"schedule"`
+	// Days is the number of days before the day of the run to load, 1 (yesterday) by default.
+	Days int `json:"days"`
+	// Options are the options of the CCDepthLoader of the job, after those of WithLoaderOptions.
+	// They are not used with WithLoad.
+	Options []depth.Option `json:"-"`
+}
+
+// LoadFunc loads the depth data of the pairs of the market for the time range.
+type LoadFunc func(market depth.Market, pairs []depth.Pair, start, end time.Time) error
+
+// Option configures the Daemon.
+type Option func(d *Daemon)
+
+// WithLoad sets the function used to load the data, by default the CCDepthLoader Load.
+func WithLoad(load LoadFunc) Option {
+	return func(d *Daemon) {
+		d.load = load
+	}
+}
+
+// WithLoaderOptions sets the function returning the options of the CCDepthLoader of each market, like its data
+// directory, namespace and provider, followed by the options of the job, see Job.Options.
+// They are not used with WithLoad.
+func WithLoaderOptions(opts func(market depth.Market) []depth.Option) Option {
+	return func(d *Daemon) {
+		d.loaderOpts = opts
+	}
+}
+
+// WithClock sets the function returning the current time, time.Now by default.
+func WithClock(now func() time.Time) Option {
+	return func(d *Daemon) {
+		d.now = now
+	}
+}
+
+// WithTimeSource sets the time of the schedules, and of the polling of Run, depth.RealTime by default,
+// like a depth.ManualTime to run the schedules deterministically in a test, or the time of the scheduler of a service.
+// It replaces the function of WithClock.
+func WithTimeSource(source depth.TimeSource) Option {
+	return func(d *Daemon) {
+		d.time, d.now = source, source.Now
+	}
+}
+
+// WithProgress sets the writer of the progress messages, os.Stdout by default.
+func WithProgress(w io.Writer) Option {
+	return func(d *Daemon) {
+		d.progress = w
+	}
+}
+
+// WithStateFile sets the file where the time of the last completed run of each job is kept.
+// When the daemon starts, it catches up the runs missed since then.
+// Without a state file, the jobs are scheduled from the start of the daemon.
+func WithStateFile(path string) Option {
+	return func(d *Daemon) {
+		d.stateFile = path
+	}
+}
+
+// WithRetryBackoff sets the delay before retrying a failed run, 1 minute by default.
+// The delay doubles with each consecutive failure of the job, up to max.
+func WithRetryBackoff(initial, max time.Duration) Option {
+	return func(d *Daemon) {
+		d.backoff, d.maxBackoff = initial, max
+	}
+}
+
+// Daemon runs the scheduled jobs. Each job has a queue of loads: the scheduled runs are queued when
+// they become due, and more loads can be queued with Enqueue. A job never overlaps with itself: its loads
+// are performed one after the other, in the order of the queue. Neither does it overlap with the jobs of its market,
+// as their loads write the same files of the data directory: a job waits for the running one of its market
+// to complete before it starts. A failed load stays at the head of the
+// queue, and is retried after the backoff delay, or right away with Retry.
+type Daemon struct {
+	jobs       []*scheduledJob
+	load       LoadFunc
+	loaderOpts func(market depth.Market) []depth.Option
+	now        func() time.Time
+	time       depth.TimeSource
+	progress   io.Writer
+	stateFile  string
+	backoff    time.Duration
+	maxBackoff time.Duration
+	mu         sync.Mutex
+	wg         sync.WaitGroup
+}
+
+type scheduledJob struct {
+	Job
+	schedule Schedule
+	// last is the scheduled time of the last completed run, and queued the one of the last queued run.
+	last    time.Time
+	queued  time.Time
+	queue   []*queuedLoad
+	running bool
+}
+
+// LoadState is the state of a queued load.
+type LoadState string
+
+const (
+	LoadPending LoadState = "pending"
+	LoadRunning LoadState = "running"
+	LoadFailed  LoadState = "failed"
+)
+
+// queuedLoad is a load of the time range in the queue of a job.
+type queuedLoad struct {
+	// at is the scheduled time of the run, zero for the loads queued with Enqueue.
+	at         time.Time
+	start, end time.Time
+	state      LoadState
+	attempts   int
+	err        error
+	// retryAt is the time before which a failed load is not retried.
+	retryAt time.Time
+}
+
+// New returns a daemon running the jobs.
+func New(jobs []Job, opts ...Option) (*Daemon, error) {
+	d := &Daemon{now: time.Now, time: depth.RealTime, progress: os.Stdout, backoff: time.Minute, maxBackoff: time.Hour}
+	for _, opt := range opts {
+		opt(d)
+	}
+	state, err := d.readState()
+	if err != nil {
+		return nil, err
+	}
+	start := d.now().UTC()
+	for _, job := range jobs {
+		schedule, err := ParseSchedule(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", job.Name, err)
+		}
+		if job.Days == 0 {
+			job.Days = 1
+		}
+		last, ok := state[job.Name]
+		if !ok {
+			last = start
+		}
+		d.jobs = append(d.jobs, &scheduledJob{Job: job, schedule: schedule, last: last, queued: last})
+	}
+	return d, nil
+}
+
+// Run runs the jobs on their schedules, checked every second of the time source, see WithTimeSource,
+// until the context is cancelled, then waits for the running jobs to complete.
+func (d *Daemon) Run(ctx context.Context) error {
+	for {
+		d.RunPending(d.now())
+		select {
+		case <-ctx.Done():
+			d.Wait()
+			return ctx.Err()
+		case <-d.time.After(time.Second):
+		}
+	}
+}
+
+// RunPending queues the runs due at the given time, and starts the jobs with loads to perform,
+// unless they are running already, or a job of their market is, or they are waiting to retry a failed load.
+func (d *Daemon) RunPending(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	running := make(map[depth.Market]bool)
+	for _, job := range d.jobs {
+		if job.running {
+			running[job.Market] = true
+		}
+	}
+	for _, job := range d.jobs {
+		job.queueDue(now)
+		if job.running || running[job.Market] || !job.ready(now) {
+			continue
+		}
+		job.running, running[job.Market] = true, true
+		d.wg.Add(1)
+		go func(job *scheduledJob) {
+			defer d.wg.Done()
+			d.runQueue(job, now)
+		}(job)
+	}
+}
+
+// Wait waits for the running jobs to complete.
+func (d *Daemon) Wait() {
+	d.wg.Wait()
+}
+
+// Enqueue queues a load of the time range for the job, performed after the loads already in its queue.
+func (d *Daemon) Enqueue(name string, start, end time.Time) error {
+	if !start.Before(end) {
+		return fmt.Errorf("the start %s is not before the end %s", start.Format(dateFormat), end.Format(dateFormat))
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job := d.job(name)
+	if job == nil {
+		return fmt.Errorf("unknown job %s", name)
+	}
+	job.queue = append(job.queue, &queuedLoad{start: start, end: end, state: LoadPending})
+	return nil
+}
+
+// Retry makes the failed load of the job be retried with the next RunPending, without waiting for the backoff delay.
+func (d *Daemon) Retry(name string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	job := d.job(name)
+	if job == nil {
+		return fmt.Errorf("unknown job %s", name)
+	}
+	if len(job.queue) == 0 || job.queue[0].state != LoadFailed {
+		return fmt.Errorf("job %s has no failed load", name)
+	}
+	job.queue[0].state, job.queue[0].retryAt = LoadPending, time.Time{}
+	return nil
+}
+
+// job returns the job of the given name, or nil. It must be called with the lock held.
+func (d *Daemon) job(name string) *scheduledJob {
+	for _, job := range d.jobs {
+		if job.Name == name {
+			return job
+		}
+	}
+	return nil
+}
+
+// queueDue queues the runs of the job scheduled up to now. It must be called with the lock held.
+func (job *scheduledJob) queueDue(now time.Time) {
+	for at := job.schedule.Next(job.queued); !at.IsZero() && !at.After(now); at = job.schedule.Next(at) {
+		end := at.Truncate(24 * time.Hour)
+		job.queue = append(job.queue, &queuedLoad{at: at, start: end.AddDate(0, 0, -job.Days), end: end, state: LoadPending})
+		job.queued = at
+	}
+}
+
+// ready tells if the job has a load to perform at the given time. It must be called with the lock held.
+func (job *scheduledJob) ready(now time.Time) bool {
+	return len(job.queue) > 0 && !now.Before(job.queue[0].retryAt)
+}
+
+// runQueue performs the loads of the queue of the job, in order, including the runs which became due in the meantime.
+// On a failure, it stops, and the load is retried after the backoff delay.
+func (d *Daemon) runQueue(job *scheduledJob, now time.Time) {
+	for {
+		d.mu.Lock()
+		job.queueDue(now)
+		if !job.ready(now) {
+			job.running = false
+			d.mu.Unlock()
+			return
+		}
+		load := job.queue[0]
+		load.state = LoadRunning
+		load.attempts++
+		d.mu.Unlock()
+
+		if load.at.IsZero() {
+			fmt.Fprintln(d.progress, "Running", job.Name, "for", load.start.Format(dateFormat), "-", load.end.Format(dateFormat))
+		} else {
+			fmt.Fprintln(d.progress, "Running", job.Name, "scheduled at", load.at.Format(time.RFC3339), "for", load.start.Format(dateFormat), "-", load.end.Format(dateFormat))
+		}
+		err := d.loadJob(job.Job, load.start, load.end)
+
+		d.mu.Lock()
+		if err != nil {
+			delay := d.backoff << (load.attempts - 1)
+			if delay > d.maxBackoff || delay <= 0 {
+				delay = d.maxBackoff
+			}
+			// the backoff starts at the failure, not at the start of a load which may have run for hours
+			load.state, load.err, load.retryAt = LoadFailed, err, d.now().Add(delay)
+			job.running = false
+			d.mu.Unlock()
+			fmt.Fprintln(d.progress, "Job", job.Name, "failed, retrying in", delay, "-", err)
+			return
+		}
+		job.queue = job.queue[1:]
+		if !load.at.IsZero() {
+			job.last = load.at
+			err = d.writeState()
+		}
+		d.mu.Unlock()
+		if err != nil {
+			fmt.Fprintln(d.progress, "Failed to write the daemon state:", err)
+		}
+		now = d.now()
+	}
+}
+
+// loadJob loads the time range of the job with the function of WithLoad, or with a CCDepthLoader
+// with the options of WithLoaderOptions and of the job.
+func (d *Daemon) loadJob(job Job, start, end time.Time) (err error) {
+	if d.load != nil {
+		return d.load(job.Market, job.Pairs, start, end)
+	}
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	opts := []depth.Option{depth.WithProgress(d.progress)}
+	if d.loaderOpts != nil {
+		opts = append(opts, d.loaderOpts(job.Market)...)
+	}
+	opts = append(opts, job.Options...)
+	depth.NewCCDepthLoader(job.Market, opts...).Load(job.Pairs, start, end)
+	return nil
+}
+
+func (d *Daemon) readState() (map[string]time.Time, error) {
+	state := map[string]time.Time{}
+	if d.stateFile == "" {
+		return state, nil
+	}
+	content, err := os.ReadFile(d.stateFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return state, json.Unmarshal(content, &state)
+}
+
+// writeState writes the time of the last run of each job to the state file. It must be called with the lock held.
+func (d *Daemon) writeState() error {
+	if d.stateFile == "" {
+		return nil
+	}
+	state := make(map[string]time.Time, len(d.jobs))
+	for _, job := range d.jobs {
+		state[job.Name] = job.last
+	}
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.stateFile, content, 0644)
+}
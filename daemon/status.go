@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// JobStatus describes a job and the loads in its queue.
+type JobStatus struct {
+	Name string `json:"name"`
+	// Last is the scheduled time of the last completed run, and Next the one of the next run to be queued.
+	Last    time.Time    `json:"last"`
+	Next    time.Time    `json:"next"`
+	Running bool         `json:"running"`
+	Loads   []LoadStatus `json:"loads"`
+}
+
+// LoadStatus describes a queued load.
+type LoadStatus struct {
+	// Scheduled is the scheduled time of the run, zero for the loads queued with Enqueue.
+	Scheduled time.Time `json:"scheduled"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	State     LoadState `json:"state"`
+	Attempts  int       `json:"attempts"`
+	Error     string    `json:"error,omitempty"`
+	// RetryAt is the time at which a failed load is retried.
+	RetryAt time.Time `json:"retry_at"`
+}
+
+// Status returns the status of the jobs, in the order they were given to New.
+func (d *Daemon) Status() []JobStatus {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	statuses := make([]JobStatus, 0, len(d.jobs))
+	for _, job := range d.jobs {
+		status := JobStatus{Name: job.Name, Last: job.last, Next: job.schedule.Next(job.queued), Running: job.running}
+		for _, load := range job.queue {
+			loadStatus := LoadStatus{
+				Scheduled: load.at,
+				Start:     load.start,
+				End:       load.end,
+				State:     load.state,
+				Attempts:  load.attempts,
+				RetryAt:   load.retryAt,
+			}
+			if load.err != nil {
+				loadStatus.Error = load.err.Error()
+			}
+			status.Loads = append(status.Loads, loadStatus)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// Handler returns the HTTP status API of the daemon:
+//
+//	GET  /jobs                                            the status of all jobs, see JobStatus
+//	POST /jobs/<name>/retry                               retries the failed load of the job now
+//	POST /jobs/<name>/loads?start=2022-11-24&end=2022-11-25  queues a load of the time range
+//
+// The POST requests respond with 204 No Content on success, and 400 Bad Request with the error otherwise.
+func (d *Daemon) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(path) == 1 && path[0] == "jobs" && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/json")
+			_ = json.NewEncoder(w).Encode(d.Status())
+		case len(path) == 3 && path[0] == "jobs" && path[2] == "retry" && r.Method == http.MethodPost:
+			respond(w, d.Retry(path[1]))
+		case len(path) == 3 && path[0] == "jobs" && path[2] == "loads" && r.Method == http.MethodPost:
+			start, err := time.Parse(dateFormat, r.URL.Query().Get("start"))
+			if err != nil {
+				respond(w, err)
+				return
+			}
+			end, err := time.Parse(dateFormat, r.URL.Query().Get("end"))
+			if err != nil {
+				respond(w, err)
+				return
+			}
+			respond(w, d.Enqueue(path[1], start, end))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func respond(w http.ResponseWriter, err error) {
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
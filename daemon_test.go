@@ -0,0 +1,361 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/daemon"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+type loadCall struct {
+	market     depth.Market
+	start, end string
+}
+
+// loadRecorder records the calls of a daemon.LoadFunc.
+type loadRecorder struct {
+	mu    sync.Mutex
+	calls []loadCall
+}
+
+func (r *loadRecorder) record(market depth.Market, start, end time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, loadCall{market, start.Format("2006-01-02"), end.Format("2006-01-02")})
+}
+
+func (r *loadRecorder) Calls() []loadCall {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]loadCall(nil), r.calls...)
+}
+
+// manualClock is a clock for the daemon.WithClock option that only moves when set.
+type manualClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *manualClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *manualClock) Set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = t
+}
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2022, 11, 24, 10, 7, 0, 0, time.UTC)
+	for spec, next := range map[string]time.Time{
+		"15 0 * * *":     time.Date(2022, 11, 25, 0, 15, 0, 0, time.UTC),
+		"*/5 * * * *":    time.Date(2022, 11, 24, 10, 10, 0, 0, time.UTC),
+		"0 9-17 * * 1-5": time.Date(2022, 11, 24, 11, 0, 0, 0, time.UTC),
+		"0 0 1 * *":      time.Date(2022, 12, 1, 0, 0, 0, 0, time.UTC),
+		"30 6 * * 0":     time.Date(2022, 11, 27, 6, 30, 0, 0, time.UTC),
+		"30 6 * * 7":     time.Date(2022, 11, 27, 6, 30, 0, 0, time.UTC),
+		// day of month or day of week
+		"0 0 1 * 6": time.Date(2022, 11, 26, 0, 0, 0, 0, time.UTC),
+	} {
+		schedule, err := daemon.ParseSchedule(spec)
+		assert.NoError(t, err, spec)
+		assert.Equal(t, next, schedule.Next(from), spec)
+	}
+	schedule, err := daemon.ParseSchedule("0 0 30 2 *")
+	assert.NoError(t, err)
+	assert.True(t, schedule.Next(from).IsZero())
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "a * * * *", "5-1 * * * *"} {
+		_, err := daemon.ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+}
+
+func TestDaemonCatchUp(t *testing.T) {
+	state := filepath.Join(t.TempDir(), "state.json")
+	assert.NoError(t, os.WriteFile(state, []byte(`{"daily": "2022-11-24T00:15:00Z"}`), 0644))
+
+	recorder := &loadRecorder{}
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		recorder.record(market, start, end)
+		return nil
+	}
+	now := time.Date(2022, 11, 26, 12, 0, 0, 0, time.UTC)
+	jobs := []daemon.Job{{Name: "daily", Market: depth.MarketBinance, Pairs: []depth.Pair{"BTC-BUSD"}, Schedule: "15 0 * * *"}}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithStateFile(state),
+		daemon.WithClock(func() time.Time { return now }), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+
+	d.RunPending(now)
+	d.Wait()
+	assert.Equal(t, []loadCall{
+		{depth.MarketBinance, "2022-11-24", "2022-11-25"},
+		{depth.MarketBinance, "2022-11-25", "2022-11-26"},
+	}, recorder.Calls())
+
+	// nothing is due until the next day
+	d.RunPending(now.Add(time.Hour))
+	d.Wait()
+	assert.Len(t, recorder.Calls(), 2)
+
+	content, err := os.ReadFile(state)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "2022-11-26T00:15:00Z")
+}
+
+func TestDaemonNoOverlap(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	started := make(chan struct{})
+	release := make(chan struct{})
+	recorder := &loadRecorder{}
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		recorder.record(market, start, end)
+		started <- struct{}{}
+		<-release
+		return nil
+	}
+	jobs := []daemon.Job{{Name: "hourly", Market: depth.MarketBinance, Schedule: "0 * * * *", Days: 2}}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+
+	clock.Set(start.Add(time.Hour))
+	d.RunPending(clock.Now())
+	<-started
+
+	// the job is still running, the next run is not started concurrently
+	clock.Set(start.Add(2 * time.Hour))
+	d.RunPending(clock.Now())
+	assert.Len(t, recorder.Calls(), 1)
+
+	// the run which became due in the meantime is performed once the first one has completed
+	release <- struct{}{}
+	<-started
+	release <- struct{}{}
+	d.Wait()
+	assert.Equal(t, []loadCall{
+		{depth.MarketBinance, "2022-11-22", "2022-11-24"},
+		{depth.MarketBinance, "2022-11-22", "2022-11-24"},
+	}, recorder.Calls())
+}
+
+func TestDaemonTimeSource(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 30, 0, 0, time.UTC)
+	source := depth.NewManualTime(start)
+	loads := make(chan time.Time, 10)
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		loads <- source.Now()
+		return nil
+	}
+	jobs := []daemon.Job{{Name: "hourly", Market: depth.MarketBinance, Schedule: "0 * * * *"}}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithTimeSource(source), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- d.Run(ctx)
+	}()
+
+	// the run is due only once the source is advanced past the hour
+	for source.Waiters() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	assert.Empty(t, loads)
+	source.Advance(30 * time.Minute)
+	assert.Equal(t, start.Add(30*time.Minute), <-loads)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
+
+func TestDaemonRetryBackoff(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 0, 0, 0, time.UTC)
+	recorder := &loadRecorder{}
+	fail := true
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		recorder.record(market, start, end)
+		if fail {
+			return errors.New("server unavailable")
+		}
+		return nil
+	}
+	jobs := []daemon.Job{{Name: "daily", Market: depth.MarketBinance, Schedule: "15 0 * * *"}}
+	clock := &manualClock{now: start}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithRetryBackoff(time.Minute, 10*time.Minute),
+		daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+
+	runAt := func(after time.Duration) int {
+		clock.Set(start.Add(after))
+		d.RunPending(clock.Now())
+		d.Wait()
+		return len(recorder.Calls())
+	}
+	assert.Equal(t, 1, runAt(15*time.Minute))
+	// retried after 1 minute, then after 2 minutes
+	assert.Equal(t, 1, runAt(15*time.Minute+30*time.Second))
+	assert.Equal(t, 2, runAt(16*time.Minute))
+	assert.Equal(t, 2, runAt(17*time.Minute))
+	fail = false
+	assert.Equal(t, 3, runAt(18*time.Minute))
+	// done until the next schedule
+	assert.Equal(t, 3, runAt(time.Hour))
+}
+
+func TestDaemonStatusAPI(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 0, 0, 0, time.UTC)
+	recorder := &loadRecorder{}
+	fail := true
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		recorder.record(market, start, end)
+		if fail {
+			return errors.New("server unavailable")
+		}
+		return nil
+	}
+	jobs := []daemon.Job{{Name: "daily", Market: depth.MarketBinance, Schedule: "15 0 * * *"}}
+	clock := &manualClock{now: start}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithRetryBackoff(time.Hour, time.Hour),
+		daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+	server := httptest.NewServer(d.Handler())
+	defer server.Close()
+
+	clock.Set(start.Add(15 * time.Minute))
+	d.RunPending(clock.Now())
+	d.Wait()
+	var statuses []daemon.JobStatus
+	resp, err := http.Get(server.URL + "/jobs")
+	assert.NoError(t, err)
+	assert.NoError(t, json.NewDecoder(resp.Body).Decode(&statuses))
+	_ = resp.Body.Close()
+	assert.Len(t, statuses, 1)
+	assert.Len(t, statuses[0].Loads, 1)
+	assert.Equal(t, daemon.LoadFailed, statuses[0].Loads[0].State)
+	assert.Equal(t, "server unavailable", statuses[0].Loads[0].Error)
+	assert.True(t, start.Add(75*time.Minute).Equal(statuses[0].Loads[0].RetryAt))
+
+	// retried and queued without waiting for the backoff delay
+	fail = false
+	resp, err = http.Post(server.URL+"/jobs/daily/retry", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	resp, err = http.Post(server.URL+"/jobs/daily/loads?start=2022-11-01&end=2022-11-03", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+	clock.Set(start.Add(16 * time.Minute))
+	d.RunPending(clock.Now())
+	d.Wait()
+	assert.Equal(t, []loadCall{
+		{depth.MarketBinance, "2022-11-23", "2022-11-24"},
+		{depth.MarketBinance, "2022-11-23", "2022-11-24"},
+		{depth.MarketBinance, "2022-11-01", "2022-11-03"},
+	}, recorder.Calls())
+	status := d.Status()[0]
+	assert.Empty(t, status.Loads)
+	assert.True(t, start.Add(15*time.Minute).Equal(status.Last))
+
+	resp, err = http.Post(server.URL+"/jobs/daily/retry", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	resp, err = http.Post(server.URL+"/jobs/weekly/loads?start=2022-11-01&end=2022-11-03", "", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestDaemonMarketJobs(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	started := make(chan string, 3)
+	release := make(chan struct{})
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		started <- pairs[0].String()
+		<-release
+		return nil
+	}
+	jobs := []daemon.Job{
+		{Name: "btc", Market: depth.MarketBinance, Pairs: []depth.Pair{"BTC-BUSD"}, Schedule: "0 * * * *"},
+		{Name: "eth", Market: depth.MarketBinance, Pairs: []depth.Pair{"ETH-BUSD"}, Schedule: "0 * * * *"},
+		{Name: "futures", Market: depth.MarketBinanceUsdsFutures, Pairs: []depth.Pair{"BTC-USDT"}, Schedule: "0 * * * *"},
+	}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+
+	// the jobs of a market write the same files, so they run one after the other, and those of other markets meanwhile
+	clock.Set(start.Add(time.Hour))
+	d.RunPending(clock.Now())
+	assert.ElementsMatch(t, []string{"BTC-BUSD", "BTC-USDT"}, []string{<-started, <-started})
+	d.RunPending(clock.Now())
+	assert.Empty(t, started)
+	release <- struct{}{}
+	release <- struct{}{}
+	for running := true; running; time.Sleep(time.Millisecond) {
+		running = false
+		for _, status := range d.Status() {
+			running = running || status.Running
+		}
+	}
+	d.RunPending(clock.Now())
+	assert.Equal(t, "ETH-BUSD", <-started)
+	release <- struct{}{}
+	d.Wait()
+}
+
+func TestDaemonLoaderOptions(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, true
+	})
+	dir := t.TempDir()
+	start := time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	var markets []depth.Market
+	jobs := []daemon.Job{{Name: "daily", Market: depth.MarketBinance, Pairs: []depth.Pair{"BTC-BUSD"}, Schedule: "15 0 * * *",
+		Options: []depth.Option{depth.WithNamespace("jobs")}}}
+	d, err := daemon.New(jobs, daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard),
+		daemon.WithLoaderOptions(func(market depth.Market) []depth.Option {
+			markets = append(markets, market)
+			return []depth.Option{depth.WithDataDir(dir), depth.WithBaseURL(url)}
+		}))
+	assert.NoError(t, err)
+
+	// the loader of the job has the options of the daemon, then those of the job
+	clock.Set(start.Add(15 * time.Minute))
+	d.RunPending(clock.Now())
+	d.Wait()
+	assert.Equal(t, []depth.Market{depth.MarketBinance}, markets)
+	assert.FileExists(t, filepath.Join(dir, "jobs", "binance", "2020-01-01_2020-01-02_depth.csv"))
+	assert.Empty(t, d.Status()[0].Loads)
+}
+
+func TestDaemonRetryAfterLongLoad(t *testing.T) {
+	start := time.Date(2022, 11, 24, 0, 0, 0, 0, time.UTC)
+	clock := &manualClock{now: start}
+	load := func(market depth.Market, pairs []depth.Pair, start, end time.Time) error {
+		// the load runs for 3 hours before it fails
+		clock.Set(clock.Now().Add(3 * time.Hour))
+		return errors.New("server unavailable")
+	}
+	jobs := []daemon.Job{{Name: "daily", Market: depth.MarketBinance, Schedule: "15 0 * * *"}}
+	d, err := daemon.New(jobs, daemon.WithLoad(load), daemon.WithRetryBackoff(time.Minute, time.Hour),
+		daemon.WithClock(clock.Now), daemon.WithProgress(io.Discard))
+	assert.NoError(t, err)
+
+	clock.Set(start.Add(15 * time.Minute))
+	d.RunPending(clock.Now())
+	d.Wait()
+	// the backoff starts at the failure
+	assert.Equal(t, start.Add(3*time.Hour+16*time.Minute), d.Status()[0].Loads[0].RetryAt)
+}
@@ -0,0 +1,51 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestDatasets(t *testing.T) {
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(100 + minute), 1, float64(101 + minute), 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/datasets") })
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("datasets"))
+
+	// the datasets of different time ranges and markets are loaded concurrently
+	var wg sync.WaitGroup
+	for _, name := range []string{"spot", "futures"} {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			if name == "spot" {
+				loader.Dataset(name, depth.MarketBinance).Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022"))
+			} else {
+				loader.Dataset(name, depth.MarketBinanceUsdsFutures).Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2022"), ParseOrDie("01-03-2022"))
+			}
+		}(name)
+	}
+	wg.Wait()
+	assert.Equal(t, []string{"futures", "spot"}, loader.Datasets())
+	assert.FileExists(t, "data/datasets/binance/2022-01-01_2022-01-02_depth.csv")
+	assert.FileExists(t, "data/datasets/binance-usds-futures/2022-01-01_2022-01-03_depth.csv")
+
+	// each dataset has its own cursor
+	spot, futures := loader.Dataset("spot", depth.MarketBinance), loader.Dataset("futures", depth.MarketBinanceUsdsFutures)
+	spot.Tick()
+	spot.Tick()
+	futures.Tick()
+	assert.Equal(t, 102.0, spot.GetDepth("BTC-BUSD").BidPrice)
+	assert.Equal(t, 101.0, futures.GetDepth("BTC-BUSD").BidPrice)
+	assert.Panics(t, func() {
+		loader.Dataset("spot", depth.MarketBinanceUsdsFutures)
+	})
+
+	assert.NoError(t, loader.DropDataset("spot"))
+	assert.NoError(t, loader.DropDataset("spot"))
+	assert.Equal(t, []string{"futures"}, loader.Datasets())
+}
@@ -0,0 +1,65 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestLoadDayFiles(t *testing.T) {
+	var downloads int64
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if minute == 0 {
+			atomic.AddInt64(&downloads, 1)
+		}
+		// ETH-USDT has no data on 2020-01-02
+		if pair == "ETH-USDT" && day.Day() == 2 {
+			return Quote{}, false
+		}
+		return Quote{float64(100 * day.Day()), 1, float64(100*day.Day() + minute), 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/days-test") })
+	newLoader := func(opts ...depth.Option) *depth.CCDepthLoader {
+		opts = append(opts, depth.WithNamespace("days-test"), depth.WithDayFiles(), depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+		return depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	}
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-USDT"}
+
+	result := newLoader().Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Len(t, result["ETH-USDT"], 24*60*4)
+	assert.Equal(t, int64(4), downloads)
+	assert.FileExists(t, "data/days-test/binance/days.manifest")
+	assert.FileExists(t, "data/days-test/binance/BTC-BUSD/2020-01-02.csv.gz")
+	assert.NoFileExists(t, "data/days-test/binance/ETH-USDT/2020-01-02.csv.gz")
+
+	// the overlapping time range downloads only its new day, the day without data included
+	loader := newLoader()
+	result = loader.Load(pairs, ParseOrDie("01-02-2020"), ParseOrDie("01-04-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Equal(t, int64(6), downloads)
+	loader.Tick()
+	assert.Equal(t, 201.0, loader.GetDepth("BTC-BUSD").AskPrice)
+	assert.Equal(t, 300.0, loader.GetDepth("ETH-USDT").BidPrice)
+
+	// the stored days are read from their files
+	result = newLoader(depth.WithReadOnly()).Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020"))
+	assert.Len(t, result["BTC-BUSD"], 3*24*60*4)
+	assert.Equal(t, int64(6), downloads)
+	assert.Panics(t, func() {
+		newLoader(depth.WithReadOnly()).Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-05-2020"))
+	})
+	assert.Panics(t, func() {
+		newLoader(depth.WithSchema(depth.FieldMid)).Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	})
+
+	// a corrupted day fails the load
+	assert.NoError(t, os.WriteFile("data/days-test/binance/BTC-BUSD/2020-01-01.csv.gz", []byte("not gzip"), 0644))
+	assert.Panics(t, func() {
+		newLoader().Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	})
+}
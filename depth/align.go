@@ -0,0 +1,182 @@
+package depth
+
+import (
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"math"
+	"time"
+)
+
+// Misalignment is a pair whose minutes are not on the minute grid of the loaded time range.
+type Misalignment struct {
+	Pair Pair
+	// Minute is the first minute of the loaded time range from which the records of the pair may be misaligned,
+	// and Time its time.
+	Minute int
+	Time   time.Time
+	Reason string
+}
+
+// AlignCheck checks that the pairs, all loaded pairs if none are given, have a record for each minute
+// of the loaded time range, so that the records of the same index are of the same minute, and returns
+// the pairs that don't. The days without vendor data are not stored, so that a pair missing a day
+// has its following days shifted, and shorter records, unless loaded with WithAlignment.
+// After LoadFrom, the time range is the one of the longest pair.
+func (l *CCDepthLoader) AlignCheck(pairs []Pair) []Misalignment {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	grid := l.gridLength(pairs)
+	var misalignments []Misalignment
+	for _, pair := range pairs {
+		length := l.length(pair)
+		var minute int
+		var reason string
+		switch {
+		case length == 0:
+			reason = "no records"
+		case length != grid && length%(24*60) != 0:
+			// the partial day is not known, the last one is the first that may be shifted
+			minute = length - length%(24*60)
+			reason = fmt.Sprintf("%d minutes, not whole days, the days after a partial day are shifted", length)
+		case length < grid:
+			minute = length
+			reason = fmt.Sprintf("%d days missing of %d, the days after a missing day are shifted", (grid-length)/(24*60), grid/(24*60))
+		case length > grid:
+			minute = grid
+			reason = fmt.Sprintf("%d minutes, more than the %d of the time range", length, grid)
+		default:
+			continue
+		}
+		misalignments = append(misalignments, Misalignment{Pair: pair, Minute: minute, Time: l.minuteTime(minute).UTC(), Reason: reason})
+	}
+	return misalignments
+}
+
+// gridLength returns the number of minutes of the loaded time range, or of the longest pair after LoadFrom.
+func (l *CCDepthLoader) gridLength(pairs []Pair) int {
+	if !l.endDate.IsZero() {
+		return int(l.endDate.Sub(l.startDate).Minutes())
+	}
+	grid := 0
+	for _, pair := range pairs {
+		if length := l.length(pair); length > grid {
+			grid = length
+		}
+	}
+	return grid
+}
+
+// Alignment is how a loader aligns the pairs with missing days, see WithAlignment.
+type Alignment string
+
+const (
+	// AlignPad stores the missing days of the downloaded pairs as NaN values, like the bad days, instead of
+	// dropping them, so that the pairs keep a record for each minute of the time range.
+	AlignPad Alignment = "pad"
+	// AlignTrim pads the missing days, and trims the loaded pairs to the days all of them have data for,
+	// starting the loaded time range at the first of these days.
+	AlignTrim Alignment = "trim"
+)
+
+// padMarker is the value of the missing days padded with AlignPad.
+const padMarker = "NaN"
+
+// WithAlignment aligns the pairs with missing days, like a pair listed after the start of the time range,
+// so that the records of the same index are of the same minute for all pairs, see AlignCheck.
+// The rows of the pairs downloaded without the alignment have no trace of their missing days, so that Load panics
+// if they can't be aligned with AlignTrim. The pairs kept as series only, see WithSeriesOnly, are not trimmed.
+// It panics if the alignment is unknown.
+func WithAlignment(alignment Alignment) Option {
+	if alignment != AlignPad && alignment != AlignTrim {
+		panic("unknown alignment " + string(alignment))
+	}
+	return func(l *CCDepthLoader) {
+		l.alignment = alignment
+	}
+}
+
+// padDays replaces the missing days of records of the given width by NaN values, with AlignPad or AlignTrim,
+// unless all days are missing.
+func (l *CCDepthLoader) padDays(days [][]string, width int) [][]string {
+	if l.alignment == "" || len(slices.Concat(days...)) == 0 {
+		return days
+	}
+	padded := make([][]string, len(days))
+	for i, values := range days {
+		if len(values) == 0 {
+			values = make([]string, 24*60*width)
+			for j := range values {
+				values[j] = padMarker
+			}
+		}
+		padded[i] = values
+	}
+	return padded
+}
+
+// trimDays trims the loaded pairs to the days all of them have data for, see AlignTrim.
+func (l *CCDepthLoader) trimDays() {
+	pairs := l.loadedPairs()
+	grid := l.gridLength(pairs)
+	if misalignments := l.AlignCheck(pairs); len(misalignments) > 0 {
+		m := misalignments[0]
+		panic(fmt.Sprintf("%s can't be aligned from %s: %s", m.Pair, m.Time.Format(time.RFC3339), m.Reason))
+	}
+	width := l.schema.Width()
+	days := grid / (24 * 60)
+	from, to := 0, days
+	for _, pair := range pairs {
+		first, last := days, 0
+		for day := 0; day < days; day++ {
+			if !l.padded(pair, day*24*60*width) {
+				if day < first {
+					first = day
+				}
+				last = day + 1
+			}
+		}
+		if first > from {
+			from = first
+		}
+		if last < to {
+			to = last
+		}
+	}
+	if from == 0 && to == days {
+		return
+	}
+	if from >= to {
+		panic("the loaded pairs have no common days")
+	}
+	start, end := from*24*60*width, to*24*60*width
+	for _, pair := range pairs {
+		if _, ok := l.records[pair]; ok {
+			l.setRecords(pair, l.denseRecords(pair)[start:end])
+			continue
+		}
+		values, err := l.allocValues(end - start)
+		if err != nil {
+			panic(err)
+		}
+		copy(values, l.values[pair][start:end])
+		if err := l.freeValues(l.values[pair]); err != nil {
+			panic(err)
+		}
+		l.values[pair] = values
+	}
+	_, _ = fmt.Fprintln(l.progress, "Trimmed", from, "days at the start and", days-to, "days at the end of the loaded pairs")
+	l.startDate = l.startDate.AddDate(0, 0, from)
+	l.endDate = l.startDate.AddDate(0, 0, to-from)
+}
+
+// padded checks if the values of the pair starting at the given index are padded, see AlignPad.
+func (l *CCDepthLoader) padded(pair Pair, index int) bool {
+	if records, ok := l.records[pair]; ok {
+		if _, ok := l.runs[pair]; ok {
+			index = l.valueIndex(pair, index/l.schema.Width())
+		}
+		return records[index] == padMarker
+	}
+	return math.IsNaN(l.values[pair][index])
+}
@@ -0,0 +1,46 @@
+package depth
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"hash"
+	"math"
+)
+
+// WithAudit hashes the records returned by GetDepth, pair by pair, in the order they are returned, with their minute,
+// so that two backtests can prove that they consumed the identical records in the identical order, see Digests.
+func WithAudit() Option {
+	return func(l *CCDepthLoader) {
+		l.audit = make(map[Pair]hash.Hash)
+	}
+}
+
+// Digests returns the SHA-256 of the records returned by GetDepth for each pair since the loader was created,
+// in hex, or nil without WithAudit. Each record is hashed with the time of its minute, so that the digest
+// changes if a strategy skips or repeats minutes.
+func (l *CCDepthLoader) Digests() map[Pair]string {
+	if l.audit == nil {
+		return nil
+	}
+	digests := make(map[Pair]string, len(l.audit))
+	for pair, h := range l.audit {
+		digests[pair] = hex.EncodeToString(h.Sum(nil))
+	}
+	return digests
+}
+
+// auditRecord hashes the record of the pair returned at the given minute, see WithAudit.
+func (l *CCDepthLoader) auditRecord(pair Pair, minute int, record Record) {
+	h, ok := l.audit[pair]
+	if !ok {
+		h = sha256.New()
+		l.audit[pair] = h
+	}
+	b := make([]byte, 0, 5*8)
+	b = binary.LittleEndian.AppendUint64(b, uint64(l.minuteTime(minute).Unix()))
+	for _, v := range []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize} {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	_, _ = h.Write(b)
+}
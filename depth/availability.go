@@ -0,0 +1,177 @@
+package depth
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DateRange is a range of days, from Start, inclusive, to End, exclusive.
+type DateRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// Availability returns the continuous ranges of days of the time range for which the vendor has depth data
+// for the pair, so that the time ranges to load can be planned, instead of discovering the missing days
+// while downloading them. Each day is probed by reading the start of its archive, without downloading it.
+// The probes are cached in data/<market>/availability.csv, unless the loader is read-only,
+// so that each day is probed once, except for the last two days, which the vendor may still publish.
+func (l *CCDepthLoader) Availability(pair Pair, startDate time.Time, endDate time.Time) []DateRange {
+	var days []time.Time
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days = append(days, date)
+	}
+	probes := l.probeDays(pair, days)
+	var ranges []DateRange
+	for _, date := range days {
+		if !probes[date.Format("2006-01-02")] {
+			continue
+		}
+		if n := len(ranges); n > 0 && ranges[n-1].End.Equal(date) {
+			ranges[n-1].End = date.AddDate(0, 0, 1)
+		} else {
+			ranges = append(ranges, DateRange{Start: date, End: date.AddDate(0, 0, 1)})
+		}
+	}
+	return ranges
+}
+
+// probeDays returns if the vendor has data for each of the days of the pair, by day, probing the days
+// without a cached probe, see Availability.
+func (l *CCDepthLoader) probeDays(pair Pair, days []time.Time) map[string]bool {
+	probes, err := l.readAvailability()
+	if err != nil {
+		panic(err)
+	}
+	var toProbe []time.Time
+	for _, date := range days {
+		if _, ok := probes[pair][date.Format("2006-01-02")]; !ok {
+			toProbe = append(toProbe, date)
+		}
+	}
+	probed := slices.MapAsync(toProbe, l.concurrency, func(date time.Time) bool {
+		_, _ = fmt.Fprintln(l.progress, "Probing depth availability for", pair, date)
+		return l.probeDay(pair, date)
+	})
+	if probes[pair] == nil {
+		probes[pair] = make(map[string]bool)
+	}
+	var settled []string
+	for i, date := range toProbe {
+		probes[pair][date.Format("2006-01-02")] = probed[i]
+		if time.Since(date) > 3*24*time.Hour {
+			settled = append(settled, joinCSV(pair.String(), date.Format("2006-01-02"), strconv.FormatBool(probed[i])))
+		}
+	}
+	if len(settled) > 0 && !l.readOnly {
+		if err := l.appendAvailability(settled); err != nil {
+			panic(err)
+		}
+	}
+	return probes[pair]
+}
+
+// probeDay checks if the provider has data for the day of the pair, see WithProvider. The crypto-chassis archive
+// of the day is probed by reading only its first lines, the day of another provider is fetched.
+func (l *CCDepthLoader) probeDay(pair Pair, date time.Time) bool {
+	ctx := l.context()
+	if _, ok := l.provider.(chassisProvider); !ok {
+		records, err := l.provider.FetchDay(ctx, l.market, pair, date)
+		if err != nil {
+			panic(err)
+		}
+		return len(records) > 0
+	}
+	url, ok, err := l.lookupURL(ctx, l.market, pair, date)
+	if err != nil {
+		panic(err)
+	}
+	if !ok {
+		return false
+	}
+	resp, err := l.get(ctx, url)
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return false
+	}
+	defer gz.Close()
+	reader := csv.NewReader(gz)
+	reader.FieldsPerRecord = -1
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			return false
+		}
+		if record[0] != "time_seconds" {
+			return true
+		}
+	}
+}
+
+func (l *CCDepthLoader) availabilityPath() string {
+	return filepath.Join(l.marketDir(), "availability.csv")
+}
+
+// readAvailability reads the cached probes of the market, by pair and day.
+func (l *CCDepthLoader) readAvailability() (map[Pair]map[string]bool, error) {
+	probes := make(map[Pair]map[string]bool)
+	file, err := os.Open(l.availabilityPath())
+	if os.IsNotExist(err) {
+		return probes, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("%s is corrupted: %v", l.availabilityPath(), row)
+		}
+		available, err := strconv.ParseBool(row[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s is corrupted: %w", l.availabilityPath(), err)
+		}
+		pair := Pair(row[0])
+		if probes[pair] == nil {
+			probes[pair] = make(map[string]bool)
+		}
+		probes[pair][row[1]] = available
+	}
+	return probes, nil
+}
+
+func (l *CCDepthLoader) appendAvailability(rows []string) error {
+	path := l.availabilityPath()
+	if err := l.perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	file, err := l.perms.openAppend(path)
+	if err != nil {
+		return err
+	}
+	for _, row := range rows {
+		if _, err = file.WriteString(row + "\n"); err != nil {
+			_ = file.Close()
+			return err
+		}
+	}
+	return file.Close()
+}
@@ -0,0 +1,108 @@
+package depth
+
+import (
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// checkpointsDir is the directory of the checkpoints in the market directory, see checkpoint.
+const checkpointsDir = "checkpoints"
+
+// WithPairConcurrency downloads the given number of pairs concurrently, one after the other by default, so that
+// the pairs with fewer days to download don't wait for the others. The days downloaded concurrently stay bounded
+// by WithConcurrency, across all the pairs. Each pair is persisted once its days are downloaded, and the depth
+// data files checkpoint each downloaded day, so that a crash loses the days being downloaded only.
+// WithStore and WithBlocks download their pairs one after the other, persisting their days as downloaded.
+// It panics if n is less than 1.
+func WithPairConcurrency(n int) Option {
+	if n < 1 {
+		panic(fmt.Sprintf("the pair concurrency must be positive, got %d", n))
+	}
+	return func(l *CCDepthLoader) {
+		l.pairConcurrency = n
+	}
+}
+
+// eachPair calls f with each pair, the given number of pairs concurrently, and panics with the first panic of f
+// once all the calls returned, like the pairs called one after the other.
+func eachPair(pairs []Pair, workers int, f func(pair Pair)) {
+	var mu sync.Mutex
+	var panicked interface{}
+	slices.EachAsync(pairs, workers, func(pair Pair) {
+		defer func() {
+			if r := recover(); r != nil {
+				mu.Lock()
+				if panicked == nil {
+					panicked = r
+				}
+				mu.Unlock()
+			}
+		}()
+		f(pair)
+	})
+	if panicked != nil {
+		panic(panicked)
+	}
+}
+
+// acquire waits for one of the days downloaded concurrently across the pairs, see WithConcurrency,
+// and returns false if the load was cancelled first. The caller must release it.
+func (l *CCDepthLoader) acquire() bool {
+	select {
+	case l.slots <- struct{}{}:
+		return true
+	case <-l.context().Done():
+		return false
+	}
+}
+
+func (l *CCDepthLoader) release() {
+	<-l.slots
+}
+
+// checkpoint returns the open store of the days of the pair downloaded for the depth data files, in
+// data/<market>/checkpoints/<pair>, so that the days of a pair not written yet are not lost by a crash,
+// or by the failure of another day, see FailedDaysError. The days are stored with the full schema,
+// as downloaded, and the checkpoint is removed once the pair is written, see dropCheckpoint.
+// The checkpoint of another schema is removed.
+func (l *CCDepthLoader) checkpoint(pair Pair) *DayFileStore {
+	dir := pairDir(filepath.Join(l.marketDir(), checkpointsDir), pair)
+	store := NewDayFileStore(dir)
+	store.perms = l.perms
+	if err := store.Open(l.schema.fullSchema()); err != nil {
+		if err := os.RemoveAll(dir); err != nil {
+			panic(err)
+		}
+		if err := store.Open(l.schema.fullSchema()); err != nil {
+			panic(err)
+		}
+	}
+	return store
+}
+
+// dropCheckpoint removes the checkpoint of the pair, see checkpoint.
+func (l *CCDepthLoader) dropCheckpoint(pair Pair) {
+	if err := os.RemoveAll(pairDir(filepath.Join(l.marketDir(), checkpointsDir), pair)); err != nil {
+		panic(err)
+	}
+}
+
+// checkpointed returns the values of the day of the pair in the checkpoint, and false if it has none.
+func checkpointed(checkpoint *DayFileStore, pair Pair, day time.Time) ([]string, bool) {
+	if checkpoint == nil {
+		return nil, false
+	}
+	ok, err := checkpoint.Has(pair, day)
+	if err != nil || !ok {
+		return nil, false
+	}
+	days, err := checkpoint.ReadRange(pair, day, day.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, false
+	}
+	return days[0], true
+}
@@ -0,0 +1,167 @@
+package depth
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// BaseSeries are the per-minute mid prices of a base asset loaded against several quotes, like BTC-BUSD
+// and BTC-USDT, both raw and converted to a common quote, for the time ranges where the liquidity moved
+// from a quote to another.
+type BaseSeries struct {
+	Base  string
+	Start time.Time
+	// Quote is the common quote of the converted series, the first of the loaded quotes.
+	Quote string
+	// Raw are the mid prices of each pair of the base, in its own quote.
+	Raw map[Pair][]float64
+	// Converted are the mid prices of each pair in the common quote, converted with Rates.
+	Converted map[Pair][]float64
+	// Rates are the mid prices of each quote in the common quote, from the loaded cross rate pairs,
+	// like BUSD-USDT, or the inverse of USDT-BUSD. The rate of the common quote is 1.
+	Rates map[string][]float64
+}
+
+// LoadBase loads the base asset, like BTC, against each of the quotes, and the cross rates of the quotes
+// to the first one, and returns their mid prices, see BaseSeries. The pairs stay loaded, so that their records
+// are available with GetDepth. The minutes without a record, or of a bad day, are NaN.
+// It panics if a quote has no cross rate pair to the first one, loading first <quote>-<first quote>,
+// then <first quote>-<quote>.
+func (l *CCDepthLoader) LoadBase(base string, quotes []string, startDate time.Time, endDate time.Time) *BaseSeries {
+	if len(quotes) == 0 {
+		panic("no quotes to load " + base + " against")
+	}
+	series := &BaseSeries{
+		Base:      base,
+		Start:     startDate,
+		Quote:     quotes[0],
+		Raw:       make(map[Pair][]float64, len(quotes)),
+		Converted: make(map[Pair][]float64, len(quotes)),
+		Rates:     make(map[string][]float64, len(quotes)),
+	}
+	pairs := make([]Pair, len(quotes))
+	for i, quote := range quotes {
+		pairs[i] = Pair(base + "-" + quote)
+	}
+	l.Load(pairs, startDate, endDate)
+	length := int(endDate.Sub(startDate).Minutes())
+	for _, quote := range quotes {
+		series.Rates[quote] = l.rates(quote, series.Quote, startDate, endDate, length)
+	}
+	for i, pair := range pairs {
+		raw := l.mids(pair, length)
+		converted := make([]float64, length)
+		for m := range converted {
+			converted[m] = raw[m] * series.Rates[quotes[i]][m]
+		}
+		series.Raw[pair], series.Converted[pair] = raw, converted
+	}
+	return series
+}
+
+// stablecoins are the quotes pegged to the US dollar, see Depegs.
+var stablecoins = []string{"BUSD", "DAI", "FDUSD", "TUSD", "USDC", "USDP", "USDT"}
+
+// Depeg is a minute where the rate of a stablecoin quote to the common stablecoin quote was off the 1:1 peg.
+type Depeg struct {
+	Quote  string
+	Minute int
+	Time   time.Time
+	Rate   float64
+}
+
+// Depegs returns the minutes where the rate of a stablecoin quote, like USDT, to the common quote, like BUSD,
+// differed from 1 by more than the threshold, like 0.005 for 0.5%. The series are converted with the loaded rates,
+// never at 1:1, so that they are right during the depegs, but the strategies may want to skip these minutes anyway.
+// There are no depegs if the common quote is not a stablecoin.
+func (s *BaseSeries) Depegs(threshold float64) []Depeg {
+	if !isStablecoin(s.Quote) {
+		return nil
+	}
+	var depegs []Depeg
+	for _, quote := range sortedQuotes(s.Rates) {
+		if quote == s.Quote || !isStablecoin(quote) {
+			continue
+		}
+		for m, rate := range s.Rates[quote] {
+			if math.Abs(rate-1) > threshold {
+				depegs = append(depegs, Depeg{Quote: quote, Minute: m, Time: s.Start.Add(time.Duration(m) * time.Minute), Rate: rate})
+			}
+		}
+	}
+	return depegs
+}
+
+func isStablecoin(quote string) bool {
+	for _, stablecoin := range stablecoins {
+		if stablecoin == quote {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedQuotes(rates map[string][]float64) []string {
+	quotes := make([]string, 0, len(rates))
+	for quote := range rates {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+	return quotes
+}
+
+// rates returns the mid prices of the quote in the common quote, loading their cross rate pair.
+func (l *CCDepthLoader) rates(quote string, common string, startDate time.Time, endDate time.Time, length int) []float64 {
+	if quote == common {
+		rates := make([]float64, length)
+		for m := range rates {
+			rates[m] = 1
+		}
+		return rates
+	}
+	direct, inverse := Pair(quote+"-"+common), Pair(common+"-"+quote)
+	for _, pair := range []Pair{direct, inverse} {
+		if l.length(pair) == 0 {
+			l.load(pair, startDate, endDate)
+		}
+		if l.length(pair) == 0 {
+			continue
+		}
+		rates := l.mids(pair, length)
+		if pair == inverse {
+			for m := range rates {
+				rates[m] = 1 / rates[m]
+			}
+		}
+		return rates
+	}
+	panic(fmt.Sprintf("no cross rate of %s to %s: neither %s nor %s has data", quote, common, direct, inverse))
+}
+
+// load loads the pair, leaving it unloaded if it has no data, even for a read-only loader.
+func (l *CCDepthLoader) load(pair Pair, startDate time.Time, endDate time.Time) {
+	defer func() {
+		if r := recover(); r != nil {
+			if err, ok := r.(error); !ok || !errors.Is(err, ErrReadOnly) {
+				panic(r)
+			}
+		}
+	}()
+	l.Load([]Pair{pair}, startDate, endDate)
+}
+
+// mids returns the mid price of the pair at each minute of the time range, NaN for the minutes without a record.
+func (l *CCDepthLoader) mids(pair Pair, length int) []float64 {
+	mids := make([]float64, length)
+	for m := range mids {
+		if m < l.length(pair) {
+			mids[m] = l.recordAt(pair, m).Mid()
+		} else {
+			mids[m] = math.NaN()
+		}
+	}
+	return mids
+}
@@ -0,0 +1,72 @@
+package depth
+
+import "time"
+
+// Basis returns the basis between a perpetual futures and a spot record, in basis points of the spot mid price.
+func Basis(spot Record, perp Record) float64 {
+	return (perp.Mid() - spot.Mid()) / spot.Mid() * 10000
+}
+
+// BasisOption configures LoadBasis.
+type BasisOption func(c *basisConfig)
+
+type basisConfig struct {
+	spot  Market
+	perp  Market
+	quote string
+	opts  []Option
+}
+
+// WithBasisMarkets sets the spot and the perpetual futures markets of the basis,
+// MarketBinance and MarketBinanceUsdsFutures by default.
+func WithBasisMarkets(spot Market, perp Market) BasisOption {
+	return func(c *basisConfig) {
+		c.spot, c.perp = spot, perp
+	}
+}
+
+// WithBasisQuote sets the quote currency the base asset is loaded against in both markets, USDT by default.
+func WithBasisQuote(quote string) BasisOption {
+	return func(c *basisConfig) {
+		c.quote = quote
+	}
+}
+
+// WithBasisLoaderOptions sets the options of the depth loaders of both markets, like WithProgress or WithDataDir.
+func WithBasisLoaderOptions(opts ...Option) BasisOption {
+	return func(c *basisConfig) {
+		c.opts = append(c.opts, opts...)
+	}
+}
+
+// LoadBasis loads the base asset, like BTC, from the spot and the perpetual futures markets,
+// and returns the per-minute basis of the perp over the spot, in basis points.
+func LoadBasis(base string, startDate time.Time, endDate time.Time, opts ...BasisOption) []float64 {
+	c := basisConfig{spot: MarketBinance, perp: MarketBinanceUsdsFutures, quote: "USDT"}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	pair := Pair(base + "-" + c.quote)
+	return LoadPairBasis(c.spot, pair, c.perp, pair, startDate, endDate, c.opts...)
+}
+
+// LoadPairBasis loads the spot pair from the spot market and the perp pair from the perpetual futures market,
+// and returns the per-minute basis between them, in basis points. Unlike LoadBasis, the pairs may differ,
+// for example a BUSD spot pair against a USDT perp.
+// The series is as long as the shorter of the two loaded series. The options are the ones of both depth loaders.
+func LoadPairBasis(spotMarket Market, spotPair Pair, perpMarket Market, perpPair Pair, startDate time.Time, endDate time.Time, opts ...Option) []float64 {
+	spot := NewCCDepthLoader(spotMarket, opts...)
+	perp := NewCCDepthLoader(perpMarket, opts...)
+	spot.Load([]Pair{spotPair}, startDate, endDate)
+	perp.Load([]Pair{perpPair}, startDate, endDate)
+
+	length := spot.length(spotPair)
+	if perp.length(perpPair) < length {
+		length = perp.length(perpPair)
+	}
+	basis := make([]float64, length)
+	for i := range basis {
+		basis[i] = Basis(spot.recordAt(spotPair, i), perp.recordAt(perpPair, i))
+	}
+	return basis
+}
@@ -0,0 +1,364 @@
+package depth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// blocksHeader starts the manifest of a dataset stored in blocks.
+const blocksHeader = "#blocks"
+
+// WithBlocks stores each day of a pair in a content-addressed block, a file named by the SHA-256 of its values
+// in data/<market>/blocks/, instead of the rows of the depth data file. The time range is a manifest referencing
+// the blocks of its pairs and days, <start>_<end>_depth.blocks:
+//
+//	#blocks
+//	#fields,bid_price,bid_size,ask_price,ask_size
+//	<Pair>,<day>,<block hash>
+//	...
+//
+// The identical days are stored once, and the days referenced by the manifests of other time ranges
+// with the same schema are not downloaded again. The manifests have no versions or index sidecars.
+func WithBlocks() Option {
+	return func(l *CCDepthLoader) {
+		l.blocks = true
+	}
+}
+
+func (l *CCDepthLoader) blocksDir() string {
+	return filepath.Join(l.marketDir(), "blocks")
+}
+
+func blockPath(dir string, hash string) string {
+	return filepath.Join(dir, hash[:2], hash)
+}
+
+func manifestPath(startDate time.Time, endDate time.Time, dir string) string {
+	return filepath.Join(dir, strings.TrimSuffix(rangeFileName(startDate, endDate), ".csv")+".blocks")
+}
+
+// blockManifest maps the pairs and days of a time range to their blocks.
+type blockManifest struct {
+	schema Schema
+	blocks map[Pair]map[string]string
+}
+
+func (m *blockManifest) block(pair Pair, day time.Time) string {
+	return m.blocks[pair][day.Format("2006-01-02")]
+}
+
+func (m *blockManifest) add(pair Pair, day time.Time, hash string) {
+	if m.blocks[pair] == nil {
+		m.blocks[pair] = make(map[string]string)
+	}
+	m.blocks[pair][day.Format("2006-01-02")] = hash
+}
+
+// readManifest reads the manifest. It returns nil if it does not exist.
+func readManifest(path string) (*blockManifest, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	manifest := &blockManifest{schema: DefaultSchema, blocks: make(map[Pair]map[string]string)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if schema := parseSchemaHeader(line); schema != nil {
+			manifest.schema = schema
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := splitCSV(line)
+		if err != nil || len(fields) != 3 {
+			return nil, fmt.Errorf("%s is corrupted: %q", path, line)
+		}
+		day, err := time.Parse("2006-01-02", fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s is corrupted: %w", path, err)
+		}
+		manifest.add(Pair(fields[0]), day, fields[2])
+	}
+	return manifest, scanner.Err()
+}
+
+// sharedBlocks returns the blocks referenced by the other manifests of the market with the loader schema.
+func (l *CCDepthLoader) sharedBlocks(dir string, except string) *blockManifest {
+	shared := &blockManifest{schema: l.schema, blocks: make(map[Pair]map[string]string)}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.blocks"))
+	if err != nil {
+		panic(err)
+	}
+	for _, path := range paths {
+		if path == except {
+			continue
+		}
+		manifest, err := readManifest(path)
+		if err != nil {
+			panic(err)
+		}
+		if !manifest.schema.Equal(l.schema) {
+			continue
+		}
+		for pair, days := range manifest.blocks {
+			for day, hash := range days {
+				if shared.blocks[pair] == nil {
+					shared.blocks[pair] = make(map[string]string)
+				}
+				shared.blocks[pair][day] = hash
+			}
+		}
+	}
+	return shared
+}
+
+// writeBlock writes the values of a day into their block, unless it already exists, and returns its hash.
+func writeBlock(perms filePerms, dir string, values []string) (string, error) {
+	content := []byte(strings.Join(values, ","))
+	sum := sha256.Sum256(content)
+	hash := hex.EncodeToString(sum[:])
+	path := blockPath(dir, hash)
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+	if err := perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return "", err
+	}
+	// write the block atomically, so that a concurrent load never reads a partial one
+	tmp := path + ".tmp"
+	if err := perms.writeFile(tmp, content); err != nil {
+		return "", err
+	}
+	return hash, replaceFile(tmp, path)
+}
+
+// readBlock reads the values of a block, checking its hash with SelfCheckFull.
+func (l *CCDepthLoader) readBlock(dir string, hash string) []string {
+	content, err := os.ReadFile(blockPath(dir, hash))
+	if err != nil {
+		panic(err)
+	}
+	if l.selfCheck == SelfCheckFull {
+		if err := checkBlock(hash, content); err != nil {
+			panic(err)
+		}
+	}
+	if len(content) == 0 {
+		return nil
+	}
+	return strings.Split(string(content), ",")
+}
+
+// loadBlocks is the Load of a loader storing the days in blocks, see WithBlocks.
+func (l *CCDepthLoader) loadBlocks(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string {
+	dir := l.marketDir()
+	blocks := l.blocksDir()
+	path := manifestPath(startDate, endDate, dir)
+	l.startDate, l.endDate = startDate, endDate
+
+	manifest, err := readManifest(path)
+	if err != nil {
+		panic(err)
+	}
+	exists := manifest != nil
+	if !exists {
+		manifest = &blockManifest{schema: l.schema, blocks: make(map[Pair]map[string]string)}
+	} else if !manifest.schema.Equal(l.schema) {
+		panic("file schema " + manifest.schema.String() + " does not match the loader schema " + l.schema.String())
+	}
+	if l.readOnly && !exists {
+		panic(fmt.Errorf("%w: %s does not exist", ErrReadOnly, path))
+	}
+
+	pairsToLoad := pairs
+	if len(pairsToLoad) == 0 {
+		pairsToLoad = defaultPairs
+	}
+	var days []time.Time
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days = append(days, date)
+	}
+
+	var bad map[Pair][]time.Time
+	if l.refetchBad && !l.readOnly {
+		bad = l.badDays(startDate, endDate)
+	}
+	var shared *blockManifest
+	var missing []Pair
+	var refs []string
+	for _, pair := range pairsToLoad {
+		if l.records[pair] != nil || l.values[pair] != nil || l.hasSeries(pair) {
+			continue
+		}
+		var toDownload []time.Time
+		for _, day := range days {
+			if containsDay(bad[pair], day) {
+				toDownload = append(toDownload, day)
+				continue
+			}
+			if manifest.block(pair, day) != "" {
+				continue
+			}
+			if shared == nil && !l.readOnly {
+				shared = l.sharedBlocks(dir, path)
+			}
+			if hash := shared.block(pair, day); hash != "" && !l.readOnly {
+				manifest.add(pair, day, hash)
+				refs = append(refs, joinCSV(pair.String(), day.Format("2006-01-02"), hash))
+				continue
+			}
+			toDownload = append(toDownload, day)
+		}
+		if len(toDownload) > 0 && l.readOnly {
+			missing = append(missing, pair)
+			continue
+		}
+		downloaded, failures := l.downloadDays(pair, toDownload, nil)
+		// the days not downloaded after the load was cancelled are not referenced, see LoadContext
+		if l.context().Err() != nil {
+			break
+		}
+		for i, values := range downloaded {
+			if failedOn(failures, toDownload[i]) {
+				continue
+			}
+			values = l.schema.project(values)
+			hash, err := writeBlock(l.perms, blocks, values)
+			if err != nil {
+				panic(err)
+			}
+			manifest.add(pair, toDownload[i], hash)
+			refs = append(refs, joinCSV(pair.String(), toDownload[i].Format("2006-01-02"), hash))
+			if containsDay(bad[pair], toDownload[i]) && len(values) > 0 {
+				if err := l.Restore(pair, toDownload[i]); err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		// the pair is not loaded with its failed days, the others are stored for the next load
+		if len(failures) > 0 {
+			l.failures = append(l.failures, failures...)
+			continue
+		}
+
+		dayValues := make([][]string, len(days))
+		for i, day := range days {
+			dayValues[i] = l.readBlock(blocks, manifest.block(pair, day))
+		}
+		record := slices.Concat(l.padDays(dayValues, l.schema.Width())...)
+		if len(record) > 0 {
+			l.setRecords(pair, record)
+		}
+	}
+	if len(pairs) > 0 && len(missing) > 0 {
+		panic(fmt.Errorf("%w: %s has no data for %s", ErrReadOnly, path, slices.Join(missing, ", ")))
+	}
+
+	if len(refs) > 0 {
+		if err := appendManifest(l.perms, path, blocksHeader, l.schema, exists, refs); err != nil {
+			panic(err)
+		}
+		_, _ = fmt.Fprintln(l.progress, "Depth blocks referenced in", path)
+	}
+	l.canceled()
+	return l.loaded()
+}
+
+func containsDay(days []time.Time, day time.Time) bool {
+	for _, d := range days {
+		if d.Equal(day) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendManifest appends the lines to the manifest, writing its header and the schema first if it doesn't exist.
+func appendManifest(perms filePerms, path string, header string, schema Schema, exists bool, refs []string) error {
+	if err := perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	file, err := perms.openAppend(path)
+	if err != nil {
+		return err
+	}
+	content := strings.Join(refs, "\n") + "\n"
+	if !exists {
+		content = header + "\n" + schema.header() + "\n" + content
+	}
+	if _, err = file.WriteString(content); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// blockGracePeriod is the age of the blocks that CollectGarbage may remove, so that it never removes
+// the blocks being written by a concurrent load, before their manifest references them.
+const blockGracePeriod = time.Hour
+
+// CollectGarbage removes the blocks of the market that are no longer referenced by any manifest, see WithBlocks,
+// and returns their paths. The blocks written in the last hour are kept, as a concurrent load may not have
+// referenced them yet. With dryRun, it only returns the paths of the blocks it would remove.
+func (l *CCDepthLoader) CollectGarbage(dryRun bool) ([]string, error) {
+	if l.readOnly && !dryRun {
+		return nil, ErrReadOnly
+	}
+	manifests, err := filepath.Glob(filepath.Join(l.marketDir(), "*.blocks"))
+	if err != nil {
+		return nil, err
+	}
+	referenced := make(map[string]bool)
+	for _, path := range manifests {
+		manifest, err := readManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, days := range manifest.blocks {
+			for _, hash := range days {
+				referenced[hash] = true
+			}
+		}
+	}
+
+	blocks, err := filepath.Glob(filepath.Join(l.blocksDir(), "*", "*"))
+	if err != nil {
+		return nil, err
+	}
+	var removed []string
+	for _, path := range blocks {
+		if referenced[filepath.Base(path)] {
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			return removed, err
+		}
+		if time.Since(info.ModTime()) < blockGracePeriod {
+			continue
+		}
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				return removed, err
+			}
+			// remove the directory once it has no blocks left
+			_ = os.Remove(filepath.Dir(path))
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
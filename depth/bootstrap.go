@@ -0,0 +1,100 @@
+package depth
+
+import (
+	"math"
+	"math/rand"
+)
+
+// BootstrapModel is the block resampling of the minutes of the loaded time range, see Bootstrap.
+type BootstrapModel struct {
+	// BlockLength is the number of consecutive minutes of each resampled block, like 60. The longer the blocks,
+	// the more of the autocorrelation of the minutes is kept, and the fewer the distinct resamples.
+	BlockLength int
+	// SameTimeOfDay draws each block from the same time of day as the block it replaces, on a random day,
+	// so that the intraday seasonality, like the spreads widening at night, is kept too.
+	SameTimeOfDay bool
+	// Stitch scales the prices of each block, so that its first mid price is the last mid price before it,
+	// instead of jumping between the price levels of the days the blocks are drawn from. The sizes are kept.
+	Stitch bool
+}
+
+// Bootstrap returns a copy of the loaded pairs with their minutes resampled in blocks of consecutive minutes,
+// drawn with replacement from the loaded time range, so that the results of a backtest can be tested
+// for their statistical significance against many resampled histories, like with the seeds from 1 to 1000.
+// The blocks keep the autocorrelation of the minutes within them, and all the pairs are resampled
+// with the same blocks, so that their correlation is kept too. A resample only depends on the seed.
+// The copy is a loader of its own, like the one of Perturb, its minutes keep the dates of the time range.
+// It panics if the block length is not positive.
+func (l *CCDepthLoader) Bootstrap(seed int64, model BootstrapModel) *CCDepthLoader {
+	if model.BlockLength <= 0 {
+		panic("the block length must be positive")
+	}
+	grid := l.gridLength(l.loadedPairs())
+	rng := rand.New(rand.NewSource(seed))
+	var starts []int
+	for minute := 0; minute < grid; minute += model.BlockLength {
+		starts = append(starts, model.blockStart(rng, minute, grid))
+	}
+	return l.copyOf(func(pair Pair) []Record {
+		length := l.length(pair)
+		records := make([]Record, 0, length)
+		lastMid := math.NaN()
+		for _, start := range starts {
+			block := make([]Record, 0, model.BlockLength)
+			for k := 0; k < model.BlockLength && len(records)+len(block) < length; k++ {
+				if start+k < length {
+					block = append(block, l.recordAt(pair, start+k))
+				} else {
+					nan := math.NaN()
+					block = append(block, Record{BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan})
+				}
+			}
+			if model.Stitch {
+				stitch(block, lastMid)
+			}
+			if mid := lastMidOf(block); !math.IsNaN(mid) {
+				lastMid = mid
+			}
+			records = append(records, block...)
+		}
+		return records
+	})
+}
+
+// blockStart draws the first minute of the block resampled at the minute of the grid of minutes.
+func (m BootstrapModel) blockStart(rng *rand.Rand, minute int, grid int) int {
+	last := grid - m.BlockLength
+	if last <= 0 {
+		return 0
+	}
+	if timeOfDay := minute % (24 * 60); m.SameTimeOfDay && timeOfDay <= last {
+		days := (last-timeOfDay)/(24*60) + 1
+		return rng.Intn(days)*24*60 + timeOfDay
+	}
+	return rng.Intn(last + 1)
+}
+
+// stitch scales the prices of the records, so that the mid price of the first one is the mid price,
+// keeping them if either of the mid prices is NaN.
+func stitch(records []Record, mid float64) {
+	if len(records) == 0 {
+		return
+	}
+	ratio := mid / records[0].Mid()
+	if math.IsNaN(ratio) || math.IsInf(ratio, 0) || ratio <= 0 {
+		return
+	}
+	for i := range records {
+		records[i].scalePrices(ratio)
+	}
+}
+
+// lastMidOf returns the mid price of the last record with one, NaN if none has.
+func lastMidOf(records []Record) float64 {
+	for i := len(records) - 1; i >= 0; i-- {
+		if mid := records[i].Mid(); !math.IsNaN(mid) {
+			return mid
+		}
+	}
+	return math.NaN()
+}
@@ -0,0 +1,178 @@
+package depth
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ManifestFile is the name of the manifest written next to the exported parts.
+const ManifestFile = "manifest.json"
+
+// Manifest lists the parts of a chunked export.
+type Manifest struct {
+	Parts []ManifestPart `json:"parts"`
+}
+
+// ManifestPart describes a single gzipped part of a chunked export.
+type ManifestPart struct {
+	File  string    `json:"file"`
+	Bytes int64     `json:"bytes"`
+	Rows  int       `json:"rows"`
+	Pairs []Pair    `json:"pairs"`
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// ExportChunks writes the loaded records of the given pairs into the directory as gzipped parts
+// (part-00001.csv.gz, part-00002.csv.gz, ...) of about maxBytes each, and a manifest.json listing them.
+// Each part has the same format as Export, including the CSV header. A part can exceed maxBytes by the size of
+// a single compressed block, as the size is checked after each written row.
+// On an error, the part being written is removed, the completed parts are left in the directory, without a manifest.
+func (l *CCDepthLoader) ExportChunks(dir string, pairs []Pair, maxBytes int64, opts ...ExportOption) (Manifest, error) {
+	manifest := Manifest{}
+	if maxBytes <= 0 {
+		return manifest, fmt.Errorf("the part size must be positive, got %d", maxBytes)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return manifest, err
+	}
+
+	var part *chunkWriter
+	closePart := func() error {
+		if part == nil {
+			return nil
+		}
+		info, err := part.close()
+		if err != nil {
+			part.abort()
+			part = nil
+			return err
+		}
+		manifest.Parts = append(manifest.Parts, info)
+		part = nil
+		return nil
+	}
+
+	config := newExportConfig(opts)
+	err := l.exportRows(pairs, config, func(t time.Time, pair Pair, row []string) error {
+		if part == nil {
+			name := fmt.Sprintf("part-%05d.%s.gz", len(manifest.Parts)+1, config.format)
+			var err error
+			if part, err = newChunkWriter(filepath.Join(dir, name), name, config); err != nil {
+				return err
+			}
+		}
+		if err := part.write(t, pair, row); err != nil {
+			return err
+		}
+		if part.file.n >= maxBytes {
+			return closePart()
+		}
+		return nil
+	})
+	if err != nil {
+		if part != nil {
+			part.abort()
+		}
+		return manifest, err
+	}
+	if err = closePart(); err != nil {
+		return manifest, err
+	}
+
+	content, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return manifest, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, ManifestFile), content, 0644)
+}
+
+// chunkWriter writes a single gzipped part and collects its manifest entry.
+type chunkWriter struct {
+	file   *countingFile
+	gz     *gzip.Writer
+	rows   rowWriter
+	info   ManifestPart
+	inPart map[Pair]bool
+}
+
+func newChunkWriter(path string, name string, config *exportConfig) (*chunkWriter, error) {
+	file, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	counting := &countingFile{File: file}
+	gz := gzip.NewWriter(counting)
+	rows, err := newRowWriter(gz, config)
+	if err != nil {
+		_ = file.Close()
+		_ = os.Remove(path)
+		return nil, err
+	}
+	return &chunkWriter{
+		file:   counting,
+		gz:     gz,
+		rows:   rows,
+		info:   ManifestPart{File: name},
+		inPart: make(map[Pair]bool),
+	}, nil
+}
+
+func (w *chunkWriter) write(t time.Time, pair Pair, row []string) error {
+	if err := w.rows.Write(row); err != nil {
+		return err
+	}
+	// flush the buffered row into the gzip writer, so that the file size reflects the written rows
+	if err := w.rows.Flush(); err != nil {
+		return err
+	}
+	if !w.inPart[pair] {
+		w.inPart[pair] = true
+		w.info.Pairs = append(w.info.Pairs, pair)
+	}
+	if w.info.Rows == 0 || t.Before(w.info.Start) {
+		w.info.Start = t
+	}
+	if t.After(w.info.End) {
+		w.info.End = t
+	}
+	w.info.Rows++
+	return nil
+}
+
+func (w *chunkWriter) close() (ManifestPart, error) {
+	if err := w.rows.Flush(); err != nil {
+		return w.info, err
+	}
+	if err := w.gz.Close(); err != nil {
+		return w.info, err
+	}
+	if err := w.file.Close(); err != nil {
+		return w.info, err
+	}
+	w.info.Bytes = w.file.n
+	return w.info, nil
+}
+
+// abort closes the part and removes its incomplete file.
+func (w *chunkWriter) abort() {
+	_ = w.gz.Close()
+	_ = w.file.Close()
+	_ = os.Remove(w.file.Name())
+}
+
+// countingFile counts the bytes written to the file.
+type countingFile struct {
+	*os.File
+	n int64
+}
+
+func (f *countingFile) Write(p []byte) (int, error) {
+	n, err := f.File.Write(p)
+	f.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,79 @@
+package depth
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// WithHTTPClient sets the HTTP client of the requests to the crypto-chassis API and its archives,
+// like one with a timeout or a proxy, http.DefaultClient by default.
+// It panics if the client is nil.
+func WithHTTPClient(client *http.Client) Option {
+	if client == nil {
+		panic("the HTTP client must not be nil")
+	}
+	return func(l *CCDepthLoader) {
+		l.client = client
+	}
+}
+
+// WithRateLimit limits the HTTP requests to the given number per second, shared by the concurrent downloads,
+// so that a long backfill stays below the rate limit of the API, instead of being slowed down by its
+// "Too many requests" responses. The requests are not limited by default.
+// It panics if the rate is not positive.
+func WithRateLimit(requestsPerSecond float64) Option {
+	if !(requestsPerSecond > 0) {
+		panic("the rate limit must be positive")
+	}
+	return func(l *CCDepthLoader) {
+		l.limiter = &rateLimiter{interval: time.Duration(float64(time.Second) / requestsPerSecond)}
+	}
+}
+
+// rateLimiter spaces the requests by its interval.
+type rateLimiter struct {
+	interval time.Duration
+	mu       sync.Mutex
+	// next is the time of the next request
+	next time.Time
+}
+
+// reserve returns how long to wait before the next request.
+func (r *rateLimiter) reserve() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	if r.next.Before(now) {
+		r.next = now
+	}
+	wait := r.next.Sub(now)
+	r.next = r.next.Add(r.interval)
+	return wait
+}
+
+// get sends a GET request with the context, once the rate limit allows it, counting the bytes of the response
+// in the stats of the context, see withDayStats.
+func (l *CCDepthLoader) get(ctx context.Context, url string) (*http.Response, error) {
+	if l.limiter != nil {
+		if wait := l.limiter.reserve(); wait > 0 {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, ctx.Err()
+			}
+		}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := l.client.Do(req)
+	if stats := dayStatsOf(ctx); stats != nil && err == nil {
+		resp.Body = countingBody{resp.Body, stats}
+	}
+	return resp, err
+}
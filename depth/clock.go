@@ -0,0 +1,108 @@
+package depth
+
+import (
+	"math"
+	"time"
+)
+
+// Instrument is a pair of a market, like BTC-USDT of MarketBinanceUsdsFutures.
+type Instrument struct {
+	Market Market
+	Pair   Pair
+}
+
+// Clock drives the loaders of several markets from the same minute, so that a portfolio or a basis strategy reads
+// the records of all its instruments, like BTC-USDT and ETH-USDT spot and BTC-USDT perpetual futures, at the same time:
+//
+//	clock := depth.NewClock(depth.NewCCDepthLoader(depth.MarketBinance), depth.NewCCDepthLoader(depth.MarketBinanceUsdsFutures))
+//	clock.Load([]depth.Instrument{{depth.MarketBinance, "BTC-USDT"}, {depth.MarketBinanceUsdsFutures, "BTC-USDT"}}, start, end)
+//	basis := depth.Basis(clock.GetDepth(depth.MarketBinance, "BTC-USDT"), clock.GetDepth(depth.MarketBinanceUsdsFutures, "BTC-USDT"))
+//	clock.Tick()
+//
+// Each Tick moves the cursor of every loader to the minute of the clock, feeding their consumers, see WithConsumer,
+// so that their lookback analytics, like RollingVol, read the same minute too. The loaders are used
+// by the clock only, moving their cursors with their own Tick breaks the alignment.
+type Clock struct {
+	loaders map[Market]*CCDepthLoader
+	// time is the minute of the records of GetDepth
+	time time.Time
+}
+
+// NewClock returns a Clock of the loaders, each of another market. It panics if two loaders are of the same market,
+// their pairs should be loaded by the same loader.
+func NewClock(loaders ...*CCDepthLoader) *Clock {
+	c := &Clock{loaders: make(map[Market]*CCDepthLoader, len(loaders))}
+	for _, l := range loaders {
+		if _, ok := c.loaders[l.market]; ok {
+			panic("the clock has two loaders of market " + string(l.market))
+		}
+		c.loaders[l.market] = l
+	}
+	return c
+}
+
+// Load loads the instruments for the time range, each by the loader of its market, and starts the clock
+// at the first minute all the loaders have a record for, past their warmup, see WithWarmup.
+// The loaders of no instrument are not loaded.
+// It panics if no loader is of the market of an instrument, and on the errors of Load.
+func (c *Clock) Load(instruments []Instrument, startDate time.Time, endDate time.Time) {
+	pairs := make(map[Market][]Pair)
+	for _, instrument := range instruments {
+		if _, ok := c.loaders[instrument.Market]; !ok {
+			panic("the clock has no loader of market " + string(instrument.Market))
+		}
+		pairs[instrument.Market] = append(pairs[instrument.Market], instrument.Pair)
+	}
+	c.time = startDate
+	for market, l := range c.loaders {
+		if len(pairs[market]) == 0 {
+			continue
+		}
+		l.Load(pairs[market], startDate, endDate)
+		if t := l.minuteTime(l.index); t.After(c.time) {
+			c.time = t
+		}
+	}
+	c.sync()
+}
+
+// Tick moves the clock, and the cursors of its loaders, to the next minute.
+// It returns false once the cursors of all the loaded loaders are past their end, see CCDepthLoader.Done.
+func (c *Clock) Tick() bool {
+	c.time = c.time.Add(time.Minute)
+	c.sync()
+	for _, l := range c.loaders {
+		if !l.startDate.IsZero() && !l.Done() {
+			return true
+		}
+	}
+	return false
+}
+
+// sync moves the cursors of the loaders behind the clock to its minute, skipping those not loaded.
+func (c *Clock) sync() {
+	for _, l := range c.loaders {
+		if l.startDate.IsZero() {
+			continue
+		}
+		for l.minuteTime(l.index).Before(c.time) {
+			l.Tick()
+		}
+	}
+}
+
+// Time returns the minute of the records of GetDepth.
+func (c *Clock) Time() time.Time {
+	return c.time
+}
+
+// GetDepth returns the record of the pair of the market at the minute of the clock,
+// NaN if the loader of the market has no record of the pair for it, or if the clock has no loader of the market.
+func (c *Clock) GetDepth(market Market, pair Pair) Record {
+	l, ok := c.loaders[market]
+	if ok && l.minuteTime(l.index).Equal(c.time) && l.index < l.length(pair) {
+		return l.GetDepth(pair)
+	}
+	nan := math.NaN()
+	return Record{pair: pair, BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan}
+}
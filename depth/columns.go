@@ -0,0 +1,44 @@
+package depth
+
+import "time"
+
+// Columns are the loaded records of a pair in columnar form, one element per minute in each column.
+type Columns struct {
+	Time     []time.Time
+	BidPrice []float64
+	BidSize  []float64
+	AskPrice []float64
+	AskSize  []float64
+}
+
+// ColumnSource provides the records of a pair in columnar form. It is implemented by CCDepthLoader,
+// and is what the integration packages, like arrowdepth, depend on.
+type ColumnSource interface {
+	Columns(pair Pair) Columns
+}
+
+// Len returns the number of minutes in the columns.
+func (c Columns) Len() int {
+	return len(c.Time)
+}
+
+// Columns returns the loaded records of the pair in columnar form, for columnar formats like Arrow.
+func (l *CCDepthLoader) Columns(pair Pair) Columns {
+	length := l.length(pair)
+	columns := Columns{
+		Time:     make([]time.Time, length),
+		BidPrice: make([]float64, length),
+		BidSize:  make([]float64, length),
+		AskPrice: make([]float64, length),
+		AskSize:  make([]float64, length),
+	}
+	for i := 0; i < length; i++ {
+		record := l.recordAt(pair, i)
+		columns.Time[i] = l.minuteTime(i)
+		columns.BidPrice[i] = record.BidPrice
+		columns.BidSize[i] = record.BidSize
+		columns.AskPrice[i] = record.AskPrice
+		columns.AskSize[i] = record.AskSize
+	}
+	return columns
+}
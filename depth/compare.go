@@ -0,0 +1,51 @@
+package depth
+
+import (
+	"math"
+	"time"
+)
+
+// Divergence is a minute where two sources of a pair disagree, see Compare.
+type Divergence struct {
+	Pair Pair
+	Time time.Time
+	A, B Record
+	// MidBps is the difference of the mid price of B over the one of A, in basis points of the one of A.
+	MidBps float64
+	// Missing is set if one of the sources has no record for the minute, or a bad day, see Tombstone.
+	Missing bool
+}
+
+// Compare walks the records of the pairs, all pairs loaded by a if none are given, loaded by two loaders
+// from different sources, like two vendors of the same market, minute by minute, and calls the emit function
+// with the minutes where the mid prices differ by more than the threshold, in basis points, or where one
+// of the sources has no record. The minutes are matched by time, so that the loaders may have different
+// time ranges, and only the minutes loaded by a are compared. It stops at the first error of the emit function.
+func Compare(a *CCDepthLoader, b *CCDepthLoader, pairs []Pair, threshold float64, emit func(Divergence) error) error {
+	if len(pairs) == 0 {
+		pairs = a.loadedPairs()
+	}
+	// offset is the minute of b at the first minute of a
+	offset := int(a.startDate.Sub(b.startDate).Minutes())
+	for _, pair := range pairs {
+		for minute := 0; minute < a.length(pair); minute++ {
+			d := Divergence{Pair: pair, Time: a.minuteTime(minute).UTC(), A: a.recordAt(pair, minute)}
+			if other := minute + offset; other >= 0 && other < b.length(pair) {
+				d.B = b.recordAt(pair, other)
+			} else {
+				nan := math.NaN()
+				d.B = Record{pair: pair, BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan}
+			}
+			d.Missing = d.A.excluded() || d.B.excluded()
+			if !d.Missing {
+				d.MidBps = (d.B.Mid() - d.A.Mid()) / d.A.Mid() * 10000
+			}
+			if d.Missing || math.Abs(d.MidBps) > threshold {
+				if err := emit(d); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
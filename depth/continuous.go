@@ -0,0 +1,131 @@
+package depth
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+// Contract is a dated futures contract, like BTC-24JUN22 on Deribit.
+type Contract struct {
+	Pair   Pair
+	Expiry time.Time
+}
+
+// RollRule returns the time at which a continuous series rolls from the current contract to the next one.
+type RollRule func(current Contract, next Contract) time.Time
+
+// RollDaysBeforeExpiry rolls to the next contract at the start of the day, the given number of days
+// before the current contract expires.
+func RollDaysBeforeExpiry(days int) RollRule {
+	return func(current Contract, next Contract) time.Time {
+		return current.Expiry.UTC().Truncate(24*time.Hour).AddDate(0, 0, -days)
+	}
+}
+
+// Adjustment is the method used to back-adjust prices of the earlier contracts at each roll.
+type Adjustment int
+
+const (
+	// AdjustNone stitches the contracts without changing their prices.
+	AdjustNone Adjustment = iota
+	// AdjustDifference shifts the earlier prices by the mid price gap between the contracts at the roll.
+	AdjustDifference
+	// AdjustRatio scales the earlier prices by the mid price ratio between the contracts at the roll.
+	AdjustRatio
+)
+
+// RollSchedule describes how dated futures contracts are stitched into a continuous series.
+type RollSchedule struct {
+	Contracts  []Contract
+	Rule       RollRule
+	Adjustment Adjustment
+}
+
+// RollSegment is the time range during which a contract is used in the continuous series.
+type RollSegment struct {
+	Contract  Contract
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+// Segments returns the contracts used for each part of the [startDate, endDate) time range.
+// The contracts are used in the order of their expiry, and the roll times are truncated to the day,
+// since depth data is loaded by days.
+func (s RollSchedule) Segments(startDate time.Time, endDate time.Time) []RollSegment {
+	contracts := append([]Contract(nil), s.Contracts...)
+	sort.Slice(contracts, func(i, j int) bool {
+		return contracts[i].Expiry.Before(contracts[j].Expiry)
+	})
+	rule := s.Rule
+	if rule == nil {
+		rule = RollDaysBeforeExpiry(0)
+	}
+
+	var segments []RollSegment
+	from := startDate
+	for i, contract := range contracts {
+		to := endDate
+		if i < len(contracts)-1 {
+			roll := rule(contract, contracts[i+1]).Truncate(24 * time.Hour)
+			if roll.Before(to) {
+				to = roll
+			}
+		}
+		if to.After(from) {
+			segments = append(segments, RollSegment{Contract: contract, StartDate: from, EndDate: to})
+			from = to
+		}
+		if !from.Before(endDate) {
+			break
+		}
+	}
+	return segments
+}
+
+// LoadContinuous loads each contract of the schedule for its segment of the time range,
+// and returns the stitched 1 minute records, back-adjusted according to the schedule.
+// Each contract after the first is also loaded for the day before its segment, so that the prices of both
+// contracts are compared at the same minute, the last one before the roll.
+// It panics if a contract does not cover every minute of its segment, as the stitched series
+// would not be aligned with the time range anymore. The options are the ones of the depth loader of each contract.
+func LoadContinuous(market Market, schedule RollSchedule, startDate time.Time, endDate time.Time, opts ...Option) []Record {
+	var records []Record
+	for i, segment := range schedule.Segments(startDate, endDate) {
+		pair := segment.Contract.Pair
+		from := segment.StartDate
+		if i > 0 {
+			from = from.AddDate(0, 0, -1)
+		}
+		loader := NewCCDepthLoader(market, opts...)
+		loader.Load([]Pair{pair}, from, segment.EndDate)
+
+		overlap := int(segment.StartDate.Sub(from).Minutes())
+		expected := overlap + int(segment.EndDate.Sub(segment.StartDate).Minutes())
+		if length := loader.length(pair); length != expected {
+			panic(fmt.Sprintf("contract %s has %d minutes of data for %s - %s, expected %d",
+				pair, length, from.Format("2006-01-02"), segment.EndDate.Format("2006-01-02"), expected))
+		}
+		if i > 0 {
+			backAdjust(records, schedule.Adjustment, records[len(records)-1].Mid(), loader.recordAt(pair, overlap-1).Mid())
+		}
+		for j := overlap; j < expected; j++ {
+			records = append(records, loader.recordAt(pair, j))
+		}
+	}
+	return records
+}
+
+// backAdjust adjusts the prices of the records of the earlier contracts, so that there is no price gap at the roll.
+func backAdjust(records []Record, adjustment Adjustment, prevMid float64, nextMid float64) {
+	for i := range records {
+		switch adjustment {
+		case AdjustDifference:
+			records[i].BidPrice += nextMid - prevMid
+			records[i].AskPrice += nextMid - prevMid
+		case AdjustRatio:
+			records[i].BidPrice *= nextMid / prevMid
+			records[i].AskPrice *= nextMid / prevMid
+		}
+	}
+}
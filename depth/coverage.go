@@ -0,0 +1,146 @@
+package depth
+
+import (
+	"encoding/csv"
+	"io"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Coverage is the state of a day of a pair in the cache of the market, see CoverageMatrix.
+type Coverage string
+
+const (
+	// CoverageCached is a day stored in a depth data file, or in the blocks of a time range, see WithBlocks.
+	CoverageCached Coverage = "cached"
+	// CoverageDownloadable is a day not cached yet, that the vendor has data for.
+	CoverageDownloadable Coverage = "downloadable"
+	// CoverageMissing is a day not cached, that the vendor has no data for.
+	CoverageMissing Coverage = "missing"
+)
+
+// CoverageMatrix returns the coverage of each day of the time range of the pairs, so that the teams sharing
+// a cache can see what still needs to be backfilled. The days of a pair are cached if a file of a time range
+// including them has a row of the pair, or if the blocks of a time range have them. The vendor availability
+// of the other days is probed, see Availability.
+func (l *CCDepthLoader) CoverageMatrix(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]Coverage {
+	cached, err := l.cachedDays()
+	if err != nil {
+		panic(err)
+	}
+	matrix := make(map[Pair][]Coverage, len(pairs))
+	for _, pair := range pairs {
+		var days, toProbe []time.Time
+		for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+			days = append(days, date)
+			if !cached[pair][date.Format("2006-01-02")] {
+				toProbe = append(toProbe, date)
+			}
+		}
+		probes := l.probeDays(pair, toProbe)
+		coverage := make([]Coverage, len(days))
+		for i, date := range days {
+			day := date.Format("2006-01-02")
+			switch {
+			case cached[pair][day]:
+				coverage[i] = CoverageCached
+			case probes[day]:
+				coverage[i] = CoverageDownloadable
+			default:
+				coverage[i] = CoverageMissing
+			}
+		}
+		matrix[pair] = coverage
+	}
+	return matrix
+}
+
+// WriteCoverage writes the coverage of the pairs as CSV, with a row of each pair and a column of each day,
+// see CoverageMatrix:
+//
+//	pair,2022-11-24,2022-11-25,...
+//	BTC-BUSD,cached,downloadable,...
+func (l *CCDepthLoader) WriteCoverage(w io.Writer, pairs []Pair, startDate time.Time, endDate time.Time) error {
+	matrix := l.CoverageMatrix(pairs, startDate, endDate)
+	writer := csv.NewWriter(w)
+	header := []string{"pair"}
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		header = append(header, date.Format("2006-01-02"))
+	}
+	_ = writer.Write(header)
+	for _, pair := range pairs {
+		row := []string{string(pair)}
+		for _, coverage := range matrix[pair] {
+			row = append(row, string(coverage))
+		}
+		_ = writer.Write(row)
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// cachedDays returns the cached days of each pair of the market, from the indexes of the depth data files
+// and the manifests of the blocks.
+func (l *CCDepthLoader) cachedDays() (map[Pair]map[string]bool, error) {
+	cached := make(map[Pair]map[string]bool)
+	add := func(pair Pair, day time.Time) {
+		if cached[pair] == nil {
+			cached[pair] = make(map[string]bool)
+		}
+		cached[pair][day.Format("2006-01-02")] = true
+	}
+	dir := l.marketDir()
+	paths, err := filepath.Glob(filepath.Join(dir, "*_depth.csv"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		startDate, endDate, ok := parseRangeFileName(filepath.Base(path))
+		if !ok {
+			continue
+		}
+		for pair := range l.openIndexOf(path).rows {
+			for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+				add(pair, date)
+			}
+		}
+	}
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.blocks"))
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range manifests {
+		manifest, err := readManifest(path)
+		if err != nil {
+			return nil, err
+		}
+		for pair, blocks := range manifest.blocks {
+			for day := range blocks {
+				date, err := time.Parse("2006-01-02", day)
+				if err != nil {
+					return nil, err
+				}
+				add(pair, date)
+			}
+		}
+	}
+	return cached, nil
+}
+
+// parseRangeFileName parses the time range of a file name, see rangeFileName.
+func parseRangeFileName(name string) (time.Time, time.Time, bool) {
+	start, rest, ok := strings.Cut(name, "_")
+	if !ok || len(rest) < len("2006-01-02") {
+		return time.Time{}, time.Time{}, false
+	}
+	startDate, err := time.Parse("2006-01-02", start)
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	endDate, err := time.Parse("2006-01-02", rest[:len("2006-01-02")])
+	if err != nil {
+		return time.Time{}, time.Time{}, false
+	}
+	return startDate, endDate, true
+}
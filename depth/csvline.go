@@ -0,0 +1,65 @@
+package depth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"strings"
+)
+
+// joinCSV joins the fields into a line of the stored files, without its line break, quoting the fields
+// with a comma, a quote, a line break or a leading space, like encoding/csv, so that a pair name
+// or a field of a future schema can't shift the columns of the line.
+func joinCSV(fields ...string) string {
+	quoted := make([]string, len(fields))
+	for i, field := range fields {
+		quoted[i] = csvField(field)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// csvField returns the field quoted as in a line of joinCSV.
+func csvField(field string) string {
+	if field == "" || !strings.ContainsAny(field, ",\"\r\n") && field[0] != ' ' && field[0] != '\t' {
+		return field
+	}
+	return `"` + strings.ReplaceAll(field, `"`, `""`) + `"`
+}
+
+// splitCSV splits a line of the stored files, written by joinCSV, or without quotes by the earlier versions.
+// It fails with an error wrapping ErrCorrupted on a malformed quote.
+func splitCSV(line string) ([]string, error) {
+	reader := csv.NewReader(strings.NewReader(line))
+	reader.FieldsPerRecord = -1
+	fields, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %q: %v", ErrCorrupted, line, err)
+	}
+	return fields, nil
+}
+
+// firstCSVField returns the first field of a line of joinCSV, and the length of its quoted form in the line,
+// reading only up to the end of the field, as the lines of the pair rows are hundreds of MB long.
+// It returns false if the quote of the field is not closed.
+func firstCSVField(line string) (string, int, bool) {
+	if !strings.HasPrefix(line, `"`) {
+		end := strings.IndexAny(line, ",\r\n")
+		if end < 0 {
+			end = len(line)
+		}
+		return line[:end], end, true
+	}
+	var b strings.Builder
+	for i := 1; i < len(line); i++ {
+		if line[i] != '"' {
+			b.WriteByte(line[i])
+			continue
+		}
+		if i+1 < len(line) && line[i+1] == '"' {
+			b.WriteByte('"')
+			i++
+			continue
+		}
+		return b.String(), i + 1, true
+	}
+	return "", 0, false
+}
@@ -0,0 +1,58 @@
+package depth
+
+import "time"
+
+// Cursor iterates over the loaded time range with its own position, independently of the cursor of the loader
+// and of the other Cursors, to run several strategies over the same loaded data, or to iterate the pairs
+// at different minutes. Unlike the cursor of the loader, it feeds no consumers, see WithConsumer, nor is audited,
+// see WithAudit. The Cursors of a loader may be used concurrently, but not while the loader loads.
+type Cursor struct {
+	loader *CCDepthLoader
+	index  int
+}
+
+// NewCursor returns a Cursor at the first minute of the loaded time range past the warmup, see WithWarmup,
+// where the cursor of the loader starts.
+func (l *CCDepthLoader) NewCursor() *Cursor {
+	return &Cursor{loader: l, index: l.warmup}
+}
+
+// Tick moves the cursor to the next minute. It returns false once the cursor is past the end, see Done.
+func (c *Cursor) Tick() bool {
+	c.index++
+	return !c.Done()
+}
+
+// Rewind moves the cursor back to the minute where it started, see NewCursor.
+func (c *Cursor) Rewind() {
+	c.index = c.loader.warmup
+}
+
+// Done returns true once the cursor is past the last minute of the longest loaded pair, or if no pair is loaded.
+func (c *Cursor) Done() bool {
+	return c.index >= c.loader.Len()
+}
+
+// HasNext returns true if the next Tick moves the cursor to a loaded minute.
+func (c *Cursor) HasNext() bool {
+	return c.Remaining() > 0
+}
+
+// Remaining returns the number of loaded minutes after the one of the cursor, see CCDepthLoader.Len.
+func (c *Cursor) Remaining() int {
+	if remaining := c.loader.Len() - c.index - 1; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// CurrentTime returns the minute of the cursor in the loaded time range, the time of the records of GetDepth.
+func (c *Cursor) CurrentTime() time.Time {
+	return c.loader.minuteTime(c.index)
+}
+
+// GetDepth returns the record of the pair at the minute of the cursor.
+// It panics with an error wrapping ErrOutOfRange if the pair has no record at the minute.
+func (c *Cursor) GetDepth(pair Pair) Record {
+	return c.loader.recordAt(pair, c.index)
+}
@@ -0,0 +1,52 @@
+package depth
+
+import "sort"
+
+// Dataset returns the named dataset of the loader, creating it for the market if it doesn't exist yet,
+// so that a service can keep several time ranges or markets loaded at once, like to serve several backtests,
+// without a process for each of them. A dataset is a loader of its own: it has the options of the loader,
+// shares its rate limit, see WithRateLimit, and has its own time range and cursor, see Load and Tick.
+// The datasets can be created and dropped concurrently, but each dataset is used like a loader,
+// by a single goroutine at a time. It panics if the dataset exists for another market.
+func (l *CCDepthLoader) Dataset(name string, market Market) *CCDepthLoader {
+	l.datasetsMu.Lock()
+	defer l.datasetsMu.Unlock()
+	if dataset, ok := l.datasets[name]; ok {
+		if dataset.market != market {
+			panic("dataset " + name + " is of market " + string(dataset.market) + ", not " + string(market))
+		}
+		return dataset
+	}
+	dataset := NewCCDepthLoader(market, l.opts...)
+	dataset.limiter = l.limiter
+	if l.datasets == nil {
+		l.datasets = make(map[string]*CCDepthLoader)
+	}
+	l.datasets[name] = dataset
+	return dataset
+}
+
+// Datasets returns the names of the datasets of the loader, sorted, see Dataset.
+func (l *CCDepthLoader) Datasets() []string {
+	l.datasetsMu.Lock()
+	defer l.datasetsMu.Unlock()
+	names := make([]string, 0, len(l.datasets))
+	for name := range l.datasets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// DropDataset closes the named dataset, see Close, and removes it from the loader.
+// Dropping a dataset that doesn't exist does nothing.
+func (l *CCDepthLoader) DropDataset(name string) error {
+	l.datasetsMu.Lock()
+	dataset, ok := l.datasets[name]
+	delete(l.datasets, name)
+	l.datasetsMu.Unlock()
+	if !ok {
+		return nil
+	}
+	return dataset.Close()
+}
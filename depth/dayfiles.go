@@ -0,0 +1,252 @@
+package depth
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dayFilesHeader starts the manifest of the days of a market stored in day files.
+const dayFilesHeader = "#days"
+
+// dayManifestFile is the name of the manifest of the day files in the market directory.
+const dayManifestFile = "days.manifest"
+
+// WithDayFiles stores each day of a pair in a gzipped file of its own, data/<market>/<pair>/<yyyy-mm-dd>.csv.gz,
+// with a line of the values of the schema per minute, instead of the rows of a depth data file per time range.
+// The downloaded days are listed in the manifest of the market, data/<market>/days.manifest, with their number
+// of minutes, 0 for the days without vendor data, the last line of a day being its current one:
+//
+//	#days
+//	#fields,bid_price,bid_size,ask_price,ask_size
+//	<Pair>,<day>,<minutes>
+//	...
+//
+// The loads of the time ranges overlapping the loaded ones only download the days not listed yet. All the time ranges
+// of the market share the schema of the manifest. The days have no versions or index sidecars, like the blocks,
+// and WithBlocks takes precedence over WithDayFiles. It is the Store of NewDayFileStore in the market directory,
+// and WithStore takes precedence over it.
+func WithDayFiles() Option {
+	return func(l *CCDepthLoader) {
+		l.dayFiles = true
+	}
+}
+
+// dayManifest maps the pairs and days of a market to their number of minutes.
+type dayManifest struct {
+	schema  Schema
+	minutes map[Pair]map[string]int
+}
+
+// day returns the number of minutes of the day of the pair, and false if it is not downloaded.
+func (m *dayManifest) day(pair Pair, day time.Time) (int, bool) {
+	minutes, ok := m.minutes[pair][day.Format("2006-01-02")]
+	return minutes, ok
+}
+
+func (m *dayManifest) add(pair Pair, day time.Time, minutes int) {
+	if m.minutes[pair] == nil {
+		m.minutes[pair] = make(map[string]int)
+	}
+	m.minutes[pair][day.Format("2006-01-02")] = minutes
+}
+
+// readDayManifest reads the manifest of the day files. It returns nil if it does not exist.
+func readDayManifest(path string) (*dayManifest, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	manifest := &dayManifest{schema: DefaultSchema, minutes: make(map[Pair]map[string]int)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if schema := parseSchemaHeader(line); schema != nil {
+			manifest.schema = schema
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields, err := splitCSV(line)
+		if err != nil || len(fields) != 3 {
+			return nil, fmt.Errorf("%s is corrupted: %q", path, line)
+		}
+		day, err := time.Parse("2006-01-02", fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s is corrupted: %w", path, err)
+		}
+		minutes, err := strconv.Atoi(fields[2])
+		if err != nil {
+			return nil, fmt.Errorf("%s is corrupted: %w", path, err)
+		}
+		manifest.add(Pair(fields[0]), day, minutes)
+	}
+	return manifest, scanner.Err()
+}
+
+// writeDayValues writes the values of a day into its file, a line of the given width per minute.
+func writeDayValues(perms filePerms, path string, values []string, width int) error {
+	var b bytes.Buffer
+	gz := gzip.NewWriter(&b)
+	for i := 0; i < len(values); i += width {
+		if _, err := io.WriteString(gz, strings.Join(values[i:i+width], ",")+"\n"); err != nil {
+			return err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	// write the day atomically, so that a concurrent load never reads a partial one
+	tmp := path + ".tmp"
+	if err := perms.writeFile(tmp, b.Bytes()); err != nil {
+		return err
+	}
+	return replaceFile(tmp, path)
+}
+
+// readDayValues reads the values of the minutes of a day file, checking it has the given number of minutes
+// of the given width.
+func readDayValues(path string, minutes int, width int) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupted, path, err)
+	}
+	content, err := io.ReadAll(gz)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrCorrupted, path, err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != minutes {
+		return nil, fmt.Errorf("%w: %s has %d minutes, not %d", ErrCorrupted, path, len(lines), minutes)
+	}
+	values := make([]string, 0, minutes*width)
+	for _, line := range lines {
+		fields := strings.Split(line, ",")
+		if len(fields) != width {
+			return nil, fmt.Errorf("%w: %s has %d values in a minute, not %d", ErrCorrupted, path, len(fields), width)
+		}
+		values = append(values, fields...)
+	}
+	return values, nil
+}
+
+// DayFileStore is the Store of WithDayFiles, storing each day of a pair in a gzipped file of its own
+// listed by the manifest of its directory. It is safe for concurrent use.
+type DayFileStore struct {
+	dir string
+	// perms are those of the loader of WithDayFiles or WithStore, or the default ones
+	perms filePerms
+	mu    sync.Mutex
+	// manifest lists the stored days, read by Open, and exists once it is written
+	manifest *dayManifest
+	exists   bool
+}
+
+// NewDayFileStore returns the DayFileStore of the directory, the one of a market.
+func NewDayFileStore(dir string) *DayFileStore {
+	return &DayFileStore{dir: dir, perms: defaultPerms}
+}
+
+// path returns the path of the file of the day of the pair.
+func (s *DayFileStore) path(pair Pair, day time.Time) string {
+	return filepath.Join(pairDir(s.dir, pair), day.Format("2006-01-02")+".csv.gz")
+}
+
+// Open reads the manifest of the directory, and fails if it lists the days of another schema.
+func (s *DayFileStore) Open(schema Schema) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	path := filepath.Join(s.dir, dayManifestFile)
+	manifest, err := readDayManifest(path)
+	if err != nil {
+		return err
+	}
+	s.exists = manifest != nil
+	if !s.exists {
+		manifest = &dayManifest{schema: schema, minutes: make(map[Pair]map[string]int)}
+	} else if !manifest.schema.Equal(schema) {
+		return fmt.Errorf("%s schema %s does not match the loader schema %s", path, manifest.schema, schema)
+	}
+	s.manifest = manifest
+	return nil
+}
+
+// Has reports whether the manifest lists the day of the pair.
+func (s *DayFileStore) Has(pair Pair, day time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manifest == nil {
+		return false, errors.New("the day file store is not open")
+	}
+	_, ok := s.manifest.day(pair, day)
+	return ok, nil
+}
+
+// WriteDay writes the file of the day of the pair, none for a day without values, and lists it in the manifest.
+func (s *DayFileStore) WriteDay(pair Pair, day time.Time, values []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manifest == nil {
+		return errors.New("the day file store is not open")
+	}
+	width := s.manifest.schema.Width()
+	if len(values)%width != 0 {
+		return fmt.Errorf("%d values of %s on %s are not minutes of %d values", len(values), pair, day.Format("2006-01-02"), width)
+	}
+	if len(values) > 0 {
+		if err := writeDayValues(s.perms, s.path(pair, day), values, width); err != nil {
+			return err
+		}
+	}
+	entry := joinCSV(pair.String(), day.Format("2006-01-02"), strconv.Itoa(len(values)/width))
+	if err := appendManifest(s.perms, filepath.Join(s.dir, dayManifestFile), dayFilesHeader, s.manifest.schema, s.exists, []string{entry}); err != nil {
+		return err
+	}
+	s.exists = true
+	s.manifest.add(pair, day, len(values)/width)
+	return nil
+}
+
+// ReadRange reads the files of the days of the pair in the time range, failing with an error wrapping ErrCorrupted
+// if one does not have the minutes of the manifest.
+func (s *DayFileStore) ReadRange(pair Pair, startDate time.Time, endDate time.Time) ([][]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.manifest == nil {
+		return nil, errors.New("the day file store is not open")
+	}
+	var days [][]string
+	for day := startDate; day.Before(endDate); day = day.AddDate(0, 0, 1) {
+		var values []string
+		if minutes, _ := s.manifest.day(pair, day); minutes > 0 {
+			var err error
+			if values, err = readDayValues(s.path(pair, day), minutes, s.manifest.schema.Width()); err != nil {
+				return nil, err
+			}
+		}
+		days = append(days, values)
+	}
+	return days, nil
+}
@@ -0,0 +1,100 @@
+package depth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// exchangeInfoURLs are the exchange information endpoints of the Binance markets, with the filters of their symbols.
+var exchangeInfoURLs = map[Market]string{
+	MarketBinance:            "https://api.binance.com/api/v3/exchangeInfo",
+	MarketBinanceUsdsFutures: "https://fapi.binance.com/fapi/v1/exchangeInfo",
+}
+
+// LoadMinNotionals returns the minimum notional of an order of each pair, in the quote currency, from the exchange
+// information of the Binance spot or USDⓈ-M futures market, so that the records with less size at the touch can be
+// told apart, see Record.Dust. The pairs the exchange doesn't list, or without a minimum, are left out.
+// The URL is the one of the exchange information endpoint, like a mirror or a test server, or empty for the default one.
+// The minimums are the current ones, the exchange changes them from time to time.
+func LoadMinNotionals(market Market, pairs []Pair, url string) (map[Pair]float64, error) {
+	if url == "" {
+		url = exchangeInfoURLs[market]
+	}
+	if url == "" {
+		return nil, fmt.Errorf("the minimum notionals are not supported for market %s", market)
+	}
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, string(body))
+	}
+
+	// {"symbols":[{"symbol":"BTCBUSD","filters":[{"filterType":"NOTIONAL","minNotional":"5.00000000"}, ...]}, ...]}
+	var info struct {
+		Symbols []struct {
+			Symbol  string `json:"symbol"`
+			Filters []struct {
+				FilterType  string `json:"filterType"`
+				MinNotional string `json:"minNotional"`
+				// Notional is the minimum of the futures markets
+				Notional string `json:"notional"`
+			} `json:"filters"`
+		} `json:"symbols"`
+	}
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, string(body))
+	}
+	bySymbol := make(map[string]Pair, len(pairs))
+	for _, pair := range pairs {
+		bySymbol[strings.ToUpper(pair.Base()+pair.Quote())] = pair
+	}
+	minNotionals := make(map[Pair]float64)
+	for _, symbol := range info.Symbols {
+		pair, ok := bySymbol[symbol.Symbol]
+		if !ok {
+			continue
+		}
+		for _, filter := range symbol.Filters {
+			if filter.FilterType != "NOTIONAL" && filter.FilterType != "MIN_NOTIONAL" {
+				continue
+			}
+			value := filter.MinNotional
+			if value == "" {
+				value = filter.Notional
+			}
+			if minNotional, err := strconv.ParseFloat(value, 64); err == nil && minNotional > 0 {
+				minNotionals[pair] = minNotional
+			}
+		}
+	}
+	return minNotionals, nil
+}
+
+// Dust checks if a side of the touch is smaller than the minimum notional of an order, in the quote currency,
+// so that the best price can't be traded, see LoadMinNotionals.
+func (r Record) Dust(minNotional float64) bool {
+	return r.BidNotional() < minNotional || r.AskNotional() < minNotional
+}
+
+// DustMinutes returns the minutes of the loaded time range where the touch of the pair is dust, see Record.Dust,
+// skipping the bad days, see Tombstone.
+func (l *CCDepthLoader) DustMinutes(pair Pair, minNotional float64) []int {
+	var minutes []int
+	for minute := 0; minute < l.length(pair); minute++ {
+		if record := l.recordAt(pair, minute); !record.excluded() && record.Dust(minNotional) {
+			minutes = append(minutes, minute)
+		}
+	}
+	return minutes
+}
@@ -0,0 +1,24 @@
+package depth_test
+
+import (
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"io"
+)
+
+func ExampleCCDepthLoader_LoadSyntheticSample() {
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	records := loader.LoadSyntheticSample()
+	fmt.Println(len(records[depth.SyntheticSamplePair])/4, "minutes")
+
+	for minute := 0; minute < 3; minute++ {
+		record := loader.GetDepth(depth.SyntheticSamplePair)
+		fmt.Printf("%s bid %.2f ask %.2f\n", loader.CurrentTime().Format("15:04"), record.BidPrice, record.AskPrice)
+		loader.Tick()
+	}
+	// Output:
+	// 1440 minutes
+	// 00:00 bid 16579.98 ask 16580.02
+	// 00:01 bid 16572.98 ask 16572.99
+	// 00:02 bid 16572.40 ask 16572.41
+}
@@ -0,0 +1,252 @@
+package depth
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ExportOption configures an export of the loaded depth records.
+type ExportOption func(c *exportConfig)
+
+type exportConfig struct {
+	filter     Filter
+	format     Format
+	spreadOnly bool
+	// delimiter and decimal are the field delimiter and the decimal separator of the CSV format, zero by default
+	delimiter rune
+	decimal   rune
+}
+
+// Format is the format of the exported records.
+type Format string
+
+const (
+	// FormatCSV writes a header row and one comma-separated row per record.
+	FormatCSV Format = "csv"
+	// FormatJSONL writes one JSON object per line and record.
+	FormatJSONL Format = "jsonl"
+)
+
+// WithFilter exports only the records matching the filter, see ParseFilter for filter expressions.
+func WithFilter(filter Filter) ExportOption {
+	return func(c *exportConfig) {
+		c.filter = filter
+	}
+}
+
+// WithFormat sets the export format, FormatCSV by default.
+func WithFormat(format Format) ExportOption {
+	return func(c *exportConfig) {
+		c.format = format
+	}
+}
+
+// WithSpreadOnly exports only the spread in basis points of each record, a small fraction of the full records,
+// for monitoring the trading costs:
+//
+//	time,pair,spread_bps
+//	2022-11-24T00:00:00Z,BTC-BUSD,0.06
+func WithSpreadOnly() ExportOption {
+	return func(c *exportConfig) {
+		c.spreadOnly = true
+	}
+}
+
+// WithDelimiter sets the field delimiter of the CSV format, a comma by default, like a semicolon for the spreadsheets
+// of the locales where the comma is the decimal separator, see WithDecimalSeparator.
+func WithDelimiter(delimiter rune) ExportOption {
+	return func(c *exportConfig) {
+		c.delimiter = delimiter
+	}
+}
+
+// WithDecimalSeparator sets the decimal separator of the numbers in the CSV format, a dot by default:
+//
+//	time;pair;bid_price;bid_size;ask_price;ask_size
+//	2022-11-24T00:00:00Z;BTC-BUSD;16544,2;0,5;16544,3;1,2
+//
+// The export fails if it is also the delimiter.
+func WithDecimalSeparator(decimal rune) ExportOption {
+	return func(c *exportConfig) {
+		c.decimal = decimal
+	}
+}
+
+func newExportConfig(opts []ExportOption) *exportConfig {
+	config := &exportConfig{format: FormatCSV}
+	for _, opt := range opts {
+		opt(config)
+	}
+	return config
+}
+
+// exportHeader is the header row of the exported CSV, and the keys of the exported JSON objects.
+var exportHeader = []string{"time", "pair", "bid_price", "bid_size", "ask_price", "ask_size"}
+
+// spreadHeader is the export header of WithSpreadOnly.
+var spreadHeader = []string{"time", "pair", "spread_bps"}
+
+func (c *exportConfig) header() []string {
+	if c.spreadOnly {
+		return spreadHeader
+	}
+	return exportHeader
+}
+
+// Export writes the loaded records of the given pairs, one row per pair and minute.
+// In the default CSV format:
+//
+//	time,pair,bid_price,bid_size,ask_price,ask_size
+//	2022-11-24T00:00:00Z,BTC-BUSD,16544.2,0.5,16544.3,1.2
+//
+// And in the JSONL format:
+//
+//	{"time":"2022-11-24T00:00:00Z","pair":"BTC-BUSD","bid_price":16544.2,"bid_size":0.5,"ask_price":16544.3,"ask_size":1.2}
+//
+// The rows are written as they are produced, so the writer can be the standard output of a pipeline.
+// If no pairs are given, all loaded pairs are exported.
+func (l *CCDepthLoader) Export(w io.Writer, pairs []Pair, opts ...ExportOption) error {
+	config := newExportConfig(opts)
+	writer, err := newRowWriter(w, config)
+	if err != nil {
+		return err
+	}
+	err = l.exportRows(pairs, config, func(t time.Time, pair Pair, row []string) error {
+		return writer.Write(row)
+	})
+	if err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+// exportRows calls the write function with each exported row, pair by pair and minute by minute.
+func (l *CCDepthLoader) exportRows(pairs []Pair, config *exportConfig, write func(t time.Time, pair Pair, row []string) error) error {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	for _, pair := range pairs {
+		for i := 0; i < l.length(pair); i++ {
+			record := l.recordAt(pair, i)
+			// the bad days are not exported, see Tombstone
+			if record.excluded() || config.filter != nil && !config.filter(record) {
+				continue
+			}
+			t := l.minuteTime(i)
+			if config.spreadOnly {
+				if err := write(t, pair, []string{
+					t.UTC().Format(time.RFC3339),
+					pair.String(),
+					formatFloat(metrics["spread_bps"](record)),
+				}); err != nil {
+					return err
+				}
+				continue
+			}
+			err := write(t, pair, []string{
+				t.UTC().Format(time.RFC3339),
+				pair.String(),
+				formatFloat(record.BidPrice),
+				formatFloat(record.BidSize),
+				formatFloat(record.AskPrice),
+				formatFloat(record.AskSize),
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// minuteTime returns the time of the given minute of the loaded time range.
+func (l *CCDepthLoader) minuteTime(minute int) time.Time {
+	return l.startDate.Add(time.Duration(minute) * time.Minute)
+}
+
+// rowWriter writes the exported rows in one of the export formats.
+type rowWriter interface {
+	Write(row []string) error
+	Flush() error
+}
+
+// newRowWriter returns the row writer of the export format, with the header already written.
+func newRowWriter(w io.Writer, config *exportConfig) (rowWriter, error) {
+	if config.format != FormatCSV && (config.delimiter != 0 || config.decimal != 0) {
+		return nil, fmt.Errorf("the delimiter and the decimal separator are only supported by the %s format", FormatCSV)
+	}
+	switch config.format {
+	case FormatJSONL:
+		return &jsonlWriter{w: w, header: config.header()}, nil
+	case FormatCSV:
+		writer := &csvWriter{Writer: csv.NewWriter(w), decimal: config.decimal}
+		if config.delimiter != 0 {
+			writer.Comma = config.delimiter
+		}
+		if writer.decimal == writer.Comma {
+			return nil, fmt.Errorf("the decimal separator %q is the delimiter", writer.decimal)
+		}
+		return writer, writer.Writer.Write(config.header())
+	}
+	return nil, fmt.Errorf("unknown export format: %s", config.format)
+}
+
+type csvWriter struct {
+	*csv.Writer
+	decimal rune
+}
+
+// Write writes the row, with the decimal separator in the numbers following the time and the pair.
+func (w *csvWriter) Write(row []string) error {
+	if w.decimal == 0 || w.decimal == '.' {
+		return w.Writer.Write(row)
+	}
+	localized := make([]string, len(row))
+	copy(localized, row)
+	for i := 2; i < len(localized); i++ {
+		localized[i] = strings.Replace(localized[i], ".", string(w.decimal), 1)
+	}
+	return w.Writer.Write(localized)
+}
+
+func (w *csvWriter) Flush() error {
+	w.Writer.Flush()
+	return w.Writer.Error()
+}
+
+type jsonlWriter struct {
+	w      io.Writer
+	header []string
+}
+
+func (w *jsonlWriter) Write(row []string) error {
+	line := []byte{'{'}
+	for i, value := range row {
+		if i > 0 {
+			line = append(line, ',')
+		}
+		line = append(line, '"')
+		line = append(line, w.header[i]...)
+		line = append(line, '"', ':')
+		if i < 2 {
+			quoted, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			line = append(line, quoted...)
+		} else {
+			line = append(line, value...)
+		}
+	}
+	line = append(line, '}', '\n')
+	_, err := w.w.Write(line)
+	return err
+}
+
+func (w *jsonlWriter) Flush() error {
+	return nil
+}
@@ -0,0 +1,55 @@
+package depth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// reservedNames are the device names Windows reserves in any directory, with any extension.
+var reservedNames = map[string]bool{
+	"CON": true, "PRN": true, "AUX": true, "NUL": true,
+	"COM1": true, "COM2": true, "COM3": true, "COM4": true, "COM5": true, "COM6": true, "COM7": true, "COM8": true, "COM9": true,
+	"LPT1": true, "LPT2": true, "LPT3": true, "LPT4": true, "LPT5": true, "LPT6": true, "LPT7": true, "LPT8": true, "LPT9": true,
+}
+
+// pathName returns the name of the directory of the pair in the cache, the same on every platform: the pair with
+// the characters Windows doesn't allow in a file name, the / and the % escaped as %XX, like a URL, and a reserved
+// device name or a trailing dot or space escaped the same way, so that . and .. are escaped as well.
+func pathName(pair Pair) string {
+	var b strings.Builder
+	name := pair.String()
+	for i := 0; i < len(name); i++ {
+		c := name[i]
+		if c < ' ' || strings.IndexByte(`<>:"/\|?*%`, c) >= 0 || i == len(name)-1 && (c == '.' || c == ' ') {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+	escaped := b.String()
+	base := strings.ToUpper(strings.SplitN(escaped, ".", 2)[0])
+	if reservedNames[base] {
+		escaped = fmt.Sprintf("%%%02X", escaped[0]) + escaped[1:]
+	}
+	return escaped
+}
+
+// pairDir returns the directory of the pair in the parent directory, named with pathName. The directory named after
+// the pair itself, as the cache had it on the platforms other than Windows, is moved to it when found, unless the
+// pair name takes it out of the parent directory.
+func pairDir(parent string, pair Pair) string {
+	dir := filepath.Join(parent, pathName(pair))
+	legacy := filepath.Join(parent, pair.String())
+	if legacy == dir || legacy != filepath.Clean(parent)+string(filepath.Separator)+pair.String() {
+		return dir
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		return dir
+	}
+	if info, err := os.Stat(legacy); err == nil && info.IsDir() {
+		_ = os.Rename(legacy, dir)
+	}
+	return dir
+}
@@ -0,0 +1,10 @@
+//go:build !windows
+
+package depth
+
+import "os"
+
+// replaceFile renames the written tmp file over the path, replacing it atomically.
+func replaceFile(tmp string, path string) error {
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,22 @@
+package depth
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+// errorSharingViolation is the error of a file opened by another process without sharing it, ERROR_SHARING_VIOLATION.
+const errorSharingViolation = syscall.Errno(32)
+
+// replaceFile renames the written tmp file over the path, replacing it. As a file opened by another process,
+// like a concurrent load, an antivirus or the search indexer, can't be replaced on Windows, it retries for up to 2 seconds.
+func replaceFile(tmp string, path string) error {
+	err := os.Rename(tmp, path)
+	for retry := 0; err != nil && retry < 20 && (errors.Is(err, syscall.ERROR_ACCESS_DENIED) || errors.Is(err, errorSharingViolation)); retry++ {
+		time.Sleep(100 * time.Millisecond)
+		err = os.Rename(tmp, path)
+	}
+	return err
+}
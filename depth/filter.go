@@ -0,0 +1,97 @@
+package depth
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Filter selects the depth records to export.
+type Filter func(record Record) bool
+
+// metrics are the record values that can be used in a filter expression.
+var metrics = map[string]func(Record) float64{
+	"bid_price":      func(r Record) float64 { return r.BidPrice },
+	"bid_size":       func(r Record) float64 { return r.BidSize },
+	"ask_price":      func(r Record) float64 { return r.AskPrice },
+	"ask_size":       func(r Record) float64 { return r.AskSize },
+	"mid":            Record.Mid,
+	"spread":         func(r Record) float64 { return r.AskPrice - r.BidPrice },
+	"spread_bps":     func(r Record) float64 { return r.SpreadPercentage() * 10000 },
+	"imbalance":      Record.Imbalance,
+	"microprice":     func(r Record) float64 { return r.FairPrice(1) },
+	"bid_notional":   Record.BidNotional,
+	"ask_notional":   Record.AskNotional,
+	"touch_notional": Record.TouchNotional,
+}
+
+// comparisons are the operators supported in a filter expression, longest first.
+var comparisons = []struct {
+	op      string
+	compare func(a, b float64) bool
+}{
+	{">=", func(a, b float64) bool { return a >= b }},
+	{"<=", func(a, b float64) bool { return a <= b }},
+	{"==", func(a, b float64) bool { return a == b }},
+	{"!=", func(a, b float64) bool { return a != b }},
+	{">", func(a, b float64) bool { return a > b }},
+	{"<", func(a, b float64) bool { return a < b }},
+}
+
+// ParseFilter parses a filter expression like "spread_bps > 10 && imbalance < 0".
+// The expression is made of comparisons between a record metric and a number, joined with && and ||,
+// where && binds tighter than ||. Parentheses are not supported.
+// The metrics are: bid_price, bid_size, ask_price, ask_size, mid, spread, spread_bps, imbalance, microprice,
+// and bid_notional, ask_notional, touch_notional, the sizes in the quote currency.
+func ParseFilter(expr string) (Filter, error) {
+	var anyOf []Filter
+	for _, disjunct := range strings.Split(expr, "||") {
+		var allOf []Filter
+		for _, term := range strings.Split(disjunct, "&&") {
+			f, err := parseComparison(strings.TrimSpace(term))
+			if err != nil {
+				return nil, err
+			}
+			allOf = append(allOf, f)
+		}
+		anyOf = append(anyOf, func(record Record) bool {
+			for _, f := range allOf {
+				if !f(record) {
+					return false
+				}
+			}
+			return true
+		})
+	}
+	return func(record Record) bool {
+		for _, f := range anyOf {
+			if f(record) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
+
+func parseComparison(term string) (Filter, error) {
+	for _, c := range comparisons {
+		i := strings.Index(term, c.op)
+		if i < 0 {
+			continue
+		}
+		name := strings.TrimSpace(term[:i])
+		metric, ok := metrics[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown metric %q in filter term %q", name, term)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(term[i+len(c.op):]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number in filter term %q: %w", term, err)
+		}
+		compare := c.compare
+		return func(record Record) bool {
+			return compare(metric(record), value)
+		}, nil
+	}
+	return nil, fmt.Errorf("no comparison operator in filter term %q", term)
+}
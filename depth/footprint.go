@@ -0,0 +1,66 @@
+package depth
+
+import "unsafe"
+
+// Footprint is the size of the loaded data of a pair.
+type Footprint struct {
+	// Minutes is the number of 1 minute records, and Values the number of stored values (Minutes times the schema width).
+	Minutes int
+	Values  int
+	// SeriesValues is the number of values of the derived series, see WithSeries.
+	SeriesValues int
+	// Bytes is the estimated memory used by the values and the series, not counting the map overhead.
+	// It includes the values kept off heap with WithOffHeap.
+	Bytes int64
+}
+
+// BytesPerMinute returns the estimated memory used by a minute of the pair, to estimate the footprint of longer
+// time ranges, or more pairs. It returns 0 if the pair has no records.
+func (f Footprint) BytesPerMinute() float64 {
+	if f.Minutes == 0 {
+		return 0
+	}
+	return float64(f.Bytes) / float64(f.Minutes)
+}
+
+// Footprints returns the footprint of each loaded pair.
+func (l *CCDepthLoader) Footprints() map[Pair]Footprint {
+	footprints := make(map[Pair]Footprint, len(l.records)+len(l.values))
+	for _, pair := range append(l.loadedPairs(), l.seriesOnlyPairs()...) {
+		f := Footprint{Minutes: l.length(pair)}
+		if l.hasSeries(pair) {
+			for name := range l.derived {
+				f.Minutes = len(l.series[name][pair])
+			}
+		} else if values, ok := l.records[pair]; ok {
+			f.Values = len(values)
+			f.Bytes = int64(unsafe.Sizeof(values)) + int64(len(values))*int64(unsafe.Sizeof(""))
+			for _, v := range values {
+				f.Bytes += int64(len(v))
+			}
+		} else {
+			values := l.values[pair]
+			f.Values = len(values)
+			f.Bytes = int64(unsafe.Sizeof(values)) + 8*int64(len(values))
+		}
+		if runs, ok := l.runs[pair]; ok {
+			f.Bytes += int64(unsafe.Sizeof(*runs)) + 8*int64(len(runs.starts))
+		}
+		for name := range l.derived {
+			series := l.series[name][pair]
+			f.SeriesValues += len(series)
+			f.Bytes += int64(unsafe.Sizeof(series)) + 8*int64(len(series))
+		}
+		footprints[pair] = f
+	}
+	return footprints
+}
+
+// MemoryFootprint returns the estimated memory used by the loaded data of all pairs, see Footprints.
+func (l *CCDepthLoader) MemoryFootprint() int64 {
+	total := int64(0)
+	for _, f := range l.Footprints() {
+		total += f.Bytes
+	}
+	return total
+}
@@ -0,0 +1,78 @@
+package depth
+
+import "time"
+
+// FundingInterval is the interval between funding timestamps of perpetual futures (00:00, 08:00, 16:00 UTC).
+const FundingInterval = 8 * time.Hour
+
+// FundingWindow describes the book behavior around a single funding timestamp.
+type FundingWindow struct {
+	Time   time.Time
+	Before WindowStats
+	After  WindowStats
+}
+
+// WindowStats summarizes the depth records of a time window.
+type WindowStats struct {
+	// Minutes is the number of 1 minute records in the window.
+	Minutes int
+	// MeanSpread is the average spread percentage.
+	MeanSpread float64
+	// MeanImbalance is the average top of book imbalance.
+	MeanImbalance float64
+	// MidReturn is the relative change of the mid price from the first to the last minute of the window.
+	MidReturn float64
+}
+
+// FundingTimes returns the funding timestamps within the [startDate, endDate) time range.
+func FundingTimes(startDate time.Time, endDate time.Time) []time.Time {
+	var times []time.Time
+	for t := startDate.UTC().Truncate(FundingInterval); t.Before(endDate); t = t.Add(FundingInterval) {
+		if !t.Before(startDate) {
+			times = append(times, t)
+		}
+	}
+	return times
+}
+
+// FundingWindows returns the book behavior in the before window, ending at the funding timestamp,
+// and the after window, starting at it, for each funding timestamp of the loaded time range.
+// Funding timestamps whose windows do not fit into the loaded data are skipped.
+func (l *CCDepthLoader) FundingWindows(pair Pair, before time.Duration, after time.Duration) []FundingWindow {
+	length := l.length(pair)
+	endDate := l.startDate.Add(time.Duration(length) * time.Minute)
+	beforeMinutes := int(before.Minutes())
+	afterMinutes := int(after.Minutes())
+
+	var windows []FundingWindow
+	for _, t := range FundingTimes(l.startDate, endDate) {
+		minute := int(t.Sub(l.startDate).Minutes())
+		if minute-beforeMinutes < 0 || minute+afterMinutes > length {
+			continue
+		}
+		windows = append(windows, FundingWindow{
+			Time:   t,
+			Before: l.windowStats(pair, minute-beforeMinutes, minute),
+			After:  l.windowStats(pair, minute, minute+afterMinutes),
+		})
+	}
+	return windows
+}
+
+// windowStats summarizes the records of the pair in the [from, to) minutes range.
+func (l *CCDepthLoader) windowStats(pair Pair, from int, to int) WindowStats {
+	stats := WindowStats{Minutes: to - from}
+	if stats.Minutes <= 0 {
+		return stats
+	}
+	for i := from; i < to; i++ {
+		record := l.recordAt(pair, i)
+		stats.MeanSpread += record.SpreadPercentage()
+		stats.MeanImbalance += record.Imbalance()
+	}
+	stats.MeanSpread /= float64(stats.Minutes)
+	stats.MeanImbalance /= float64(stats.Minutes)
+	firstMid := l.recordAt(pair, from).Mid()
+	stats.MidReturn = l.recordAt(pair, to-1).Mid()/firstMid - 1
+	return stats
+}
@@ -0,0 +1,31 @@
+package depth
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithDownloadHook invokes the hook with the records of each minute of each day downloaded from the provider,
+// before they are stored, so that the hook corrects them in place, like the known bad prints of a pair,
+// without forking the download. The stored days are not passed to the hook again, unless downloaded again,
+// see WithRefetchBad. The hooks are invoked in the order they are configured, concurrently for the days
+// downloaded concurrently, see WithConcurrency. The records of a day without vendor data are not passed to the hooks.
+// A hook returning an error fails the day with it, see FailedDaysError. It panics if the hook is nil.
+func WithDownloadHook(hook func(pair Pair, date time.Time, records []Record) error) Option {
+	if hook == nil {
+		panic("the download hook must not be nil")
+	}
+	return func(l *CCDepthLoader) {
+		l.downloadHooks = append(l.downloadHooks, hook)
+	}
+}
+
+// hookDay invokes the download hooks with the records of the day of the pair.
+func (l *CCDepthLoader) hookDay(pair Pair, date time.Time, records []Record) error {
+	for _, hook := range l.downloadHooks {
+		if err := hook(pair, date, records); err != nil {
+			return fmt.Errorf("download hook of %s on %s: %w", pair, date.Format("2006-01-02"), err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,219 @@
+package depth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// rowSpan is the position of a pair row in the depth data file, including its line break.
+type rowSpan struct {
+	offset int64
+	length int64
+}
+
+// fileIndex lists the pair rows of a depth data file. It is kept in the <file>.idx sidecar,
+// so that loading a few pairs of a large file reads only their rows:
+//
+//	#index,<file size>,<file modification time in ns>
+//	<Pair>,<offset>,<length>
+//	...
+//
+// The index is used only while the file has the recorded size and modification time,
+// otherwise the file is scanned again.
+type fileIndex struct {
+	size    int64
+	modTime int64
+	rows    map[Pair]rowSpan
+}
+
+func indexPath(path string) string {
+	return path + ".idx"
+}
+
+// openIndex returns the index of the open depth data file, from its sidecar if it is up to date,
+// or by scanning the file, and then updating the sidecar unless the loader is read-only.
+func (l *CCDepthLoader) openIndex(file *os.File, path string) *fileIndex {
+	info, err := file.Stat()
+	if err != nil {
+		panic(err)
+	}
+	if index := readIndex(path); index != nil && index.size == info.Size() && index.modTime == info.ModTime().UnixNano() {
+		return index
+	}
+	index, err := scanIndex(file)
+	if err != nil {
+		panic(err)
+	}
+	if !l.readOnly {
+		if err := index.write(path, l.perms); err != nil {
+			panic(err)
+		}
+	}
+	return index
+}
+
+// openIndexOf returns the index of the depth data file, see openIndex.
+func (l *CCDepthLoader) openIndexOf(path string) *fileIndex {
+	file, err := os.Open(path)
+	if err != nil {
+		panic(err)
+	}
+	defer file.Close()
+	return l.openIndex(file, path)
+}
+
+// readIndex reads the sidecar of the file. It returns nil if there is none, or if it is malformed.
+func readIndex(path string) *fileIndex {
+	content, err := os.ReadFile(indexPath(path))
+	if err != nil {
+		return nil
+	}
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	header := strings.Split(lines[0], ",")
+	if len(header) != 3 || header[0] != "#index" {
+		return nil
+	}
+	index := &fileIndex{rows: make(map[Pair]rowSpan, len(lines)-1)}
+	if index.size, err = strconv.ParseInt(header[1], 10, 64); err != nil {
+		return nil
+	}
+	if index.modTime, err = strconv.ParseInt(header[2], 10, 64); err != nil {
+		return nil
+	}
+	for _, line := range lines[1:] {
+		fields, err := splitCSV(line)
+		if err != nil || len(fields) != 3 {
+			return nil
+		}
+		var span rowSpan
+		if span.offset, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil
+		}
+		if span.length, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+			return nil
+		}
+		index.rows[Pair(fields[0])] = span
+	}
+	return index
+}
+
+// scanIndex builds the index of the file by scanning its lines for the pair names, without parsing the rows.
+func scanIndex(file *os.File) (*fileIndex, error) {
+	if _, err := file.Seek(0, 0); err != nil {
+		return nil, err
+	}
+	index := &fileIndex{rows: make(map[Pair]rowSpan)}
+	reader := bufio.NewReaderSize(file, 1<<16)
+	offset, start := int64(0), int64(0)
+	var pair Pair
+	inRow, lineStart := false, true
+	for {
+		// the rows are longer than the buffer, so they are read in chunks
+		chunk, err := reader.ReadSlice('\n')
+		if lineStart && len(chunk) > 0 {
+			start = offset
+			pair, inRow = rowPair(linePrefix(chunk))
+		}
+		offset += int64(len(chunk))
+		lineStart = len(chunk) > 0 && chunk[len(chunk)-1] == '\n'
+		if inRow && (lineStart || err == io.EOF) {
+			index.rows[pair] = rowSpan{offset: start, length: offset - start}
+			inRow = false
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != bufio.ErrBufferFull {
+			return nil, err
+		}
+	}
+	index.size = offset
+	return index, nil
+}
+
+// add records a row appended at the end of the file.
+func (index *fileIndex) add(pair Pair, length int) {
+	index.rows[pair] = rowSpan{offset: index.size, length: int64(length)}
+	index.size += int64(length)
+}
+
+// write writes the sidecar of the file, replacing the previous one. It writes nothing if the file
+// doesn't have the size of the index, as it was changed by someone else in the meantime.
+func (index *fileIndex) write(path string, perms filePerms) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != index.size {
+		return nil
+	}
+	index.modTime = info.ModTime().UnixNano()
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("#index,%d,%d\n", index.size, index.modTime))
+	for _, pair := range sortedPairs(index.rows) {
+		span := index.rows[pair]
+		b.WriteString(fmt.Sprintf("%s,%d,%d\n", csvField(pair.String()), span.offset, span.length))
+	}
+	// replace the sidecar atomically, so that a concurrent load never reads a partial one
+	tmp := indexPath(path) + ".tmp"
+	if err := perms.writeFile(tmp, []byte(b.String())); err != nil {
+		return err
+	}
+	return replaceFile(tmp, indexPath(path))
+}
+
+// at returns the index of the rows within the first size bytes of the file, see WithVersion.
+func (index *fileIndex) at(size int64) *fileIndex {
+	if size >= index.size {
+		return index
+	}
+	rows := make(map[Pair]rowSpan, len(index.rows))
+	for pair, span := range index.rows {
+		if span.offset+span.length <= size {
+			rows[pair] = span
+		}
+	}
+	return &fileIndex{size: size, modTime: index.modTime, rows: rows}
+}
+
+// readIndexedRecords reads the rows of the given pairs found in the index, and returns the history length.
+func (l *CCDepthLoader) readIndexedRecords(file *os.File, index *fileIndex, pairs []Pair) uint {
+	var rows []*pairRow
+	for _, pair := range pairs {
+		span, ok := index.rows[pair]
+		if !ok {
+			continue
+		}
+		line := make([]byte, span.length)
+		if _, err := file.ReadAt(line, span.offset); err != nil {
+			panic(err)
+		}
+		if rowPair, _ := rowPair(linePrefix(line)); rowPair != pair {
+			panic(fmt.Errorf("%w: the index points to the row of %s for the pair %s", ErrCorrupted, rowPair, pair))
+		}
+		rows = append(rows, &pairRow{pair: pair, line: string(line)})
+	}
+	return l.parseRows(rows)
+}
+
+// linePrefix returns the start of the line, long enough for the pair name.
+func linePrefix(line []byte) string {
+	if len(line) > 256 {
+		line = line[:256]
+	}
+	return string(line)
+}
+
+func sortedPairs(rows map[Pair]rowSpan) []Pair {
+	pairs := make([]Pair, 0, len(rows))
+	for pair := range rows {
+		pairs = append(pairs, pair)
+	}
+	sortPairs(pairs)
+	return pairs
+}
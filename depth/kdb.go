@@ -0,0 +1,132 @@
+package depth
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// kdb+ types of the exported vectors.
+const (
+	kdbFloat     = 9
+	kdbSymbol    = 11
+	kdbTimestamp = 12
+	kdbEnum      = 20
+)
+
+// kdbEpoch is the epoch of kdb+ timestamps.
+var kdbEpoch = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// ExportKdb writes the loaded records of the given pairs as a kdb+ splayed table directory:
+//
+//	<dir>/sym          the symbol list of the exported pairs
+//	<dir>/depth/.d     the column names
+//	<dir>/depth/time   timestamp column
+//	<dir>/depth/pair   symbol column, enumerated against sym
+//	<dir>/depth/bid_price, bid_size, ask_price, ask_size   float columns
+//
+// The files are kdb+ serialized vectors. As splayed tables can't hold plain symbols, the pair column is
+// a vector of indices into the sym list, so the sym list is loaded first:
+//
+//	\l db
+//
+// or, without changing the directory:
+//
+//	sym:get `:db/sym; depth:get `:db/depth/
+//
+// The rows are sorted by pair, then by time. If no pairs are given, all loaded pairs are exported.
+func (l *CCDepthLoader) ExportKdb(dir string, pairs []Pair) error {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	var times []int64
+	var pairColumn []int64
+	var bidPrice, bidSize, askPrice, askSize []float64
+	symbols := make([]string, len(pairs))
+	for i, pair := range pairs {
+		symbols[i] = pair.String()
+		columns := l.Columns(pair)
+		for _, t := range columns.Time {
+			times = append(times, t.Sub(kdbEpoch).Nanoseconds())
+			pairColumn = append(pairColumn, int64(i))
+		}
+		bidPrice = append(bidPrice, columns.BidPrice...)
+		bidSize = append(bidSize, columns.BidSize...)
+		askPrice = append(askPrice, columns.AskPrice...)
+		askSize = append(askSize, columns.AskSize...)
+	}
+
+	table := filepath.Join(dir, "depth")
+	if err := os.MkdirAll(table, 0755); err != nil {
+		return err
+	}
+	files := []struct {
+		path   string
+		vector []byte
+	}{
+		{filepath.Join(dir, "sym"), kdbSymbols(symbols)},
+		{filepath.Join(table, ".d"), kdbSymbols(exportHeader)},
+		{filepath.Join(table, "time"), kdbLongs(kdbTimestamp, times)},
+		{filepath.Join(table, "pair"), kdbEnumeration("sym", pairColumn)},
+		{filepath.Join(table, "bid_price"), kdbFloats(bidPrice)},
+		{filepath.Join(table, "bid_size"), kdbFloats(bidSize)},
+		{filepath.Join(table, "ask_price"), kdbFloats(askPrice)},
+		{filepath.Join(table, "ask_size"), kdbFloats(askSize)},
+	}
+	for _, f := range files {
+		if err := os.WriteFile(f.path, f.vector, 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// kdbHeader returns the header of a serialized kdb+ vector file: the 0xff01 file marker,
+// the vector type, the attributes byte and the vector length.
+func kdbHeader(kdbType byte, length int, capacity int) []byte {
+	b := make([]byte, 8, 8+capacity)
+	b[0], b[1], b[2], b[3] = 0xff, 0x01, kdbType, 0
+	binary.LittleEndian.PutUint32(b[4:], uint32(length))
+	return b
+}
+
+func kdbFloats(values []float64) []byte {
+	b := kdbHeader(kdbFloat, len(values), 8*len(values))
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	return b
+}
+
+func kdbLongs(kdbType byte, values []int64) []byte {
+	b := kdbHeader(kdbType, len(values), 8*len(values))
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+	return b
+}
+
+// kdbSymbols serializes the symbols as null-terminated strings.
+func kdbSymbols(values []string) []byte {
+	b := kdbHeader(kdbSymbol, len(values), 0)
+	for _, v := range values {
+		b = append(b, v...)
+		b = append(b, 0)
+	}
+	return b
+}
+
+// kdbEnumeration serializes the indices into the domain, like `sym$ in q. The header holds the name
+// of the domain in place of the vector length, null-padded to 16 bytes, followed by the 8 byte length.
+func kdbEnumeration(domain string, indices []int64) []byte {
+	b := make([]byte, 16, 24+8*len(indices))
+	b[0], b[1], b[2], b[3] = 0xff, 0x01, kdbEnum, 0
+	copy(b[4:15], domain)
+	b = binary.LittleEndian.AppendUint64(b, uint64(len(indices)))
+	for _, v := range indices {
+		b = binary.LittleEndian.AppendUint64(b, uint64(v))
+	}
+	return b
+}
@@ -0,0 +1,115 @@
+package depth
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ExportLean writes the loaded records of the given pairs as QuantConnect Lean minute quote data, in the directory
+// structure Lean reads from its data folder, one zip archive per pair and day:
+//
+//	<dir>/<security type>/<lean market>/minute/<symbol>/<yyyymmdd>_quote.zip
+//
+// The spot markets are written under crypto, and the Binance futures markets under cryptofuture, with the
+// market names Lean uses, like binanceus for binance-us. It returns an error for the markets without a Lean name.
+//
+// Each archive contains a single <yyyymmdd>_<symbol>_minute_quote.csv file, with one row per minute:
+//
+//	<ms since midnight>,<bid open>,<bid high>,<bid low>,<bid close>,<last bid size>,<ask open>,<ask high>,<ask low>,<ask close>,<last ask size>
+//
+// Since there is a single snapshot per minute, the open, high, low and close prices of each bar are the same.
+// The symbol is the lowercase pair without the dash, like btcbusd. If no pairs are given, all loaded pairs are exported.
+func (l *CCDepthLoader) ExportLean(dir string, pairs []Pair) error {
+	lean, ok := leanMarkets[l.market]
+	if !ok {
+		return fmt.Errorf("the market %s has no Lean equivalent", l.market)
+	}
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	for _, pair := range pairs {
+		symbol := strings.ToLower(pair.Base() + pair.Quote())
+		symbolDir := filepath.Join(dir, lean.securityType, lean.market, "minute", symbol)
+		if err := os.MkdirAll(symbolDir, 0755); err != nil {
+			return err
+		}
+
+		var day time.Time
+		var rows strings.Builder
+		flush := func() error {
+			if rows.Len() == 0 {
+				return nil
+			}
+			date := day.Format("20060102")
+			err := writeZip(filepath.Join(symbolDir, date+"_quote.zip"), date+"_"+symbol+"_minute_quote.csv", rows.String())
+			rows.Reset()
+			return err
+		}
+		for i := 0; i < l.length(pair); i++ {
+			t := l.minuteTime(i).UTC()
+			if !t.Truncate(24 * time.Hour).Equal(day) {
+				if err := flush(); err != nil {
+					return err
+				}
+				day = t.Truncate(24 * time.Hour)
+			}
+			r := l.recordAt(pair, i)
+			if r.excluded() {
+				continue
+			}
+			bid, ask := formatFloat(r.BidPrice), formatFloat(r.AskPrice)
+			rows.WriteString(fmt.Sprintf("%d,%s,%s,%s,%s,%s,%s,%s,%s,%s,%s\n",
+				t.Sub(day).Milliseconds(),
+				bid, bid, bid, bid, formatFloat(r.BidSize),
+				ask, ask, ask, ask, formatFloat(r.AskSize),
+			))
+		}
+		if err := flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// leanMarket is the Lean security type and market name of a crypto-chassis market.
+type leanMarket struct {
+	securityType string
+	market       string
+}
+
+// leanMarkets maps the crypto-chassis markets to their Lean data folders.
+var leanMarkets = map[Market]leanMarket{
+	MarketBinance:            {"crypto", "binance"},
+	MarketBinanceUs:          {"crypto", "binanceus"},
+	MarketBinanceUsdsFutures: {"cryptofuture", "binance"},
+	MarketBinanceCoinFutures: {"cryptofuture", "binance"},
+	MarketBitfinex:           {"crypto", "bitfinex"},
+	MarketCoinbase:           {"crypto", "coinbase"},
+	MarketKraken:             {"crypto", "kraken"},
+	MarketFtx:                {"crypto", "ftx"},
+	MarketFtxUs:              {"crypto", "ftxus"},
+}
+
+// writeZip writes a zip archive with a single file of the given content.
+func writeZip(path string, name string, content string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	archive := zip.NewWriter(file)
+	w, err := archive.Create(name)
+	if err == nil {
+		_, err = w.Write([]byte(content))
+	}
+	if closeErr := archive.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
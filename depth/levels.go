@@ -0,0 +1,151 @@
+package depth
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Level is a price level of a side of the book.
+type Level struct {
+	Price float64 `json:"price"`
+	Size  float64 `json:"size"`
+}
+
+// WithDepthLevels loads the snapshots of the given number of levels of each side of the book, like 10,
+// instead of the top of book only. The levels after the top of book are stored after the fields of the schema,
+// see LevelFields, and the records have all the levels in their Bids and Asks, with their BidPrice, BidSize,
+// AskPrice and AskSize being those of the first level. The levels missing from a snapshot are NaN.
+// It panics if the number of levels is not positive.
+func WithDepthLevels(levels int) Option {
+	if levels <= 0 {
+		panic("the number of depth levels must be positive")
+	}
+	return func(l *CCDepthLoader) {
+		l.levels = levels
+	}
+}
+
+// LevelFields returns the fields of the levels after the top of book, up to the given number of levels,
+// like bid_price_1, bid_size_1, ask_price_1 and ask_size_1 of the second level, see WithDepthLevels.
+func LevelFields(levels int) Schema {
+	var fields Schema
+	for level := 1; level < levels; level++ {
+		for _, field := range DefaultSchema {
+			fields = append(fields, Field(string(field)+"_"+strconv.Itoa(level)))
+		}
+	}
+	return fields
+}
+
+// fullIndex returns the index of the field among the downloaded values of a minute, those of DefaultSchema
+// and then those of each level after the top of book, see LevelFields, and false for the computed fields.
+func (f Field) fullIndex() (int, bool) {
+	for i, field := range DefaultSchema {
+		if f == field {
+			return i, true
+		}
+		if prefix := string(field) + "_"; strings.HasPrefix(string(f), prefix) {
+			level, err := strconv.Atoi(string(f)[len(prefix):])
+			if err == nil && level > 0 && strconv.Itoa(level) == string(f)[len(prefix):] {
+				return 4*level + i, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// levels returns the number of levels of each side of the book stored by the schema, 1 for the top of book only.
+func (s Schema) levels() int {
+	levels := 1
+	for _, field := range s {
+		if index, ok := field.fullIndex(); ok && index/4+1 > levels {
+			levels = index/4 + 1
+		}
+	}
+	return levels
+}
+
+// withLevels returns the schema with the fields of the levels after the top of book, see LevelFields.
+func (s Schema) withLevels(levels int) Schema {
+	var schema Schema
+	for _, field := range s {
+		if index, ok := field.fullIndex(); !ok || index < 4 {
+			schema = append(schema, field)
+		}
+	}
+	return append(schema, LevelFields(levels)...)
+}
+
+// fullSchema returns the schema of the downloaded values of the levels of the schema, see fullIndex.
+func (s Schema) fullSchema() Schema {
+	return DefaultSchema.withLevels(s.levels())
+}
+
+// level returns the level of a side of the record, the first one being the top of book, NaN if it has no such level.
+func (r Record) level(bid bool, level int) Level {
+	switch {
+	case level == 0 && bid:
+		return Level{Price: r.BidPrice, Size: r.BidSize}
+	case level == 0:
+		return Level{Price: r.AskPrice, Size: r.AskSize}
+	case bid && level < len(r.Bids):
+		return r.Bids[level]
+	case !bid && level < len(r.Asks):
+		return r.Asks[level]
+	}
+	return Level{Price: math.NaN(), Size: math.NaN()}
+}
+
+// levelValue returns the value of a price level field of the record, see fullIndex.
+func (r Record) levelValue(index int) float64 {
+	level := r.level(index%4 < 2, index/4)
+	if index%2 == 0 {
+		return level.Price
+	}
+	return level.Size
+}
+
+// setLevelValue sets the value of a price level field of the record, after the top of book, see fullIndex.
+func (r *Record) setLevelValue(index int, v float64) {
+	levels := &r.Bids
+	if index%4 >= 2 {
+		levels = &r.Asks
+	}
+	if index%2 == 0 {
+		(*levels)[index/4].Price = v
+	} else {
+		(*levels)[index/4].Size = v
+	}
+}
+
+// equal checks if the records have the same values at all the levels, the NaN values being different.
+func (r Record) equal(other Record) bool {
+	if r.BidPrice != other.BidPrice || r.BidSize != other.BidSize || r.AskPrice != other.AskPrice || r.AskSize != other.AskSize ||
+		len(r.Bids) != len(other.Bids) || len(r.Asks) != len(other.Asks) {
+		return false
+	}
+	for i := range r.Bids {
+		if r.Bids[i] != other.Bids[i] {
+			return false
+		}
+	}
+	for i := range r.Asks {
+		if r.Asks[i] != other.Asks[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// scalePrices multiplies the prices of all the levels of the record by the ratio.
+func (r *Record) scalePrices(ratio float64) {
+	r.BidPrice *= ratio
+	r.AskPrice *= ratio
+	for i := range r.Bids {
+		r.Bids[i].Price *= ratio
+	}
+	for i := range r.Asks {
+		r.Asks[i].Price *= ratio
+	}
+}
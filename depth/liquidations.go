@@ -0,0 +1,206 @@
+package depth
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Liquidation is a single forced liquidation order reported by the exchange.
+type Liquidation struct {
+	Time time.Time
+	// Side is the side of the liquidation order: Sell when a long position was liquidated, Buy for a short one.
+	Side     Side
+	Price    float64
+	Quantity float64
+}
+
+// Notional returns the liquidated amount in the quote currency.
+func (l Liquidation) Notional() float64 {
+	return l.Price * l.Quantity
+}
+
+// LiquidationLoader loads the liquidation events of futures markets.
+// The events are aligned to the 1 minute depth grid: the slice at index i contains the liquidations
+// that happened during the i-th minute of the loaded time range.
+type LiquidationLoader interface {
+	// Load loads the liquidation events for the given pairs and time range.
+	Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][][]Liquidation
+}
+
+// NewLiquidationLoader returns a LiquidationLoader for the Binance futures markets.
+func NewLiquidationLoader(market Market, opts ...LiquidationOption) LiquidationLoader {
+	if market != MarketBinanceUsdsFutures && market != MarketBinanceCoinFutures {
+		panic("liquidations are not supported for market " + string(market))
+	}
+	l := &BinanceLiquidationLoader{market: market, baseURL: "https://data.binance.vision", progress: os.Stdout}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// LiquidationOption configures the BinanceLiquidationLoader.
+type LiquidationOption func(l *BinanceLiquidationLoader)
+
+// WithLiquidationProgress sets the writer of the download progress messages, the standard output by default.
+func WithLiquidationProgress(w io.Writer) LiquidationOption {
+	return func(l *BinanceLiquidationLoader) {
+		l.progress = w
+	}
+}
+
+// WithLiquidationBaseURL sets the URL of the Binance public data website, like a mirror or a test server,
+// https://data.binance.vision by default.
+func WithLiquidationBaseURL(baseURL string) LiquidationOption {
+	return func(l *BinanceLiquidationLoader) {
+		l.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// BinanceLiquidationLoader loads the daily liquidation snapshots archived on the Binance public data website:
+// https://data.binance.vision/data/futures/cm/daily/liquidationSnapshot/BTCUSD_PERP/BTCUSD_PERP-liquidationSnapshot-2022-11-24.zip
+// The archive is a zipped CSV with the following columns:
+//
+//	time,side,order_type,time_in_force,original_quantity,price,average_price,order_status,last_fill_quantity,accumulated_fill_quantity
+//
+// The archives do not exist for every pair and day, the missing days have no liquidations.
+type BinanceLiquidationLoader struct {
+	market   Market
+	baseURL  string
+	progress io.Writer
+}
+
+// Load loads the liquidations of the pairs, bucketed by the minute they happened in.
+
+func (l *BinanceLiquidationLoader) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][][]Liquidation {
+	historyLength := int(endDate.Sub(startDate).Minutes())
+	result := make(map[Pair][][]Liquidation)
+	for _, pair := range pairs {
+		var days []time.Time
+		for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+			days = append(days, date)
+		}
+		type dayResult struct {
+			liquidations []Liquidation
+			err          error
+		}
+		results := slices.MapAsync(days, 30, func(date time.Time) dayResult {
+			fmt.Fprintln(l.progress, "Downloading liquidations for", pair, date)
+			liquidations, err := l.downloadDay(pair, date)
+			return dayResult{liquidations, err}
+		})
+
+		byMinute := make([][]Liquidation, historyLength)
+		var liquidations []Liquidation
+		for _, day := range results {
+			// panic in the calling goroutine, so that it can be recovered
+			if day.err != nil {
+				panic(day.err)
+			}
+			liquidations = append(liquidations, day.liquidations...)
+		}
+		for _, liquidation := range liquidations {
+			minute := int(liquidation.Time.Sub(startDate) / time.Minute)
+			if minute >= 0 && minute < historyLength {
+				byMinute[minute] = append(byMinute[minute], liquidation)
+			}
+		}
+		result[pair] = byMinute
+	}
+	return result
+}
+
+func (l *BinanceLiquidationLoader) downloadDay(pair Pair, date time.Time) ([]Liquidation, error) {
+	symbol := strings.ToUpper(pair.Base() + pair.Quote())
+	marketType := "um"
+	if l.market == MarketBinanceCoinFutures {
+		marketType = "cm"
+		symbol += "_PERP"
+	}
+	name := symbol + "-liquidationSnapshot-" + date.Format("2006-01-02")
+	url := l.baseURL + "/data/futures/" + marketType + "/daily/liquidationSnapshot/" + symbol + "/" + name + ".zip"
+
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, string(body))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+
+	var liquidations []Liquidation
+	for _, f := range archive.File {
+		fileLiquidations, err := readLiquidations(f)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+		liquidations = append(liquidations, fileLiquidations...)
+	}
+	return liquidations, nil
+}
+
+// readLiquidations reads the liquidations from a CSV file of the liquidation snapshot archive.
+func readLiquidations(f *zip.File) ([]Liquidation, error) {
+	file, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	var liquidations []Liquidation
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return liquidations, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if record[0] == "time" {
+			continue
+		}
+		if len(record) < 10 {
+			return nil, fmt.Errorf("expected 10 columns, got %d: %v", len(record), record)
+		}
+		ms, err := strconv.ParseInt(record[0], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		price, err := strconv.ParseFloat(record[6], 64)
+		if err != nil {
+			return nil, err
+		}
+		quantity, err := strconv.ParseFloat(record[9], 64)
+		if err != nil {
+			return nil, err
+		}
+		side := Buy
+		if record[1] == "SELL" {
+			side = Sell
+		}
+		liquidations = append(liquidations, Liquidation{Time: time.UnixMilli(ms), Side: side, Price: price, Quantity: quantity})
+	}
+}
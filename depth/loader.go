@@ -2,18 +2,23 @@ package depth
 
 import (
 	"bufio"
-	"compress/gzip"
+	"context"
 	"encoding/csv"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"github.com/life4/genesis/slices"
+	"hash"
 	"io"
+	"log"
 	"math"
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -43,6 +48,8 @@ import (
 //	}
 //
 // Using the url, it downloads the csv.gz file, unzips it, and appends the data to the depth data file.
+// The position of each pair row is kept in the <file>.idx sidecar, so that loading some of the pairs
+// reads only their rows. Each append creates a new version of the file, see Version.
 //
 // The resulting CSV content format:
 //
@@ -53,6 +60,15 @@ import (
 //
 // Each group of 4 values represents a single 1 minute record.
 // There are as many 4-value groups as there are minutes in the startDate-endDate time-range.
+// When the loader stores a different set of fields (see WithSchema), the second header line lists them,
+// and each 1 minute record has one value per field:
+//
+//	#fields,mid,spread
+//
+// With the deeper snapshots (see WithDepthLevels), the fields of the levels after the top of book follow them:
+//
+//	#fields,bid_price,bid_size,ask_price,ask_size,bid_price_1,bid_size_1,ask_price_1,ask_size_1,...
+//
 // Example:
 //
 //	#,BTC-BUSD
@@ -64,16 +80,163 @@ type Loader interface {
 	Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string
 	// Tick can be used to iterate the data after it has been loaded.
 	// With each call, it moves the pointer to the next minute in the loaded data time range.
-	Tick()
+	// It returns false once the pointer is past the end of the loaded data.
+	Tick() bool
 	// GetDepth returns the current depth record for the given pair.
 	// To proceed to the next minute, call Tick().
 	GetDepth(pair Pair) Record
 }
 
-func NewCCDepthLoader(market Market) Loader {
-	return &CCDepthLoader{
-		market:  market,
-		records: make(map[Pair][]string),
+// NewCCDepthLoader returns a Loader of the crypto-chassis depth data of the market.
+// Besides the Loader methods, the CCDepthLoader provides the analytics (RollingVol, FundingWindows, Series)
+// and the exporters (Export, ExportChunks, ExportLean, ExportKdb, Serve) over the loaded records.
+func NewCCDepthLoader(market Market, opts ...Option) *CCDepthLoader {
+	l := &CCDepthLoader{
+		market:          market,
+		baseURL:         "https://api.cryptochassis.com",
+		records:         make(map[Pair][]string),
+		values:          make(map[Pair][]float64),
+		runs:            make(map[Pair]*runIndex),
+		parseWorkers:    runtime.GOMAXPROCS(0),
+		dataDir:         "data",
+		perms:           defaultPerms,
+		client:          http.DefaultClient,
+		concurrency:     30,
+		pairConcurrency: 1,
+		schema:          DefaultSchema,
+		selfCheck:       SelfCheckManifest,
+		progress:        os.Stdout,
+		sampler:         SnapshotSampler,
+		derived:         make(map[string]func(Record) float64),
+		series:          make(map[string]map[Pair][]float64),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if l.levels > 1 {
+		l.schema = l.schema.withLevels(l.levels)
+	}
+	if l.provider == nil {
+		l.provider = chassisProvider{l}
+	}
+	if l.dayFiles && l.store == nil {
+		l.store = NewDayFileStore(l.marketDir())
+	}
+	storePerms(l.store, l.perms)
+	l.slots = make(chan struct{}, l.concurrency)
+	l.opts = opts
+	return l
+}
+
+// Option configures the CCDepthLoader.
+type Option func(l *CCDepthLoader)
+
+// WithProgress sets the writer of the download progress messages, the standard output by default.
+// Use io.Discard to silence them, or os.Stderr to keep the standard output for exported data.
+func WithProgress(w io.Writer) Option {
+	return func(l *CCDepthLoader) {
+		l.progress = w
+	}
+}
+
+// WithSchema sets the fields stored for each minute, for example only FieldMid and FieldSpread.
+// By default, the full top of book is stored (DefaultSchema).
+// The schema is recorded in the file header, and loading a file stored with a different schema panics.
+// It panics if the schema is not valid, see Schema.Validate.
+func WithSchema(fields ...Field) Option {
+	schema := Schema(fields)
+	if err := schema.Validate(); err != nil {
+		panic(err)
+	}
+	return func(l *CCDepthLoader) {
+		l.schema = schema
+	}
+}
+
+// WithParseWorkers sets the number of rows of the depth data file parsed concurrently, GOMAXPROCS by default.
+// Each pair is a row, so that the rows of large files with many pairs are parsed in parallel.
+// It panics if workers is less than 1.
+func WithParseWorkers(workers int) Option {
+	if workers < 1 {
+		panic("the number of parse workers must be positive, got " + strconv.Itoa(workers))
+	}
+	return func(l *CCDepthLoader) {
+		l.parseWorkers = workers
+	}
+}
+
+// WithNamespace keeps the cache files in their own directory of the data directory, data/<namespace>/<market>/,
+// so that several projects with different settings, like different schemas, can share a machine without
+// overwriting each other's files. By default, the files are kept in data/<market>/, see WithDataDir.
+// It panics if the namespace is not a single directory name.
+func WithNamespace(namespace string) Option {
+	if namespace == "" || namespace == "." || namespace == ".." || strings.ContainsAny(namespace, `/\`) {
+		panic("invalid namespace: " + namespace)
+	}
+	return func(l *CCDepthLoader) {
+		l.namespace = namespace
+	}
+}
+
+// WithDataDir sets the data directory of the cache files, data in the working directory by default.
+// It panics if the directory is empty.
+func WithDataDir(dir string) Option {
+	if dir == "" {
+		panic("the data directory must not be empty")
+	}
+	return func(l *CCDepthLoader) {
+		l.dataDir = dir
+	}
+}
+
+// WithConcurrency sets the number of days downloaded concurrently, 30 by default, see WithPairConcurrency.
+// It panics if n is less than 1.
+func WithConcurrency(n int) Option {
+	if n < 1 {
+		panic("the concurrency must be positive, got " + strconv.Itoa(n))
+	}
+	return func(l *CCDepthLoader) {
+		l.concurrency = n
+	}
+}
+
+// WithLogger writes the download progress messages to the logger, instead of the writer of WithProgress,
+// with a log line of each message.
+func WithLogger(logger *log.Logger) Option {
+	return func(l *CCDepthLoader) {
+		l.progress = logWriter{logger}
+	}
+}
+
+// logWriter writes each progress message as a log line.
+type logWriter struct {
+	logger *log.Logger
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	return len(p), w.logger.Output(2, strings.TrimSuffix(string(p), "\n"))
+}
+
+// WithBaseURL sets the URL of the crypto-chassis API, like a mirror or a test server,
+// https://api.cryptochassis.com by default.
+func WithBaseURL(baseURL string) Option {
+	return func(l *CCDepthLoader) {
+		l.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// ErrRangeMismatch is the error of a load of another time range than the one already loaded, see Load.
+var ErrRangeMismatch = errors.New("the loader has another time range loaded")
+
+// ErrReadOnly is the error a read-only loader panics with, when a load would write to the data directory.
+var ErrReadOnly = errors.New("the loader is read-only")
+
+// WithReadOnly never writes to the data directory, for example when it is mounted from a read-only volume.
+// Load only reads the cache files, and panics with an error wrapping ErrReadOnly if the file of the time range
+// does not exist, or lacks some of the given pairs, instead of downloading them. Without pairs, it loads the stored ones.
+func WithReadOnly() Option {
+	return func(l *CCDepthLoader) {
+		l.readOnly = true
 	}
 }
 
@@ -132,15 +295,248 @@ func (p Pair) Valid() bool {
 }
 
 type CCDepthLoader struct {
-	market  Market
-	records map[Pair][]string
-	index   int
+	market    Market
+	baseURL   string
+	namespace string
+	// dataDir is the data directory of the cache files, see WithDataDir
+	dataDir string
+	// perms are the modes and the owner of the written files, see WithFileModes and WithFileOwner
+	perms    filePerms
+	readOnly bool
+	blocks   bool
+	// dayFiles stores each day of a pair in a file of its own, see WithDayFiles
+	dayFiles bool
+	// store persists the downloaded days instead of the depth data files, see WithStore
+	store Store
+	// stats are the statistics of the last load, see Stats, guarded by mu for the pairs downloaded concurrently,
+	// and failures its failed days, see FailedDaysError
+	mu       sync.Mutex
+	stats    LoadStats
+	failures []DayFailure
+	// client sends the HTTP requests, throttled by limiter, see WithHTTPClient and WithRateLimit
+	client  *http.Client
+	limiter *rateLimiter
+	// concurrency is the number of days downloaded concurrently, across the pairConcurrency pairs, bounded by slots
+	concurrency     int
+	pairConcurrency int
+	slots           chan struct{}
+	// levels is the number of levels of each side of the book, see WithDepthLevels
+	levels int
+	// provider fetches the days to download, see WithProvider
+	provider Provider
+	// sampler reduces the snapshots of each minute of the crypto-chassis archives, see WithSampler
+	sampler Sampler
+	// warmup is the number of minutes the cursor starts past, and consumers are fed the minutes it enters,
+	// see WithWarmup and WithConsumer
+	warmup    int
+	consumers []func(pair Pair, minute int, record Record)
+	// downloadHooks correct the records of the downloaded days, see WithDownloadHook
+	downloadHooks []func(pair Pair, date time.Time, records []Record) error
+	// fed are the pairs fed to the consumers up to the cursor, nil until the cursor is past the warmup
+	fed map[Pair]bool
+	// refetchBad downloads the bad days again, see WithRefetchBad
+	refetchBad bool
+	// bad are the minutes of the bad days of the loaded pairs, see Tombstone
+	bad map[Pair][]minuteRange
+	// version is the pinned version of the files, see WithVersion
+	version int
+	offHeap bool
+	// runLengths keeps the downloaded records as runs, see WithRunLengths
+	runLengths bool
+	// runs are the runs of the pairs kept as runs, with the records of their changed minutes only
+	runs map[Pair]*runIndex
+	// movePrice and moveSize are the moves of the book that NextChange yields, see WithMoveThreshold
+	movePrice, moveSize float64
+	// seriesOnly discards the records once the series are computed, see WithSeriesOnly
+	seriesOnly bool
+	// parseWorkers is the number of rows parsed concurrently
+	parseWorkers int
+	records      map[Pair][]string
+	// values are the records parsed off heap, see WithOffHeap
+	values   map[Pair][]float64
+	schema   Schema
+	progress io.Writer
+	derived  map[string]func(Record) float64
+	series   map[string]map[Pair][]float64
+	index    int
+	// loadedLength is the number of minutes of the longest loaded pair, see Len
+	loadedLength int
+	startDate    time.Time
+	// endDate is the end of the loaded time range, zero after LoadFrom
+	endDate time.Time
+	// alignment aligns the pairs with missing days, see WithAlignment
+	alignment Alignment
+	// audit are the hashes of the records returned by GetDepth, see WithAudit
+	audit map[Pair]hash.Hash
+	// selfCheck is the consistency check of the cache files on open, see WithSelfCheck
+	selfCheck SelfCheck
+	// ctx is the context of the running load, see LoadContext
+	ctx context.Context
+	// rangeStart and rangeEnd are the time range of the loads, requested the pairs loaded for it, requestedAll set
+	// once all the pairs of the file were, and result the records returned by the last load, see Load
+	rangeStart   time.Time
+	rangeEnd     time.Time
+	requested    map[Pair]bool
+	requestedAll bool
+	result       map[Pair][]string
+	// opts are the options of the loader, applied to its datasets, see Dataset
+	opts []Option
+	// datasets are the named datasets of the loader, see Dataset
+	datasets   map[string]*CCDepthLoader
+	datasetsMu sync.Mutex
+}
+
+// marketDir returns the directory of the cache files of the market, see WithDataDir and WithNamespace.
+func (l *CCDepthLoader) marketDir() string {
+	return filepath.Join(l.dataDir, l.namespace, string(l.market))
+}
+
+// cachePath returns the path of the file of the time range in the data directory of the market.
+func (l *CCDepthLoader) cachePath(startDate time.Time, endDate time.Time) string {
+	return filepath.Join(l.marketDir(), rangeFileName(startDate, endDate))
+}
+
+func rangeFileName(startDate time.Time, endDate time.Time) string {
+	return startDate.Format("2006-01-02") + "_" + endDate.Format("2006-01-02") + "_depth.csv"
+}
+
+// migrateLegacyCache moves the file of the time range from the data directory root, where it was stored
+// before the files were kept per market, to the market directory, so that it is not downloaded again.
+// The legacy path has no market in it, so the file goes to the first market loading its time range.
+// The namespaced loaders never had legacy files. A read-only loader reads the legacy file where it is.
+// It returns the path of the file to load.
+func (l *CCDepthLoader) migrateLegacyCache(path string, startDate time.Time, endDate time.Time) string {
+	if l.namespace != "" {
+		return path
+	}
+	legacy := filepath.Join(l.dataDir, rangeFileName(startDate, endDate))
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		return path
+	}
+	if _, err := os.Stat(legacy); err != nil {
+		return path
+	}
+	if l.readOnly {
+		return legacy
+	}
+	if err := l.perms.mkdirAll(filepath.Dir(path)); err != nil {
+		panic(err)
+	}
+	if err := os.Rename(legacy, path); err != nil {
+		panic(err)
+	}
+	fmt.Fprintln(l.progress, "Moved", legacy, "to", path)
+	return path
 }
 
+// Load loads the pairs for the time range, see Loader. Loading again pairs already loaded is a no-op, returning the
+// same records, and a loader loads a single time range: loading another one panics with an error wrapping
+// ErrRangeMismatch, use another loader instead.
 func (l *CCDepthLoader) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string {
-	path := "data/" + startDate.Format("2006-01-02") + "_" + endDate.Format("2006-01-02") + "_depth.csv"
+	records, err := l.LoadContext(context.Background(), pairs, startDate, endDate)
+	if err != nil {
+		panic(err)
+	}
+	return records
+}
+
+// LoadContext is Load with a context, so that a long download can be cancelled, or bounded by a deadline.
+// The HTTP requests are aborted when the context is done, and LoadContext returns its error.
+// The pairs downloaded before are kept in the file, but no pair is written with some of its days missing,
+// so that the next load downloads the others again. When some days fail to download, the other pairs are loaded,
+// and it returns their records with a *FailedDaysError, to download only the failed days again, see RetryFailed,
+// while Load panics with it. It returns an error wrapping ErrRangeMismatch for another
+// time range than the loaded one, and still panics on the other errors, like Load.
+func (l *CCDepthLoader) LoadContext(ctx context.Context, pairs []Pair, startDate time.Time, endDate time.Time) (records map[Pair][]string, err error) {
+	if !l.rangeStart.IsZero() {
+		if !startDate.Equal(l.rangeStart) || !endDate.Equal(l.rangeEnd) {
+			return nil, fmt.Errorf("%w: %s - %s, not %s - %s", ErrRangeMismatch,
+				l.rangeStart.Format("2006-01-02"), l.rangeEnd.Format("2006-01-02"),
+				startDate.Format("2006-01-02"), endDate.Format("2006-01-02"))
+		}
+	}
+	started := time.Now()
+	l.stats = LoadStats{Pairs: make(map[Pair]PairStats)}
+	if !l.rangeStart.IsZero() && l.loadedAll(pairs) {
+		l.completeStats(pairs, startDate, endDate, started)
+		return l.result, nil
+	}
+	l.ctx, l.failures = ctx, nil
+	defer func() {
+		l.ctx = nil
+		l.completeStats(pairs, startDate, endDate, started)
+		if r := recover(); r != nil {
+			if cause, ok := r.(error); ok && cause == ctx.Err() {
+				records, err = nil, cause
+				return
+			}
+			panic(r)
+		}
+	}()
+	records = l.loadDepth(pairs, startDate, endDate)
+	l.rangeStart, l.rangeEnd, l.result = startDate, endDate, records
+	if l.offHeap {
+		// the loader doesn't keep the records of the off heap values, a repeated load reads none
+		l.result = make(map[Pair][]string)
+	}
+	if l.requested == nil {
+		l.requested = make(map[Pair]bool)
+	}
+	failed := &FailedDaysError{Failures: l.failures, loader: l, startDate: startDate, endDate: endDate}
+	for _, pair := range pairs {
+		l.requested[pair] = !containsPair(failed.Pairs(), pair)
+	}
+	for pair := range records {
+		l.requested[pair] = true
+	}
+	if len(l.failures) > 0 {
+		return records, failed
+	}
+	l.requestedAll = l.requestedAll || len(pairs) == 0
+	return records, nil
+}
+
+// loadedAll checks if the pairs were all loaded before, including those without data, see Load.
+// No pairs are all the pairs of the file, see Loader.
+func (l *CCDepthLoader) loadedAll(pairs []Pair) bool {
+	if len(pairs) == 0 {
+		return l.requestedAll
+	}
+	for _, pair := range pairs {
+		if !l.requested[pair] {
+			return false
+		}
+	}
+	return true
+}
+
+// context returns the context of the running load, see LoadContext.
+func (l *CCDepthLoader) context() context.Context {
+	if l.ctx == nil {
+		return context.Background()
+	}
+	return l.ctx
+}
+
+// canceled panics with the error of the context of the load once it is done, see LoadContext.
+func (l *CCDepthLoader) canceled() {
+	if err := l.context().Err(); err != nil {
+		panic(err)
+	}
+}
+
+// loadDepth is the Load of the running LoadContext.
+func (l *CCDepthLoader) loadDepth(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string {
+	if l.blocks {
+		return l.loadBlocks(pairs, startDate, endDate)
+	}
+	if l.store != nil {
+		return l.loadStore(pairs, startDate, endDate)
+	}
+	path := l.migrateLegacyCache(l.cachePath(startDate, endDate), startDate, endDate)
 	// historyLength is number of minutes between start and end date
 	historyLength := int(endDate.Sub(startDate).Minutes())
+	l.startDate, l.endDate = startDate, endDate
 
 	var pairsToLoad []Pair
 
@@ -151,6 +547,7 @@ func (l *CCDepthLoader) Load(pairs []Pair, startDate time.Time, endDate time.Tim
 	}
 
 	fileExists := false
+	var index *fileIndex
 
 	if _, err := os.Stat(path); err == nil {
 		// open read mode
@@ -161,176 +558,277 @@ func (l *CCDepthLoader) Load(pairs []Pair, startDate time.Time, endDate time.Tim
 		defer file.Close()
 
 		fileExists = true
-		testPairs := pairs[0:]
-		fileHistoryLength := l.readDepthRecordsFromFile(file, testPairs)
+		if schema := l.readSchemaFromHeader(file); !schema.Equal(l.schema) {
+			panic("file schema " + schema.String() + " does not match the loader schema " + l.schema.String())
+		}
+		// the pairs kept as series only are not read again, see WithSeriesOnly
+		testPairs := slices.Filter(pairs, func(s Pair) bool {
+			return !l.hasSeries(s)
+		})
+		// a file truncated below its last version panics, see WithSelfCheck
+		if l.selfCheck != SelfCheckNone {
+			if err := l.checkVersions(file, path, historyLength); err != nil {
+				panic(err)
+			}
+		}
+		// the rows appended after the pinned version are not read, see WithVersion
+		size := l.versionSize(file, path)
+		if l.selfCheck == SelfCheckFull {
+			if err := l.checkRows(file, path, size, historyLength); err != nil {
+				panic(err)
+			}
+		}
+		var fileHistoryLength uint
+		if len(testPairs) > 0 {
+			// read only the rows of the requested pairs
+			index = l.openIndex(file, path)
+			fileHistoryLength = l.readIndexedRecords(file, index.at(size), testPairs)
+		} else if len(pairs) == 0 {
+			fileHistoryLength = l.readDepthRecords(io.NewSectionReader(file, 0, size), testPairs)
+		}
 
-		if fileHistoryLength != 0 && math.Abs(float64(fileHistoryLength)-float64(historyLength)) >= 1400 {
-			panic("file history length does not match the range for more than 1 day")
+		if l.selfCheck != SelfCheckNone && fileHistoryLength != 0 && math.Abs(float64(fileHistoryLength)-float64(historyLength)) >= 1400 {
+			panic(fmt.Errorf("%w: %s history length does not match the range for more than 1 day", ErrCorrupted, path))
 		}
 
 		pairsToLoad = testPairs[0:]
-		if len(pairsToLoad) == 0 {
+		if len(pairs) == 0 {
 			pairsToLoad = l.readPairNamesFromHeader(file)
 		}
 		pairsToLoad = slices.Filter(pairsToLoad, func(s Pair) bool {
-			return l.records[s] == nil
+			return l.records[s] == nil && l.values[s] == nil && !l.hasSeries(s)
 		})
 		if len(pairsToLoad) > 0 {
-			fmt.Println("Missing prices will be fetched and appended to the file")
+			_, _ = fmt.Fprintln(l.progress, "Missing prices will be fetched and appended to the file")
+			if index == nil && !l.readOnly {
+				index = l.openIndex(file, path)
+			}
 		}
 	}
 
+	if l.readOnly {
+		if !fileExists {
+			panic(fmt.Errorf("%w: %s does not exist", ErrReadOnly, path))
+		}
+		if len(pairs) > 0 && len(pairsToLoad) > 0 {
+			panic(fmt.Errorf("%w: %s has no data for %s", ErrReadOnly, path, slices.Join(pairsToLoad, ", ")))
+		}
+		return l.loaded()
+	}
+
 	// make sure the directory exists
-	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+	if err := l.perms.mkdirAll(filepath.Dir(path)); err != nil {
 		panic(err)
 	}
-	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	file, err := l.perms.openAppend(path)
 	if err != nil {
 		panic(err)
 	}
 	defer file.Close()
 
 	if !fileExists {
+		index = &fileIndex{rows: make(map[Pair]rowSpan)}
 		// Put pairs in the file header as a comment
-		_, err = file.WriteString(fmt.Sprintf("#,%s\n", slices.Join(defaultPairs, ",")))
-		if err != nil {
+		header := joinCSV(append([]string{"#"}, slices.Map(defaultPairs, Pair.String)...)...) + "\n"
+		// Put the stored fields in the second header line, unless it's the default schema
+		if !l.schema.Equal(DefaultSchema) {
+			header += l.schema.header() + "\n"
+		}
+		if _, err = file.WriteString(header); err != nil {
 			panic(err)
 		}
+		index.size += int64(len(header))
 	}
 
 	// load data for missing pairs
-	slices.Each(pairsToLoad, func(pair Pair) {
+	previousSize := index.size
+	var appended []Pair
+	minutes := make(map[Pair]int)
+	var mu sync.Mutex
+	eachPair(pairsToLoad, l.pairConcurrency, func(pair Pair) {
+		if l.context().Err() != nil {
+			return
+		}
 		var days []time.Time
 		for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
 			days = append(days, date)
 		}
-		recordsForEachDay := slices.MapAsync(days, 30, func(date time.Time) []string {
-			fmt.Println("Downloading depth for", pair, date)
-			return l.downloadDay(pair, date)
-		})
-		var fullRecord = slices.Concat(recordsForEachDay...)
+		recordsForEachDay, failures := l.downloadDays(pair, days, l.checkpoint(pair))
+		// the pair is not written with the days not downloaded after the load was cancelled
+		if l.context().Err() != nil {
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		// nor with its failed days, the others are kept by its checkpoint for the next load
+		if len(failures) > 0 {
+			l.failures = append(l.failures, failures...)
+			return
+		}
+		var fullRecord = l.schema.project(slices.Concat(l.padDays(recordsForEachDay, l.schema.fullSchema().Width())...))
 		if len(fullRecord) == 0 {
+			l.dropCheckpoint(pair)
 			return
 		}
-		l.records[pair] = fullRecord
-		_, err = file.WriteString(fmt.Sprintf("%s,%s\n", pair, slices.Join(fullRecord, ",")))
-		if err != nil {
+		l.setRecords(pair, fullRecord)
+		row := l.formatRow(pair)
+		if _, err := file.WriteString(row); err != nil {
 			panic(err)
 		}
+		index.add(pair, len(row))
+		appended = append(appended, pair)
+		minutes[pair] = len(fullRecord) / len(l.schema)
+		l.dropCheckpoint(pair)
 	})
 
+	// the refetched pairs are appended again, their last row replaces the previous ones
+	if l.refetchBad && l.context().Err() == nil {
+		refetched := l.refetchBadDays(startDate, endDate)
+		if len(refetched) > 0 && index == nil {
+			index = l.openIndexOf(path)
+		}
+		for _, pair := range refetched {
+			row := l.formatRow(pair)
+			if _, err = file.WriteString(row); err != nil {
+				panic(err)
+			}
+			index.add(pair, len(row))
+			appended = append(appended, pair)
+			minutes[pair] = l.length(pair)
+		}
+	}
+
+	if len(appended) > 0 && index != nil {
+		if err := index.write(path, l.perms); err != nil {
+			panic(err)
+		}
+	}
+	if len(appended) > 0 {
+		if !fileExists {
+			// the header is part of the first version
+			previousSize = 0
+		}
+		if err := recordVersion(l.perms, path, previousSize, index.size, appended, minutes); err != nil {
+			panic(err)
+		}
+	}
+	l.canceled()
 	if len(pairsToLoad) > 0 {
-		fmt.Println("Depth data written to", path)
+		_, _ = fmt.Fprintln(l.progress, "Depth data written to", path)
 	}
 
-	return l.records
+	return l.loaded()
 }
 
-func (l *CCDepthLoader) downloadDay(pair Pair, date time.Time) (S []string) {
-	url := l.getURL(pair.String(), date)
-	resp, err := http.Get(url)
-	if err != nil {
-		panic(err)
+// loaded completes a load: it trims the pairs with AlignTrim, computes the series, moves the cursor past the warmup,
+// parses the records into values, and returns the records read or downloaded by the load.
+func (l *CCDepthLoader) loaded() map[Pair][]string {
+	if l.alignment == AlignTrim {
+		l.trimDays()
 	}
-	defer resp.Body.Close()
-	gz, err := gzip.NewReader(resp.Body)
-	if err != nil {
-		panic(err)
+	l.loadBadDays()
+	l.computeSeries()
+	if l.warmup > 0 || len(l.consumers) > 0 {
+		l.warmUp()
 	}
-	defer gz.Close()
-
-	// Parse CSV into structure and keep in memory
-	reader := csv.NewReader(gz)
-	reader.FieldsPerRecord = -1
+	var records map[Pair][]string
+	if l.seriesOnly {
+		records = l.discardRecords()
+	} else {
+		records = l.parseValues()
+	}
+	l.loadedLength = l.longestLength()
+	return records
+}
 
-	// date is for every second, but we need only each minute
-	var prevRecord []string
-	var prevRecordTime time.Time
-	var records [][]string
-	for {
-		record, err := reader.Read()
-		if err == io.EOF {
-			break
+// downloadDays downloads the days of the pair concurrently, see WithConcurrency, except those of the checkpoint,
+// and writes each downloaded day to the checkpoint, if any. It returns the failures of the days, nil values for them.
+func (l *CCDepthLoader) downloadDays(pair Pair, days []time.Time, checkpoint *DayFileStore) ([][]string, []DayFailure) {
+	errs := make([]error, len(days))
+	reused := make([]bool, len(days))
+	stats := make([]dayStats, len(days))
+	started := time.Now()
+	values := slices.MapAsync(days, l.concurrency, func(date time.Time) (values []string) {
+		i := 0
+		for !days[i].Equal(date) {
+			i++
 		}
-		if record[0] == "time_seconds" {
-			continue
+		if values, ok := checkpointed(checkpoint, pair, date); ok {
+			reused[i] = true
+			return values
 		}
-		// Parse time seconds into time
-		s, _ := strconv.ParseInt(record[0], 10, 64)
-		timeSeconds := time.Unix(s, 0)
-
-		// if the gap between two records is more than 1 second, we should reuse the previous record
-		if !prevRecordTime.IsZero() && timeSeconds.Sub(prevRecordTime) > time.Second {
-			// add previous record for each missing minute
-			for prevRecordTime.Add(time.Minute).Before(timeSeconds) {
-				prevRecordTime = prevRecordTime.Add(time.Minute)
-				records = append(records, prevRecord)
+		defer func() {
+			if r := recover(); r != nil {
+				err, ok := r.(error)
+				if !ok {
+					err = fmt.Errorf("%v", r)
+				}
+				errs[i] = err
 			}
+		}()
+		if !l.acquire() {
+			return nil
 		}
-
-		if timeSeconds.Second() == 0 {
-			bidPriceAndSize := strings.Split(record[1], "_")
-			askPriceAndSize := strings.Split(record[2], "_")
-			record = []string{
-				bidPriceAndSize[0],
-				bidPriceAndSize[1],
-				askPriceAndSize[0],
-				askPriceAndSize[1],
+		defer l.release()
+		_, _ = fmt.Fprintln(l.progress, "Downloading depth for", pair, date)
+		values = l.downloadDay(withDayStats(l.context(), &stats[i]), pair, date)
+		if checkpoint != nil && l.context().Err() == nil {
+			if err := checkpoint.WriteDay(pair, date, values); err != nil {
+				panic(err)
 			}
-
-			records = append(records, record)
-			prevRecord = record
-			prevRecordTime = timeSeconds
+		}
+		return values
+	})
+	fetched := 0
+	for _, r := range reused {
+		if !r {
+			fetched++
 		}
 	}
-	if len(records) > 0 {
-		// join records into one line
-		fullRec := slices.Concat(records...)
-		numbersPerRecord := 4
-		minutesInADay := 1440
-		if len(fullRec) != numbersPerRecord*minutesInADay {
-			panic("wrong number of records: " + strconv.Itoa(len(fullRec)))
+	l.addDownload(pair, fetched, len(days)-fetched, stats, time.Since(started))
+	var failures []DayFailure
+	for i, err := range errs {
+		if err != nil {
+			failures = append(failures, DayFailure{Pair: pair, Day: days[i], Err: err})
 		}
-		return fullRec
 	}
-	return nil
+	return values, failures
 }
 
-func (l *CCDepthLoader) getURL(pair string, date time.Time) string {
-	url := "https://api.cryptochassis.com/v1/market-depth/" +
-		string(l.market) + "/" +
-		pair +
-		"?startTime=" + date.Format("2006-01-02")
-
-	resp, err := http.Get(url)
+// downloadDay downloads the values of each minute of the day of the pair from the provider, see WithProvider,
+// or returns nil if it has none, or if the context of the load was cancelled, see LoadContext.
+func (l *CCDepthLoader) downloadDay(ctx context.Context, pair Pair, date time.Time) []string {
+	records, err := l.provider.FetchDay(ctx, l.market, pair, date)
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
 		panic(err)
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		panic(err)
+	if len(records) == 0 {
+		return nil
 	}
-	var result map[string]interface{}
-	err = json.Unmarshal(body, &result)
-	if err != nil {
-		// check if error is Timeout then repeat the request after 1 second
-		if strings.Contains(string(body), "Too many requests, please try again later.") {
-			time.Sleep(time.Second)
-			return l.getURL(pair, date)
-		}
+	if len(records) != 24*60 {
+		panic("wrong number of records: " + strconv.Itoa(len(records)))
+	}
+	if err := l.hookDay(pair, date, records); err != nil {
 		panic(err)
 	}
-	urls := result["urls"].([]interface{})
-	if len(urls) > 0 {
-		return urls[0].(map[string]interface{})["url"].(string)
+	full := l.schema.fullSchema()
+	values := make([]string, 0, full.Width()*len(records))
+	for _, r := range records {
+		for _, field := range full {
+			values = append(values, formatFloat(r.value(field)))
+		}
 	}
-	panic(err)
+	return values
 }
 
 func (l *CCDepthLoader) readPairNamesFromHeader(file *os.File) []Pair {
-	firstLine := l.readFirstLine(file)
-	pairNames := strings.Split(firstLine, ",")
+	pairNames, err := splitCSV(l.readFirstLine(file))
+	if err != nil {
+		panic(err)
+	}
 	if pairNames[0] == "#" {
 		return slices.Map(pairNames[1:], func(s string) Pair {
 			return Pair(s)
@@ -339,60 +837,212 @@ func (l *CCDepthLoader) readPairNamesFromHeader(file *os.File) []Pair {
 	return nil
 }
 
-func (l *CCDepthLoader) readFirstLine(file *os.File) string {
-	_, _ = file.Seek(0, 0)
-	scanner := bufio.NewScanner(file)
-	scanner.Scan()
-	return scanner.Text()
+// LoadFrom reads the depth data in the file format described for Loader from the reader,
+// for example from the standard input, instead of loading it from the data directory.
+// The startDate is the start of the time range the data was loaded for.
+// The data may be stored with any schema: the loader takes the schema from the #fields header line,
+// or uses the DefaultSchema if there is none, instead of the one set with WithSchema.
+// It returns all the read records.
+func (l *CCDepthLoader) LoadFrom(r io.Reader, startDate time.Time) map[Pair][]string {
+	l.startDate, l.endDate = startDate, time.Time{}
+	l.schema = DefaultSchema
+	reader := bufio.NewReader(r)
+	// the header lines are comments, the csv parser skips them, but the schema has to be read first
+	for {
+		next, err := reader.Peek(1)
+		if err != nil || next[0] != '#' {
+			break
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			panic(err)
+		}
+		if schema := parseSchemaHeader(strings.TrimRight(line, "\r\n")); schema != nil {
+			if err := schema.Validate(); err != nil {
+				panic(err)
+			}
+			l.schema = schema
+		}
+	}
+	l.readDepthRecords(reader, nil)
+	return l.loaded()
 }
 
-func (l *CCDepthLoader) readDepthRecordsFromFile(file *os.File, pairs []Pair) uint {
-	historyLength := uint(0)
+// readSchemaFromHeader reads the schema from the second header line.
+// Files without the schema line are stored with the DefaultSchema.
+func (l *CCDepthLoader) readSchemaFromHeader(file *os.File) Schema {
+	_, second := readHeaderLines(file)
+	if schema := parseSchemaHeader(second); schema != nil {
+		return schema
+	}
+	return DefaultSchema
+}
 
+func (l *CCDepthLoader) readFirstLine(file *os.File) string {
+	first, _ := readHeaderLines(file)
+	return first
+}
+
+// readHeaderLines returns the first line of the file, and the second one if it is a header line too.
+// The second line is a pair row otherwise, of hundreds of MB for a long time range, which is not read.
+func readHeaderLines(file *os.File) (string, string) {
 	_, _ = file.Seek(0, 0)
-	csvParser := csv.NewReader(file)
-	csvParser.FieldsPerRecord = 0
-	csvParser.TrimLeadingSpace = true
-	csvParser.Comment = '#'
+	reader := bufio.NewReader(file)
+	first, err := readLine(reader)
+	if err != nil {
+		return first, ""
+	}
+	if next, err := reader.Peek(1); err != nil || next[0] != '#' {
+		return first, ""
+	}
+	second, _ := readLine(reader)
+	return first, second
+}
 
-	foundPairs := make(map[Pair]bool)
+// readLine reads a line of any length in chunks, without its line break,
+// unlike a bufio.Scanner failing on the lines longer than its buffer.
+func readLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return strings.TrimRight(string(line), "\r\n"), err
+		}
+	}
+}
 
+// readDepthRecords reads the pair rows of the given pairs, or of all pairs if none are given.
+func (l *CCDepthLoader) readDepthRecords(r io.Reader, pairs []Pair) uint {
+	reader := bufio.NewReader(r)
+	var rows []*pairRow
+	foundPairs := make(map[Pair]bool)
 	for {
-		record, err := csvParser.Read()
+		line, err := reader.ReadString('\n')
+		if pair, ok := rowPair(line); ok && (len(pairs) == 0 || slices.Contains(pairs, pair)) {
+			rows = append(rows, &pairRow{pair: pair, line: line})
+			if len(pairs) > 0 {
+				foundPairs[pair] = true
+				if len(foundPairs) == len(pairs) {
+					break
+				}
+			}
+		}
 		if err == io.EOF {
 			break
 		}
 		if err != nil {
 			panic(err)
 		}
-		pair := Pair(record[0])
-		if len(pairs) > 0 && !slices.Contains(pairs, pair) {
-			continue
-		}
-		depths := record[1:]
-		historyLength = uint(math.Max(float64(historyLength), float64(len(depths)/4)))
-		if len(depths) > 0 && len(depths)/4 != int(historyLength) {
-			panic("file is corrupted: history length is not consistent at pair " + string(pair))
-		}
-
-		l.records[pair] = depths
+	}
+	return l.parseRows(rows)
+}
 
-		if len(pairs) > 0 {
-			foundPairs[pair] = true
-			if len(foundPairs) == len(pairs) {
-				break
+// parseRows parses the rows concurrently with the parse workers, see WithParseWorkers, and stores their records.
+// When a pair has several rows, the last one wins. It returns the history length.
+func (l *CCDepthLoader) parseRows(rows []*pairRow) uint {
+	next := make(chan *pairRow)
+	var wg sync.WaitGroup
+	for i := 0; i < l.parseWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for row := range next {
+				row.parse(l.schema.Width())
 			}
+		}()
+	}
+	for _, row := range rows {
+		next <- row
+	}
+	close(next)
+	wg.Wait()
+
+	historyLength := uint(0)
+	width := l.schema.Width()
+	for _, row := range rows {
+		if row.err != nil {
+			panic(row.err)
+		}
+		depths := row.values
+		minutes := len(depths) / width
+		if row.runs != nil {
+			minutes = row.runs.minutes
+		}
+		historyLength = uint(math.Max(float64(historyLength), float64(minutes)))
+		if len(depths) > 0 && minutes != int(historyLength) {
+			panic(fmt.Errorf("%w: history length is not consistent at pair %s", ErrCorrupted, row.pair))
+		}
+		l.records[row.pair] = depths
+		if row.runs != nil {
+			l.runs[row.pair] = row.runs
+		} else {
+			delete(l.runs, row.pair)
 		}
 	}
 	return historyLength
 }
 
+// pairRow is a row of the depth data file, parsed by a parse worker.
+type pairRow struct {
+	pair   Pair
+	line   string
+	values []string
+	// runs are the runs of a row with run lengths, see WithRunLengths
+	runs *runIndex
+	err  error
+}
+
+// parse parses the CSV line of the row into the values following the pair name,
+// and into the records of the changed minutes if it has run lengths.
+func (r *pairRow) parse(width int) {
+	parser := csv.NewReader(strings.NewReader(r.line))
+	parser.TrimLeadingSpace = true
+	record, err := parser.Read()
+	if err != nil {
+		r.err = err
+		return
+	}
+	r.values = record[1:]
+	r.line = ""
+	// the values may have trailing spaces, like after a manual edit
+	for i, v := range r.values {
+		r.values[i] = strings.TrimSpace(v)
+	}
+	if hasRuns(r.values) {
+		r.values, r.runs, r.err = parseRuns(r.values, width)
+		return
+	}
+	// a value more or less would shift the fields of all the following minutes
+	if len(r.values)%width != 0 {
+		r.err = fmt.Errorf("%w: the row of %s has %d values, not minutes of %d values", ErrCorrupted, r.pair, len(r.values), width)
+	}
+}
+
+// rowPair returns the pair of a row of the depth data file, or false for the header and the blank lines,
+// and for a row whose quoted pair is not closed.
+func rowPair(line string) (Pair, bool) {
+	if strings.HasPrefix(line, "#") || strings.TrimSpace(line) == "" {
+		return "", false
+	}
+	name, _, ok := firstCSVField(strings.TrimLeft(line, " \t"))
+	return Pair(name), ok
+}
+
 type Record struct {
-	pair     Pair
-	BidPrice float64
-	BidSize  float64
-	AskPrice float64
-	AskSize  float64
+	pair Pair
+	// Time is the minute of the record in the loaded time range, or the second of a RawSnapshot,
+	// the zero time for the records returned by a Provider.
+	// It is not encoded, the replayed snapshots having the time of their records, see Serve.
+	Time     time.Time `json:"-"`
+	BidPrice float64   `json:"bid_price"`
+	BidSize  float64   `json:"bid_size"`
+	AskPrice float64   `json:"ask_price"`
+	AskSize  float64   `json:"ask_size"`
+	// Bids and Asks are the levels of each side of the book, the first one being the top of book,
+	// or nil if only the top of book is loaded, see WithDepthLevels
+	Bids []Level `json:"bids,omitempty"`
+	Asks []Level `json:"asks,omitempty"`
 }
 
 func (r Record) SpreadPercentage() float64 {
@@ -403,26 +1053,159 @@ func (r Record) Imbalance() float64 {
 	return (r.BidSize - r.AskSize) / (r.BidSize + r.AskSize)
 }
 
-func (l *CCDepthLoader) Tick() {
-	l.index += 4
+// BidNotional returns the size of the best bid in the quote currency, its price times its size in the base currency.
+func (r Record) BidNotional() float64 {
+	return r.BidPrice * r.BidSize
+}
+
+// AskNotional returns the size of the best ask in the quote currency, see BidNotional.
+func (r Record) AskNotional() float64 {
+	return r.AskPrice * r.AskSize
+}
+
+// TouchNotional returns the size of both sides of the top of book in the quote currency.
+func (r Record) TouchNotional() float64 {
+	return r.BidNotional() + r.AskNotional()
+}
+
+// Tick moves the cursor to the next minute, feeding it to the consumers, see WithConsumer.
+// It returns false once the cursor is past the last minute of the longest loaded pair, see Done,
+// so that the loop of a backtest ends there instead of GetDepth panicking.
+func (l *CCDepthLoader) Tick() bool {
+	l.index++
+	l.consume(l.index)
+	return !l.Done()
+}
+
+// Done returns true once the cursor is past the last minute of the longest loaded pair, or if no pair is loaded.
+func (l *CCDepthLoader) Done() bool {
+	return l.index >= l.Len()
+}
+
+// HasNext returns true if the next Tick moves the cursor to a loaded minute.
+func (l *CCDepthLoader) HasNext() bool {
+	return l.Remaining() > 0
+}
+
+// Len returns the number of loaded minutes, those of the longest loaded pair.
+func (l *CCDepthLoader) Len() int {
+	return l.loadedLength
+}
+
+// longestLength returns the number of minutes of the longest loaded pair, kept as the Len of the loaded pairs
+// once they are loaded, so that each Tick doesn't list the pairs.
+func (l *CCDepthLoader) longestLength() int {
+	length := 0
+	for _, pair := range l.loadedPairs() {
+		if l.length(pair) > length {
+			length = l.length(pair)
+		}
+	}
+	return length
+}
+
+// Remaining returns the number of loaded minutes after the one of the cursor.
+func (l *CCDepthLoader) Remaining() int {
+	if remaining := l.Len() - l.index - 1; remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// CurrentTime returns the minute of the cursor in the loaded time range, the time of the records of GetDepth.
+func (l *CCDepthLoader) CurrentTime() time.Time {
+	return l.minuteTime(l.index)
 }
 
+// GetDepth returns the record of the pair at the minute of the cursor. It panics with an error wrapping ErrOutOfRange
+// if the pair has no record at the minute, like past the end of the loaded data, see Done.
 func (l *CCDepthLoader) GetDepth(pair Pair) Record {
-	if l.index >= len(l.records[pair]) {
-		panic("index out of range")
+	record := l.recordAt(pair, l.index)
+	if l.audit != nil {
+		l.auditRecord(pair, l.index, record)
+	}
+	return record
+}
+
+// GetDepths returns the current depth records of all the loaded pairs, see GetDepthsFor.
+func (l *CCDepthLoader) GetDepths() map[Pair]Record {
+	return l.GetDepthsFor(l.loadedPairs())
+}
+
+// GetDepthsFor returns the current depth records of the pairs, all of the same minute, in one call instead of
+// a GetDepth of each pair. Like GetDepth, it panics with an error wrapping ErrOutOfRange if a pair has no record
+// at the minute, like a pair shorter than the others, so that the records are never of different minutes.
+func (l *CCDepthLoader) GetDepthsFor(pairs []Pair) map[Pair]Record {
+	records := make(map[Pair]Record, len(pairs))
+	for _, pair := range pairs {
+		records[pair] = l.GetDepth(pair)
+	}
+	return records
+}
+
+// loadedPairs returns the loaded pairs in alphabetical order.
+func (l *CCDepthLoader) loadedPairs() []Pair {
+	pairs := make([]Pair, 0, len(l.records)+len(l.values))
+	for pair := range l.records {
+		pairs = append(pairs, pair)
 	}
-	record := l.records[pair][l.index : l.index+4]
-	return Record{
-		pair:     pair,
-		BidPrice: mustParseFloat(record[0]),
-		BidSize:  mustParseFloat(record[1]),
-		AskPrice: mustParseFloat(record[2]),
-		AskSize:  mustParseFloat(record[3]),
+	for pair := range l.values {
+		if _, ok := l.records[pair]; !ok {
+			pairs = append(pairs, pair)
+		}
+	}
+	sortPairs(pairs)
+	return pairs
+}
+
+// sortPairs sorts the pairs in alphabetical order.
+func sortPairs(pairs []Pair) {
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i] < pairs[j]
+	})
+}
+
+// length returns the number of 1 minute records loaded for the given pair.
+func (l *CCDepthLoader) length(pair Pair) int {
+	if runs, ok := l.runs[pair]; ok {
+		return runs.minutes
+	}
+	// the records read by the current load replace the values moved off heap by the previous one
+	if records, ok := l.records[pair]; ok {
+		return len(records) / l.schema.Width()
 	}
+	return len(l.values[pair]) / l.schema.Width()
+}
+
+// recordAt returns the depth record for the given pair at the given minute of the loaded range.
+func (l *CCDepthLoader) recordAt(pair Pair, minute int) Record {
+	if minute < 0 || minute >= l.length(pair) {
+		panic(fmt.Errorf("%w: %s has no record at %s", ErrOutOfRange, pair, l.minuteTime(minute).UTC().Format(time.RFC3339)))
+	}
+	if l.isBad(pair, minute) {
+		return l.nanRecord(pair, minute)
+	}
+	width := l.schema.Width()
+	index := l.valueIndex(pair, minute)
+	var record Record
+	if records, ok := l.records[pair]; ok {
+		record = l.schema.record(pair, records[index:index+width])
+	} else {
+		record = l.schema.recordOf(pair, l.values[pair][index:index+width])
+	}
+	record.Time = l.minuteTime(minute)
+	return record
+}
+
+// nanRecord returns the record of the pair at the minute without data, the one of a bad day, see Tombstone.
+func (l *CCDepthLoader) nanRecord(pair Pair, minute int) Record {
+	nan := math.NaN()
+	return Record{pair: pair, Time: l.minuteTime(minute), BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan}
 }
 
+// mustParseFloat parses a value, in decimal or scientific notation, ignoring the surrounding spaces.
 func mustParseFloat(s string) float64 {
-	f, err := strconv.ParseFloat(s, 64)
+	f, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,118 @@
+package depth
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// LoadStats are the statistics of the last load of the loader, see Stats, so that a pipeline budgets the usage
+// of the vendor, and detects the performance regressions of its loads over time.
+type LoadStats struct {
+	// Duration is the wall time of the load.
+	Duration time.Duration `json:"duration"`
+	// Pairs are the statistics of the loaded pairs, and of those downloaded without data.
+	Pairs map[Pair]PairStats `json:"pairs"`
+}
+
+// PairStats are the statistics of a pair in a load.
+type PairStats struct {
+	// DaysFetched is the number of days downloaded, and DaysReused the number of days of the time range
+	// read from the cache, or from the checkpoint of a pair not written yet, see WithPairConcurrency.
+	DaysFetched int `json:"days_fetched"`
+	DaysReused  int `json:"days_reused"`
+	// Bytes is the number of bytes of the HTTP responses of the downloaded days, as received, so gzipped for the
+	// crypto-chassis archives. The days of a Provider count only the responses of the client of the loader.
+	Bytes int64 `json:"bytes"`
+	// Duration is the wall time of the download of the days.
+	Duration time.Duration `json:"duration"`
+	// Retries is the number of requests sent again after a "Too many requests" response.
+	Retries int `json:"retries"`
+}
+
+// Days returns the number of days of the pair in the load.
+func (s PairStats) Days() int {
+	return s.DaysFetched + s.DaysReused
+}
+
+// Stats returns the statistics of the last load, the zero LoadStats before the first.
+func (l *CCDepthLoader) Stats() LoadStats {
+	return l.stats
+}
+
+// dayStats counts the bytes and the retries of the requests of a downloaded day, see withDayStats.
+type dayStats struct {
+	bytes   int64
+	retries int64
+}
+
+type dayStatsKey struct{}
+
+// withDayStats returns the context counting the requests sent with it in the stats, see get.
+func withDayStats(ctx context.Context, stats *dayStats) context.Context {
+	return context.WithValue(ctx, dayStatsKey{}, stats)
+}
+
+// dayStatsOf returns the stats of the context, nil if it counts none.
+func dayStatsOf(ctx context.Context) *dayStats {
+	stats, _ := ctx.Value(dayStatsKey{}).(*dayStats)
+	return stats
+}
+
+// countRetry counts a retry in the stats of the context.
+func countRetry(ctx context.Context) {
+	if stats := dayStatsOf(ctx); stats != nil {
+		atomic.AddInt64(&stats.retries, 1)
+	}
+}
+
+// countingBody counts the bytes read from a response body in the stats.
+type countingBody struct {
+	io.ReadCloser
+	stats *dayStats
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(&b.stats.bytes, int64(n))
+	return n, err
+}
+
+// addDownload adds the downloaded days of the pair to the stats of the load.
+func (l *CCDepthLoader) addDownload(pair Pair, fetched int, reused int, stats []dayStats, duration time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	pairStats := l.stats.Pairs[pair]
+	pairStats.DaysFetched += fetched
+	pairStats.DaysReused += reused
+	pairStats.Duration += duration
+	for _, s := range stats {
+		pairStats.Bytes += s.bytes
+		pairStats.Retries += int(s.retries)
+	}
+	l.stats.Pairs[pair] = pairStats
+}
+
+// completeStats completes the stats of a load of the pairs in the time range: the days of the loaded pairs
+// which were not downloaded are reused.
+func (l *CCDepthLoader) completeStats(pairs []Pair, startDate time.Time, endDate time.Time, started time.Time) {
+	if len(pairs) == 0 {
+		pairs = defaultPairs
+	}
+	days := 0
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days++
+	}
+	for _, pair := range pairs {
+		if l.length(pair) == 0 && !l.hasSeries(pair) {
+			continue
+		}
+		pairStats := l.stats.Pairs[pair]
+		if reused := days - pairStats.Days(); reused > 0 {
+			pairStats.DaysReused += reused
+		}
+		l.stats.Pairs[pair] = pairStats
+	}
+	l.stats.Duration = time.Since(started)
+}
@@ -0,0 +1,74 @@
+package depth
+
+import "time"
+
+// WithOffHeap keeps the loaded values parsed as float64 in memory allocated outside of the Go heap,
+// instead of the Go heap. The garbage collector doesn't scan that memory, which avoids long GC pauses
+// when ticking through years of data of many pairs. On platforms without anonymous memory maps, the values stay
+// on the Go heap.
+//
+// A repeated Load of the loaded pairs then returns no records, which the loader doesn't keep,
+// and Close must be called to release the memory once the loader isn't used anymore.
+func WithOffHeap() Option {
+	return func(l *CCDepthLoader) {
+		l.offHeap = true
+	}
+}
+
+// parseValues parses the loaded records into float64 values once, off heap with WithOffHeap,
+// so that GetDepth reads the values without parsing them, and returns the records it parsed.
+func (l *CCDepthLoader) parseValues() map[Pair][]string {
+	records := l.records
+	for pair, strings := range records {
+		values, err := l.allocValues(len(strings))
+		if err != nil {
+			panic(err)
+		}
+		for i, s := range strings {
+			values[i] = mustParseFloat(s)
+		}
+		if old, ok := l.values[pair]; ok {
+			if err := l.freeValues(old); err != nil {
+				panic(err)
+			}
+		}
+		l.values[pair] = values
+	}
+	l.records = make(map[Pair][]string)
+	return records
+}
+
+// allocValues allocates n values, off heap with WithOffHeap.
+func (l *CCDepthLoader) allocValues(n int) ([]float64, error) {
+	if l.offHeap {
+		return allocFloats(n)
+	}
+	return make([]float64, n), nil
+}
+
+// freeValues releases the values allocated by allocValues.
+func (l *CCDepthLoader) freeValues(values []float64) error {
+	if l.offHeap {
+		return freeFloats(values)
+	}
+	return nil
+}
+
+// Close releases the memory of the values kept off heap with WithOffHeap, and unloads all pairs,
+// so that the loader can load another time range, see Load.
+// The loader must not be used with the released values anymore, so it can't be closed while it is serving.
+// Without WithOffHeap, it only unloads the pairs.
+func (l *CCDepthLoader) Close() error {
+	var err error
+	for pair, values := range l.values {
+		if freeErr := l.freeValues(values); freeErr != nil && err == nil {
+			err = freeErr
+		}
+		delete(l.values, pair)
+	}
+	l.records = make(map[Pair][]string)
+	l.runs = make(map[Pair]*runIndex)
+	l.rangeStart, l.rangeEnd, l.requested, l.requestedAll, l.result = time.Time{}, time.Time{}, nil, false, nil
+	l.index, l.fed, l.loadedLength = 0, nil, 0
+	return err
+}
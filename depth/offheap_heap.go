@@ -0,0 +1,13 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package depth
+
+// allocFloats allocates n float64 values on the heap, as there are no anonymous memory maps on the platform.
+func allocFloats(n int) ([]float64, error) {
+	return make([]float64, n), nil
+}
+
+// freeFloats leaves the values to the garbage collector.
+func freeFloats(values []float64) error {
+	return nil
+}
@@ -0,0 +1,28 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package depth
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// allocFloats allocates n float64 values in an anonymous memory map.
+func allocFloats(n int) ([]float64, error) {
+	if n == 0 {
+		return []float64{}, nil
+	}
+	b, err := syscall.Mmap(-1, 0, 8*n, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_ANON|syscall.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	return unsafe.Slice((*float64)(unsafe.Pointer(&b[0])), n), nil
+}
+
+// freeFloats unmaps the values allocated with allocFloats.
+func freeFloats(values []float64) error {
+	if len(values) == 0 {
+		return nil
+	}
+	return syscall.Munmap(unsafe.Slice((*byte)(unsafe.Pointer(&values[0])), 8*len(values)))
+}
@@ -0,0 +1,280 @@
+package depth
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ohlcvHeader is the first line of the cache files of the candles, with their columns after the time.
+const ohlcvHeader = "open,high,low,close,volume"
+
+// Candle is the OHLCV candle of a minute, from its start Time to the start of the next minute.
+type Candle struct {
+	Time  time.Time `json:"time"`
+	Open  float64   `json:"open"`
+	High  float64   `json:"high"`
+	Low   float64   `json:"low"`
+	Close float64   `json:"close"`
+	// Volume is the traded amount in the base currency, NaN for the candles derived from the depth records, see Derive
+	Volume float64 `json:"volume"`
+}
+
+// CCCandleLoader loads the 1 minute candles of the crypto-chassis OHLC endpoint, see Load, or derives them
+// from the mid prices of the depth records of its loader, see Derive, and returns them with the cursor
+// of the loader, see GetCandle. The downloaded days of a pair are cached in data/<market>/ohlc/<pair>/<day>.csv,
+// with a line per minute, the minutes without trades having NaN prices and no volume:
+//
+//	open,high,low,close,volume
+//	16544.2,16551.3,16540.1,16549.9,12.5
+//	...
+type CCCandleLoader struct {
+	depth *CCDepthLoader
+	// startDate and endDate are the time range of the candles of each pair
+	startDate time.Time
+	endDate   time.Time
+	candles   map[Pair][]Candle
+}
+
+// Candles returns the loader of the candles of the pairs of the loader, iterated with its cursor, see GetCandle.
+// The candles are always downloaded from crypto-chassis, see WithBaseURL, even with another Provider.
+func (l *CCDepthLoader) Candles() *CCCandleLoader {
+	return &CCCandleLoader{depth: l, candles: make(map[Pair][]Candle)}
+}
+
+// setRange sets the time range of the candles, and panics with an error wrapping ErrRangeMismatch
+// if the candles of another time range are loaded.
+func (c *CCCandleLoader) setRange(startDate time.Time, endDate time.Time) {
+	if !c.startDate.IsZero() && (!startDate.Equal(c.startDate) || !endDate.Equal(c.endDate)) {
+		panic(fmt.Errorf("%w: %s - %s, not %s - %s", ErrRangeMismatch,
+			c.startDate.Format("2006-01-02"), c.endDate.Format("2006-01-02"),
+			startDate.Format("2006-01-02"), endDate.Format("2006-01-02")))
+	}
+	c.startDate, c.endDate = startDate, endDate
+}
+
+// Load loads the candles of the pairs for the time range, downloading the days missing from the cache,
+// and returns the candles of each pair. Like the depth loader, it loads a single time range: loading another one
+// panics with an error wrapping ErrRangeMismatch. It panics if the endpoint lists no candles for a day.
+func (c *CCCandleLoader) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]Candle {
+	c.setRange(startDate, endDate)
+	var days []time.Time
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days = append(days, date)
+	}
+	result := make(map[Pair][]Candle, len(pairs))
+	for _, pair := range pairs {
+		if candles, ok := c.candles[pair]; ok {
+			result[pair] = candles
+			continue
+		}
+		candles := loadDays(c.depth.concurrency, days, func(date time.Time) []Candle {
+			return c.loadDay(pair, date)
+		})
+		if length := int(endDate.Sub(startDate).Minutes()); len(candles) > length {
+			candles = candles[:length]
+		}
+		c.candles[pair], result[pair] = candles, candles
+	}
+	return result
+}
+
+// Derive derives the candles of the loaded pairs of the depth loader, all of them if none are given, from the mid prices
+// of their records, and returns the candles of each pair. The candle of a minute opens at the mid price of its record
+// and closes at the one of the next minute, its high and low being the higher and the lower of both, so that the last
+// loaded minute has no candle. The candles have no volume, and are NaN for the minutes without a mid price.
+// It panics with an error wrapping ErrRangeMismatch if the loader has the candles of another time range.
+func (c *CCCandleLoader) Derive(pairs []Pair) map[Pair][]Candle {
+	l := c.depth
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	c.setRange(l.startDate, l.minuteTime(l.gridLength(l.loadedPairs())))
+	result := make(map[Pair][]Candle, len(pairs))
+	for _, pair := range pairs {
+		var candles []Candle
+		for minute := 0; minute+1 < l.length(pair); minute++ {
+			first, last := l.recordAt(pair, minute).Mid(), l.recordAt(pair, minute+1).Mid()
+			candles = append(candles, Candle{
+				Time:   l.minuteTime(minute).UTC(),
+				Open:   first,
+				High:   math.Max(first, last),
+				Low:    math.Min(first, last),
+				Close:  last,
+				Volume: math.NaN(),
+			})
+		}
+		c.candles[pair], result[pair] = candles, candles
+	}
+	return result
+}
+
+// GetCandle returns the last closed candle of the pair at the cursor of the depth loader, the one of the minute
+// before the records of GetDepth, so that a strategy never reads the close of a minute before it ends.
+// It is NaN at the first minute, and out of the time range of the candles.
+func (c *CCCandleLoader) GetCandle(pair Pair) Candle {
+	minute := c.depth.cursorMinute(c.startDate) - 1
+	if candles := c.candles[pair]; minute >= 0 && minute < len(candles) {
+		return candles[minute]
+	}
+	nan := math.NaN()
+	return Candle{Time: c.startDate.Add(time.Duration(minute) * time.Minute).UTC(), Open: nan, High: nan, Low: nan, Close: nan, Volume: nan}
+}
+
+// dayPath returns the path of the cache file of the candles of the day of the pair.
+func (c *CCCandleLoader) dayPath(pair Pair, date time.Time) string {
+	return filepath.Join(pairDir(filepath.Join(c.depth.marketDir(), "ohlc"), pair), date.Format("2006-01-02")+".csv")
+}
+
+// loadDay reads the candles of the day of the pair from the cache, downloading them if they are not cached.
+func (c *CCCandleLoader) loadDay(pair Pair, date time.Time) []Candle {
+	path := c.dayPath(pair, date)
+	if content, err := os.ReadFile(path); err == nil {
+		candles, err := parseCandles(string(content), date)
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", path, err))
+		}
+		return candles
+	} else if !os.IsNotExist(err) {
+		panic(err)
+	}
+	if c.depth.readOnly {
+		panic(fmt.Errorf("%w: %s does not exist", ErrReadOnly, path))
+	}
+	fmt.Fprintln(c.depth.progress, "Downloading candles for", pair, date)
+	candles, err := c.downloadDay(pair, date)
+	if err != nil {
+		panic(err)
+	}
+	lines := []string{ohlcvHeader}
+	for _, candle := range candles {
+		lines = append(lines, strings.Join([]string{formatFloat(candle.Open), formatFloat(candle.High),
+			formatFloat(candle.Low), formatFloat(candle.Close), formatFloat(candle.Volume)}, ","))
+	}
+	if err := writeDayFile(c.depth.perms, path, lines); err != nil {
+		panic(err)
+	}
+	return candles
+}
+
+// parseCandles parses the candles of a cache file of the candles of a day.
+func parseCandles(content string, date time.Time) ([]Candle, error) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if lines[0] != ohlcvHeader || len(lines) != 24*60+1 {
+		return nil, fmt.Errorf("%w: not a day of candles", ErrCorrupted)
+	}
+	candles := make([]Candle, 0, 24*60)
+	for minute, line := range lines[1:] {
+		columns := strings.Split(line, ",")
+		if len(columns) != 5 {
+			return nil, fmt.Errorf("%w: malformed candle line %q", ErrCorrupted, line)
+		}
+		var values [5]float64
+		for i := range values {
+			v, err := strconv.ParseFloat(columns[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed candle line %q", ErrCorrupted, line)
+			}
+			values[i] = v
+		}
+		candles = append(candles, Candle{Time: date.Add(time.Duration(minute) * time.Minute).UTC(),
+			Open: values[0], High: values[1], Low: values[2], Close: values[3], Volume: values[4]})
+	}
+	return candles, nil
+}
+
+// downloadDay downloads the 1 minute candles of the day of the pair.
+// The archive is a gzipped CSV with a candle per line, its columns are found by their names in the header:
+//
+//	time_seconds,open,high,low,close,volume,vwap,number_of_trades,twap
+//	1669248000,16544.2,16551.3,16540.1,16549.9,12.5,16546.8,318,16547.1
+func (c *CCCandleLoader) downloadDay(pair Pair, date time.Time) ([]Candle, error) {
+	l := c.depth
+	ctx := l.context()
+	url, ok, err := l.lookupArchive(ctx, l.baseURL+"/v1/ohlc/"+string(l.market)+"/"+pair.String()+
+		"?startTime="+date.Format("2006-01-02")+"&interval=1m")
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no OHLC data URL for %s on %s", pair, date.Format("2006-01-02"))
+	}
+	resp, err := l.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	defer gz.Close()
+	candles, err := readCandles(gz, date)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return candles, nil
+}
+
+// readCandles reads the candles of the CSV of a day into its minutes, NaN for the minutes without a candle.
+func readCandles(r io.Reader, date time.Time) ([]Candle, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	names := []string{"time_seconds", "open", "high", "low", "close", "volume"}
+	columns := make([]int, len(names))
+	for i, name := range names {
+		columns[i] = -1
+		for j, column := range header {
+			if column == name {
+				columns[i] = j
+			}
+		}
+		if columns[i] < 0 {
+			return nil, fmt.Errorf("no %s column in the OHLC header %q", name, strings.Join(header, ","))
+		}
+	}
+	nan := math.NaN()
+	candles := make([]Candle, 24*60)
+	for minute := range candles {
+		candles[minute] = Candle{Time: date.Add(time.Duration(minute) * time.Minute).UTC(), Open: nan, High: nan, Low: nan, Close: nan, Volume: 0}
+	}
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return candles, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < len(header) {
+			return nil, fmt.Errorf("malformed OHLC row: %q", strings.Join(row, ","))
+		}
+		seconds, err := strconv.ParseInt(row[columns[0]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed OHLC row: %q: %w", strings.Join(row, ","), err)
+		}
+		var values [5]float64
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(row[columns[i+1]], 64); err != nil {
+				return nil, fmt.Errorf("malformed OHLC row: %q: %w", strings.Join(row, ","), err)
+			}
+		}
+		minute := int(time.Unix(seconds, 0).Sub(date) / time.Minute)
+		if minute < 0 || minute >= len(candles) {
+			continue
+		}
+		candles[minute].Open, candles[minute].High, candles[minute].Low, candles[minute].Close, candles[minute].Volume =
+			values[0], values[1], values[2], values[3], values[4]
+	}
+}
@@ -0,0 +1,129 @@
+package depth
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// filePerms are the modes and the owner of the directories and files written in the data directory.
+type filePerms struct {
+	dirMode  os.FileMode
+	fileMode os.FileMode
+	// explicit sets the modes regardless of the umask, once set by WithFileModes
+	explicit bool
+	// uid and gid are the owner set by WithFileOwner, -1 to keep the one of the process
+	uid int
+	gid int
+}
+
+// defaultPerms are the modes of the written directories and files without WithFileModes, within the umask.
+var defaultPerms = filePerms{dirMode: 0755, fileMode: 0644, uid: -1, gid: -1}
+
+// WithFileModes sets the modes of the directories and files written in the data directory, like the depth data files
+// and their sidecars, the blocks, the day files and the checkpoints, and those of the DayFileStore of WithStore,
+// instead of 0755 and 0644 within the umask. The exported files keep the latter.
+// They are set regardless of the umask, for the volumes shared by processes of other users.
+// It panics if a mode has other bits than the permission bits.
+func WithFileModes(dir os.FileMode, file os.FileMode) Option {
+	if dir&^os.ModePerm != 0 || file&^os.ModePerm != 0 {
+		panic(fmt.Sprintf("the file modes must only have permission bits, got %s and %s", dir, file))
+	}
+	return func(l *CCDepthLoader) {
+		l.perms.dirMode, l.perms.fileMode, l.perms.explicit = dir, file, true
+	}
+}
+
+// WithFileOwner sets the owner of the directories and files written in the data directory, see WithFileModes,
+// like a root process of a container writing them for the user of another one. An id of -1 keeps the one
+// of the process. The writes fail if the process may not change the owner, like on Windows.
+// It panics if an id is less than -1.
+func WithFileOwner(uid int, gid int) Option {
+	if uid < -1 || gid < -1 {
+		panic(fmt.Sprintf("the owner ids must be -1 or more, got %d and %d", uid, gid))
+	}
+	return func(l *CCDepthLoader) {
+		l.perms.uid, l.perms.gid = uid, gid
+	}
+}
+
+// storePerms sets the perms of the day files of the store, and of the local store of a RemoteStore.
+func storePerms(store Store, perms filePerms) {
+	switch store := store.(type) {
+	case *DayFileStore:
+		store.perms = perms
+	case *RemoteStore:
+		storePerms(store.local, perms)
+	}
+}
+
+// mkdirAll creates the directory and its missing parents, setting the modes and the owner of the created ones.
+func (p filePerms) mkdirAll(dir string) error {
+	var created []string
+	for d := dir; ; d = filepath.Dir(d) {
+		if _, err := os.Stat(d); err == nil || filepath.Dir(d) == d {
+			break
+		}
+		created = append(created, d)
+	}
+	if err := os.MkdirAll(dir, p.dirMode); err != nil {
+		return err
+	}
+	for _, d := range created {
+		if err := p.apply(d, p.dirMode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeFile writes the file like os.WriteFile, setting its mode and owner.
+func (p filePerms) writeFile(path string, content []byte) error {
+	if err := os.WriteFile(path, content, p.fileMode); err != nil {
+		return err
+	}
+	return p.apply(path, p.fileMode)
+}
+
+// create creates or truncates the file like os.Create, setting its mode and owner.
+func (p filePerms) create(path string) (*os.File, error) {
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, p.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.apply(path, p.fileMode); err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// openAppend opens the file to append to it, setting its mode and owner if it is created.
+func (p filePerms) openAppend(path string) (*os.File, error) {
+	_, err := os.Stat(path)
+	created := os.IsNotExist(err)
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, p.fileMode)
+	if err != nil {
+		return nil, err
+	}
+	if created {
+		if err := p.apply(path, p.fileMode); err != nil {
+			_ = file.Close()
+			return nil, err
+		}
+	}
+	return file, nil
+}
+
+// apply sets the mode of the written path regardless of the umask, with WithFileModes, and its owner.
+func (p filePerms) apply(path string, mode os.FileMode) error {
+	if p.explicit {
+		if err := os.Chmod(path, mode); err != nil {
+			return err
+		}
+	}
+	if p.uid != -1 || p.gid != -1 {
+		return os.Chown(path, p.uid, p.gid)
+	}
+	return nil
+}
@@ -0,0 +1,113 @@
+package depth
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// PerturbModel is the noise added to each minute of the records by Perturb.
+type PerturbModel struct {
+	// PriceJitter is the largest shift of the bid and ask prices, as a fraction of the spread, like 0.5 to shift them
+	// by up to half the spread. Both prices are shifted together, so that the spread is kept.
+	PriceJitter float64
+	// SizeJitter is the largest relative change of the bid and ask sizes, like 0.2 to scale each of them by 0.8 to 1.2.
+	SizeJitter float64
+}
+
+// DefaultPerturbModel shifts the prices by up to half the spread, and scales the sizes by up to 20%.
+var DefaultPerturbModel = PerturbModel{PriceJitter: 0.5, SizeJitter: 0.2}
+
+// Perturb returns a copy of the loaded pairs with the noise of the model added to each minute, so that a strategy
+// can be checked for its robustness to the noise of the data. The noise of a pair only depends on the seed,
+// so that a copy is reproduced by perturbing the same data with the same seed.
+// The copy is a loader of its own, like a dataset, see Dataset: it has the options and the time range of the loader,
+// its own cursor, at the start of the time range, and is never stored, a repeated load of its time range returns
+// the perturbed records. The bad days stay NaN, see Tombstone.
+// It panics if the jitters are negative, or if the size jitter is 1 or more, which could make the sizes negative.
+func (l *CCDepthLoader) Perturb(seed int64, model PerturbModel) *CCDepthLoader {
+	if model.PriceJitter < 0 || model.SizeJitter < 0 || model.SizeJitter >= 1 {
+		panic("the jitters must be positive, and the size jitter less than 1")
+	}
+	return l.copyOf(func(pair Pair) []Record {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(pair))
+		rng := rand.New(rand.NewSource(seed ^ int64(h.Sum64())))
+		records := make([]Record, l.length(pair))
+		for minute := range records {
+			records[minute] = model.perturb(rng, l.recordAt(pair, minute))
+		}
+		return records
+	})
+}
+
+// copyOf returns a loader of the records of each loaded pair, in the order of the loaded pairs, with the options
+// and the time range of the loader, see Perturb.
+func (l *CCDepthLoader) copyOf(recordsOf func(pair Pair) []Record) *CCDepthLoader {
+	c := NewCCDepthLoader(l.market, l.opts...)
+	c.limiter = l.limiter
+	c.schema = l.schema
+	c.startDate, c.endDate = l.startDate, l.endDate
+	for _, pair := range l.loadedPairs() {
+		records := recordsOf(pair)
+		values := make([]string, 0, len(records)*l.schema.Width())
+		for _, record := range records {
+			for _, field := range l.schema {
+				values = append(values, formatFloat(record.value(field)))
+			}
+		}
+		c.records[pair] = values
+	}
+	result := c.loaded()
+	c.rangeStart, c.rangeEnd, c.result = l.rangeStart, l.rangeEnd, result
+	c.requested = make(map[Pair]bool, len(l.requested))
+	for pair := range l.requested {
+		c.requested[pair] = true
+	}
+	c.requestedAll = l.requestedAll
+	return c
+}
+
+// perturb adds the noise of the model to the record, drawn from the random source.
+// All the levels are shifted with the top of book, and their sizes scaled on their own, see WithDepthLevels.
+func (m PerturbModel) perturb(rng *rand.Rand, record Record) Record {
+	shift := (2*rng.Float64() - 1) * m.PriceJitter * (record.AskPrice - record.BidPrice)
+	record.BidPrice += shift
+	record.AskPrice += shift
+	record.BidSize *= 1 + (2*rng.Float64()-1)*m.SizeJitter
+	record.AskSize *= 1 + (2*rng.Float64()-1)*m.SizeJitter
+	for _, levels := range [][]Level{record.Bids, record.Asks} {
+		for i := 1; i < len(levels); i++ {
+			levels[i].Price += shift
+			levels[i].Size *= 1 + (2*rng.Float64()-1)*m.SizeJitter
+		}
+	}
+	if len(record.Bids) > 0 {
+		record.Bids[0] = Level{Price: record.BidPrice, Size: record.BidSize}
+	}
+	if len(record.Asks) > 0 {
+		record.Asks[0] = Level{Price: record.AskPrice, Size: record.AskSize}
+	}
+	return record
+}
+
+// value returns the value of the field of the record.
+func (r Record) value(field Field) float64 {
+	switch field {
+	case FieldBidPrice:
+		return r.BidPrice
+	case FieldBidSize:
+		return r.BidSize
+	case FieldAskPrice:
+		return r.AskPrice
+	case FieldAskSize:
+		return r.AskSize
+	case FieldMid:
+		return r.Mid()
+	case FieldSpread:
+		return r.AskPrice - r.BidPrice
+	}
+	if index, ok := field.fullIndex(); ok {
+		return r.levelValue(index)
+	}
+	panic("unknown field " + string(field))
+}
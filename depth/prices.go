@@ -0,0 +1,218 @@
+package depth
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PriceLoader loads mark or index price series of derivatives markets.
+// The series are aligned to the 1 minute depth grid: the value at index i is the price
+// at the start of the i-th minute of the loaded time range, same as the depth record at that index.
+type PriceLoader interface {
+	// Load loads the price series for the given pairs and time range.
+	Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]float64
+}
+
+// PriceKind is the kind of price series loaded by a PriceLoader.
+type PriceKind string
+
+const (
+	MarkPrice  PriceKind = "markPriceKlines"
+	IndexPrice PriceKind = "indexPriceKlines"
+)
+
+// NewMarkPriceLoader returns a PriceLoader of the mark price for the Binance futures markets.
+func NewMarkPriceLoader(market Market, opts ...PriceOption) PriceLoader {
+	return newBinancePriceLoader(market, MarkPrice, opts)
+}
+
+// NewIndexPriceLoader returns a PriceLoader of the index price for the Binance futures markets.
+func NewIndexPriceLoader(market Market, opts ...PriceOption) PriceLoader {
+	return newBinancePriceLoader(market, IndexPrice, opts)
+}
+
+func newBinancePriceLoader(market Market, kind PriceKind, opts []PriceOption) *BinancePriceLoader {
+	l := &BinancePriceLoader{market: market, kind: kind, progress: os.Stdout, client: http.DefaultClient}
+	switch market {
+	case MarketBinanceUsdsFutures:
+		l.baseURL = "https://fapi.binance.com"
+	case MarketBinanceCoinFutures:
+		l.baseURL = "https://dapi.binance.com"
+	default:
+		panic(fmt.Sprintf("%s is not supported for market %s", kind, market))
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// PriceOption configures the BinancePriceLoader.
+type PriceOption func(l *BinancePriceLoader)
+
+// WithPriceProgress sets the writer of the download progress messages, the standard output by default.
+func WithPriceProgress(w io.Writer) PriceOption {
+	return func(l *BinancePriceLoader) {
+		l.progress = w
+	}
+}
+
+// WithPriceBaseURL sets the URL of the Binance futures API, like a mirror or a test server,
+// https://fapi.binance.com or https://dapi.binance.com by default.
+func WithPriceBaseURL(baseURL string) PriceOption {
+	return func(l *BinancePriceLoader) {
+		l.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithPriceHTTPClient sets the HTTP client of the requests to the Binance futures API,
+// like one with a timeout or a proxy, http.DefaultClient by default.
+// It panics if the client is nil.
+func WithPriceHTTPClient(client *http.Client) PriceOption {
+	if client == nil {
+		panic("the HTTP client must not be nil")
+	}
+	return func(l *BinancePriceLoader) {
+		l.client = client
+	}
+}
+
+// BinancePriceLoader loads the mark and index price klines from the public Binance futures API:
+// https://fapi.binance.com/fapi/v1/markPriceKlines?symbol=BTCUSDT&interval=1m&startTime=1633824000000
+type BinancePriceLoader struct {
+	market   Market
+	kind     PriceKind
+	baseURL  string
+	progress io.Writer
+	client   *http.Client
+}
+
+// klinesLimit is the maximum number of klines returned by a single request.
+const klinesLimit = 1500
+
+// klinesRetries is the number of times a rate limited klines request is retried, after the Retry-After
+// of the response, or after a backoff doubling from klinesBackoff without it.
+const klinesRetries = 5
+
+// klinesBackoff is the wait before the first retry of a rate limited klines request without Retry-After.
+const klinesBackoff = time.Second
+
+// Load loads the price series of the pairs. The minutes before the first kline of a pair have no price,
+// and are NaN, rather than a made up value. Missing minutes after the first kline reuse the previous price,
+// same as the depth records. Pairs without any kline in the time range are left out.
+func (l *BinancePriceLoader) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]float64 {
+	historyLength := int(endDate.Sub(startDate).Minutes())
+	prices := make(map[Pair][]float64)
+	for _, pair := range pairs {
+		byMinute := make(map[int64]float64)
+		for from := startDate; from.Before(endDate); from = from.Add(klinesLimit * time.Minute) {
+			fmt.Fprintln(l.progress, "Downloading", l.kind, "for", pair, from)
+			for openTime, price := range l.downloadKlines(pair, from, endDate) {
+				byMinute[openTime] = price
+			}
+		}
+		if len(byMinute) == 0 {
+			continue
+		}
+
+		series := make([]float64, historyLength)
+		prev := math.NaN()
+		for i := range series {
+			openTime := startDate.Add(time.Duration(i) * time.Minute).UnixMilli()
+			if price, ok := byMinute[openTime]; ok {
+				prev = price
+			}
+			series[i] = prev
+		}
+		prices[pair] = series
+	}
+	return prices
+}
+
+// downloadKlines returns the open price of up to klinesLimit 1 minute klines by their open time in milliseconds.
+// A rate limited request is retried up to klinesRetries times, see klinesBackoff.
+func (l *BinancePriceLoader) downloadKlines(pair Pair, from time.Time, endDate time.Time) map[int64]float64 {
+	url := l.getURL(pair, from, endDate)
+	backoff := klinesBackoff
+	var body []byte
+	for retry := 0; ; retry++ {
+		resp, err := l.client.Get(url)
+		if err != nil {
+			panic(err)
+		}
+		body, err = io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		if err != nil {
+			panic(err)
+		}
+		if resp.StatusCode == http.StatusTooManyRequests && retry < klinesRetries {
+			wait, ok := retryAfter(resp.Header.Get("Retry-After"))
+			if !ok {
+				wait = backoff
+			}
+			fmt.Fprintln(l.progress, "Rate limited, retrying", l.kind, "for", pair, "in", wait)
+			time.Sleep(wait)
+			backoff *= 2
+			continue
+		}
+		if resp.StatusCode != http.StatusOK {
+			panic(fmt.Sprintf("%s: %s: %s", url, resp.Status, string(body)))
+		}
+		break
+	}
+
+	// [[openTime, open, high, low, close, ignore, closeTime, ...], ...]
+	var klines [][]interface{}
+	if err := json.Unmarshal(body, &klines); err != nil {
+		panic(fmt.Errorf("%w: %s", err, string(body)))
+	}
+	prices := make(map[int64]float64, len(klines))
+	for _, kline := range klines {
+		openTime := int64(kline[0].(float64))
+		prices[openTime] = mustParseFloat(kline[1].(string))
+	}
+	return prices
+}
+
+// retryAfter parses the Retry-After header of a response, a number of seconds or an HTTP date,
+// and returns false without a valid one.
+func retryAfter(value string) (time.Duration, bool) {
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(date); wait > 0 {
+			return wait, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+func (l *BinancePriceLoader) getURL(pair Pair, from time.Time, endDate time.Time) string {
+	base := l.baseURL + "/fapi/v1/"
+	symbol := pair.Base() + pair.Quote()
+	if l.market == MarketBinanceCoinFutures {
+		base = l.baseURL + "/dapi/v1/"
+		if l.kind == MarkPrice {
+			symbol += "_PERP"
+		}
+	}
+	param := "symbol"
+	if l.kind == IndexPrice {
+		param = "pair"
+	}
+	return base + string(l.kind) +
+		"?" + param + "=" + strings.ToUpper(symbol) +
+		"&interval=1m" +
+		"&limit=" + strconv.Itoa(klinesLimit) +
+		"&startTime=" + strconv.FormatInt(from.UnixMilli(), 10) +
+		"&endTime=" + strconv.FormatInt(endDate.UnixMilli()-1, 10)
+}
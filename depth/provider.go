@@ -0,0 +1,206 @@
+package depth
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Provider fetches the depth data of a day from a data source, like an internal archive or another vendor,
+// so that its days are cached, aligned and iterated like those of crypto-chassis, see WithProvider.
+type Provider interface {
+	// FetchDay returns the record of each minute of the day of the pair, from 00:00 UTC, and no records
+	// if the source has no data for the day. It returns an error wrapping the error of the context
+	// once it is done, see LoadContext.
+	FetchDay(ctx context.Context, market Market, pair Pair, date time.Time) ([]Record, error)
+}
+
+// WithProvider downloads the days from the provider, instead of the crypto-chassis API.
+// The provider must return the 1440 records of a day, or none. The cache files don't record their provider,
+// so a provider should have its own data directory or namespace, see WithNamespace.
+// It panics if the provider is nil.
+func WithProvider(provider Provider) Option {
+	if provider == nil {
+		panic("the provider must not be nil")
+	}
+	return func(l *CCDepthLoader) {
+		l.provider = provider
+	}
+}
+
+// chassisProvider is the Provider of the crypto-chassis API, the default one, see WithBaseURL.
+// It sends the requests with the client and the rate limit of the loader, see WithHTTPClient and WithRateLimit.
+type chassisProvider struct {
+	l *CCDepthLoader
+}
+
+func (p chassisProvider) FetchDay(ctx context.Context, market Market, pair Pair, date time.Time) ([]Record, error) {
+	archive, err := p.l.openArchive(ctx, market, pair, date)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+
+	// Parse CSV into structure and keep in memory
+	reader := csv.NewReader(archive)
+	reader.FieldsPerRecord = -1
+
+	return sampleMinutes(reader, p.l.sampler)
+}
+
+// openArchive opens the CSV of the crypto-chassis depth archive of the day of the pair. The caller must Close it.
+func (l *CCDepthLoader) openArchive(ctx context.Context, market Market, pair Pair, date time.Time) (io.ReadCloser, error) {
+	url, ok, err := l.lookupURL(ctx, market, pair, date)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no depth data URL for %s on %s", pair, date.Format("2006-01-02"))
+	}
+	resp, err := l.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	return gzipArchive{gz, resp.Body}, nil
+}
+
+// gzipArchive is the gzipped body of an archive, closing the body with the reader.
+type gzipArchive struct {
+	*gzip.Reader
+	body io.Closer
+}
+
+func (a gzipArchive) Close() error {
+	_ = a.Reader.Close()
+	return a.body.Close()
+}
+
+// sampleMinutes reduces the per-second snapshots of the rows of an archive to the record of each minute
+// with the sampler. The minutes without a record reuse the record of the previous minute.
+func sampleMinutes(reader *csv.Reader, sampler Sampler) ([]Record, error) {
+	var records []Record
+	var previous Record
+	// snapshots are those of the minute, in minutes since the Unix epoch
+	var snapshots []Snapshot
+	var minute int64
+	for {
+		row, err := reader.Read()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		var seconds int64
+		if err == nil {
+			if row[0] == "time_seconds" {
+				continue
+			}
+			seconds, _ = strconv.ParseInt(row[0], 10, 64)
+		}
+		if len(snapshots) > 0 && (err == io.EOF || seconds/60 != minute) {
+			record, ok, sampleErr := sampler.Sample(snapshots)
+			if sampleErr != nil {
+				return nil, sampleErr
+			}
+			if ok {
+				previous = record
+			}
+			if ok || len(records) > 0 {
+				records = append(records, previous)
+			}
+			snapshots = snapshots[:0]
+			// the minutes without snapshots, before those of the row
+			for gap := minute + 1; err == nil && len(records) > 0 && gap < seconds/60; gap++ {
+				records = append(records, previous)
+			}
+		}
+		if err == io.EOF {
+			return records, nil
+		}
+		minute = seconds / 60
+		snapshots = append(snapshots, Snapshot{Second: int(seconds % 60), row: row})
+	}
+}
+
+// parseChassisQuote parses a row of a crypto-chassis archive, like 1633824000,54968.99_1.52092,54969_0.00001,
+// with the levels of each side separated by |, like 54968.99_1.52092|54968.5_0.1, in the deeper snapshots.
+func parseChassisQuote(row []string) (Record, error) {
+	if len(row) < 3 {
+		return Record{}, fmt.Errorf("malformed depth row: %q", strings.Join(row, ","))
+	}
+	var sides [2][]Level
+	for side, column := range row[1:3] {
+		for _, level := range strings.Split(column, "|") {
+			price, size, _ := strings.Cut(level, "_")
+			p, err := strconv.ParseFloat(price, 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("malformed depth row: %q: %w", strings.Join(row, ","), err)
+			}
+			s, err := strconv.ParseFloat(size, 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("malformed depth row: %q: %w", strings.Join(row, ","), err)
+			}
+			sides[side] = append(sides[side], Level{Price: p, Size: s})
+		}
+	}
+	bids, asks := sides[0], sides[1]
+	record := Record{BidPrice: bids[0].Price, BidSize: bids[0].Size, AskPrice: asks[0].Price, AskSize: asks[0].Size}
+	if len(bids) > 1 || len(asks) > 1 {
+		record.Bids, record.Asks = bids, asks
+	}
+	return record, nil
+}
+
+// lookupURL returns the URL of the archive of the day of the pair, and false if the vendor lists no archive.
+func (l *CCDepthLoader) lookupURL(ctx context.Context, market Market, pair Pair, date time.Time) (string, bool, error) {
+	url := l.baseURL + "/v1/market-depth/" +
+		string(market) + "/" +
+		pair.String() +
+		"?startTime=" + date.Format("2006-01-02")
+	if levels := l.schema.levels(); levels > 1 {
+		url += "&depth=" + strconv.Itoa(levels)
+	}
+	return l.lookupArchive(ctx, url)
+}
+
+// lookupArchive returns the URL of the first archive listed by the crypto-chassis endpoint, and false if it lists none.
+func (l *CCDepthLoader) lookupArchive(ctx context.Context, url string) (string, bool, error) {
+	resp, err := l.get(ctx, url)
+	if err != nil {
+		return "", false, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", false, err
+	}
+	var result map[string]interface{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		// check if error is Timeout then repeat the request after 1 second
+		if strings.Contains(string(body), "Too many requests, please try again later.") {
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return "", false, ctx.Err()
+			}
+			countRetry(ctx)
+			return l.lookupArchive(ctx, url)
+		}
+		return "", false, fmt.Errorf("%w: %s", err, string(body))
+	}
+	urls, _ := result["urls"].([]interface{})
+	if len(urls) > 0 {
+		return urls[0].(map[string]interface{})["url"].(string), true, nil
+	}
+	return "", false, nil
+}
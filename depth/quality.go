@@ -0,0 +1,83 @@
+package depth
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// DayQuality describes the loaded data of a day of a pair.
+type DayQuality struct {
+	Pair Pair
+	Day  time.Time
+	// Minutes is the number of loaded minutes of the day, and Missing the number of the others.
+	Minutes int
+	Missing int
+	// Stale is the number of minutes with the same record as the previous one, as the gaps of the vendor data
+	// are filled with the last record before them.
+	Stale int
+	// Crossed is the number of minutes with a bid price above the ask price.
+	Crossed int
+	// Bad is set for the bad days, see Tombstone.
+	Bad bool
+	// Hash is the SHA-256 of the values of the day, see DayHash, and Bytes the size of the hashed values,
+	// so that two loads can be checked to have the identical data. They are empty for partial days.
+	Hash  string
+	Bytes int64
+}
+
+// Quality returns the quality of each loaded day of the pairs, all loaded pairs if none are given.
+func (l *CCDepthLoader) Quality(pairs []Pair) []DayQuality {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	var report []DayQuality
+	for _, pair := range pairs {
+		length := l.length(pair)
+		for from := 0; from < length; from += 24 * 60 {
+			day := DayQuality{Pair: pair, Day: l.minuteTime(from).UTC(), Bad: l.isBad(pair, from)}
+			var prev Record
+			for i := from; i < from+24*60 && i < length; i++ {
+				day.Minutes++
+				record := l.recordAt(pair, i)
+				if i > from && record.equal(prev) {
+					day.Stale++
+				}
+				if record.BidPrice > record.AskPrice {
+					day.Crossed++
+				}
+				prev = record
+			}
+			day.Missing = 24*60 - day.Minutes
+			if day.Hash = l.DayHash(pair, day.Day); day.Hash != "" {
+				day.Bytes = int64(8 * 24 * 60 * l.schema.Width())
+			}
+			report = append(report, day)
+		}
+	}
+	return report
+}
+
+// WriteQualityReport writes the quality of each loaded day of the pairs as CSV, see Quality:
+//
+//	pair,day,minutes,missing,stale,crossed,bad,bytes,hash
+func (l *CCDepthLoader) WriteQualityReport(w io.Writer, pairs []Pair) error {
+	writer := csv.NewWriter(w)
+	_ = writer.Write([]string{"pair", "day", "minutes", "missing", "stale", "crossed", "bad", "bytes", "hash"})
+	for _, day := range l.Quality(pairs) {
+		_ = writer.Write([]string{
+			string(day.Pair),
+			day.Day.Format("2006-01-02"),
+			strconv.Itoa(day.Minutes),
+			strconv.Itoa(day.Missing),
+			strconv.Itoa(day.Stale),
+			strconv.Itoa(day.Crossed),
+			strconv.FormatBool(day.Bad),
+			strconv.FormatInt(day.Bytes, 10),
+			day.Hash,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,59 @@
+package depth
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+)
+
+// RawSnapshot is a snapshot of the book in a crypto-chassis archive, at its second.
+type RawSnapshot struct {
+	Time   time.Time `json:"time"`
+	Record Record    `json:"record"`
+}
+
+// LoadRaw downloads the untouched per-second snapshots of the book of the pair during the day, in the order of the
+// archive, without sampling them to minutes, see WithSampler, nor storing them, so that a researcher measures what the
+// records of the minutes hide, like the moves of the spread between them. The snapshots are always downloaded from
+// crypto-chassis, see WithBaseURL, even with another Provider, and the loader does not need to be loaded.
+// It returns no snapshots if the archive of the day is empty, and an error wrapping ErrReadOnly for a read-only
+// loader, as the snapshots are not stored.
+func (l *CCDepthLoader) LoadRaw(ctx context.Context, pair Pair, date time.Time) ([]RawSnapshot, error) {
+	if l.readOnly {
+		return nil, fmt.Errorf("%w: the raw snapshots are not stored", ErrReadOnly)
+	}
+	_, _ = fmt.Fprintln(l.progress, "Downloading raw depth for", pair, date)
+	archive, err := l.openArchive(ctx, l.market, pair, date)
+	if err != nil {
+		return nil, err
+	}
+	defer archive.Close()
+	reader := csv.NewReader(archive)
+	reader.FieldsPerRecord = -1
+	var snapshots []RawSnapshot
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return snapshots, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row[0] == "time_seconds" {
+			continue
+		}
+		seconds, err := strconv.ParseInt(row[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed depth row time %q: %w", row[0], err)
+		}
+		record, err := parseChassisQuote(row)
+		if err != nil {
+			return nil, err
+		}
+		record.pair, record.Time = pair, time.Unix(seconds, 0).UTC()
+		snapshots = append(snapshots, RawSnapshot{Time: record.Time, Record: record})
+	}
+}
@@ -0,0 +1,22 @@
+//go:build go1.23
+
+package depth
+
+import (
+	"iter"
+	"time"
+)
+
+// Records returns an iterator over the records of the pair with their minutes, from the minute where a Cursor starts,
+// see NewCursor, to the last loaded minute of the pair, for the range-over-func loops replacing the Tick and GetDepth
+// ones. Like a Cursor, it moves neither the cursor of the loader nor feeds the consumers.
+func (l *CCDepthLoader) Records(pair Pair) iter.Seq2[time.Time, Record] {
+	return func(yield func(time.Time, Record) bool) {
+		for c := l.NewCursor(); c.index < l.length(pair); c.Tick() {
+			record := c.GetDepth(pair)
+			if !yield(record.Time, record) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package depth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// StoreHandler serves the days of the store over HTTP, so that the loaders of a fleet of backtest workers share
+// the days downloaded by any of them, see NewRemoteStore. Each day of a pair is a resource of its own:
+//
+//	GET /<pair>/<yyyy-mm-dd>?fields=bid_price,bid_size,ask_price,ask_size
+//	PUT /<pair>/<yyyy-mm-dd>?fields=bid_price,bid_size,ask_price,ask_size
+//
+// with a line of the comma-separated values of the fields per minute, and no lines for a day without vendor data.
+// The GET of a day not stored is Not Found, and the requests of a schema the store can't open are Conflict.
+// The store is opened with the schema of each request, and must be safe for concurrent use, like a DayFileStore.
+// The handler of a market is usually mounted under its name, like http.StripPrefix("/binance", handler).
+func StoreHandler(store Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if len(parts) != 2 || parts[0] == "" {
+			http.NotFound(w, r)
+			return
+		}
+		pair := Pair(parts[0])
+		day, err := time.Parse("2006-01-02", parts[1])
+		if err != nil {
+			http.Error(w, "malformed day "+parts[1], http.StatusBadRequest)
+			return
+		}
+		schema := parseSchemaHeader(schemaHeader + "," + r.URL.Query().Get("fields"))
+		if err := schema.Validate(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := store.Open(schema); err != nil {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			stored, err := store.Has(pair, day)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			if !stored {
+				http.NotFound(w, r)
+				return
+			}
+			days, err := store.ReadRange(pair, day, day.AddDate(0, 0, 1))
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.Header().Set("Content-Type", "text/csv")
+			_, _ = w.Write(formatDay(days[0], schema.Width()))
+		case http.MethodPut:
+			values, err := parseDay(r.Body, schema.Width())
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := store.WriteDay(pair, day, values); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, PUT")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// formatDay formats the values of a day, a line of the given width per minute.
+func formatDay(values []string, width int) []byte {
+	var b bytes.Buffer
+	for i := 0; i+width <= len(values); i += width {
+		b.WriteString(strings.Join(values[i:i+width], ","))
+		b.WriteByte('\n')
+	}
+	return b.Bytes()
+}
+
+// parseDay parses the lines of the values of a day, checking each has the given width.
+func parseDay(r io.Reader, width int) ([]string, error) {
+	var values []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), ",")
+		if len(fields) != width {
+			return nil, fmt.Errorf("%d values in a minute, not %d", len(fields), width)
+		}
+		values = append(values, fields...)
+	}
+	return values, scanner.Err()
+}
+
+// RemoteStore is a Store reading through a central store served by StoreHandler, so that a fleet of backtest workers
+// is a cooperative cache of the vendor data: the days missing from the local store are fetched from the central
+// one, and only the days missing from both are downloaded from the vendor, each being then uploaded to the central
+// store for the other workers. It is safe for concurrent use if the local store is.
+type RemoteStore struct {
+	// url is the URL of the StoreHandler of the market
+	url    string
+	local  Store
+	client *http.Client
+	schema Schema
+}
+
+// NewRemoteStore returns the RemoteStore of the StoreHandler at the URL, like http://depth-cache:8081/binance,
+// keeping the days in the local store, like the DayFileStore of the market directory, and sending the requests
+// with the client. It panics if the local store or the client is nil.
+func NewRemoteStore(url string, local Store, client *http.Client) *RemoteStore {
+	if local == nil {
+		panic("the local store must not be nil")
+	}
+	if client == nil {
+		panic("the HTTP client must not be nil")
+	}
+	return &RemoteStore{url: strings.TrimSuffix(url, "/"), local: local, client: client}
+}
+
+// dayURL returns the URL of the day of the pair in the central store.
+func (s *RemoteStore) dayURL(pair Pair, day time.Time) string {
+	return s.url + "/" + url.PathEscape(pair.String()) + "/" + day.Format("2006-01-02") + "?fields=" + url.QueryEscape(s.schema.String())
+}
+
+// Open opens the local store.
+func (s *RemoteStore) Open(schema Schema) error {
+	s.schema = schema
+	return s.local.Open(schema)
+}
+
+// Has reports whether the day of the pair is in the local store, fetching it from the central store into the local
+// one if it is not. A day missing from both is downloaded from the vendor by the loader, and uploaded by WriteDay.
+func (s *RemoteStore) Has(pair Pair, day time.Time) (bool, error) {
+	if stored, err := s.local.Has(pair, day); err != nil || stored {
+		return stored, err
+	}
+	resp, err := s.client.Get(s.dayURL(pair, day))
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("%s: %s", s.dayURL(pair, day), resp.Status)
+	}
+	values, err := parseDay(resp.Body, s.schema.Width())
+	if err != nil {
+		return false, fmt.Errorf("%w: %s: %v", ErrCorrupted, s.dayURL(pair, day), err)
+	}
+	if err := s.local.WriteDay(pair, day, values); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// WriteDay writes the day of the pair into the local store, and uploads it to the central store.
+func (s *RemoteStore) WriteDay(pair Pair, day time.Time, values []string) error {
+	if err := s.local.WriteDay(pair, day, values); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPut, s.dayURL(pair, day), bytes.NewReader(formatDay(values, s.schema.Width())))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/csv")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", s.dayURL(pair, day), resp.Status)
+	}
+	return nil
+}
+
+// ReadRange reads the days of the pair from the local store, where Has fetched those of the central store.
+func (s *RemoteStore) ReadRange(pair Pair, startDate time.Time, endDate time.Time) ([][]string, error) {
+	return s.local.ReadRange(pair, startDate, endDate)
+}
@@ -0,0 +1,245 @@
+package depth
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// RenamePair renames a pair in the stored data of the market, like after the exchange renamed its ticker,
+// so that its days are not downloaded again under the new name. It rewrites the header and the rows of the depth
+// data files, their index and versions sidecars, the manifests of the blocks, see WithBlocks, and the tombstones,
+// and renames the loaded pair. It returns an error if a file already has data for both names.
+// The files are replaced atomically, but the market must not be loaded by other loaders while they are renamed,
+// as their appends would be lost.
+func (l *CCDepthLoader) RenamePair(from Pair, to Pair) error {
+	if l.readOnly {
+		return ErrReadOnly
+	}
+	dir := l.marketDir()
+	paths, err := filepath.Glob(filepath.Join(dir, "*_depth.csv"))
+	if err != nil {
+		return err
+	}
+	for _, path := range paths {
+		if err := l.renameInFile(path, from, to); err != nil {
+			return err
+		}
+	}
+	manifests, err := filepath.Glob(filepath.Join(dir, "*.blocks"))
+	if err != nil {
+		return err
+	}
+	for _, path := range manifests {
+		if err := renameInManifest(l.perms, path, from, to); err != nil {
+			return err
+		}
+	}
+	tombstones, err := l.Tombstones()
+	if err != nil {
+		return err
+	}
+	renamed := false
+	for i := range tombstones {
+		if tombstones[i].Pair == from {
+			tombstones[i].Pair = to
+			renamed = true
+		}
+	}
+	if renamed {
+		if err := l.writeTombstones(tombstones); err != nil {
+			return err
+		}
+	}
+	l.renameLoaded(from, to)
+	return nil
+}
+
+// renameInFile renames the pair in the header and the rows of the depth data file, and updates its sidecars.
+// The sizes of the versions move with the renamed rows before them.
+func (l *CCDepthLoader) renameInFile(path string, from Pair, to Pair) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	index, err := scanIndex(file)
+	if err != nil {
+		return err
+	}
+	header, err := splitCSV(l.readFirstLine(file))
+	if err != nil {
+		return err
+	}
+	inHeader := header[0] == "#" && containsName(header[1:], string(from))
+	_, hasRow := index.rows[from]
+	if !hasRow && !inHeader {
+		return nil
+	}
+	if _, ok := index.rows[to]; ok && hasRow {
+		return fmt.Errorf("%s already has data for %s", path, to)
+	}
+	versions, err := readVersions(path)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, 0); err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	out, err := l.perms.create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := bufio.NewWriter(out)
+	reader := bufio.NewReader(file)
+	// sizes maps the sizes of the file at the line breaks to the sizes of the renamed file
+	sizes := map[int64]int64{0: 0}
+	var offset, renamedOffset int64
+	for first := true; ; first = false {
+		line, err := reader.ReadString('\n')
+		offset += int64(len(line))
+		if first && inHeader {
+			// the pair is removed from the header if the renamed one is already listed
+			listed := containsName(header[1:], string(to))
+			var names []string
+			for _, name := range header {
+				if name == string(from) && listed {
+					continue
+				}
+				if name == string(from) {
+					name = string(to)
+				}
+				names = append(names, name)
+			}
+			line = joinCSV(names...) + line[len(strings.TrimRight(line, "\r\n")):]
+		} else if pair, ok := rowPair(line); ok && pair == from {
+			indent := len(line) - len(strings.TrimLeft(line, " \t"))
+			_, length, _ := firstCSVField(line[indent:])
+			line = line[:indent] + csvField(to.String()) + line[indent+length:]
+		}
+		renamedOffset += int64(len(line))
+		sizes[offset] = renamedOffset
+		if _, writeErr := writer.WriteString(line); writeErr != nil {
+			_ = out.Close()
+			return writeErr
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			_ = out.Close()
+			return err
+		}
+	}
+	if err := writer.Flush(); err != nil {
+		_ = out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// the file is closed before it is replaced, as an open file can't be replaced on Windows
+	_ = file.Close()
+	if err := replaceFile(tmp, path); err != nil {
+		return err
+	}
+
+	if len(versions) > 0 {
+		lines := make([]string, len(versions))
+		for i, version := range versions {
+			size, ok := sizes[version.Size]
+			if !ok {
+				return fmt.Errorf("%s is corrupted: its version %d does not end at a row", versionsPath(path), version.Number)
+			}
+			version.Size = size
+			for j, pair := range version.Pairs {
+				if pair == from {
+					version.Pairs[j] = to
+					version.Minutes[to] = version.Minutes[from]
+				}
+			}
+			lines[i] = formatVersion(version)
+		}
+		tmp := versionsPath(path) + ".tmp"
+		if err := l.perms.writeFile(tmp, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+			return err
+		}
+		if err := replaceFile(tmp, versionsPath(path)); err != nil {
+			return err
+		}
+	}
+	// the index is scanned again, with the offsets of the renamed rows
+	_ = os.Remove(indexPath(path))
+	l.openIndexOf(path)
+	return nil
+}
+
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if strings.TrimSpace(n) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// renameInManifest renames the pair in the manifest of blocks. The blocks don't have the pair in them.
+func renameInManifest(perms filePerms, path string, from Pair, to Pair) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	renamed, listed := false, false
+	for i, line := range lines {
+		name, length, _ := firstCSVField(line)
+		if name == string(to) {
+			listed = true
+		} else if name == string(from) {
+			lines[i] = csvField(to.String()) + line[length:]
+			renamed = true
+		}
+	}
+	if !renamed {
+		return nil
+	}
+	if listed {
+		return fmt.Errorf("%s already has data for %s", path, to)
+	}
+	tmp := path + ".tmp"
+	if err := perms.writeFile(tmp, []byte(strings.Join(lines, "\n"))); err != nil {
+		return err
+	}
+	return replaceFile(tmp, path)
+}
+
+// renameLoaded renames the loaded pair.
+func (l *CCDepthLoader) renameLoaded(from Pair, to Pair) {
+	if records, ok := l.records[from]; ok {
+		l.records[to] = records
+		delete(l.records, from)
+	}
+	if values, ok := l.values[from]; ok {
+		l.values[to] = values
+		delete(l.values, from)
+	}
+	if runs, ok := l.runs[from]; ok {
+		l.runs[to] = runs
+		delete(l.runs, from)
+	}
+	if bad, ok := l.bad[from]; ok {
+		l.bad[to] = bad
+		delete(l.bad, from)
+	}
+	for _, columns := range l.series {
+		if column, ok := columns[from]; ok {
+			columns[to] = column
+			delete(columns, from)
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package depth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// DayFailure is a day of a pair whose download failed, after the retries of the HTTP requests.
+type DayFailure struct {
+	Pair Pair
+	Day  time.Time
+	Err  error
+}
+
+// FailedDaysError is the error of a load where some days failed to download: the other pairs are loaded,
+// and the downloaded days of the failed pairs are stored, or checkpointed with the depth data files,
+// see WithPairConcurrency, so that RetryFailed, or a later load, downloads only the failed days.
+// The failed pairs are not loaded until they are loaded again.
+type FailedDaysError struct {
+	Failures  []DayFailure
+	loader    *CCDepthLoader
+	startDate time.Time
+	endDate   time.Time
+}
+
+func (e *FailedDaysError) Error() string {
+	var days []string
+	for _, f := range e.Failures {
+		days = append(days, fmt.Sprintf("%s on %s: %v", f.Pair, f.Day.Format("2006-01-02"), f.Err))
+	}
+	return "failed days: " + strings.Join(days, "; ")
+}
+
+// Pairs returns the pairs with failed days, in the order of their failures.
+func (e *FailedDaysError) Pairs() []Pair {
+	var pairs []Pair
+	for _, f := range e.Failures {
+		if !containsPair(pairs, f.Pair) {
+			pairs = append(pairs, f.Pair)
+		}
+	}
+	return pairs
+}
+
+// RetryFailed loads the failed pairs again, downloading only their failed days, and returns their records,
+// like LoadContext, with a FailedDaysError for the days failing again.
+func (e *FailedDaysError) RetryFailed(ctx context.Context) (map[Pair][]string, error) {
+	return e.loader.LoadContext(ctx, e.Pairs(), e.startDate, e.endDate)
+}
+
+// failedOn checks if the day is one of the failures.
+func failedOn(failures []DayFailure, day time.Time) bool {
+	for _, f := range failures {
+		if f.Day.Equal(day) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,101 @@
+package depth
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Revision is a loaded day of a pair that differs from the one the vendor serves now, as it revised its archive.
+// The hashes are the SHA-256 of the values of the day, see DayHash, and the vendor one is empty if it has no data anymore.
+type Revision struct {
+	Pair       Pair
+	Day        time.Time
+	CachedHash string
+	VendorHash string
+}
+
+// DetectRevisions downloads again a sample of the loaded days of the pairs, all loaded pairs if none are given,
+// and returns those the vendor revised since they were cached. The sample are the given number of days
+// spread evenly over the loaded time range, all the days if it is not positive.
+// The bad days, and the days with missing minutes are skipped. To refresh the revised days deliberately,
+// mark them as bad with MarkBad, and load them again with WithRefetchBad.
+func (l *CCDepthLoader) DetectRevisions(pairs []Pair, sample int) []Revision {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	var revisions []Revision
+	for _, pair := range pairs {
+		for _, day := range sampleDays(l.length(pair)/(24*60), sample) {
+			from := day * 24 * 60
+			if l.isBad(pair, from) {
+				continue
+			}
+			date := l.minuteTime(from)
+			cached := l.DayHash(pair, date)
+			_, _ = fmt.Fprintln(l.progress, "Checking depth revision for", pair, date)
+			vendor := ""
+			if values := l.schema.project(l.downloadDay(l.context(), pair, date)); len(values) > 0 {
+				vendor = hashValues(parseValues(values))
+			}
+			if vendor != cached {
+				revisions = append(revisions, Revision{Pair: pair, Day: date, CachedHash: cached, VendorHash: vendor})
+			}
+		}
+	}
+	return revisions
+}
+
+// DayHash returns the SHA-256 of the loaded values of the day of the pair, in hex, or an empty string if the day
+// is not fully loaded. The values are hashed as floats, so that the hash doesn't depend on their formatting,
+// or on WithOffHeap, only on the stored schema.
+func (l *CCDepthLoader) DayHash(pair Pair, day time.Time) string {
+	from := int(day.Sub(l.startDate).Minutes())
+	if from < 0 || from+24*60 > l.length(pair) {
+		return ""
+	}
+	width := l.schema.Width()
+	values := make([]float64, 0, 24*60*width)
+	for minute := from; minute < from+24*60; minute++ {
+		index := l.valueIndex(pair, minute)
+		if records, ok := l.records[pair]; ok {
+			values = append(values, parseValues(records[index:index+width])...)
+		} else {
+			values = append(values, l.values[pair][index:index+width]...)
+		}
+	}
+	return hashValues(values)
+}
+
+// sampleDays returns the given number of day indices spread evenly over the days, or all of them.
+func sampleDays(days int, sample int) []int {
+	if sample <= 0 || sample > days {
+		sample = days
+	}
+	indices := make([]int, sample)
+	for i := range indices {
+		indices[i] = i * days / sample
+	}
+	return indices
+}
+
+func parseValues(values []string) []float64 {
+	parsed := make([]float64, len(values))
+	for i, v := range values {
+		parsed[i] = mustParseFloat(v)
+	}
+	return parsed
+}
+
+func hashValues(values []float64) string {
+	hash := sha256.New()
+	b := make([]byte, 0, 8*len(values))
+	for _, v := range values {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(v))
+	}
+	_, _ = hash.Write(b)
+	return hex.EncodeToString(hash.Sum(nil))
+}
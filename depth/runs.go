@@ -0,0 +1,276 @@
+package depth
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runToken starts the value following a 1 minute record in a row stored with WithRunLengths,
+// the number of following minutes with the same record.
+const runToken = "*"
+
+// WithRunLengths stores only the minutes where the top of book changed, with the number of following minutes
+// with the same record, which saves the disk and the memory of the illiquid pairs with long stretches of identical
+// records. The run length follows the record in the row of the pair, for example 3 identical minutes:
+//
+//	BTC-BUSD,54968.99,1.52092,54969,0.00001,*2
+//
+// The rows with run lengths are read by all loaders, and kept as runs in memory, so that Load and LoadFrom
+// return only the records of the changed minutes of these pairs, while GetDepth and the exports expand the runs.
+// The loader also keeps the downloaded records as runs.
+func WithRunLengths() Option {
+	return func(l *CCDepthLoader) {
+		l.runLengths = true
+	}
+}
+
+// runIndex lists the minutes at which each record of a pair stored as runs starts.
+type runIndex struct {
+	starts  []int
+	minutes int
+}
+
+// record returns the index of the record of the minute.
+func (r *runIndex) record(minute int) int {
+	return sort.Search(len(r.starts), func(i int) bool { return r.starts[i] > minute }) - 1
+}
+
+// compressRuns returns the records of the minutes where the values changed, and their runs.
+func compressRuns(values []string, width int) ([]string, *runIndex) {
+	runs := &runIndex{minutes: len(values) / width}
+	var records []string
+	for i := 0; i+width <= len(values); i += width {
+		if len(records) > 0 && equalValues(records[len(records)-width:], values[i:i+width]) {
+			continue
+		}
+		records = append(records, values[i:i+width]...)
+		runs.starts = append(runs.starts, i/width)
+	}
+	return records, runs
+}
+
+func equalValues(a []string, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// expandRuns returns the values of each minute of the records stored as runs.
+func expandRuns(records []string, runs *runIndex, width int) []string {
+	values := make([]string, 0, runs.minutes*width)
+	for i, start := range runs.starts {
+		end := runs.minutes
+		if i+1 < len(runs.starts) {
+			end = runs.starts[i+1]
+		}
+		for m := start; m < end; m++ {
+			values = append(values, records[i*width:(i+1)*width]...)
+		}
+	}
+	return values
+}
+
+// parseRuns parses the values of a row with run lengths into the records of the changed minutes and their runs.
+func parseRuns(values []string, width int) ([]string, *runIndex, error) {
+	runs := &runIndex{}
+	records := make([]string, 0, len(values))
+	for i := 0; i < len(values); {
+		if i+width > len(values) {
+			return nil, nil, fmt.Errorf("file is corrupted: incomplete record at value %d", i)
+		}
+		for _, v := range values[i : i+width] {
+			if strings.HasPrefix(v, runToken) {
+				return nil, nil, fmt.Errorf("file is corrupted: unexpected run length at value %d", i)
+			}
+		}
+		records = append(records, values[i:i+width]...)
+		runs.starts = append(runs.starts, runs.minutes)
+		runs.minutes++
+		i += width
+		if i < len(values) && strings.HasPrefix(values[i], runToken) {
+			n, err := strconv.Atoi(values[i][len(runToken):])
+			if err != nil || n < 1 {
+				return nil, nil, fmt.Errorf("file is corrupted: invalid run length %q", values[i])
+			}
+			runs.minutes += n
+			i++
+		}
+	}
+	return records, runs, nil
+}
+
+// hasRuns checks if the values of a row have run lengths.
+func hasRuns(values []string) bool {
+	for _, v := range values {
+		if strings.HasPrefix(v, runToken) {
+			return true
+		}
+	}
+	return false
+}
+
+// setRecords stores the values of each minute of the pair, as runs with WithRunLengths.
+func (l *CCDepthLoader) setRecords(pair Pair, values []string) {
+	if l.runLengths {
+		l.records[pair], l.runs[pair] = compressRuns(values, l.schema.Width())
+		return
+	}
+	l.records[pair] = values
+	delete(l.runs, pair)
+}
+
+// denseRecords returns the values of each minute of the pair, expanding its runs.
+func (l *CCDepthLoader) denseRecords(pair Pair) []string {
+	if runs, ok := l.runs[pair]; ok {
+		return expandRuns(l.records[pair], runs, l.schema.Width())
+	}
+	return l.records[pair]
+}
+
+// formatRow returns the row of the pair in the depth data file, with its run lengths if it is stored as runs.
+func (l *CCDepthLoader) formatRow(pair Pair) string {
+	runs, ok := l.runs[pair]
+	if !ok {
+		return fmt.Sprintf("%s,%s\n", csvField(pair.String()), strings.Join(l.records[pair], ","))
+	}
+	width := l.schema.Width()
+	var b strings.Builder
+	b.WriteString(csvField(pair.String()))
+	for i, start := range runs.starts {
+		b.WriteString(",")
+		b.WriteString(strings.Join(l.records[pair][i*width:(i+1)*width], ","))
+		end := runs.minutes
+		if i+1 < len(runs.starts) {
+			end = runs.starts[i+1]
+		}
+		if end-start > 1 {
+			b.WriteString(fmt.Sprintf(",%s%d", runToken, end-start-1))
+		}
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// valueIndex returns the index of the first value of the minute of the pair in its records.
+func (l *CCDepthLoader) valueIndex(pair Pair, minute int) int {
+	if runs, ok := l.runs[pair]; ok {
+		return runs.record(minute) * l.schema.Width()
+	}
+	return minute * l.schema.Width()
+}
+
+// WithMoveThreshold makes NextChange and WithChangesOnly yield only the minutes where the book of a pair moved
+// since the last yielded minute by at least the given price, like a tick, on the bid or the ask, or by at least
+// the given size on a side, instead of any change. A zero threshold ignores the moves of its kind, unless both are zero.
+// It panics if a threshold is negative.
+func WithMoveThreshold(price float64, size float64) Option {
+	if price < 0 || size < 0 {
+		panic(fmt.Sprintf("the move thresholds must not be negative, got %v and %v", price, size))
+	}
+	return func(l *CCDepthLoader) {
+		l.movePrice, l.moveSize = price, size
+	}
+}
+
+// NextChange moves to the next minute where the record of one of the loaded pairs changed, skipping the unchanged
+// minutes, for the strategies iterating on the book events rather than on the wall clock, which Tick preserves.
+// It checks the runs of the pairs stored with WithRunLengths without expanding them. It returns false,
+// past the last minute, when no pair changes anymore. See WithMoveThreshold to skip the small changes too.
+func (l *CCDepthLoader) NextChange() bool {
+	pairs := l.loadedPairs()
+	l.index = l.nextChange(pairs, l.index)
+	l.consume(l.index)
+	for _, pair := range pairs {
+		if l.index < l.length(pair) {
+			return true
+		}
+	}
+	return false
+}
+
+// nextChange returns the first minute after the given one where the record of one of the pairs changed,
+// or the end of the longest pair if none changes.
+func (l *CCDepthLoader) nextChange(pairs []Pair, minute int) int {
+	next := 0
+	for _, pair := range pairs {
+		if length := l.length(pair); length > next {
+			next = length
+		}
+	}
+	for _, pair := range pairs {
+		if change := l.pairChange(pair, minute); change > minute && change < next {
+			next = change
+		}
+	}
+	return next
+}
+
+// pairChange returns the first minute after the given one where the book of the pair moved beyond the thresholds,
+// see WithMoveThreshold, or the end of the pair if it does not move anymore.
+func (l *CCDepthLoader) pairChange(pair Pair, minute int) int {
+	next := l.recordChange(pair, minute)
+	if l.movePrice == 0 && l.moveSize == 0 || minute >= l.length(pair) {
+		return next
+	}
+	for next < l.length(pair) && !l.moved(pair, minute, next) {
+		next = l.recordChange(pair, next)
+	}
+	return next
+}
+
+// moved checks if the book of the pair moved beyond the thresholds between the minutes, see WithMoveThreshold.
+// Entering or leaving a bad day is a move.
+func (l *CCDepthLoader) moved(pair Pair, from int, to int) bool {
+	if l.isBad(pair, from) || l.isBad(pair, to) {
+		return l.isBad(pair, from) != l.isBad(pair, to)
+	}
+	a, b := l.recordAt(pair, from), l.recordAt(pair, to)
+	if l.movePrice > 0 && (math.Abs(a.BidPrice-b.BidPrice) >= l.movePrice || math.Abs(a.AskPrice-b.AskPrice) >= l.movePrice) {
+		return true
+	}
+	return l.moveSize > 0 && (math.Abs(a.BidSize-b.BidSize) >= l.moveSize || math.Abs(a.AskSize-b.AskSize) >= l.moveSize)
+}
+
+// recordChange returns the first minute after the given one where the record of the pair changed,
+// or the end of the pair if it does not change anymore. The bounds of the bad days are changes.
+func (l *CCDepthLoader) recordChange(pair Pair, minute int) int {
+	end := l.length(pair)
+	// the records change at the bounds of the bad days, see Tombstone
+	for _, r := range l.bad[pair] {
+		if r.from > minute && r.from < end {
+			end = r.from
+		} else if r.to > minute && r.to < end {
+			end = r.to
+		}
+	}
+	if minute+1 >= end {
+		return end
+	}
+	if runs, ok := l.runs[pair]; ok {
+		if r := runs.record(minute); r+1 < len(runs.starts) && runs.starts[r+1] < end {
+			return runs.starts[r+1]
+		}
+		return end
+	}
+	width := l.schema.Width()
+	for next := minute + 1; next < end; next++ {
+		if records, ok := l.records[pair]; ok {
+			if !equalValues(records[minute*width:(minute+1)*width], records[next*width:(next+1)*width]) {
+				return next
+			}
+			continue
+		}
+		for i := 0; i < width; i++ {
+			if l.values[pair][minute*width+i] != l.values[pair][next*width+i] {
+				return next
+			}
+		}
+	}
+	return end
+}
@@ -0,0 +1,30 @@
+package depth
+
+import (
+	"bytes"
+	"compress/gzip"
+	_ "embed"
+	"time"
+)
+
+//go:embed sample/synthetic-day.csv.gz
+var syntheticSample []byte
+
+// SyntheticSamplePair is the made-up pair of the synthetic sample, see LoadSyntheticSample.
+const SyntheticSamplePair Pair = "SYN-USD"
+
+// SyntheticSampleStart is the start of the day of the synthetic sample, the Unix epoch, as it is of no real market day.
+var SyntheticSampleStart = time.Unix(0, 0).UTC()
+
+// LoadSyntheticSample reads the synthetic sample embedded in the package, 1440 minutes of SyntheticSamplePair
+// from SyntheticSampleStart, with LoadFrom, so that the examples and the tests replay depth data without the network
+// nor the data directory. The records are not market data: they are drawn with NewSynthetic from a made-up model
+// of a book, with a mid price around 16580 and spreads of a few cents.
+// It returns all the read records.
+func (l *CCDepthLoader) LoadSyntheticSample() map[Pair][]string {
+	reader, err := gzip.NewReader(bytes.NewReader(syntheticSample))
+	if err != nil {
+		panic(err)
+	}
+	return l.LoadFrom(reader, SyntheticSampleStart)
+}
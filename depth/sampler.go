@@ -0,0 +1,86 @@
+package depth
+
+import (
+	"sort"
+)
+
+// Sampler reduces the per-second snapshots of the book during a minute of the crypto-chassis archives to the record
+// of the minute, see WithSampler.
+type Sampler interface {
+	// Sample returns the record of the minute from its snapshots, in the order of their seconds,
+	// and false if the minute has no record, the record of the previous minute being reused then.
+	Sample(snapshots []Snapshot) (Record, bool, error)
+}
+
+// Snapshot is a snapshot of the book at a second of a minute, parsed by Record only when a Sampler needs it.
+type Snapshot struct {
+	// Second is the second of the snapshot in its minute, from 0 to 59
+	Second int
+	row    []string
+}
+
+// Record parses the book of the snapshot.
+func (s Snapshot) Record() (Record, error) {
+	return parseChassisQuote(s.row)
+}
+
+// WithSampler sets the sampler reducing the per-second snapshots of the crypto-chassis archives to the records
+// of the minutes, SnapshotSampler by default, like MedianSampler. The other providers have their own sampling,
+// see WithProvider. The days sampled by another sampler should be kept in their own namespace, see WithNamespace.
+// It panics if the sampler is nil.
+func WithSampler(sampler Sampler) Option {
+	if sampler == nil {
+		panic("the sampler must not be nil")
+	}
+	return func(l *CCDepthLoader) {
+		l.sampler = sampler
+	}
+}
+
+// SnapshotSampler samples the snapshot at the start of each minute, at its second 0, the minutes without one
+// reusing the record of the previous minute.
+var SnapshotSampler Sampler = snapshotSampler{}
+
+type snapshotSampler struct{}
+
+func (snapshotSampler) Sample(snapshots []Snapshot) (Record, bool, error) {
+	if len(snapshots) == 0 || snapshots[0].Second != 0 {
+		return Record{}, false, nil
+	}
+	record, err := snapshots[0].Record()
+	return record, err == nil, err
+}
+
+// MedianSampler samples the median book of each minute, the median of each field of the top of book
+// over the snapshots of the minute, so that a single second of a thin book doesn't make the record of the minute.
+// The records have no deeper levels, see WithDepthLevels.
+var MedianSampler Sampler = medianSampler{}
+
+type medianSampler struct{}
+
+func (medianSampler) Sample(snapshots []Snapshot) (Record, bool, error) {
+	if len(snapshots) == 0 {
+		return Record{}, false, nil
+	}
+	fields := make([][]float64, 4)
+	for _, snapshot := range snapshots {
+		record, err := snapshot.Record()
+		if err != nil {
+			return Record{}, false, err
+		}
+		for i, v := range []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize} {
+			fields[i] = append(fields[i], v)
+		}
+	}
+	return Record{BidPrice: median(fields[0]), BidSize: median(fields[1]), AskPrice: median(fields[2]), AskSize: median(fields[3])}, true, nil
+}
+
+// median returns the median of the values, the mean of the two middle ones for an even number of values.
+func median(values []float64) float64 {
+	sort.Float64s(values)
+	middle := len(values) / 2
+	if len(values)%2 == 0 {
+		return (values[middle-1] + values[middle]) / 2
+	}
+	return values[middle]
+}
@@ -0,0 +1,203 @@
+package depth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Field is a per-minute value stored in the depth data file.
+type Field string
+
+const (
+	FieldBidPrice Field = "bid_price"
+	FieldBidSize  Field = "bid_size"
+	FieldAskPrice Field = "ask_price"
+	FieldAskSize  Field = "ask_size"
+	// FieldMid is the mid price between the best bid and ask.
+	FieldMid Field = "mid"
+	// FieldSpread is the absolute difference between the best ask and bid prices.
+	FieldSpread Field = "spread"
+)
+
+// Schema is the list of fields stored for each minute, in their order in the file.
+type Schema []Field
+
+// DefaultSchema stores the full top of book: bid price and size, ask price and size.
+var DefaultSchema = Schema{FieldBidPrice, FieldBidSize, FieldAskPrice, FieldAskSize}
+
+// schemaHeader starts the file header line listing the schema fields.
+const schemaHeader = "#fields"
+
+func (s Schema) String() string {
+	return slices.Join(s, ",")
+}
+
+// Width returns the number of values stored per minute.
+func (s Schema) Width() int {
+	return len(s)
+}
+
+// Equal checks if the two schemas store the same fields in the same order.
+func (s Schema) Equal(other Schema) bool {
+	return s.String() == other.String()
+}
+
+// Validate checks that the schema stores at least one field, and only known fields, each at most once.
+// The known fields are those of the constants and of the levels after the top of book, see LevelFields.
+func (s Schema) Validate() error {
+	if len(s) == 0 {
+		return errors.New("the schema has no fields")
+	}
+	seen := make(map[Field]bool, len(s))
+	for i, field := range s {
+		switch field {
+		case FieldBidPrice, FieldBidSize, FieldAskPrice, FieldAskSize, FieldMid, FieldSpread:
+		default:
+			if _, ok := field.fullIndex(); ok {
+				break
+			}
+			return fmt.Errorf("unknown schema field #%d: %s", i, field)
+		}
+		if seen[field] {
+			return fmt.Errorf("duplicate schema field: %s", field)
+		}
+		seen[field] = true
+	}
+	return nil
+}
+
+// header returns the schema header line of the schema, see parseSchemaHeader.
+func (s Schema) header() string {
+	fields := []string{schemaHeader}
+	for _, field := range s {
+		fields = append(fields, string(field))
+	}
+	return joinCSV(fields...)
+}
+
+// parseSchemaHeader parses the schema header line. It returns nil if the line is not a schema header,
+// or if it is malformed.
+func parseSchemaHeader(line string) Schema {
+	if !strings.HasPrefix(line, schemaHeader+",") {
+		return nil
+	}
+	fields, err := splitCSV(line)
+	if err != nil {
+		return nil
+	}
+	schema := Schema{}
+	for _, f := range fields[1:] {
+		schema = append(schema, Field(f))
+	}
+	return schema
+}
+
+// project converts the downloaded values of the full top of book, 4 per minute, followed by those of the levels
+// after it, see fullSchema, to the values of the schema.
+func (s Schema) project(values []string) []string {
+	full := s.fullSchema()
+	if s.Equal(full) {
+		return values
+	}
+	// offsets of the stored fields among the downloaded values, -1 for the computed ones
+	offsets := make([]int, len(s))
+	for j, field := range s {
+		offsets[j] = -1
+		if index, ok := field.fullIndex(); ok {
+			offsets[j] = index
+		}
+	}
+	width := full.Width()
+	projected := make([]string, 0, len(values)/width*s.Width())
+	for i := 0; i+width <= len(values); i += width {
+		record := Record{
+			BidPrice: mustParseFloat(values[i]),
+			BidSize:  mustParseFloat(values[i+1]),
+			AskPrice: mustParseFloat(values[i+2]),
+			AskSize:  mustParseFloat(values[i+3]),
+		}
+		for j, field := range s {
+			switch {
+			case offsets[j] >= 0:
+				projected = append(projected, values[i+offsets[j]])
+			case field == FieldMid:
+				projected = append(projected, formatFloat(record.Mid()))
+			case field == FieldSpread:
+				projected = append(projected, formatFloat(record.AskPrice-record.BidPrice))
+			}
+		}
+	}
+	return projected
+}
+
+// record builds the depth record from the values of a single minute.
+func (s Schema) record(pair Pair, values []string) Record {
+	// a schema has at most one value of each field, and 6 without the levels after the top of book
+	var top [6]float64
+	parsed := top[:]
+	if len(s) > len(top) {
+		parsed = make([]float64, len(s))
+	}
+	for i := range s {
+		parsed[i] = mustParseFloat(values[i])
+	}
+	return s.recordOf(pair, parsed[:len(s)])
+}
+
+// recordOf builds the depth record from the parsed values of a single minute.
+// When the schema stores no bid and ask prices, they are derived from the mid price and the spread.
+// When it stores the levels after the top of book, the record has all the levels, see WithDepthLevels.
+func (s Schema) recordOf(pair Pair, values []float64) Record {
+	record := Record{pair: pair}
+	mid, spread := 0.0, 0.0
+	hasBid, hasAsk := false, false
+	var levelValues []int
+	for i, field := range s {
+		v := values[i]
+		switch field {
+		case FieldBidPrice:
+			record.BidPrice, hasBid = v, true
+		case FieldBidSize:
+			record.BidSize = v
+		case FieldAskPrice:
+			record.AskPrice, hasAsk = v, true
+		case FieldAskSize:
+			record.AskSize = v
+		case FieldMid:
+			mid = v
+		case FieldSpread:
+			spread = v
+		default:
+			levelValues = append(levelValues, i)
+		}
+	}
+	if !hasBid {
+		record.BidPrice = mid - spread/2
+	}
+	if !hasAsk {
+		record.AskPrice = mid + spread/2
+	}
+	if len(levelValues) > 0 {
+		levels := s.levels()
+		record.Bids, record.Asks = make([]Level, levels), make([]Level, levels)
+		nan := math.NaN()
+		for level := 1; level < levels; level++ {
+			record.Bids[level], record.Asks[level] = Level{Price: nan, Size: nan}, Level{Price: nan, Size: nan}
+		}
+		record.Bids[0] = Level{Price: record.BidPrice, Size: record.BidSize}
+		record.Asks[0] = Level{Price: record.AskPrice, Size: record.AskSize}
+		for _, i := range levelValues {
+			index, _ := s[i].fullIndex()
+			record.setLevelValue(index, values[i])
+		}
+	}
+	return record
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
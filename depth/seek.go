@@ -0,0 +1,54 @@
+package depth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrOutOfRange is the error of a time out of the loaded time range, see Seek and GetDepthAt.
+var ErrOutOfRange = errors.New("the time is out of the loaded time range")
+
+// Seek moves the cursor to the minute of the time in the loaded time range, so that the records of GetDepth
+// are those of the minute, without counting the Ticks from the start of the time range. Moving the cursor forward
+// feeds the consumers the minutes it enters, like Tick, see WithConsumer, while moving it back feeds them none.
+// It returns an error wrapping ErrOutOfRange if the time is not in the loaded time range, the cursor not moving.
+func (l *CCDepthLoader) Seek(t time.Time) error {
+	minute, err := l.loadedMinute(t)
+	if err != nil {
+		return err
+	}
+	for l.index < minute {
+		l.Tick()
+	}
+	l.index = minute
+	return nil
+}
+
+// GetDepthAt returns the record of the pair at the minute of the time in the loaded time range, without moving
+// the cursor, see Seek, nor being audited, see WithAudit. It returns an error wrapping ErrOutOfRange if the time
+// is not in the loaded minutes of the pair.
+func (l *CCDepthLoader) GetDepthAt(pair Pair, t time.Time) (Record, error) {
+	minute, err := l.loadedMinute(t)
+	if err != nil {
+		return Record{}, err
+	}
+	if minute >= l.length(pair) {
+		return Record{}, fmt.Errorf("%w: %s has no record at %s", ErrOutOfRange, pair, t.UTC().Format(time.RFC3339))
+	}
+	return l.recordAt(pair, minute), nil
+}
+
+// loadedMinute returns the minute of the time in the loaded time range, failing if it is out of the range.
+func (l *CCDepthLoader) loadedMinute(t time.Time) (int, error) {
+	end := l.endDate
+	if end.IsZero() {
+		// the time range read by LoadFrom ends with its longest pair
+		end = l.minuteTime(l.Len())
+	}
+	if l.startDate.IsZero() || t.Before(l.startDate) || !t.Before(end) {
+		return 0, fmt.Errorf("%w: %s is not in %s - %s", ErrOutOfRange, t.UTC().Format(time.RFC3339),
+			l.startDate.Format(time.RFC3339), end.Format(time.RFC3339))
+	}
+	return l.minuteOf(t), nil
+}
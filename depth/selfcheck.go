@@ -0,0 +1,143 @@
+package depth
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+)
+
+// SelfCheck is the consistency check of the cache files when a load opens them, see WithSelfCheck.
+type SelfCheck string
+
+const (
+	// SelfCheckNone trusts the files, for the fastest open.
+	SelfCheckNone SelfCheck = "none"
+	// SelfCheckManifest checks the files against their versions, without parsing them, see Verify:
+	// a file must not be smaller than its last version, and the pairs must not have more minutes than the time range.
+	SelfCheckManifest SelfCheck = "manifest"
+	// SelfCheckFull also parses all the rows of the files, including those of the pairs not loaded, checks their index
+	// sidecar, and checks the hash of each block, see WithBlocks, for a cache of unknown origin.
+	SelfCheckFull SelfCheck = "full"
+)
+
+// ErrCorrupted is the error Load panics with, when a cache file fails the self-check, see WithSelfCheck.
+var ErrCorrupted = errors.New("file is corrupted")
+
+// WithSelfCheck sets the consistency check of the cache files when a load opens them, SelfCheckManifest by default.
+// A load panics with an error wrapping ErrCorrupted if a file fails the check.
+// It panics if the level is unknown.
+func WithSelfCheck(level SelfCheck) Option {
+	switch level {
+	case SelfCheckNone, SelfCheckManifest, SelfCheckFull:
+	default:
+		panic("unknown self-check level: " + string(level))
+	}
+	return func(l *CCDepthLoader) {
+		l.selfCheck = level
+	}
+}
+
+// checkVersions checks the open file of the time range against its versions, see SelfCheckManifest.
+func (l *CCDepthLoader) checkVersions(file *os.File, path string, historyLength int) error {
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	versions, err := readVersions(path)
+	if err != nil {
+		return err
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	last := versions[len(versions)-1]
+	if info.Size() < last.Size {
+		return fmt.Errorf("%w: %s has %d bytes, less than the %d of its version %d",
+			ErrCorrupted, path, info.Size(), last.Size, last.Number)
+	}
+	for _, version := range versions {
+		for pair, minutes := range version.Minutes {
+			if minutes > historyLength {
+				return fmt.Errorf("%w: %s has %d minutes of %s in its version %d, more than the %d of the time range",
+					ErrCorrupted, path, minutes, pair, version.Number, historyLength)
+			}
+		}
+	}
+	return nil
+}
+
+// checkRows parses all the rows within the first size bytes of the open file, and checks its index sidecar,
+// see SelfCheckFull.
+func (l *CCDepthLoader) checkRows(file *os.File, path string, size int64, historyLength int) error {
+	width := l.schema.Width()
+	reader := bufio.NewReader(io.NewSectionReader(file, 0, size))
+	for {
+		line, err := reader.ReadString('\n')
+		if pair, ok := rowPair(line); ok {
+			row := &pairRow{pair: pair, line: line}
+			row.parse(width)
+			if row.err != nil {
+				return fmt.Errorf("%w: %s has a malformed row of %s: %v", ErrCorrupted, path, pair, row.err)
+			}
+			minutes := len(row.values) / width
+			if row.runs != nil {
+				minutes = row.runs.minutes
+			} else if len(row.values)%width != 0 {
+				return fmt.Errorf("%w: %s has %d values of %s, not a multiple of the %d fields of its schema",
+					ErrCorrupted, path, len(row.values), pair, width)
+			}
+			if minutes > historyLength {
+				return fmt.Errorf("%w: %s has %d minutes of %s, more than the %d of the time range",
+					ErrCorrupted, path, minutes, pair, historyLength)
+			}
+			for _, value := range row.values {
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					return fmt.Errorf("%w: %s has a malformed value of %s: %q", ErrCorrupted, path, pair, value)
+				}
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	sidecar := readIndex(path)
+	if sidecar == nil || sidecar.size != info.Size() || sidecar.modTime != info.ModTime().UnixNano() {
+		// a stale sidecar is not used, see openIndex
+		return nil
+	}
+	index, err := scanIndex(file)
+	if err != nil {
+		return err
+	}
+	for pair, span := range index.rows {
+		if sidecar.rows[pair] != span {
+			return fmt.Errorf("%w: the index of %s does not match the row of %s", ErrCorrupted, path, pair)
+		}
+	}
+	if len(sidecar.rows) != len(index.rows) {
+		return fmt.Errorf("%w: the index of %s has %d rows, the file has %d", ErrCorrupted, path, len(sidecar.rows), len(index.rows))
+	}
+	return nil
+}
+
+// checkBlock checks that the content of a block has its hash, see SelfCheckFull.
+func checkBlock(hash string, content []byte) error {
+	sum := sha256.Sum256(content)
+	if hex.EncodeToString(sum[:]) != hash {
+		return fmt.Errorf("%w: the block %s does not have its hash", ErrCorrupted, hash)
+	}
+	return nil
+}
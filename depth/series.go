@@ -0,0 +1,110 @@
+package depth
+
+// WithSeries registers a named series derived from the depth records, like the mid price or the spread.
+// The series is computed once for each loaded pair at the end of Load, and can be retrieved with Series,
+// which avoids recomputing the same values in hot backtest loops.
+func WithSeries(name string, f func(Record) float64) Option {
+	return func(l *CCDepthLoader) {
+		l.derived[name] = f
+	}
+}
+
+// FairPriceSeries is the name of the series registered by WithFairPrice.
+const FairPriceSeries = "fair_price"
+
+// WithFairPrice registers the fair price series of the records with the given imbalance weight, see Record.FairPrice,
+// to be retrieved with Series(FairPriceSeries, pair).
+func WithFairPrice(weight float64) Option {
+	return WithSeries(FairPriceSeries, func(r Record) float64 {
+		return r.FairPrice(weight)
+	})
+}
+
+// WithSeriesOnly keeps only the derived series of the loaded pairs in memory, see WithSeries, and discards their
+// records once the series are computed, for the pipelines needing a few values of months of data of many pairs.
+// For example, a mid price only loader:
+//
+//	loader := depth.NewCCDepthLoader(market, depth.WithSeries("mid", depth.Record.Mid), depth.WithSeriesOnly())
+//
+// Load and LoadFrom then return only the records read or downloaded by the call, and GetDepth can't be used.
+// The pairs with series are not loaded again.
+func WithSeriesOnly() Option {
+	return func(l *CCDepthLoader) {
+		l.seriesOnly = true
+	}
+}
+
+// Series returns the derived series registered with the given name for the pair.
+// It returns nil if the pair is not loaded, and panics if no series is registered with the name.
+func (l *CCDepthLoader) Series(name string, pair Pair) []float64 {
+	if _, ok := l.derived[name]; !ok {
+		panic("series is not registered: " + name)
+	}
+	return l.series[name][pair]
+}
+
+// computeSeries computes all registered series for all loaded pairs.
+// The series of the pairs discarded with WithSeriesOnly are kept.
+func (l *CCDepthLoader) computeSeries() {
+	for name, f := range l.derived {
+		columns := make(map[Pair][]float64, len(l.records))
+		for pair, column := range l.series[name] {
+			if l.seriesOnly {
+				columns[pair] = column
+			}
+		}
+		for _, pair := range l.loadedPairs() {
+			column := make([]float64, l.length(pair))
+			for i := range column {
+				column[i] = f(l.recordAt(pair, i))
+			}
+			columns[pair] = column
+		}
+		l.series[name] = columns
+	}
+}
+
+// discardRecords discards the records of the loaded pairs, once their series are computed, see WithSeriesOnly.
+// It returns the discarded records.
+func (l *CCDepthLoader) discardRecords() map[Pair][]string {
+	records := l.records
+	l.records = make(map[Pair][]string)
+	l.runs = make(map[Pair]*runIndex)
+	return records
+}
+
+// hasSeries checks if the series of the pair are kept without its records, see WithSeriesOnly.
+func (l *CCDepthLoader) hasSeries(pair Pair) bool {
+	for name := range l.derived {
+		if _, ok := l.series[name][pair]; ok {
+			return l.seriesOnly
+		}
+	}
+	return false
+}
+
+// seriesOnlyPairs returns the pairs with series but without records, in alphabetical order.
+func (l *CCDepthLoader) seriesOnlyPairs() []Pair {
+	var pairs []Pair
+	if !l.seriesOnly {
+		return nil
+	}
+	for name := range l.derived {
+		for pair := range l.series[name] {
+			if _, ok := l.records[pair]; !ok && l.values[pair] == nil && !containsPair(pairs, pair) {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+	sortPairs(pairs)
+	return pairs
+}
+
+func containsPair(pairs []Pair, pair Pair) bool {
+	for _, p := range pairs {
+		if p == pair {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,292 @@
+package depth
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"time"
+)
+
+// TickSnapshot contains the records of several pairs at the same minute.
+type TickSnapshot struct {
+	Time    time.Time       `json:"time"`
+	Records map[Pair]Record `json:"records"`
+	// Dropped is the number of snapshots dropped before this one, as the client was too slow, see WithBuffer.
+	Dropped int `json:"dropped,omitempty"`
+	// emitted is when the replay produced the snapshot, the delivery is delayed from, see WithLatency
+	emitted time.Time
+}
+
+// ServeOption configures the replay of Serve.
+type ServeOption func(c *serveConfig)
+
+type serveConfig struct {
+	pace        time.Duration
+	step        bool
+	changesOnly bool
+	buffer      int
+	overflow    Overflow
+	latency     time.Duration
+	jitter      time.Duration
+	seed        int64
+	// time is the time of the pace and the latency, see WithTimeSource
+	time TimeSource
+}
+
+// Overflow is what a replay does with a new snapshot when the buffer of a slow client is full, see WithBuffer.
+type Overflow string
+
+const (
+	// Park pauses the replay until the client consumes a buffered snapshot.
+	Park Overflow = "park"
+	// DropOldest drops the oldest buffered snapshot, so that the client gets the latest ones.
+	DropOldest Overflow = "drop-oldest"
+	// DropNewest drops the new snapshot, so that the client gets the buffered ones first.
+	DropNewest Overflow = "drop-newest"
+)
+
+// WithBuffer buffers up to the given number of snapshots for each client, written to the connection
+// as fast as the client reads them, while the replay goes on at its pace, see WithPace. When the buffer is full,
+// the replay parks until the client catches up, or drops a snapshot, but never the last one, so that a slow client never makes the replay
+// hold more than the buffered snapshots. The snapshot following the dropped ones has their number.
+// Without a buffer, the replay writes each snapshot to the connection before producing the next one.
+// The buffer is not used with WithStep, where the client requests each snapshot.
+// It panics if the size is not positive, or the overflow is unknown.
+func WithBuffer(size int, overflow Overflow) ServeOption {
+	if size < 1 {
+		panic(fmt.Sprintf("the buffer size must be positive, got %d", size))
+	}
+	if overflow != Park && overflow != DropOldest && overflow != DropNewest {
+		panic("unknown overflow " + string(overflow))
+	}
+	return func(c *serveConfig) {
+		c.buffer, c.overflow = size, overflow
+	}
+}
+
+// WithLatency delays the delivery of each snapshot to the client by the latency and a random jitter
+// of up to the given duration, drawn from the seed, after the replay produces it, so that an execution logic
+// can be tested with the data arriving late, like from an exchange across the ocean. The snapshots keep
+// their order, a snapshot with a shorter delay waits for the previous one, and the replay goes on at its pace
+// while the snapshots are delayed. With WithBuffer, the buffered snapshots include those being delayed.
+// With WithStep, each requested snapshot is delayed. It panics if the latency or the jitter is negative.
+func WithLatency(latency time.Duration, jitter time.Duration, seed int64) ServeOption {
+	if latency < 0 || jitter < 0 {
+		panic(fmt.Sprintf("the latency and the jitter must not be negative, got %s and %s", latency, jitter))
+	}
+	return func(c *serveConfig) {
+		c.latency, c.jitter, c.seed = latency, jitter, seed
+	}
+}
+
+// WithPace waits the given duration between the snapshots of a replay, instead of writing them
+// as fast as the connection allows.
+func WithPace(pace time.Duration) ServeOption {
+	return func(c *serveConfig) {
+		c.pace = pace
+	}
+}
+
+// WithTimeSource sets the time of the pace and the latency of the replays, see WithPace and WithLatency,
+// RealTime by default, like a ManualTime to step a paced replay in a test.
+func WithTimeSource(source TimeSource) ServeOption {
+	return func(c *serveConfig) {
+		c.time = source
+	}
+}
+
+// WithStep makes the client request each snapshot, by writing a line to the connection,
+// so that it consumes the replay in lockstep with its own processing.
+func WithStep() ServeOption {
+	return func(c *serveConfig) {
+		c.step = true
+	}
+}
+
+// WithChangesOnly replays only the minutes where the record of one of the pairs changed, for event-driven
+// strategies, instead of every minute of the time range. See NextChange and WithMoveThreshold.
+func WithChangesOnly() ServeOption {
+	return func(c *serveConfig) {
+		c.changesOnly = true
+	}
+}
+
+// Serve replays the loaded records of the given pairs, or all loaded pairs if none are given,
+// to each connection accepted on the listener. It is meant for a Unix socket, so that processes
+// running on the same machine, possibly written in other languages, can consume the replay:
+//
+//	listener, _ := net.Listen("unix", "/tmp/depth.sock")
+//	loader.Serve(listener, []depth.Pair{"BTC-BUSD"}, depth.WithPace(10*time.Millisecond))
+//
+// Each connection gets its own replay from the start of the loaded time range, independent of Tick,
+// written as one JSON line per minute, flushed right away:
+//
+//	{"time":"2022-11-24T00:00:00Z","records":{"BTC-BUSD":{"bid_price":16544.2,"bid_size":0.5,"ask_price":16544.3,"ask_size":1.2}}}
+//
+// The connection is closed after the last minute. A slow reader slows down only its own replay.
+// Serve returns when the listener is closed.
+// The replays read the loaded records without locking, so the loader must not Load or LoadFrom
+// while it is serving, that would be a data race.
+func (l *CCDepthLoader) Serve(listener net.Listener, pairs []Pair, opts ...ServeOption) error {
+	config := &serveConfig{time: RealTime}
+	for _, opt := range opts {
+		opt(config)
+	}
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	for {
+		conn, err := listener.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		go func(conn net.Conn) {
+			defer conn.Close()
+			_ = l.replay(conn, pairs, config)
+		}(conn)
+	}
+}
+
+// replay writes the snapshots of all minutes to the connection, or of the changed ones with WithChangesOnly.
+func (l *CCDepthLoader) replay(conn net.Conn, pairs []Pair, config *serveConfig) error {
+	writer := bufio.NewWriter(conn)
+	encoder := json.NewEncoder(writer)
+	write := func(snapshot TickSnapshot) error {
+		if err := encoder.Encode(snapshot); err != nil {
+			return err
+		}
+		return writer.Flush()
+	}
+	buffer, overflow := config.buffer, config.overflow
+	if config.latency > 0 || config.jitter > 0 {
+		write = config.delayed(write)
+		if buffer == 0 {
+			// the snapshots being delayed at the pace of the replay, so that the latency doesn't slow it down
+			buffer, overflow = 1, Park
+			if config.pace > 0 {
+				buffer += int((config.latency + config.jitter) / config.pace)
+			}
+		}
+	}
+	if buffer == 0 || config.step {
+		return l.produce(conn, pairs, config, write)
+	}
+
+	snapshots := make(chan TickSnapshot, buffer)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(snapshots)
+		dropped := 0
+		// the last dropped snapshot is sent at the end, so that the client gets the end of the replay
+		var last *TickSnapshot
+		err := l.produce(conn, pairs, config, func(snapshot TickSnapshot) error {
+			snapshot.Dropped = dropped
+			select {
+			case snapshots <- snapshot:
+				dropped, last = 0, nil
+				return nil
+			case <-done:
+				return net.ErrClosed
+			default:
+			}
+			switch overflow {
+			case DropNewest:
+				dropped++
+				last = &snapshot
+				return nil
+			case DropOldest:
+				select {
+				case oldest := <-snapshots:
+					snapshot.Dropped += oldest.Dropped + 1
+				default:
+				}
+			}
+			select {
+			case snapshots <- snapshot:
+				dropped = 0
+				return nil
+			case <-done:
+				return net.ErrClosed
+			}
+		})
+		if err == nil && last != nil {
+			last.Dropped = dropped - 1
+			select {
+			case snapshots <- *last:
+			case <-done:
+			}
+		}
+	}()
+	for snapshot := range snapshots {
+		if err := write(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// delayed returns the write function waiting for the latency of each snapshot before writing it, see WithLatency.
+func (c *serveConfig) delayed(write func(TickSnapshot) error) func(TickSnapshot) error {
+	rng := rand.New(rand.NewSource(c.seed))
+	var due time.Time
+	return func(snapshot TickSnapshot) error {
+		delay := c.latency
+		if c.jitter > 0 {
+			delay += time.Duration(rng.Int63n(int64(c.jitter) + 1))
+		}
+		if at := snapshot.emitted.Add(delay); at.After(due) {
+			due = at
+		}
+		<-c.time.After(due.Sub(c.time.Now()))
+		return write(snapshot)
+	}
+}
+
+// produce calls the emit function with the snapshot of each replayed minute, at the pace of the replay.
+func (l *CCDepthLoader) produce(conn net.Conn, pairs []Pair, config *serveConfig, emit func(TickSnapshot) error) error {
+	requests := bufio.NewReader(conn)
+	length := 0
+	for _, pair := range pairs {
+		if l.length(pair) > length {
+			length = l.length(pair)
+		}
+	}
+	next := func(minute int) int { return minute + 1 }
+	if config.changesOnly {
+		next = func(minute int) int { return l.nextChange(pairs, minute) }
+	}
+	for i := 0; i < length; i = next(i) {
+		if config.step {
+			if _, err := requests.ReadString('\n'); err != nil {
+				return err
+			}
+		} else if config.pace > 0 && i > 0 {
+			<-config.time.After(config.pace)
+		}
+		snapshot := l.snapshotAt(pairs, i)
+		snapshot.emitted = config.time.Now()
+		if err := emit(snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// snapshotAt returns the records of the pairs at the given minute, skipping the pairs without data for it,
+// and those of a bad day, see Tombstone.
+func (l *CCDepthLoader) snapshotAt(pairs []Pair, minute int) TickSnapshot {
+	snapshot := TickSnapshot{Time: l.minuteTime(minute).UTC(), Records: make(map[Pair]Record, len(pairs))}
+	for _, pair := range pairs {
+		if minute < l.length(pair) && !l.isBad(pair, minute) {
+			snapshot.Records[pair] = l.recordAt(pair, minute)
+		}
+	}
+	return snapshot
+}
@@ -0,0 +1,70 @@
+package depth
+
+import (
+	"fmt"
+	"time"
+)
+
+// Splice is a window of the minutes of a source loader, spliced into the timeline of another loader, see Splice.
+type Splice struct {
+	// Source is the loader of the window, like one with the crash days of May 2021 loaded
+	Source *CCDepthLoader
+	// Start and End are the window, in the time range of the source, with End exclusive
+	Start time.Time
+	End   time.Time
+	// At is the time of the loader the window replaces the minutes from
+	At time.Time
+}
+
+// Splice returns a copy of the loaded pairs with the windows of the splices replacing their minutes, in the order
+// of the splices, so that a strategy can be backtested on a path with the stress windows of the history, like crashes,
+// in the market regime of the loaded time range. The joins are smooth: the prices of a window are scaled,
+// so that it starts at the last mid price before it, and the prices after it are scaled to continue
+// from the last mid price of the window. A window past the end of the time range is cut.
+// The copy is a loader of its own, like the one of Perturb.
+// It panics if a window is not in the time range of its source, if its source lacks a loaded pair,
+// or if it starts out of the time range of the loader.
+func (l *CCDepthLoader) Splice(splices ...Splice) *CCDepthLoader {
+	pairs := l.loadedPairs()
+	grid := l.gridLength(pairs)
+	for _, s := range splices {
+		from, to := s.Source.minuteOf(s.Start), s.Source.minuteOf(s.End)
+		if at := l.minuteOf(s.At); at < 0 || at >= grid {
+			panic(fmt.Sprintf("the splice at %s is out of the time range", s.At.Format(time.RFC3339)))
+		}
+		for _, pair := range pairs {
+			if from < 0 || to <= from || to > s.Source.length(pair) {
+				panic(fmt.Sprintf("the window %s - %s of %s is not in the time range of its source",
+					s.Start.Format(time.RFC3339), s.End.Format(time.RFC3339), pair))
+			}
+		}
+	}
+	return l.copyOf(func(pair Pair) []Record {
+		records := make([]Record, l.length(pair))
+		for minute := range records {
+			records[minute] = l.recordAt(pair, minute)
+		}
+		for _, s := range splices {
+			from, at := s.Source.minuteOf(s.Start), l.minuteOf(s.At)
+			if at >= len(records) {
+				continue
+			}
+			end := at + s.Source.minuteOf(s.End) - from
+			if end > len(records) {
+				end = len(records)
+			}
+			window := records[at:end]
+			for k := range window {
+				window[k] = s.Source.recordAt(pair, from+k)
+			}
+			stitch(window, lastMidOf(records[:at]))
+			stitch(records[end:], lastMidOf(window))
+		}
+		return records
+	})
+}
+
+// minuteOf returns the minute of the time in the loaded time range.
+func (l *CCDepthLoader) minuteOf(t time.Time) int {
+	return int(t.Sub(l.startDate).Minutes())
+}
@@ -0,0 +1,46 @@
+package depth
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// RollingVol returns the sample standard deviation of the 1 minute log returns of the mid price
+// over the last window returns, ending at the current minute.
+// When fewer than window returns are available before the cursor, all of them are used.
+// It returns NaN if there are fewer than 2 returns to compute the volatility from.
+func (l *CCDepthLoader) RollingVol(pair Pair, window int) float64 {
+	current := l.index
+	if current >= l.length(pair) {
+		panic(fmt.Errorf("%w: %s has no record at %s", ErrOutOfRange, pair, l.CurrentTime().UTC().Format(time.RFC3339)))
+	}
+	first := current - window
+	if first < 0 {
+		first = 0
+	}
+	n := current - first
+	if n < 2 {
+		return math.NaN()
+	}
+
+	returns := make([]float64, 0, n)
+	prevMid := l.recordAt(pair, first).Mid()
+	for i := first + 1; i <= current; i++ {
+		mid := l.recordAt(pair, i).Mid()
+		returns = append(returns, math.Log(mid/prevMid))
+		prevMid = mid
+	}
+
+	mean := 0.0
+	for _, r := range returns {
+		mean += r
+	}
+	mean /= float64(n)
+
+	variance := 0.0
+	for _, r := range returns {
+		variance += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(variance / float64(n-1))
+}
@@ -0,0 +1,123 @@
+package depth
+
+import (
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"time"
+)
+
+// Store persists the downloaded days of the pairs of a market, see WithStore, so that a backend like SQLite,
+// Parquet or S3 stores them without changing the download and the iteration of the loader.
+// The values of a day are the values of the schema of each of its minutes, in the order of the minutes.
+type Store interface {
+	// Open prepares the store for the days of the schema, failing if it stores the days of another one.
+	// The loader opens its store at the start of each Load.
+	Open(schema Schema) error
+	// Has reports whether the day of the pair is stored, the days without vendor data included.
+	Has(pair Pair, day time.Time) (bool, error)
+	// WriteDay stores the values of the day of the pair, none for a day without vendor data.
+	WriteDay(pair Pair, day time.Time, values []string) error
+	// ReadRange returns the values of each day of the pair in the time range, none for the days not stored.
+	ReadRange(pair Pair, startDate time.Time, endDate time.Time) ([][]string, error)
+}
+
+// WithStore persists the downloaded days in the store instead of the depth data files of the time ranges,
+// so that the loads of the time ranges overlapping the stored ones only download the days not stored yet.
+// The days have no versions or index sidecars, like the blocks, and WithBlocks takes precedence over WithStore.
+func WithStore(store Store) Option {
+	if store == nil {
+		panic("the store must not be nil")
+	}
+	return func(l *CCDepthLoader) {
+		l.store = store
+	}
+}
+
+// loadStore is the Load of a loader storing the days in its store, see WithStore.
+func (l *CCDepthLoader) loadStore(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string {
+	width := l.schema.Width()
+	l.startDate, l.endDate = startDate, endDate
+	if err := l.store.Open(l.schema); err != nil {
+		panic(err)
+	}
+
+	pairsToLoad := pairs
+	if len(pairsToLoad) == 0 {
+		pairsToLoad = defaultPairs
+	}
+	var days []time.Time
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days = append(days, date)
+	}
+
+	var bad map[Pair][]time.Time
+	if l.refetchBad && !l.readOnly {
+		bad = l.badDays(startDate, endDate)
+	}
+	var missing []Pair
+	written := 0
+	for _, pair := range pairsToLoad {
+		if l.records[pair] != nil || l.values[pair] != nil || l.hasSeries(pair) {
+			continue
+		}
+		var toDownload []time.Time
+		for _, day := range days {
+			stored, err := l.store.Has(pair, day)
+			if err != nil {
+				panic(err)
+			}
+			if !stored || containsDay(bad[pair], day) {
+				toDownload = append(toDownload, day)
+			}
+		}
+		if len(toDownload) > 0 && l.readOnly {
+			missing = append(missing, pair)
+			continue
+		}
+		downloaded, failures := l.downloadDays(pair, toDownload, nil)
+		// the days not downloaded after the load was cancelled are not stored, see LoadContext
+		if l.context().Err() != nil {
+			break
+		}
+		for i, values := range downloaded {
+			day := toDownload[i]
+			if failedOn(failures, day) {
+				continue
+			}
+			if err := l.store.WriteDay(pair, day, l.schema.project(values)); err != nil {
+				panic(err)
+			}
+			written++
+			if containsDay(bad[pair], day) && len(values) > 0 {
+				if err := l.Restore(pair, day); err != nil {
+					panic(err)
+				}
+			}
+		}
+
+		// the pair is not loaded with its failed days, the others are stored for the next load
+		if len(failures) > 0 {
+			l.failures = append(l.failures, failures...)
+			continue
+		}
+
+		dayValues, err := l.store.ReadRange(pair, startDate, endDate)
+		if err != nil {
+			panic(err)
+		}
+		record := slices.Concat(l.padDays(dayValues, width)...)
+		if len(record) > 0 {
+			l.setRecords(pair, record)
+		}
+	}
+	// a read-only load of the default pairs fails only if the store has none of them
+	if len(missing) > 0 && (len(pairs) > 0 || len(missing) == len(pairsToLoad)) {
+		panic(fmt.Errorf("%w: the store has no data for %s", ErrReadOnly, slices.Join(missing, ", ")))
+	}
+
+	if written > 0 {
+		_, _ = fmt.Fprintln(l.progress, "Depth days stored:", written)
+	}
+	l.canceled()
+	return l.loaded()
+}
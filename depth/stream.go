@@ -0,0 +1,43 @@
+package depth
+
+import "context"
+
+// Stream sends the snapshot of each loaded minute of the pairs on the returned channel, from the first minute
+// of the loaded time range past the warmup, see WithWarmup, to the last one of the longest loaded pair, like the replay
+// of Serve without its connection, for the pipelines of goroutines not sharing the cursor of the loader.
+// The channel is unbuffered, so that the stream goes on at the pace of its consumer, and it is closed after the last
+// minute, or once the context is done. Each snapshot has a record of every pair, the one with NaN prices and sizes
+// for a pair past its end, as for a bad day. Without pairs, it streams the loaded ones. Like a Cursor, it moves
+// neither the cursor of the loader nor feeds the consumers, and the loader must not load while it streams.
+func (l *CCDepthLoader) Stream(ctx context.Context, pairs []Pair) <-chan TickSnapshot {
+	if len(pairs) == 0 {
+		pairs = l.loadedPairs()
+	}
+	length := l.Len()
+	snapshots := make(chan TickSnapshot)
+	go func() {
+		defer close(snapshots)
+		for minute := l.warmup; minute < length; minute++ {
+			select {
+			case snapshots <- l.streamedSnapshot(pairs, minute):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return snapshots
+}
+
+// streamedSnapshot returns the records of all the pairs at the given minute, the NaN record of a pair without data
+// for it, see Stream.
+func (l *CCDepthLoader) streamedSnapshot(pairs []Pair, minute int) TickSnapshot {
+	snapshot := TickSnapshot{Time: l.minuteTime(minute).UTC(), Records: make(map[Pair]Record, len(pairs))}
+	for _, pair := range pairs {
+		if minute < l.length(pair) {
+			snapshot.Records[pair] = l.recordAt(pair, minute)
+		} else {
+			snapshot.Records[pair] = l.nanRecord(pair, minute)
+		}
+	}
+	return snapshot
+}
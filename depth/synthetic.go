@@ -0,0 +1,219 @@
+package depth
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"sort"
+	"time"
+)
+
+// SyntheticModel are the dynamics of a pair fitted to its loaded minutes, see Calibrate.
+// The mid price is a random walk of the minutes, the spread and the size at the touch are drawn from their
+// distribution each minute, and the imbalance of the touch, see Record.Imbalance, reverts to its mean.
+type SyntheticModel struct {
+	Pair Pair
+	// Mid is the mid price of the first minute of the synthetic records
+	Mid float64
+	// Volatility is the standard deviation of the 1 minute log returns of the mid price
+	Volatility float64
+	// Spreads are the 101 percentiles of the spread, relative to the mid price, see Record.SpreadPercentage
+	Spreads []float64
+	// Sizes are the 101 percentiles of the size of both sides of the touch, in the base currency
+	Sizes []float64
+	// ImbalanceMean, ImbalanceStdDev and ImbalanceAutocorrelation are the mean, the standard deviation,
+	// and the 1 minute autocorrelation of the imbalance of the touch
+	ImbalanceMean            float64
+	ImbalanceStdDev          float64
+	ImbalanceAutocorrelation float64
+}
+
+// Calibrate fits the dynamics of the synthetic records to the loaded minutes of the pair, skipping those without
+// a record and the bad days, see Tombstone, so that a strategy can be evaluated on many synthetic paths
+// with the market conditions of the pair, see NewSynthetic. It panics if the pair has fewer than 2 such minutes.
+func (l *CCDepthLoader) Calibrate(pair Pair) SyntheticModel {
+	var mids, spreads, sizes, imbalances []float64
+	var returns, lagged, leading []float64
+	prev := -1
+	for minute := 0; minute < l.length(pair); minute++ {
+		record := l.recordAt(pair, minute)
+		if math.IsNaN(record.Mid()) || math.IsNaN(record.Imbalance()) {
+			continue
+		}
+		mids = append(mids, record.Mid())
+		spreads = append(spreads, (record.AskPrice-record.BidPrice)/record.Mid())
+		sizes = append(sizes, record.BidSize+record.AskSize)
+		imbalances = append(imbalances, record.Imbalance())
+		if n := len(mids); prev == minute-1 && n > 1 {
+			returns = append(returns, math.Log(mids[n-1]/mids[n-2]))
+			lagged, leading = append(lagged, imbalances[n-2]), append(leading, imbalances[n-1])
+		}
+		prev = minute
+	}
+	if len(mids) < 2 {
+		panic(fmt.Sprintf("%s has fewer than 2 minutes to calibrate the synthetic records to", pair))
+	}
+	mean, stdDev := meanStdDev(imbalances)
+	_, volatility := meanStdDev(returns)
+	return SyntheticModel{
+		Pair:                     pair,
+		Mid:                      mids[0],
+		Volatility:               volatility,
+		Spreads:                  percentiles(spreads),
+		Sizes:                    percentiles(sizes),
+		ImbalanceMean:            mean,
+		ImbalanceStdDev:          stdDev,
+		ImbalanceAutocorrelation: correlation(lagged, leading),
+	}
+}
+
+// meanStdDev returns the mean and the sample standard deviation of the values, 0 for fewer than 2 values.
+func meanStdDev(values []float64) (float64, float64) {
+	if len(values) < 2 {
+		return 0, 0
+	}
+	mean := 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(variance / float64(len(values)-1))
+}
+
+// correlation returns the correlation of the values, 0 if either of them is constant.
+func correlation(xs []float64, ys []float64) float64 {
+	xMean, xStdDev := meanStdDev(xs)
+	yMean, yStdDev := meanStdDev(ys)
+	if xStdDev == 0 || yStdDev == 0 {
+		return 0
+	}
+	covariance := 0.0
+	for i := range xs {
+		covariance += (xs[i] - xMean) * (ys[i] - yMean)
+	}
+	return covariance / float64(len(xs)-1) / (xStdDev * yStdDev)
+}
+
+// percentiles returns the 101 percentiles of the values, from the minimum to the maximum.
+func percentiles(values []float64) []float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	result := make([]float64, 101)
+	for p := range result {
+		result[p] = sorted[p*(len(sorted)-1)/100]
+	}
+	return result
+}
+
+// draw draws a value from the distribution of the percentiles, interpolated between them.
+func draw(rng *rand.Rand, percentiles []float64) float64 {
+	position := rng.Float64() * float64(len(percentiles)-1)
+	i := int(position)
+	if i == len(percentiles)-1 {
+		return percentiles[i]
+	}
+	return percentiles[i] + (position-float64(i))*(percentiles[i+1]-percentiles[i])
+}
+
+// Synthetic is a Loader of the endless synthetic records of the pairs of the models, see Calibrate.
+// Each Tick draws the next minute of each pair, so that a strategy runs on paths of any length without keeping them.
+// The records of a pair only depend on the seed and its model.
+type Synthetic struct {
+	seed   int64
+	models map[Pair]SyntheticModel
+	// rngs are the random sources of the pairs, mids and imbalances the state of their dynamics
+	rngs       map[Pair]*rand.Rand
+	mids       map[Pair]float64
+	imbalances map[Pair]float64
+	current    map[Pair]Record
+}
+
+// NewSynthetic returns a Loader of the synthetic records of the pairs of the models, drawn from the seed.
+func NewSynthetic(seed int64, models ...SyntheticModel) *Synthetic {
+	s := &Synthetic{seed: seed, models: make(map[Pair]SyntheticModel, len(models))}
+	for _, model := range models {
+		s.models[model.Pair] = model
+	}
+	s.reset()
+	return s
+}
+
+// reset starts the records of the pairs from their first minute.
+func (s *Synthetic) reset() {
+	s.rngs = make(map[Pair]*rand.Rand, len(s.models))
+	s.mids = make(map[Pair]float64, len(s.models))
+	s.imbalances = make(map[Pair]float64, len(s.models))
+	s.current = make(map[Pair]Record, len(s.models))
+	for pair, model := range s.models {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(pair))
+		s.rngs[pair] = rand.New(rand.NewSource(s.seed ^ int64(h.Sum64())))
+		s.mids[pair], s.imbalances[pair] = model.Mid, model.ImbalanceMean
+		s.current[pair] = s.record(pair)
+	}
+}
+
+// Load starts the records of the pairs from their first minute, and returns the values of the minutes
+// of the time range of the pairs with a model, in the DefaultSchema. The cursor is at the first minute,
+// and its Ticks go on past the end of the time range.
+func (s *Synthetic) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]string {
+	minutes := int(endDate.Sub(startDate).Minutes())
+	result := make(map[Pair][]string)
+	s.reset()
+	for minute := 0; minute < minutes; minute++ {
+		for _, pair := range pairs {
+			if _, ok := s.models[pair]; !ok {
+				continue
+			}
+			record := s.current[pair]
+			for _, v := range []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize} {
+				result[pair] = append(result[pair], formatFloat(v))
+			}
+		}
+		s.Tick()
+	}
+	s.reset()
+	return result
+}
+
+// Tick draws the next minute of each pair. It always returns true, the records being endless.
+func (s *Synthetic) Tick() bool {
+	for pair, model := range s.models {
+		rng := s.rngs[pair]
+		s.mids[pair] *= math.Exp(model.Volatility * rng.NormFloat64())
+		phi := model.ImbalanceAutocorrelation
+		imbalance := model.ImbalanceMean + phi*(s.imbalances[pair]-model.ImbalanceMean) +
+			model.ImbalanceStdDev*math.Sqrt(1-phi*phi)*rng.NormFloat64()
+		s.imbalances[pair] = math.Max(-0.99, math.Min(0.99, imbalance))
+		s.current[pair] = s.record(pair)
+	}
+	return true
+}
+
+// record draws the record of the current minute of the pair, from its mid price and imbalance.
+func (s *Synthetic) record(pair Pair) Record {
+	model, rng := s.models[pair], s.rngs[pair]
+	mid, imbalance := s.mids[pair], s.imbalances[pair]
+	spread, size := draw(rng, model.Spreads)*mid, draw(rng, model.Sizes)
+	return Record{
+		pair:     pair,
+		BidPrice: mid - spread/2,
+		BidSize:  size * (1 + imbalance) / 2,
+		AskPrice: mid + spread/2,
+		AskSize:  size * (1 - imbalance) / 2,
+	}
+}
+
+// GetDepth returns the record of the current minute of the pair, NaN for the pairs without a model.
+func (s *Synthetic) GetDepth(pair Pair) Record {
+	if record, ok := s.current[pair]; ok {
+		return record
+	}
+	nan := math.NaN()
+	return Record{pair: pair, BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan}
+}
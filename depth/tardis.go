@@ -0,0 +1,200 @@
+package depth
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// tardisExchange is the exchange identifier of a market in the Tardis.dev API, and the format of its symbols,
+// with the base and the quote of the pair as the first and the second argument.
+type tardisExchange struct {
+	id     string
+	symbol string
+	// xbt is set for the exchanges listing Bitcoin as XBT
+	xbt bool
+}
+
+// tardisExchanges are the Tardis.dev exchanges of the markets, see https://docs.tardis.dev/historical-data-details.
+var tardisExchanges = map[Market]tardisExchange{
+	MarketBitfinex:               {id: "bitfinex", symbol: "%s%s"},
+	MarketBitmex:                 {id: "bitmex", symbol: "%s%s", xbt: true},
+	MarketBinance:                {id: "binance", symbol: "%s%s"},
+	MarketBinanceCoinFutures:     {id: "binance-delivery", symbol: "%s%s_PERP"},
+	MarketBinanceUsdsFutures:     {id: "binance-futures", symbol: "%s%s"},
+	MarketBinanceUs:              {id: "binance-us", symbol: "%s%s"},
+	MarketBitstamp:               {id: "bitstamp", symbol: "%s%s"},
+	MarketCoinbase:               {id: "coinbase", symbol: "%s-%s"},
+	MarketDeribit:                {id: "deribit", symbol: "%[1]s-PERPETUAL"},
+	MarketFtx:                    {id: "ftx", symbol: "%s-%s"},
+	MarketFtxUs:                  {id: "ftx-us", symbol: "%s-%s"},
+	MarketGateio:                 {id: "gate-io", symbol: "%s_%s"},
+	MarketGateioPerpetualFutures: {id: "gate-io-futures", symbol: "%s_%s"},
+	MarketGemini:                 {id: "gemini", symbol: "%s%s"},
+	MarketHuobi:                  {id: "huobi", symbol: "%s%s"},
+	MarketHuobiCoinSwap:          {id: "huobi-dm-swap", symbol: "%s-%s"},
+	MarketHuobiUsdtSwap:          {id: "huobi-dm-linear-swap", symbol: "%s-%s"},
+	MarketKucoin:                 {id: "kucoin", symbol: "%s-%s"},
+	MarketKraken:                 {id: "kraken", symbol: "%s-%s", xbt: true},
+	MarketKrakenFutures:          {id: "cryptofacilities", symbol: "PI_%s%s", xbt: true},
+	MarketOkex:                   {id: "okex", symbol: "%s-%s"},
+}
+
+// NewTardisDepthLoader returns a Loader of the Tardis.dev quotes of the market, downloaded with the API key,
+// see TardisProvider. The cache files are kept in the tardis namespace, apart from those of crypto-chassis,
+// unless the options set another one, see WithNamespace. The options may also set a TardisProvider
+// with its own options, see WithProvider. It panics if Tardis.dev has no exchange for the market.
+func NewTardisDepthLoader(market Market, apiKey string, opts ...Option) *CCDepthLoader {
+	if _, ok := tardisExchanges[market]; !ok {
+		panic("Tardis.dev has no exchange for market " + string(market))
+	}
+	return NewCCDepthLoader(market, append([]Option{WithNamespace("tardis"), WithProvider(NewTardisProvider(apiKey))}, opts...)...)
+}
+
+// TardisProvider is the Provider of the daily quotes datasets of the Tardis.dev historical data API:
+// https://datasets.tardis.dev/v1/binance/quotes/2022/11/24/BTCUSDT.csv.gz
+// The dataset is a gzipped CSV of every update of the touch, with the timestamps in microseconds:
+//
+//	exchange,symbol,timestamp,local_timestamp,ask_amount,ask_price,bid_price,bid_amount
+//
+// The updates are sampled at the start of each minute, like those of the BinanceVisionProvider.
+// The datasets of the first day of each month are free, the others need an API key, see https://tardis.dev.
+// The symbols of the pairs are built with the format of the exchange, like BTCUSDT on Binance and XBT-USD on Kraken,
+// other symbols, like those of the delivery futures, are set with WithTardisSymbols.
+type TardisProvider struct {
+	apiKey  string
+	baseURL string
+	client  *http.Client
+	symbols map[Pair]string
+}
+
+// NewTardisProvider returns the Provider of the Tardis.dev datasets, downloaded with the API key,
+// or only the free ones without an API key.
+func NewTardisProvider(apiKey string, opts ...TardisOption) *TardisProvider {
+	p := &TardisProvider{apiKey: apiKey, baseURL: "https://datasets.tardis.dev", client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// TardisOption configures the TardisProvider.
+type TardisOption func(p *TardisProvider)
+
+// WithTardisBaseURL sets the URL of the datasets API, like a mirror or a test server, https://datasets.tardis.dev by default.
+func WithTardisBaseURL(baseURL string) TardisOption {
+	return func(p *TardisProvider) {
+		p.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithTardisHTTPClient sets the client of the downloads, http.DefaultClient by default.
+// It panics if the client is nil.
+func WithTardisHTTPClient(client *http.Client) TardisOption {
+	if client == nil {
+		panic("the HTTP client must not be nil")
+	}
+	return func(p *TardisProvider) {
+		p.client = client
+	}
+}
+
+// WithTardisSymbols sets the Tardis.dev symbols of the pairs, like FI_XBTUSD_221230 of a delivery future,
+// instead of the symbols built with the format of the exchange.
+func WithTardisSymbols(symbols map[Pair]string) TardisOption {
+	return func(p *TardisProvider) {
+		p.symbols = symbols
+	}
+}
+
+// symbol returns the symbol of the pair in the datasets API, uppercase, with the / and : replaced with -.
+func (p *TardisProvider) symbol(exchange tardisExchange, pair Pair) string {
+	if symbol, ok := p.symbols[pair]; ok {
+		return symbol
+	}
+	base, quote := strings.ToUpper(pair.Base()), strings.ToUpper(pair.Quote())
+	if exchange.xbt && base == "BTC" {
+		base = "XBT"
+	}
+	return fmt.Sprintf(exchange.symbol, base, quote)
+}
+
+func (p *TardisProvider) FetchDay(ctx context.Context, market Market, pair Pair, date time.Time) ([]Record, error) {
+	exchange, ok := tardisExchanges[market]
+	if !ok {
+		return nil, fmt.Errorf("Tardis.dev has no exchange for market %s", market)
+	}
+	url := p.baseURL + "/v1/" + exchange.id + "/quotes/" + date.Format("2006/01/02") + "/" + p.symbol(exchange, pair) + ".csv.gz"
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("%s: %s: %s", url, resp.Status, string(body))
+	}
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	defer gz.Close()
+	sampler := newMinuteSampler(date)
+	if err := readTardisQuotes(gz, sampler); err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return sampler.records(), nil
+}
+
+// readTardisQuotes samples the updates of a quotes dataset.
+func readTardisQuotes(r io.Reader, sampler *minuteSampler) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if row[0] == "exchange" {
+			continue
+		}
+		if len(row) < 8 {
+			return fmt.Errorf("expected 8 columns, got %d: %v", len(row), row)
+		}
+		// the sides without an order are empty
+		var values [4]float64
+		for i, s := range []string{row[6], row[7], row[5], row[4]} {
+			if s == "" {
+				continue
+			}
+			if values[i], err = strconv.ParseFloat(s, 64); err != nil {
+				return fmt.Errorf("malformed quotes row: %q: %w", strings.Join(row, ","), err)
+			}
+		}
+		us, err := strconv.ParseInt(row[2], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed quotes row: %q: %w", strings.Join(row, ","), err)
+		}
+		sampler.add(us/1000, Record{BidPrice: values[0], BidSize: values[1], AskPrice: values[2], AskSize: values[3]})
+	}
+}
@@ -0,0 +1,98 @@
+package depth
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Side is the aggressor side of a trade.
+type Side int
+
+const (
+	// Buy is a buyer-initiated trade (lifted the ask).
+	Buy Side = 1
+	// Sell is a seller-initiated trade (hit the bid).
+	Sell Side = -1
+)
+
+// Mid returns the mid price between the best bid and the best ask.
+func (r Record) Mid() float64 {
+	return (r.BidPrice + r.AskPrice) / 2
+}
+
+// FairPrice returns the mid price moved towards the side with the smaller size, as it is the more likely to be
+// taken next, by the imbalance weighted portion of half the spread: mid + weight * imbalance * spread / 2.
+// The weight 1 is the microprice, the prices weighted by the size of the opposite side, and the weight 0 the mid.
+func (r Record) FairPrice(weight float64) float64 {
+	return r.Mid() + weight*r.Imbalance()*(r.AskPrice-r.BidPrice)/2
+}
+
+// EffectiveSpread returns the effective spread paid by a trade executed at the given price
+// against this book snapshot, as a fraction of the mid price: 2 * side * (price - mid) / mid.
+func (r Record) EffectiveSpread(price float64, side Side) float64 {
+	mid := r.Mid()
+	return 2 * float64(side) * (price - mid) / mid
+}
+
+// RealizedSpread returns the realized spread of a trade executed at the given price against
+// the book snapshot at, measured against the mid price of a later snapshot:
+// 2 * side * (price - laterMid) / mid.
+// The difference between the effective and the realized spread is the price impact of the trade.
+func RealizedSpread(price float64, side Side, at Record, later Record) float64 {
+	return 2 * float64(side) * (price - later.Mid()) / at.Mid()
+}
+
+// PriceImpact returns the permanent price impact of a trade, as a fraction of the mid price:
+// 2 * side * (laterMid - mid) / mid.
+func PriceImpact(side Side, at Record, later Record) float64 {
+	return 2 * float64(side) * (later.Mid() - at.Mid()) / at.Mid()
+}
+
+// TradeSpread is the transaction cost of the trades of a pair during a minute, their VWAP against the depth record
+// of the minute, see TradeSpreads.
+type TradeSpread struct {
+	Time time.Time `json:"time"`
+	// Side is the side of the net taker volume, Buy if the takers bought more than they sold, 0 if they bought as much.
+	Side   Side    `json:"side"`
+	VWAP   float64 `json:"vwap"`
+	Volume float64 `json:"volume"`
+	// Effective, Realized and Impact are the EffectiveSpread, the RealizedSpread and the PriceImpact of the VWAP
+	// traded on the Side, NaN without trades, net volume or depth record, and Realized and Impact NaN
+	// without the later record
+	Effective float64 `json:"effective"`
+	Realized  float64 `json:"realized"`
+	Impact    float64 `json:"impact"`
+}
+
+// TradeSpreads returns the spreads of the trades of the pair in each loaded minute, the VWAP of the minute
+// traded on the side of its net taker volume against the depth record of the same minute, and against the one
+// of the horizon later for the realized spread and the price impact. The depth records are the ones of the depth
+// loader, GetDepthAt, so that a bad day or a minute out of its time range has NaN spreads.
+// It panics if the horizon is not a positive number of minutes.
+func (t *CCTradeLoader) TradeSpreads(pair Pair, horizon time.Duration) []TradeSpread {
+	if horizon <= 0 || horizon%time.Minute != 0 {
+		panic(fmt.Sprintf("the horizon must be a positive number of minutes, got %s", horizon))
+	}
+	nan := math.NaN()
+	minutes := t.minutes[pair]
+	spreads := make([]TradeSpread, len(minutes))
+	for i, m := range minutes {
+		spread := TradeSpread{Time: t.startDate.Add(time.Duration(i) * time.Minute), VWAP: m.VWAP, Volume: m.Volume,
+			Effective: nan, Realized: nan, Impact: nan}
+		if sold := m.Volume - m.BuyVolume; m.BuyVolume > sold {
+			spread.Side = Buy
+		} else if m.BuyVolume < sold {
+			spread.Side = Sell
+		}
+		if at, err := t.depth.GetDepthAt(pair, spread.Time); err == nil && spread.Side != 0 && m.Count > 0 {
+			spread.Effective = at.EffectiveSpread(m.VWAP, spread.Side)
+			if later, err := t.depth.GetDepthAt(pair, spread.Time.Add(horizon)); err == nil {
+				spread.Realized = RealizedSpread(m.VWAP, spread.Side, at, later)
+				spread.Impact = PriceImpact(spread.Side, at, later)
+			}
+		}
+		spreads[i] = spread
+	}
+	return spreads
+}
@@ -0,0 +1,128 @@
+package depth
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TimeSource is the time of the paced replays, see WithTimeSource, and of the daemon schedules, so that the tests
+// advance it deterministically, and the services drive it from their own schedulers, instead of the wall clock.
+type TimeSource interface {
+	// Now returns the current time of the source.
+	Now() time.Time
+	// After returns a channel receiving the current time once the duration elapsed in the time of the source.
+	After(d time.Duration) <-chan time.Time
+}
+
+// RealTime is the wall clock, the default TimeSource.
+var RealTime TimeSource = realTime{}
+
+type realTime struct{}
+
+func (realTime) Now() time.Time {
+	return time.Now()
+}
+
+func (realTime) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// acceleratedTime runs faster than the wall clock from its start, see NewAcceleratedTime.
+type acceleratedTime struct {
+	start time.Time
+	// origin is the wall clock time of the start
+	origin time.Time
+	speed  float64
+}
+
+// NewAcceleratedTime returns a TimeSource starting at the time, and running the given number of times faster
+// than the wall clock from then on, like 60 to replay an hour of paced minutes in a minute.
+// It panics if the speed is not positive.
+func NewAcceleratedTime(start time.Time, speed float64) TimeSource {
+	if speed <= 0 {
+		panic(fmt.Sprintf("the speed must be positive, got %v", speed))
+	}
+	return &acceleratedTime{start: start, origin: time.Now(), speed: speed}
+}
+
+func (a *acceleratedTime) Now() time.Time {
+	return a.start.Add(time.Duration(float64(time.Since(a.origin)) * a.speed))
+}
+
+func (a *acceleratedTime) After(d time.Duration) <-chan time.Time {
+	c := make(chan time.Time, 1)
+	time.AfterFunc(time.Duration(float64(d)/a.speed), func() { c <- a.Now() })
+	return c
+}
+
+// ManualTime is a TimeSource only moving with Advance and Set, so that a test steps a paced replay deterministically.
+// It is safe for concurrent use.
+type ManualTime struct {
+	mu  sync.Mutex
+	now time.Time
+	// waiters are the channels of After, with their due times
+	waiters []manualWaiter
+}
+
+type manualWaiter struct {
+	due time.Time
+	c   chan time.Time
+}
+
+// NewManualTime returns a ManualTime at the given time.
+func NewManualTime(now time.Time) *ManualTime {
+	return &ManualTime{now: now}
+}
+
+// Now returns the current time of the source.
+func (m *ManualTime) Now() time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.now
+}
+
+// After returns a channel receiving the time once the source is advanced past the duration, right away if it is not positive.
+func (m *ManualTime) After(d time.Duration) <-chan time.Time {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	c := make(chan time.Time, 1)
+	if d <= 0 {
+		c <- m.now
+		return c
+	}
+	m.waiters = append(m.waiters, manualWaiter{due: m.now.Add(d), c: c})
+	return c
+}
+
+// Advance moves the source forward by the duration, see Set.
+func (m *ManualTime) Advance(d time.Duration) {
+	m.Set(m.Now().Add(d))
+}
+
+// Set moves the source to the time, and fires the channels of After due by then, in the order of their due times.
+// A time before the current one only moves the source back.
+func (m *ManualTime) Set(t time.Time) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.now = t
+	sort.SliceStable(m.waiters, func(i, j int) bool { return m.waiters[i].due.Before(m.waiters[j].due) })
+	pending := m.waiters[:0]
+	for _, w := range m.waiters {
+		if w.due.After(t) {
+			pending = append(pending, w)
+			continue
+		}
+		w.c <- t
+	}
+	m.waiters = pending
+}
+
+// Waiters returns the number of channels of After not fired yet, so that a test advances the source
+// once the replay waits for it.
+func (m *ManualTime) Waiters() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.waiters)
+}
@@ -0,0 +1,213 @@
+package depth
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Tombstone marks a day of a pair as bad, like during an exchange outage or a vendor error.
+// The minutes of the bad days are loaded as NaN values, and skipped by the exports and the replays.
+// They are downloaded again only by the loaders with WithRefetchBad.
+type Tombstone struct {
+	Pair   Pair
+	Day    time.Time
+	Reason string
+}
+
+// WithRefetchBad downloads again the bad days of the loaded pairs, see Tombstone, and removes their tombstones.
+// The new records of a pair are appended to the depth data file as a new row, which replaces the previous one.
+// The pairs with missing days are not downloaded again, as their days can't be told apart in the row.
+func WithRefetchBad() Option {
+	return func(l *CCDepthLoader) {
+		l.refetchBad = true
+	}
+}
+
+// tombstonesPath returns the file of the tombstones of the market, kept with its depth data files.
+func (l *CCDepthLoader) tombstonesPath() string {
+	return filepath.Join(l.marketDir(), "tombstones.csv")
+}
+
+// Tombstones returns the bad days of the market, in the order they were marked.
+func (l *CCDepthLoader) Tombstones() ([]Tombstone, error) {
+	file, err := os.Open(l.tombstonesPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	rows, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	tombstones := make([]Tombstone, 0, len(rows))
+	for _, row := range rows {
+		if len(row) != 3 {
+			return nil, fmt.Errorf("%s is corrupted: %v", l.tombstonesPath(), row)
+		}
+		day, err := time.Parse("2006-01-02", row[1])
+		if err != nil {
+			return nil, fmt.Errorf("%s is corrupted: %w", l.tombstonesPath(), err)
+		}
+		tombstones = append(tombstones, Tombstone{Pair: Pair(row[0]), Day: day, Reason: row[2]})
+	}
+	return tombstones, nil
+}
+
+// MarkBad marks the day of the pair as bad for the given reason, replacing its previous tombstone.
+func (l *CCDepthLoader) MarkBad(pair Pair, day time.Time, reason string) error {
+	tombstones, err := l.Tombstones()
+	if err != nil {
+		return err
+	}
+	tombstones = removeTombstone(tombstones, pair, day)
+	return l.writeTombstones(append(tombstones, Tombstone{Pair: pair, Day: day.UTC().Truncate(24 * time.Hour), Reason: reason}))
+}
+
+// Restore removes the tombstone of the day of the pair, if it has one.
+func (l *CCDepthLoader) Restore(pair Pair, day time.Time) error {
+	tombstones, err := l.Tombstones()
+	if err != nil {
+		return err
+	}
+	return l.writeTombstones(removeTombstone(tombstones, pair, day))
+}
+
+func removeTombstone(tombstones []Tombstone, pair Pair, day time.Time) []Tombstone {
+	kept := tombstones[:0]
+	for _, tombstone := range tombstones {
+		if tombstone.Pair != pair || !tombstone.Day.Equal(day.UTC().Truncate(24*time.Hour)) {
+			kept = append(kept, tombstone)
+		}
+	}
+	return kept
+}
+
+func (l *CCDepthLoader) writeTombstones(tombstones []Tombstone) error {
+	if l.readOnly {
+		return ErrReadOnly
+	}
+	path := l.tombstonesPath()
+	if err := l.perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	file, err := l.perms.create(tmp)
+	if err != nil {
+		return err
+	}
+	writer := csv.NewWriter(file)
+	for _, tombstone := range tombstones {
+		_ = writer.Write([]string{string(tombstone.Pair), tombstone.Day.Format("2006-01-02"), tombstone.Reason})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return replaceFile(tmp, path)
+}
+
+// badDays returns the bad days of each pair within the time range.
+func (l *CCDepthLoader) badDays(startDate time.Time, endDate time.Time) map[Pair][]time.Time {
+	tombstones, err := l.Tombstones()
+	if err != nil {
+		panic(err)
+	}
+	days := make(map[Pair][]time.Time)
+	for _, tombstone := range tombstones {
+		if !tombstone.Day.Before(startDate) && tombstone.Day.Before(endDate) {
+			days[tombstone.Pair] = append(days[tombstone.Pair], tombstone.Day)
+		}
+	}
+	return days
+}
+
+// refetchBadDays downloads again the bad days of the loaded pairs with complete records, and splices them into
+// their records. It returns the updated pairs, after removing the tombstones of their days.
+func (l *CCDepthLoader) refetchBadDays(startDate time.Time, endDate time.Time) []Pair {
+	width := l.schema.Width()
+	historyLength := int(endDate.Sub(startDate).Minutes())
+	var updated []Pair
+	for pair, days := range l.badDays(startDate, endDate) {
+		if l.records[pair] == nil {
+			continue
+		}
+		record := l.denseRecords(pair)
+		if len(record) != historyLength*width {
+			_, _ = fmt.Fprintln(l.progress, "Bad days of", pair, "not downloaded again, as it has missing days")
+			continue
+		}
+		refetched := false
+		for _, day := range days {
+			if l.context().Err() != nil {
+				break
+			}
+			_, _ = fmt.Fprintln(l.progress, "Downloading depth again for", pair, day)
+			values := l.schema.project(l.downloadDay(l.context(), pair, day))
+			offset := int(day.Sub(startDate).Minutes()) * width
+			if len(values) != 24*60*width {
+				_, _ = fmt.Fprintln(l.progress, "Bad day of", pair, day, "still has no data")
+				continue
+			}
+			copy(record[offset:], values)
+			if err := l.Restore(pair, day); err != nil {
+				panic(err)
+			}
+			refetched = true
+		}
+		if refetched {
+			l.setRecords(pair, record)
+			updated = append(updated, pair)
+		}
+	}
+	sortPairs(updated)
+	return updated
+}
+
+// minuteRange is a range of minutes of the loaded time range, from inclusive, to exclusive.
+type minuteRange struct {
+	from, to int
+}
+
+// loadBadDays finds the minutes of the bad days of the loaded time range, see Tombstone.
+func (l *CCDepthLoader) loadBadDays() {
+	l.bad = nil
+	if _, err := os.Stat(l.tombstonesPath()); err != nil {
+		return
+	}
+	tombstones, err := l.Tombstones()
+	if err != nil {
+		panic(err)
+	}
+	l.bad = make(map[Pair][]minuteRange)
+	for _, tombstone := range tombstones {
+		if from := int(tombstone.Day.Sub(l.startDate).Minutes()); from >= 0 {
+			l.bad[tombstone.Pair] = append(l.bad[tombstone.Pair], minuteRange{from: from, to: from + 24*60})
+		}
+	}
+}
+
+// isBad checks if the minute of the pair is of a bad day.
+func (l *CCDepthLoader) isBad(pair Pair, minute int) bool {
+	for _, r := range l.bad[pair] {
+		if minute >= r.from && minute < r.to {
+			return true
+		}
+	}
+	return false
+}
+
+// excluded checks if the record is of a bad day, see Tombstone.
+func (r Record) excluded() bool {
+	return math.IsNaN(r.BidPrice) && math.IsNaN(r.AskPrice)
+}
@@ -0,0 +1,299 @@
+package depth
+
+import (
+	"compress/gzip"
+	"encoding/csv"
+	"fmt"
+	"github.com/life4/genesis/slices"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tradesHeader is the first line of the cache files of the trades, with the columns of TradeMinute.
+const tradesHeader = "volume,buy_volume,vwap,count"
+
+// TradeMinute aggregates the trades of a pair during a minute.
+type TradeMinute struct {
+	// Volume is the traded amount in the base currency, and BuyVolume the part of it bought by the takers
+	Volume    float64 `json:"volume"`
+	BuyVolume float64 `json:"buy_volume"`
+	// VWAP is the volume weighted average price of the trades, NaN without trades
+	VWAP  float64 `json:"vwap"`
+	Count int     `json:"count"`
+}
+
+// CCTradeLoader loads the trades of the crypto-chassis trade endpoint, aggregated per minute,
+// with the market, the data directory, the HTTP client and the rate limit of its depth loader, see Trades.
+// Each day of a pair is cached in data/<market>/trades/<pair>/<day>.csv, with a line per minute:
+//
+//	volume,buy_volume,vwap,count
+//	12.5,7.25,16544.21,318
+//	...
+type CCTradeLoader struct {
+	depth *CCDepthLoader
+	// startDate and endDate are the loaded time range, and minutes the loaded minutes of each pair
+	startDate time.Time
+	endDate   time.Time
+	minutes   map[Pair][]TradeMinute
+}
+
+// Trades returns the loader of the trades of the pairs of the loader, iterated with its cursor: GetTrades returns
+// the minute of the records of GetDepth, so that each Tick moves both the depth records and the trades.
+// The trades are always downloaded from crypto-chassis, see WithBaseURL, even with another Provider.
+func (l *CCDepthLoader) Trades() *CCTradeLoader {
+	return &CCTradeLoader{depth: l, minutes: make(map[Pair][]TradeMinute)}
+}
+
+// Load loads the trades of the pairs for the time range, downloading the days missing from the cache,
+// and returns the minutes of each pair. Like the depth loader, it loads a single time range: loading another one
+// panics with an error wrapping ErrRangeMismatch. It panics if the endpoint lists no trades for a day.
+func (t *CCTradeLoader) Load(pairs []Pair, startDate time.Time, endDate time.Time) map[Pair][]TradeMinute {
+	if !t.startDate.IsZero() && (!startDate.Equal(t.startDate) || !endDate.Equal(t.endDate)) {
+		panic(fmt.Errorf("%w: %s - %s, not %s - %s", ErrRangeMismatch,
+			t.startDate.Format("2006-01-02"), t.endDate.Format("2006-01-02"),
+			startDate.Format("2006-01-02"), endDate.Format("2006-01-02")))
+	}
+	t.startDate, t.endDate = startDate, endDate
+	var days []time.Time
+	for date := startDate; date.Before(endDate); date = date.AddDate(0, 0, 1) {
+		days = append(days, date)
+	}
+	result := make(map[Pair][]TradeMinute, len(pairs))
+	for _, pair := range pairs {
+		if minutes, ok := t.minutes[pair]; ok {
+			result[pair] = minutes
+			continue
+		}
+		minutes := loadDays(t.depth.concurrency, days, func(date time.Time) []TradeMinute {
+			return t.loadDay(pair, date)
+		})
+		if length := int(endDate.Sub(startDate).Minutes()); len(minutes) > length {
+			minutes = minutes[:length]
+		}
+		t.minutes[pair], result[pair] = minutes, minutes
+	}
+	return result
+}
+
+// loadDays loads the minutes of the days concurrently, and returns them in the order of the days.
+// It panics in the calling goroutine if a day fails, once the others are loaded.
+func loadDays[T any](concurrency int, days []time.Time, load func(date time.Time) []T) []T {
+	var failure interface{}
+	var once sync.Once
+	byDay := slices.MapAsync(days, concurrency, func(date time.Time) []T {
+		defer func() {
+			if r := recover(); r != nil {
+				once.Do(func() { failure = r })
+			}
+		}()
+		return load(date)
+	})
+	if failure != nil {
+		panic(failure)
+	}
+	var minutes []T
+	for _, day := range byDay {
+		minutes = append(minutes, day...)
+	}
+	return minutes
+}
+
+// GetTrades returns the trades of the pair in the minute of the cursor of the depth loader,
+// no trades out of the loaded time range.
+func (t *CCTradeLoader) GetTrades(pair Pair) TradeMinute {
+	if minutes, minute := t.minutes[pair], t.depth.cursorMinute(t.startDate); minute >= 0 && minute < len(minutes) {
+		return minutes[minute]
+	}
+	return TradeMinute{VWAP: math.NaN()}
+}
+
+// cursorMinute returns the minute of the cursor in the time range starting at the date,
+// the cursor itself if the loader has no time range loaded.
+func (l *CCDepthLoader) cursorMinute(startDate time.Time) int {
+	if l.startDate.IsZero() {
+		return l.index
+	}
+	return int(l.minuteTime(l.index).Sub(startDate).Minutes())
+}
+
+// dayPath returns the path of the cache file of the trades of the day of the pair.
+func (t *CCTradeLoader) dayPath(pair Pair, date time.Time) string {
+	return filepath.Join(pairDir(filepath.Join(t.depth.marketDir(), "trades"), pair), date.Format("2006-01-02")+".csv")
+}
+
+// loadDay reads the minutes of the day of the pair from the cache, downloading them if they are not cached.
+func (t *CCTradeLoader) loadDay(pair Pair, date time.Time) []TradeMinute {
+	path := t.dayPath(pair, date)
+	if content, err := os.ReadFile(path); err == nil {
+		minutes, err := parseTradeMinutes(string(content))
+		if err != nil {
+			panic(fmt.Errorf("%s: %w", path, err))
+		}
+		return minutes
+	} else if !os.IsNotExist(err) {
+		panic(err)
+	}
+	if t.depth.readOnly {
+		panic(fmt.Errorf("%w: %s does not exist", ErrReadOnly, path))
+	}
+	fmt.Fprintln(t.depth.progress, "Downloading trades for", pair, date)
+	minutes, err := t.downloadDay(pair, date)
+	if err != nil {
+		panic(err)
+	}
+	lines := []string{tradesHeader}
+	for _, m := range minutes {
+		lines = append(lines, formatFloat(m.Volume)+","+formatFloat(m.BuyVolume)+","+formatFloat(m.VWAP)+","+strconv.Itoa(m.Count))
+	}
+	if err := writeDayFile(t.depth.perms, path, lines); err != nil {
+		panic(err)
+	}
+	return minutes
+}
+
+// writeDayFile writes the lines of the cache file of a day atomically, so that a concurrent load never reads a partial one.
+func writeDayFile(perms filePerms, path string, lines []string) error {
+	if err := perms.mkdirAll(filepath.Dir(path)); err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := perms.writeFile(tmp, []byte(strings.Join(lines, "\n")+"\n")); err != nil {
+		return err
+	}
+	return replaceFile(tmp, path)
+}
+
+// parseTradeMinutes parses the minutes of a cache file of the trades of a day.
+func parseTradeMinutes(content string) ([]TradeMinute, error) {
+	lines := strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+	if lines[0] != tradesHeader || len(lines) != 24*60+1 {
+		return nil, fmt.Errorf("%w: not a day of trades", ErrCorrupted)
+	}
+	minutes := make([]TradeMinute, 0, 24*60)
+	for _, line := range lines[1:] {
+		columns := strings.Split(line, ",")
+		if len(columns) != 4 {
+			return nil, fmt.Errorf("%w: malformed trades line %q", ErrCorrupted, line)
+		}
+		var values [3]float64
+		for i := range values {
+			v, err := strconv.ParseFloat(columns[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("%w: malformed trades line %q", ErrCorrupted, line)
+			}
+			values[i] = v
+		}
+		count, err := strconv.Atoi(columns[3])
+		if err != nil {
+			return nil, fmt.Errorf("%w: malformed trades line %q", ErrCorrupted, line)
+		}
+		minutes = append(minutes, TradeMinute{Volume: values[0], BuyVolume: values[1], VWAP: values[2], Count: count})
+	}
+	return minutes, nil
+}
+
+// downloadDay downloads the trades of the day of the pair, aggregated per minute.
+// The archive is a gzipped CSV with a trade per line, its columns are found by their names in the header:
+//
+//	time_seconds,time_nanoseconds,price,size,is_buyer_maker,trade_id
+//	1669248000,101000000,16544.2,0.012,0,2226162386
+func (t *CCTradeLoader) downloadDay(pair Pair, date time.Time) ([]TradeMinute, error) {
+	ctx := t.depth.context()
+	url, ok, err := t.depth.lookupArchive(ctx, t.depth.baseURL+"/v1/trade/"+string(t.depth.market)+"/"+pair.String()+
+		"?startTime="+date.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("no trade data URL for %s on %s", pair, date.Format("2006-01-02"))
+	}
+	resp, err := t.depth.get(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	gz, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	defer gz.Close()
+	minutes, err := readTrades(gz, date)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return minutes, nil
+}
+
+// readTrades aggregates the trades of the CSV of a day into its minutes.
+func readTrades(r io.Reader, date time.Time) ([]TradeMinute, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		columns[name] = i
+	}
+	for _, name := range []string{"time_seconds", "price", "size", "is_buyer_maker"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("no %s column in the trades header %q", name, strings.Join(header, ","))
+		}
+	}
+	minutes := make([]TradeMinute, 24*60)
+	notionals := make([]float64, 24*60)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(row) < len(header) {
+			return nil, fmt.Errorf("malformed trades row: %q", strings.Join(row, ","))
+		}
+		seconds, err := strconv.ParseInt(row[columns["time_seconds"]], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed trades row: %q: %w", strings.Join(row, ","), err)
+		}
+		price, err := strconv.ParseFloat(row[columns["price"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed trades row: %q: %w", strings.Join(row, ","), err)
+		}
+		size, err := strconv.ParseFloat(row[columns["size"]], 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed trades row: %q: %w", strings.Join(row, ","), err)
+		}
+		buyerMaker, err := strconv.ParseBool(row[columns["is_buyer_maker"]])
+		if err != nil {
+			return nil, fmt.Errorf("malformed trades row: %q: %w", strings.Join(row, ","), err)
+		}
+		minute := int(time.Unix(seconds, 0).Sub(date) / time.Minute)
+		if minute < 0 || minute >= len(minutes) {
+			continue
+		}
+		m := &minutes[minute]
+		m.Volume += size
+		m.Count++
+		notionals[minute] += price * size
+		// the buyer is the taker when the seller made the order
+		if !buyerMaker {
+			m.BuyVolume += size
+		}
+	}
+	for i := range minutes {
+		minutes[i].VWAP = notionals[i] / minutes[i].Volume
+		if minutes[i].Volume == 0 {
+			minutes[i].VWAP = math.NaN()
+		}
+	}
+	return minutes, nil
+}
@@ -0,0 +1,224 @@
+package depth
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Version is a version of a depth data file. The file is append-only: each load appending new pairs creates
+// a new version, and the rows of the older versions are never rewritten, so that a version is a prefix of the file.
+// The versions are kept in the <file>.versions sidecar, with the number of 1 minute records of each appended pair,
+// so that opening the file checks its consistency without parsing it, see Verify:
+//
+//	<number>,<file size>,<creation time>,<Pair1>,<minutes>,<Pair2>,<minutes>...
+//
+// The pairs are quoted as CSV fields, like in the rows of the file. The sidecars written before had the pairs
+// of a version in a single field, <Pair1>:<minutes>;<Pair2>:<minutes>..., which are still read.
+// A file written before the versions were recorded has them recorded on the next append, its content up to then
+// being the version 1, with unknown pairs.
+type Version struct {
+	Number int
+	// Size is the size of the file in this version.
+	Size    int64
+	Created time.Time
+	// Pairs are the pairs appended in this version, and Minutes the number of their 1 minute records.
+	// A pair with less minutes than the time range has no data for some of its days.
+	Pairs   []Pair
+	Minutes map[Pair]int
+}
+
+// WithVersion pins the loader to a version of the files, so that a long-running experiment keeps reading the same data
+// while a concurrent backfill appends new pairs to them. The pinned loader never writes, like with WithReadOnly,
+// and Load panics if the file of the time range has no such version. See Version.
+// It panics if the version is less than 1.
+func WithVersion(version int) Option {
+	if version < 1 {
+		panic("the version must be positive, got " + strconv.Itoa(version))
+	}
+	return func(l *CCDepthLoader) {
+		l.version = version
+		l.readOnly = true
+	}
+}
+
+// Versions returns the versions of the file of the time range, from the oldest, and nil if the file does not exist.
+// A file written before the versions were recorded has a single version with unknown pairs.
+func (l *CCDepthLoader) Versions(startDate time.Time, endDate time.Time) ([]Version, error) {
+	path := l.cachePath(startDate, endDate)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	versions, err := readVersions(path)
+	if err != nil || len(versions) > 0 {
+		return versions, err
+	}
+	return []Version{{Number: 1, Size: info.Size(), Created: info.ModTime().UTC()}}, nil
+}
+
+// Verify checks the consistency of the file of the time range with its last recorded version, without parsing it:
+// the file must have the size of the version, and the pairs no more minutes than the time range.
+// A file larger than its last version is being appended to, or was written without recording the version.
+// Load panics only if the file is smaller than its last version, as it was truncated, see WithSelfCheck.
+// It returns nil if the file does not exist, or was written before the versions were recorded.
+func (l *CCDepthLoader) Verify(startDate time.Time, endDate time.Time) error {
+	path := l.cachePath(startDate, endDate)
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	versions, err := readVersions(path)
+	if err != nil || len(versions) == 0 {
+		return err
+	}
+	last := versions[len(versions)-1]
+	if info.Size() != last.Size {
+		return fmt.Errorf("%s has %d bytes, its version %d has %d", path, info.Size(), last.Number, last.Size)
+	}
+	historyLength := int(endDate.Sub(startDate).Minutes())
+	for _, version := range versions {
+		for pair, minutes := range version.Minutes {
+			if minutes > historyLength {
+				return fmt.Errorf("%s has %d minutes of %s in its version %d, more than the %d of the time range",
+					path, minutes, pair, version.Number, historyLength)
+			}
+		}
+	}
+	return nil
+}
+
+func versionsPath(path string) string {
+	return path + ".versions"
+}
+
+// versionSize returns the size of the open file in the pinned version, or its current size if none is pinned.
+func (l *CCDepthLoader) versionSize(file *os.File, path string) int64 {
+	info, err := file.Stat()
+	if err != nil {
+		panic(err)
+	}
+	if l.version == 0 {
+		return info.Size()
+	}
+	versions, err := readVersions(path)
+	if err != nil {
+		panic(err)
+	}
+	if len(versions) == 0 {
+		versions = []Version{{Number: 1, Size: info.Size()}}
+	}
+	for _, version := range versions {
+		if version.Number == l.version {
+			return version.Size
+		}
+	}
+	panic(fmt.Errorf("%w: %s has no version %d", ErrReadOnly, path, l.version))
+}
+
+func readVersions(path string) ([]Version, error) {
+	content, err := os.ReadFile(versionsPath(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var versions []Version
+	for _, line := range strings.Split(strings.TrimSpace(string(content)), "\n") {
+		fields, err := splitCSV(line)
+		if err != nil || len(fields) < 3 {
+			return nil, fmt.Errorf("%s is corrupted: %q", versionsPath(path), line)
+		}
+		var version Version
+		if version.Number, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, err
+		}
+		if version.Size, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+			return nil, err
+		}
+		if version.Created, err = time.Parse(time.RFC3339, fields[2]); err != nil {
+			return nil, err
+		}
+		entries := fields[3:]
+		if len(entries) == 1 {
+			if entries, err = legacyVersionEntries(entries[0]); err != nil {
+				return nil, fmt.Errorf("%s is corrupted: %q", versionsPath(path), line)
+			}
+		}
+		if len(entries)%2 != 0 {
+			return nil, fmt.Errorf("%s is corrupted: %q", versionsPath(path), line)
+		}
+		for i := 0; i < len(entries); i += 2 {
+			if version.Minutes == nil {
+				version.Minutes = make(map[Pair]int)
+			}
+			pair := Pair(entries[i])
+			if version.Minutes[pair], err = strconv.Atoi(entries[i+1]); err != nil {
+				return nil, fmt.Errorf("%s is corrupted: %q", versionsPath(path), line)
+			}
+			version.Pairs = append(version.Pairs, pair)
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// recordVersion appends the version of the file with the given appended pairs to its sidecar.
+// The previous size is the one of the file before the append, recorded as the version 1 if the file had no versions.
+func recordVersion(perms filePerms, path string, previousSize int64, size int64, pairs []Pair, minutes map[Pair]int) error {
+	versions, err := readVersions(path)
+	if err != nil {
+		return err
+	}
+	var lines []string
+	if len(versions) == 0 && previousSize > 0 {
+		versions = append(versions, Version{Number: 1, Size: previousSize})
+		lines = append(lines, formatVersion(Version{Number: 1, Size: previousSize, Created: time.Now()}))
+	}
+	lines = append(lines, formatVersion(Version{Number: len(versions) + 1, Size: size, Created: time.Now(), Pairs: pairs, Minutes: minutes}))
+
+	file, err := perms.openAppend(versionsPath(path))
+	if err != nil {
+		return err
+	}
+	if _, err = file.WriteString(strings.Join(lines, "\n") + "\n"); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// legacyVersionEntries returns the pairs and minutes of the single field of the pairs of a version written before
+// they were quoted, <Pair1>:<minutes>;<Pair2>:<minutes>..., alternated as in the fields of the current format.
+func legacyVersionEntries(field string) ([]string, error) {
+	if field == "" {
+		return nil, nil
+	}
+	var entries []string
+	for _, entry := range strings.Split(field, ";") {
+		// the minutes follow the last colon, the pair name may have one
+		colon := strings.LastIndex(entry, ":")
+		if colon < 0 {
+			return nil, fmt.Errorf("no minutes in %q", entry)
+		}
+		entries = append(entries, entry[:colon], entry[colon+1:])
+	}
+	return entries, nil
+}
+
+func formatVersion(version Version) string {
+	fields := []string{strconv.Itoa(version.Number), strconv.FormatInt(version.Size, 10), version.Created.UTC().Format(time.RFC3339)}
+	for _, pair := range version.Pairs {
+		fields = append(fields, pair.String(), strconv.Itoa(version.Minutes[pair]))
+	}
+	return joinCSV(fields...)
+}
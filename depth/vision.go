@@ -0,0 +1,244 @@
+package depth
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BinanceVisionProvider is the Provider of the daily best bid and ask dumps archived on the Binance public data website:
+// https://data.binance.vision/data/futures/um/daily/bookTicker/BTCUSDT/BTCUSDT-bookTicker-2023-01-01.zip
+// The archive is a zipped CSV of every update of the touch, with the following columns:
+//
+//	update_id,best_bid_price,best_bid_qty,best_ask_price,best_ask_qty,transaction_time,event_time
+//
+// The updates are sampled at the start of each minute, like the crypto-chassis archives: the record of a minute
+// is the last update at or before it, and the minutes before the first update of the day have the first one.
+// The dumps exist for the USDⓈ-M and COIN-M futures markets only, and not for every pair and day,
+// the missing days have no data. The archives are checked against their published checksums, when there is one.
+type BinanceVisionProvider struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewBinanceVisionProvider returns the Provider of the Binance public data website, see WithProvider.
+func NewBinanceVisionProvider(opts ...VisionOption) *BinanceVisionProvider {
+	p := &BinanceVisionProvider{baseURL: "https://data.binance.vision", client: http.DefaultClient}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// VisionOption configures the BinanceVisionProvider.
+type VisionOption func(p *BinanceVisionProvider)
+
+// WithVisionBaseURL sets the URL of the Binance public data website, like a mirror or a test server,
+// https://data.binance.vision by default.
+func WithVisionBaseURL(baseURL string) VisionOption {
+	return func(p *BinanceVisionProvider) {
+		p.baseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithVisionHTTPClient sets the client of the downloads, http.DefaultClient by default.
+// It panics if the client is nil.
+func WithVisionHTTPClient(client *http.Client) VisionOption {
+	if client == nil {
+		panic("the HTTP client must not be nil")
+	}
+	return func(p *BinanceVisionProvider) {
+		p.client = client
+	}
+}
+
+func (p *BinanceVisionProvider) FetchDay(ctx context.Context, market Market, pair Pair, date time.Time) ([]Record, error) {
+	symbol := strings.ToUpper(pair.Base() + pair.Quote())
+	var marketType string
+	switch market {
+	case MarketBinanceUsdsFutures:
+		marketType = "um"
+	case MarketBinanceCoinFutures:
+		marketType = "cm"
+		symbol += "_PERP"
+	default:
+		return nil, fmt.Errorf("the Binance Vision dumps are not supported for market %s", market)
+	}
+	name := symbol + "-bookTicker-" + date.Format("2006-01-02")
+	url := p.baseURL + "/data/futures/" + marketType + "/daily/bookTicker/" + symbol + "/" + name + ".zip"
+
+	// the dumps of the liquid pairs are hundreds of megabytes, so they are buffered on disk, not in memory
+	file, err := os.CreateTemp("", name+"-*.zip")
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+	hash := sha256.New()
+	size, found, err := p.download(ctx, url, io.MultiWriter(file, hash))
+	if err != nil || !found {
+		return nil, err
+	}
+	checksum, found, err := p.checksum(ctx, url+".CHECKSUM")
+	if err != nil {
+		return nil, err
+	}
+	if found && checksum != hex.EncodeToString(hash.Sum(nil)) {
+		return nil, fmt.Errorf("%s: %w: its checksum does not match", url, ErrCorrupted)
+	}
+	archive, err := zip.NewReader(file, size)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	sampler := newMinuteSampler(date)
+	for _, f := range archive.File {
+		if err := sampler.read(f); err != nil {
+			return nil, fmt.Errorf("%s: %w", url, err)
+		}
+	}
+	return sampler.records(), nil
+}
+
+// download writes the body of the URL to the writer, and returns its size, and false if there is no such file.
+func (p *BinanceVisionProvider) download(ctx context.Context, url string, w io.Writer) (int64, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, false, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, false, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return 0, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, false, fmt.Errorf("%s: %s: %s", url, resp.Status, string(body))
+	}
+	size, err := io.Copy(w, resp.Body)
+	if err != nil {
+		return 0, false, err
+	}
+	return size, true, nil
+}
+
+// checksum returns the sha256 of a checksum file, like "<sha256>  BTCUSDT-bookTicker-2023-01-01.zip",
+// and false if there is no such file.
+func (p *BinanceVisionProvider) checksum(ctx context.Context, url string) (string, bool, error) {
+	var b strings.Builder
+	_, found, err := p.download(ctx, url, &b)
+	if err != nil || !found {
+		return "", false, err
+	}
+	fields := strings.Fields(b.String())
+	if len(fields) == 0 {
+		return "", false, fmt.Errorf("%s: the checksum file is empty", url)
+	}
+	return strings.ToLower(fields[0]), true, nil
+}
+
+// minuteSampler keeps the last update of the touch at or before the start of each minute of a day,
+// whatever the order of the updates.
+type minuteSampler struct {
+	start time.Time
+	// last is the last update at or before the start of each minute, after the start of the previous one
+	last [24 * 60]Record
+	// lastTimes are the transaction times of last, in milliseconds since the start of the day
+	lastTimes [24 * 60]int64
+	// sampled tells the minutes with an update
+	sampled   [24 * 60]bool
+	first     Record
+	firstTime int64
+	updates   int
+}
+
+func newMinuteSampler(date time.Time) *minuteSampler {
+	return &minuteSampler{start: date}
+}
+
+// read samples the updates of a CSV file of the archive.
+func (s *minuteSampler) read(f *zip.File) error {
+	file, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if row[0] == "update_id" {
+			continue
+		}
+		if len(row) < 7 {
+			return fmt.Errorf("expected 7 columns, got %d: %v", len(row), row)
+		}
+		var values [4]float64
+		for i := range values {
+			if values[i], err = strconv.ParseFloat(row[i+1], 64); err != nil {
+				return fmt.Errorf("malformed bookTicker row: %q: %w", strings.Join(row, ","), err)
+			}
+		}
+		ms, err := strconv.ParseInt(row[5], 10, 64)
+		if err != nil {
+			return fmt.Errorf("malformed bookTicker row: %q: %w", strings.Join(row, ","), err)
+		}
+		s.add(ms, Record{BidPrice: values[0], BidSize: values[1], AskPrice: values[2], AskSize: values[3]})
+	}
+}
+
+// add samples the update of the transaction time, in Unix milliseconds.
+func (s *minuteSampler) add(ms int64, record Record) {
+	offset := ms - s.start.UnixMilli()
+	if s.updates == 0 || offset < s.firstTime {
+		s.first, s.firstTime = record, offset
+	}
+	s.updates++
+	// the update is sampled at the start of the first minute not before it
+	var minute int64
+	if offset > 0 {
+		minute = (offset + 59999) / 60000
+	}
+	if minute >= int64(len(s.last)) {
+		return
+	}
+	if !s.sampled[minute] || offset >= s.lastTimes[minute] {
+		s.last[minute], s.lastTimes[minute], s.sampled[minute] = record, offset, true
+	}
+}
+
+// records returns the sampled record of each minute of the day, and none if there were no updates.
+func (s *minuteSampler) records() []Record {
+	if s.updates == 0 {
+		return nil
+	}
+	records := make([]Record, len(s.last))
+	previous := s.first
+	for m := range records {
+		if s.sampled[m] {
+			previous = s.last[m]
+		}
+		records[m] = previous
+	}
+	return records
+}
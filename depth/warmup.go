@@ -0,0 +1,66 @@
+package depth
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithWarmup starts the cursor past the first d of the loaded time range, rounded down to the minute, for the
+// indicators needing some history before a backtest trades, like a rolling volatility. The warmup minutes are
+// still fed to the consumers, see WithConsumer, and the lookback analytics like RollingVol still read them.
+// It panics if d is negative.
+func WithWarmup(d time.Duration) Option {
+	if d < 0 {
+		panic(fmt.Sprintf("the warmup must not be negative, got %s", d))
+	}
+	return func(l *CCDepthLoader) {
+		l.warmup = int(d / time.Minute)
+	}
+}
+
+// WithConsumer feeds the record of each loaded pair to the consumer at each minute the cursor enters:
+// the minutes up to the cursor once loaded, the first one, or those of the warmup, see WithWarmup,
+// then the minute of each Tick, or of each NextChange, skipping the unchanged minutes. The consumers, like rolling statistics,
+// are fed in the order they are configured, and the pairs in alphabetical order.
+func WithConsumer(consume func(pair Pair, minute int, record Record)) Option {
+	return func(l *CCDepthLoader) {
+		l.consumers = append(l.consumers, consume)
+	}
+}
+
+// warmUp moves the cursor past the warmup once the time range is loaded, and feeds the consumers
+// with the minutes up to the cursor of the pairs not fed yet, like those loaded after the first load.
+func (l *CCDepthLoader) warmUp() {
+	if l.fed == nil {
+		l.fed = make(map[Pair]bool)
+		l.index = l.warmup
+	}
+	for _, pair := range l.loadedPairs() {
+		if l.fed[pair] {
+			continue
+		}
+		l.fed[pair] = true
+		for minute := 0; minute <= l.index && minute < l.length(pair); minute++ {
+			record := l.recordAt(pair, minute)
+			for _, consume := range l.consumers {
+				consume(pair, minute, record)
+			}
+		}
+	}
+}
+
+// consume feeds the consumers with the records of the loaded pairs at the minute.
+func (l *CCDepthLoader) consume(minute int) {
+	if len(l.consumers) == 0 {
+		return
+	}
+	for _, pair := range l.loadedPairs() {
+		if minute >= l.length(pair) {
+			continue
+		}
+		record := l.recordAt(pair, minute)
+		for _, consume := range l.consumers {
+			consume(pair, minute, record)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetDepths(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		if pair == "ETH-BUSD" {
+			return Quote{10, 1, float64(11 + minute), 1}
+		}
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	loader.Tick()
+
+	records := loader.GetDepths()
+	assert.Len(t, records, 2)
+	assert.Equal(t, float64(102), records["BTC-BUSD"].AskPrice)
+	assert.Equal(t, float64(12), records["ETH-BUSD"].AskPrice)
+	assert.Equal(t, records["BTC-BUSD"].Time, records["ETH-BUSD"].Time)
+	records = loader.GetDepthsFor([]depth.Pair{"ETH-BUSD"})
+	assert.Len(t, records, 1)
+	assert.Equal(t, loader.GetDepth("ETH-BUSD"), records["ETH-BUSD"])
+
+	// a pair without a record at the minute fails the call, like a pair without data on its last day
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, pair != "ETH-BUSD" || day.Day() == 1
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/depths-test") })
+	short := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("depths-test"), depth.WithDayFiles(),
+		depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	short.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, ParseOrDie("01-03-2020"))
+	assert.NoError(t, short.Seek(end))
+	assert.Len(t, short.GetDepthsFor([]depth.Pair{"BTC-BUSD"}), 1)
+	defer func() {
+		err, _ := recover().(error)
+		assert.True(t, errors.Is(err, depth.ErrOutOfRange), err)
+	}()
+	short.GetDepths()
+	t.Fatal("the records are of different minutes")
+}
@@ -0,0 +1,79 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestEndOfData(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	assert.True(t, loader.Done())
+	assert.Equal(t, 0, loader.Len())
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, 24*60, loader.Len())
+	assert.Equal(t, 24*60-1, loader.Remaining())
+
+	// the loop ends with the last minute
+	minutes := 1
+	for loader.Tick() {
+		minutes++
+	}
+	assert.Equal(t, 24*60, minutes)
+	assert.True(t, loader.Done())
+	assert.False(t, loader.HasNext())
+	assert.Equal(t, 0, loader.Remaining())
+	assert.PanicsWithError(t, "the time is out of the loaded time range: BTC-BUSD has no record at 2020-01-02T00:00:00Z", func() {
+		loader.RollingVol("BTC-BUSD", 30)
+	})
+	defer func() {
+		err, _ := recover().(error)
+		assert.True(t, errors.Is(err, depth.ErrOutOfRange), err)
+	}()
+	loader.GetDepth("BTC-BUSD")
+	t.Fatal("the record past the end was returned")
+}
+
+func TestCursorEnd(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	cursor := loader.NewCursor()
+	for cursor.HasNext() {
+		assert.True(t, cursor.Tick())
+	}
+	assert.Equal(t, 0, cursor.Remaining())
+	assert.Equal(t, float64(101+24*60-1), cursor.GetDepth("BTC-BUSD").AskPrice)
+	assert.False(t, cursor.Tick())
+	assert.True(t, cursor.Done())
+	assert.Equal(t, 24*60-1, loader.Remaining())
+}
+
+func TestTickAllocations(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	cursor := loader.NewCursor()
+	// the loaded length is kept once loaded, the pairs are not listed by each Tick
+	assert.Zero(t, testing.AllocsPerRun(100, func() {
+		loader.Tick()
+		cursor.Tick()
+		loader.HasNext()
+		cursor.Remaining()
+	}))
+	assert.NoError(t, loader.Close())
+	assert.Equal(t, 0, loader.Len())
+	assert.True(t, loader.Done())
+}
@@ -0,0 +1,4 @@
+// Package duckdepth writes the loaded depth data into a DuckDB database, so that it can be
+// analysed with SQL (joins, window functions) on the same box as the Go replay.
+// It requires cgo, as the DuckDB driver links the DuckDB library; without cgo the package is empty.
+package duckdepth
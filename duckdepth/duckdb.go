@@ -0,0 +1,104 @@
+//go:build cgo
+
+package duckdepth
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/marcboeker/go-duckdb"
+)
+
+// Table is the name of the table holding the depth records.
+const Table = "depth"
+
+const createTable = `CREATE TABLE IF NOT EXISTS ` + Table + ` (
+	time TIMESTAMPTZ NOT NULL,
+	pair VARCHAR NOT NULL,
+	bid_price DOUBLE NOT NULL,
+	bid_size DOUBLE NOT NULL,
+	ask_price DOUBLE NOT NULL,
+	ask_size DOUBLE NOT NULL
+)`
+
+// WriteDuckDB appends the loaded records of the given pairs to the depth table of the DuckDB
+// database at path, creating the database and the table if needed.
+func WriteDuckDB(path string, loader depth.ColumnSource, pairs []depth.Pair) error {
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return Write(db, loader, pairs)
+}
+
+// Write appends the loaded records of the given pairs to the depth table of an open DuckDB database,
+// creating the table if needed.
+func Write(db *sql.DB, loader depth.ColumnSource, pairs []depth.Pair) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, createTable); err != nil {
+		return err
+	}
+	return conn.Raw(func(driverConn any) error {
+		appender, err := duckdb.NewAppenderFromConn(driverConn.(driver.Conn), "", Table)
+		if err != nil {
+			return err
+		}
+		for _, pair := range pairs {
+			columns := loader.Columns(pair)
+			for i, t := range columns.Time {
+				err := appender.AppendRow(t, pair.String(),
+					columns.BidPrice[i], columns.BidSize[i], columns.AskPrice[i], columns.AskSize[i])
+				if err != nil {
+					_ = appender.Close()
+					return err
+				}
+			}
+		}
+		return appender.Close()
+	})
+}
+
+// Query runs the SQL query against the DuckDB database at path and returns the result rows
+// as column name to value maps. It is meant for quick on-box analysis; use database/sql with
+// the "duckdb" driver directly for anything larger.
+func Query(path string, query string, args ...any) ([]map[string]any, error) {
+	db, err := sql.Open("duckdb", path)
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close()
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	var result []map[string]any
+	for rows.Next() {
+		values := make([]any, len(names))
+		pointers := make([]any, len(names))
+		for i := range values {
+			pointers[i] = &values[i]
+		}
+		if err := rows.Scan(pointers...); err != nil {
+			return nil, err
+		}
+		row := make(map[string]any, len(names))
+		for i, name := range names {
+			row[name] = values[i]
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
@@ -0,0 +1,37 @@
+//go:build cgo
+
+package duckdepth_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/bogdantimes/order-book-depth-loader/duckdepth"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteDuckDB(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	path := filepath.Join(t.TempDir(), "depth.duckdb")
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD"}
+	assert.NoError(t, duckdepth.WriteDuckDB(path, loader, pairs))
+
+	rows, err := duckdepth.Query(path, `SELECT pair, count(*) AS n, max(bid_price) AS bid
+		FROM depth GROUP BY pair ORDER BY pair`)
+	assert.NoError(t, err)
+	assert.Equal(t, []map[string]any{
+		{"pair": "BTC-BUSD", "n": int64(2), "bid": 102.0},
+		{"pair": "ETH-BUSD", "n": int64(2), "bid": 12.0},
+	}, rows)
+
+	// writing again appends
+	assert.NoError(t, duckdepth.WriteDuckDB(path, loader, pairs[:1]))
+	rows, err = duckdepth.Query(path, `SELECT count(*) AS n FROM depth WHERE pair = ?`, "BTC-BUSD")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(4), rows[0]["n"])
+}
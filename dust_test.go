@@ -0,0 +1,32 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDust(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"symbols":[
+			{"symbol":"BTCBUSD","filters":[{"filterType":"PRICE_FILTER","minPrice":"0.01"},{"filterType":"NOTIONAL","minNotional":"10.00000000"}]},
+			{"symbol":"ETHBUSD","filters":[{"filterType":"MIN_NOTIONAL","notional":"5"}]},
+			{"symbol":"BNBBUSD","filters":[]}]}`))
+	}))
+	defer server.Close()
+
+	minNotionals, err := depth.LoadMinNotionals(depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD", "BNB-BUSD", "SOL-BUSD"}, server.URL)
+	assert.NoError(t, err)
+	assert.Equal(t, map[depth.Pair]float64{"BTC-BUSD": 10, "ETH-BUSD": 5}, minNotionals)
+	_, err = depth.LoadMinNotionals(depth.MarketBinanceCoinFutures, nil, "")
+	assert.Error(t, err)
+
+	// the bid of the second minute is 5 BUSD
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,100,1,101,1,100,0.05,101,1\n"), ParseOrDie("01-01-2020"))
+	assert.Equal(t, []int{1}, loader.DustMinutes("BTC-BUSD", minNotionals["BTC-BUSD"]))
+	assert.False(t, loader.GetDepth("BTC-BUSD").Dust(10))
+}
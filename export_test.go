@@ -0,0 +1,80 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestExportFilter(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		// the spread is 20 bps every 100 minutes, and 1 bps otherwise
+		if minute%100 == 0 {
+			return Quote{99.9, 1, 100.1, 2}
+		}
+		return Quote{99.995, 1, 100.005, 2}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	var all bytes.Buffer
+	assert.NoError(t, loader.Export(&all, nil))
+	lines := strings.Split(strings.TrimSpace(all.String()), "\n")
+	assert.Len(t, lines, 24*60+1)
+	assert.Equal(t, "time,pair,bid_price,bid_size,ask_price,ask_size", lines[0])
+	assert.Equal(t, "BTC-BUSD,99.9,1,100.1,2", lines[1][strings.Index(lines[1], ",")+1:])
+
+	filter, err := depth.ParseFilter("spread_bps > 10 && imbalance < 0")
+	assert.NoError(t, err)
+	var filtered bytes.Buffer
+	assert.NoError(t, loader.Export(&filtered, []depth.Pair{"BTC-BUSD"}, depth.WithFilter(filter)))
+	assert.Len(t, strings.Split(strings.TrimSpace(filtered.String()), "\n"), 15+1)
+
+	filter, err = depth.ParseFilter("spread_bps>10 && imbalance>0 || mid == 100 && spread < 0.1")
+	assert.NoError(t, err)
+	filtered.Reset()
+	assert.NoError(t, loader.Export(&filtered, nil, depth.WithFilter(filter)))
+	assert.Len(t, strings.Split(strings.TrimSpace(filtered.String()), "\n"), 24*60-15+1)
+
+	_, err = depth.ParseFilter("volume > 1")
+	assert.Error(t, err)
+	_, err = depth.ParseFilter("spread_bps 1")
+	assert.Error(t, err)
+	_, err = depth.ParseFilter("spread_bps > x")
+	assert.Error(t, err)
+}
+
+func TestExportSpreadOnly(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,80,1,80.5,2,100,1,100,1\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, nil, depth.WithSpreadOnly(), depth.WithFilter(func(r depth.Record) bool {
+		return r.AskPrice > r.BidPrice
+	})))
+	assert.Equal(t, "time,pair,spread_bps\n2020-01-01T00:00:00Z,BTC-BUSD,62.5\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, loader.Export(&out, nil, depth.WithSpreadOnly(), depth.WithFormat(depth.FormatJSONL)))
+	assert.Equal(t, `{"time":"2020-01-01T00:00:00Z","pair":"BTC-BUSD","spread_bps":62.5}`+"\n"+
+		`{"time":"2020-01-01T00:01:00Z","pair":"BTC-BUSD","spread_bps":0}`+"\n", out.String())
+}
+
+func TestExportLocale(t *testing.T) {
+	// the values may be in scientific notation, with trailing spaces
+	input := "#,BTC-BUSD\nBTC-BUSD,80.5 ,1e-3,8.1E1, 2\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.Equal(t, 0.001, loader.GetDepth("BTC-BUSD").BidSize)
+
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, nil, depth.WithDelimiter(';'), depth.WithDecimalSeparator(',')))
+	assert.Equal(t, "time;pair;bid_price;bid_size;ask_price;ask_size\n2020-01-01T00:00:00Z;BTC-BUSD;80,5;0,001;81;2\n", out.String())
+
+	assert.Error(t, loader.Export(&out, nil, depth.WithDecimalSeparator(',')))
+	assert.Error(t, loader.Export(&out, nil, depth.WithFormat(depth.FormatJSONL), depth.WithDelimiter(';')))
+}
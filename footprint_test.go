@@ -0,0 +1,26 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestMemoryFootprint(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithSeries("mid", depth.Record.Mid))
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	footprints := loader.Footprints()
+	assert.Len(t, footprints, 2)
+	btc := footprints["BTC-BUSD"]
+	assert.Equal(t, 2, btc.Minutes)
+	assert.Equal(t, 8, btc.Values)
+	assert.Equal(t, 2, btc.SeriesValues)
+	// the slice headers, the 8 parsed values, and the 2 series values
+	assert.Equal(t, int64(24+8*8+24+2*8), btc.Bytes)
+	assert.Equal(t, float64(btc.Bytes)/2, btc.BytesPerMinute())
+	assert.Equal(t, btc.Bytes+footprints["ETH-BUSD"].Bytes, loader.MemoryFootprint())
+	assert.Equal(t, 0.0, depth.Footprint{}.BytesPerMinute())
+}
@@ -0,0 +1,37 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestFundingWindows(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	assert.Len(t, depth.FundingTimes(start, end), 3)
+	assert.Len(t, depth.FundingTimes(start.Add(time.Minute), end), 2)
+
+	WriteFixture(t, depth.MarketBinanceUsdsFutures, []depth.Pair{"BTC-USDT"}, start, end, func(pair depth.Pair, minute int) Quote {
+		// the spread widens during the 10 minutes after each funding timestamp
+		if minute%480 < 10 {
+			return Quote{99, 1, 101, 3}
+		}
+		return Quote{99.5, 1, 100.5, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinanceUsdsFutures)
+	loader.Load([]depth.Pair{"BTC-USDT"}, start, end)
+
+	windows := loader.FundingWindows("BTC-USDT", 10*time.Minute, 10*time.Minute)
+	// the first funding timestamp has no data before it
+	assert.Len(t, windows, 2)
+	for _, w := range windows {
+		assert.Equal(t, 0, w.Time.Hour()%8)
+		assert.Equal(t, 10, w.Before.Minutes)
+		assert.InDelta(t, 1.0/99.5, w.Before.MeanSpread, 1e-12)
+		assert.InDelta(t, 2.0/99, w.After.MeanSpread, 1e-12)
+		assert.InDelta(t, -0.5, w.After.MeanImbalance, 1e-12)
+		assert.InDelta(t, 0, w.After.MidReturn, 1e-12)
+	}
+}
@@ -0,0 +1,47 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestDownloadHook(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, float64(101 + minute), 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/hook-test") })
+	var hooked []time.Time
+	// the ask of the first minute of each day is a known bad print
+	fix := depth.WithDownloadHook(func(pair depth.Pair, date time.Time, records []depth.Record) error {
+		hooked = append(hooked, date)
+		records[0].AskPrice = records[1].AskPrice
+		return nil
+	})
+	newLoader := func(opts ...depth.Option) *depth.CCDepthLoader {
+		opts = append(opts, depth.WithNamespace("hook-test"), depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithConcurrency(1))
+		return depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	}
+
+	loader := newLoader(fix)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, hooked, 2)
+	assert.Equal(t, 102.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	// the stored days keep the corrections, and are not hooked again
+	loader = newLoader(fix, depth.WithReadOnly())
+	loader.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, hooked, 2)
+	assert.Equal(t, 102.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	failure := errors.New("unknown symbol")
+	assert.PanicsWithError(t, "failed days: ETH-USDT on 2020-01-01: download hook of ETH-USDT on 2020-01-01: unknown symbol", func() {
+		newLoader(depth.WithDownloadHook(func(pair depth.Pair, date time.Time, records []depth.Record) error {
+			return failure
+		})).Load([]depth.Pair{"ETH-USDT"}, ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"))
+	})
+}
@@ -0,0 +1,54 @@
+package order_book_depth_loader_test
+
+import (
+	"encoding/binary"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportKdb(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	dir := t.TempDir()
+	assert.NoError(t, loader.ExportKdb(dir, nil))
+
+	sym, err := os.ReadFile(filepath.Join(dir, "sym"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x01, 11, 0, 2, 0, 0, 0}, sym[:8])
+	assert.Equal(t, "BTC-BUSD\x00ETH-BUSD\x00", string(sym[8:]))
+
+	columns, err := os.ReadFile(filepath.Join(dir, "depth", ".d"))
+	assert.NoError(t, err)
+	assert.Equal(t, "time\x00pair\x00bid_price\x00bid_size\x00ask_price\x00ask_size\x00", string(columns[8:]))
+
+	bidPrice, err := os.ReadFile(filepath.Join(dir, "depth", "bid_price"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{0xff, 0x01, 9, 0, 4, 0, 0, 0}, bidPrice[:8])
+	assert.Len(t, bidPrice, 8+4*8)
+	assert.Equal(t, 12.0, math.Float64frombits(binary.LittleEndian.Uint64(bidPrice[8+3*8:])))
+
+	times, err := os.ReadFile(filepath.Join(dir, "depth", "time"))
+	assert.NoError(t, err)
+	assert.Equal(t, byte(12), times[2])
+	// 2020.01.01D00:01 is 7305 days and 1 minute after the kdb+ epoch
+	assert.Equal(t, int64(7305*24*3600+60)*1e9, int64(binary.LittleEndian.Uint64(times[8+8:])))
+
+	// the reference layout of `:pair set `sym$`BTC-BUSD`BTC-BUSD`ETH-BUSD`ETH-BUSD
+	pair, err := os.ReadFile(filepath.Join(dir, "depth", "pair"))
+	assert.NoError(t, err)
+	assert.Equal(t, []byte{
+		0xff, 0x01, 20, 0, 's', 'y', 'm', 0, 0, 0, 0, 0, 0, 0, 0, 0,
+		4, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+		1, 0, 0, 0, 0, 0, 0, 0,
+	}, pair)
+}
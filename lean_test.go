@@ -0,0 +1,58 @@
+package order_book_depth_loader_test
+
+import (
+	"archive/zip"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportLean(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 2}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	dir := t.TempDir()
+	assert.NoError(t, loader.ExportLean(dir, nil))
+
+	for _, date := range []string{"20200101", "20200102"} {
+		archive, err := zip.OpenReader(filepath.Join(dir, "crypto", "binance", "minute", "btcbusd", date+"_quote.zip"))
+		assert.NoError(t, err)
+		assert.Len(t, archive.File, 1)
+		assert.Equal(t, date+"_btcbusd_minute_quote.csv", archive.File[0].Name)
+		f, err := archive.File[0].Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(f)
+		assert.NoError(t, err)
+		rows := strings.Split(strings.TrimSpace(string(content)), "\n")
+		assert.Len(t, rows, 24*60)
+		assert.Equal(t, "0,100,100,100,100,1,101,101,101,101,2", rows[0])
+		assert.Equal(t, "60000,100,100,100,100,1,101,101,101,101,2", rows[1])
+		_ = archive.Close()
+	}
+}
+
+func TestExportLeanMarkets(t *testing.T) {
+	input := "#,BTC-USDT\nBTC-USDT,100,1,101,2\n"
+	dir := t.TempDir()
+	for market, folder := range map[depth.Market]string{
+		depth.MarketBinanceUs:          "crypto/binanceus",
+		depth.MarketBinanceUsdsFutures: "cryptofuture/binance",
+		depth.MarketBinanceCoinFutures: "cryptofuture/binance",
+	} {
+		loader := depth.NewCCDepthLoader(market)
+		loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+		assert.NoError(t, loader.ExportLean(dir, nil))
+		assert.FileExists(t, filepath.Join(dir, folder, "minute", "btcusdt", "20200101_quote.zip"))
+	}
+
+	loader := depth.NewCCDepthLoader(depth.MarketDeribit)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.Error(t, loader.ExportLean(dir, nil))
+}
@@ -0,0 +1,83 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDepthLevels(t *testing.T) {
+	// the snapshots have 3 levels, but the first minute, with 2
+	var depths []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		if parts[0] != "csv" {
+			depths = append(depths, r.URL.Query().Get("depth"))
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}]}`, server.URL+"/csv/"+r.URL.Query().Get("startTime"))
+			return
+		}
+		day, _ := time.Parse("2006-01-02", parts[1])
+		gz := gzip.NewWriter(w)
+		_, _ = fmt.Fprintln(gz, "time_seconds,bid_price_bid_size,ask_price_ask_size")
+		for m := 0; m < 24*60; m++ {
+			if m == 0 {
+				_, _ = fmt.Fprintf(gz, "%d,100_1|99_2,101_3|102_4\n", day.Unix())
+				continue
+			}
+			_, _ = fmt.Fprintf(gz, "%d,100_1|99_2|98_3,101_4|102_5|103_6\n", day.Unix()+int64(m*60))
+		}
+		_ = gz.Close()
+	}))
+	defer server.Close()
+	t.Cleanup(func() { _ = os.RemoveAll("data/levels") })
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(server.URL),
+		depth.WithNamespace("levels"), depth.WithDepthLevels(3))
+	records := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, records["BTC-BUSD"], 24*60*12)
+	assert.Equal(t, []string{"3"}, depths)
+
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, 100.0, record.BidPrice)
+	assert.Equal(t, []depth.Level{{Price: 101, Size: 3}, {Price: 102, Size: 4}}, record.Asks[:2])
+	assert.True(t, math.IsNaN(record.Asks[2].Price))
+	loader.Tick()
+	record = loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, []float64{100, 1, 101, 4}, []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize})
+	assert.Equal(t, []depth.Level{{Price: 100, Size: 1}, {Price: 99, Size: 2}, {Price: 98, Size: 3}}, record.Bids)
+	assert.Equal(t, []depth.Level{{Price: 101, Size: 4}, {Price: 102, Size: 5}, {Price: 103, Size: 6}}, record.Asks)
+
+	// the levels are stored after the fields of the schema
+	content, err := os.ReadFile("data/levels/binance/2022-01-01_2022-01-02_depth.csv")
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "#fields,bid_price,bid_size,ask_price,ask_size,bid_price_1,bid_size_1,ask_price_1,ask_size_1,bid_price_2")
+	assert.Contains(t, string(content), "BTC-BUSD,100,1,101,3,99,2,102,4,NaN,NaN,NaN,NaN,100,1,101,4,99,2,102,5,98,3,103,6,")
+
+	// the cache is read with the levels, and projected to another schema
+	assert.Equal(t, records, depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard),
+		depth.WithNamespace("levels"), depth.WithDepthLevels(3), depth.WithReadOnly()).Load([]depth.Pair{"BTC-BUSD"}, start, end))
+	mids := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(server.URL),
+		depth.WithNamespace("levels"), depth.WithDataDir(t.TempDir()), depth.WithSchema(depth.FieldMid, depth.FieldSpread), depth.WithDepthLevels(2))
+	assert.Len(t, mids.Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"], 24*60*6)
+	mids.Tick()
+	// the top of book has no sizes without their fields
+	assert.Equal(t, []depth.Level{{Price: 100, Size: 0}, {Price: 99, Size: 2}}, mids.GetDepth("BTC-BUSD").Bids)
+
+	assert.Equal(t, depth.Schema{"bid_price_1", "bid_size_1", "ask_price_1", "ask_size_1"}, depth.LevelFields(2))
+	assert.NoError(t, depth.Schema{depth.FieldMid, "ask_size_12"}.Validate())
+	assert.Error(t, depth.Schema{"bid_price_0"}.Validate())
+	assert.Error(t, depth.Schema{"bid_price_01"}.Validate())
+	assert.Panics(t, func() {
+		depth.WithDepthLevels(0)
+	})
+}
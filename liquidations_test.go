@@ -0,0 +1,66 @@
+package order_book_depth_loader_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// liquidationSnapshot is the CSV of a Binance liquidation snapshot archive of 2020-01-01,
+// with two liquidations in the minute 00:01 and one in the minute 00:03.
+const liquidationSnapshot = `time,side,order_type,time_in_force,original_quantity,price,average_price,order_status,last_fill_quantity,accumulated_fill_quantity
+1577836865000,SELL,LIMIT,IOC,10,7180.1,7181.5,FILLED,10,10
+1577836919999,SELL,LIMIT,IOC,2,7180.0,7180.5,FILLED,2,2
+1577836990000,BUY,LIMIT,IOC,5,7210.0,7205.0,FILLED,1,4
+`
+
+func zipFile(t *testing.T, name string, content string) []byte {
+	var b bytes.Buffer
+	archive := zip.NewWriter(&b)
+	f, err := archive.Create(name)
+	assert.NoError(t, err)
+	_, err = f.Write([]byte(content))
+	assert.NoError(t, err)
+	assert.NoError(t, archive.Close())
+	return b.Bytes()
+}
+
+func TestLiquidationLoader(t *testing.T) {
+	archive := zipFile(t, "BTCUSDT-liquidationSnapshot-2020-01-01.csv", liquidationSnapshot)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data/futures/um/daily/liquidationSnapshot/BTCUSDT/BTCUSDT-liquidationSnapshot-2020-01-01.zip":
+			_, _ = w.Write(archive)
+		case "/data/futures/um/daily/liquidationSnapshot/ETHUSDT/ETHUSDT-liquidationSnapshot-2020-01-01.zip":
+			http.Error(w, "rate limited", http.StatusForbidden)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	loader := depth.NewLiquidationLoader(depth.MarketBinanceUsdsFutures,
+		depth.WithLiquidationBaseURL(server.URL), depth.WithLiquidationProgress(io.Discard))
+	liquidations := loader.Load([]depth.Pair{"BTC-USDT"}, start, end)["BTC-USDT"]
+
+	// the second day has no archive, so no liquidations
+	assert.Len(t, liquidations, 2*24*60)
+	assert.Empty(t, liquidations[0])
+	assert.Len(t, liquidations[1], 2)
+	assert.Equal(t, depth.Sell, liquidations[1][0].Side)
+	assert.Equal(t, 7181.5*10, liquidations[1][0].Notional())
+	assert.Empty(t, liquidations[2])
+	assert.Equal(t, []depth.Liquidation{{Time: liquidations[3][0].Time, Side: depth.Buy, Price: 7205, Quantity: 4}}, liquidations[3])
+	assert.Equal(t, int64(1577836990000), liquidations[3][0].Time.UnixMilli())
+
+	// other errors than a missing archive are not taken for a day without liquidations
+	assert.Panics(t, func() {
+		loader.Load([]depth.Pair{"ETH-USDT"}, start, end)
+	})
+}
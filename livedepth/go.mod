@@ -0,0 +1,14 @@
+module github.com/bogdantimes/order-book-depth-loader/livedepth
+
+go 1.19
+
+require (
+	github.com/bogdantimes/order-book-depth-loader v0.1.0
+	github.com/gorilla/websocket v1.5.3
+	github.com/stretchr/testify v1.12.1
+)
+
+require (
+	github.com/life4/genesis v1.1.0 // indirect
+	go.yaml.in/yaml/v3 v3.0.5 // indirect
+)
@@ -0,0 +1,278 @@
+// Package livedepth samples the best bid and ask of the exchange WebSocket streams once a minute, into the records
+// of the depth package, so that a strategy runs the same code against the live data as in its backtests.
+package livedepth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/gorilla/websocket"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// streamURLs are the WebSocket endpoints of the combined streams of the Binance markets.
+var streamURLs = map[depth.Market]string{
+	depth.MarketBinance:            "wss://stream.binance.com:9443",
+	depth.MarketBinanceUsdsFutures: "wss://fstream.binance.com",
+	depth.MarketBinanceCoinFutures: "wss://dstream.binance.com",
+}
+
+// LiveLoader samples the book ticker streams of the Binance markets, which push every change of the best bid and ask.
+// Like the loaders of the depth package, it has a cursor moved with Tick, which waits for the start of the next
+// minute and samples the last quote of each pair received before it, and GetDepth, which returns the sampled quote.
+// A strategy written against these two methods runs the same on a depth.Loader and on a LiveLoader.
+// The stream is connected again after a disconnection, the quotes of the pairs stay those received before it.
+type LiveLoader struct {
+	market   depth.Market
+	url      string
+	interval time.Duration
+	dialer   *websocket.Dialer
+	progress io.Writer
+
+	mu    sync.Mutex
+	pairs []depth.Pair
+	// symbols are the pairs of the stream symbols, like BTCUSDT
+	symbols map[string]depth.Pair
+	// latest are the last received quotes, current the quotes sampled by the last Tick
+	latest  map[depth.Pair]depth.Record
+	current map[depth.Pair]depth.Record
+	// time is the start of the minute of the current quotes, and next the one the next Tick waits for
+	time time.Time
+	next time.Time
+
+	cancel    context.CancelFunc
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewLiveLoader returns a LiveLoader of the Binance spot, USDⓈ-M or COIN-M futures market.
+// It panics for the other markets.
+func NewLiveLoader(market depth.Market, opts ...Option) *LiveLoader {
+	url, ok := streamURLs[market]
+	if !ok {
+		panic("the live streams are not supported for market " + string(market))
+	}
+	l := &LiveLoader{
+		market:   market,
+		url:      url,
+		interval: time.Minute,
+		dialer:   websocket.DefaultDialer,
+		progress: os.Stdout,
+		latest:   make(map[depth.Pair]depth.Record),
+		current:  make(map[depth.Pair]depth.Record),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	return l
+}
+
+// Option configures the LiveLoader.
+type Option func(l *LiveLoader)
+
+// WithURL sets the WebSocket endpoint of the combined streams, like a test server, ws://localhost:8080.
+func WithURL(url string) Option {
+	return func(l *LiveLoader) {
+		l.url = strings.TrimSuffix(url, "/")
+	}
+}
+
+// WithInterval sets the sampling interval, a minute by default, like a second to check a strategy quickly.
+// It panics if the interval is not positive.
+func WithInterval(interval time.Duration) Option {
+	if interval <= 0 {
+		panic("the interval must be positive")
+	}
+	return func(l *LiveLoader) {
+		l.interval = interval
+	}
+}
+
+// WithDialer sets the WebSocket dialer, like one with a proxy, websocket.DefaultDialer by default.
+func WithDialer(dialer *websocket.Dialer) Option {
+	return func(l *LiveLoader) {
+		l.dialer = dialer
+	}
+}
+
+// WithProgress sets the writer of the connection messages, the standard output by default.
+func WithProgress(w io.Writer) Option {
+	return func(l *LiveLoader) {
+		l.progress = w
+	}
+}
+
+// Start connects to the book ticker streams of the pairs, and receives their quotes until the context is done,
+// or the loader closed, see Close. It returns the error of the first connection, the later ones are retried.
+func (l *LiveLoader) Start(ctx context.Context, pairs []depth.Pair) error {
+	l.mu.Lock()
+	l.pairs = pairs
+	l.symbols = make(map[string]depth.Pair, len(pairs))
+	for _, pair := range pairs {
+		l.symbols[l.symbol(pair)] = pair
+	}
+	l.mu.Unlock()
+	conn, err := l.dial(ctx)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	l.mu.Lock()
+	l.cancel = cancel
+	l.next = time.Now().Truncate(l.interval).Add(l.interval)
+	l.mu.Unlock()
+	go l.run(ctx, conn)
+	return nil
+}
+
+// symbol returns the stream symbol of the pair, like BTCUSDT, or BTCUSD_PERP on the COIN-M futures market.
+func (l *LiveLoader) symbol(pair depth.Pair) string {
+	symbol := strings.ToUpper(pair.Base() + pair.Quote())
+	if l.market == depth.MarketBinanceCoinFutures {
+		symbol += "_PERP"
+	}
+	return symbol
+}
+
+func (l *LiveLoader) dial(ctx context.Context) (*websocket.Conn, error) {
+	streams := make([]string, len(l.pairs))
+	for i, pair := range l.pairs {
+		streams[i] = strings.ToLower(l.symbol(pair)) + "@bookTicker"
+	}
+	url := l.url + "/stream?streams=" + strings.Join(streams, "/")
+	conn, _, err := l.dialer.DialContext(ctx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", url, err)
+	}
+	return conn, nil
+}
+
+// run receives the quotes of the connection, and connects again once it is lost, until the context is done.
+func (l *LiveLoader) run(ctx context.Context, conn *websocket.Conn) {
+	for {
+		closed := make(chan struct{})
+		go func() {
+			select {
+			case <-ctx.Done():
+				_ = conn.Close()
+			case <-closed:
+			}
+		}()
+		err := l.receive(conn)
+		close(closed)
+		_ = conn.Close()
+		for {
+			if ctx.Err() != nil {
+				return
+			}
+			fmt.Fprintln(l.progress, "Reconnecting the live stream of", l.market, "after", err)
+			select {
+			case <-time.After(time.Second):
+			case <-ctx.Done():
+				return
+			}
+			if conn, err = l.dial(ctx); err == nil {
+				break
+			}
+		}
+	}
+}
+
+// receive keeps the quotes of the connection, until it fails.
+func (l *LiveLoader) receive(conn *websocket.Conn) error {
+	for {
+		_, message, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		// {"stream":"btcusdt@bookTicker","data":{"u":400900217,"s":"BTCUSDT","b":"16544.2","B":"0.5","a":"16544.3","A":"1.2"}}
+		var event struct {
+			Data struct {
+				Symbol   string `json:"s"`
+				BidPrice string `json:"b"`
+				BidSize  string `json:"B"`
+				AskPrice string `json:"a"`
+				AskSize  string `json:"A"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(message, &event); err != nil {
+			return fmt.Errorf("%w: %s", err, string(message))
+		}
+		var values [4]float64
+		for i, s := range []string{event.Data.BidPrice, event.Data.BidSize, event.Data.AskPrice, event.Data.AskSize} {
+			if values[i], err = strconv.ParseFloat(s, 64); err != nil {
+				return fmt.Errorf("malformed book ticker: %s: %w", string(message), err)
+			}
+		}
+		l.mu.Lock()
+		if pair, ok := l.symbols[event.Data.Symbol]; ok {
+			l.latest[pair] = depth.Record{BidPrice: values[0], BidSize: values[1], AskPrice: values[2], AskSize: values[3]}
+		}
+		l.mu.Unlock()
+	}
+}
+
+// Tick waits for the start of the next minute, and samples the last received quote of each pair.
+// The minutes the strategy is too slow for are skipped, the samples are those of the start of the minute Tick
+// returns in. It returns false at once once the loader is closed, keeping the quotes of the last minute.
+func (l *LiveLoader) Tick() bool {
+	l.mu.Lock()
+	next := l.next
+	l.mu.Unlock()
+	timer := time.NewTimer(time.Until(next))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-l.done:
+		return false
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, pair := range l.pairs {
+		if record, ok := l.latest[pair]; ok {
+			l.current[pair] = record
+		}
+	}
+	l.time = next
+	l.next = time.Now().Truncate(l.interval).Add(l.interval)
+	return true
+}
+
+// GetDepth returns the quote of the pair sampled by the last Tick, NaN before the first quote of the pair.
+func (l *LiveLoader) GetDepth(pair depth.Pair) depth.Record {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if record, ok := l.current[pair]; ok {
+		return record
+	}
+	nan := math.NaN()
+	return depth.Record{BidPrice: nan, BidSize: nan, AskPrice: nan, AskSize: nan}
+}
+
+// Time returns the start of the minute of the quotes sampled by the last Tick, zero before the first Tick.
+func (l *LiveLoader) Time() time.Time {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.time
+}
+
+// Close disconnects the stream, and makes Tick return at once.
+func (l *LiveLoader) Close() error {
+	l.closeOnce.Do(func() {
+		l.mu.Lock()
+		if l.cancel != nil {
+			l.cancel()
+		}
+		l.mu.Unlock()
+		close(l.done)
+	})
+	return nil
+}
@@ -0,0 +1,94 @@
+package livedepth_test
+
+import (
+	"context"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/bogdantimes/order-book-depth-loader/livedepth"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLiveLoader(t *testing.T) {
+	quotes := make(chan string, 10)
+	var queries []string
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer conn.Close()
+		for quote := range quotes {
+			// an empty quote drops the connection
+			if quote == "" {
+				return
+			}
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(quote)); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+	defer close(quotes)
+
+	loader := livedepth.NewLiveLoader(depth.MarketBinance, livedepth.WithURL("ws"+strings.TrimPrefix(server.URL, "http")),
+		livedepth.WithInterval(100*time.Millisecond), livedepth.WithProgress(io.Discard))
+	defer loader.Close()
+	assert.NoError(t, loader.Start(context.Background(), []depth.Pair{"BTC-USDT", "ETH-USDT"}))
+	assert.Equal(t, []string{"/stream?streams=btcusdt@bookTicker/ethusdt@bookTicker"}, queries)
+
+	quote := func(symbol string, bid float64) string {
+		return fmt.Sprintf(`{"stream":%q,"data":{"u":1,"s":%q,"b":"%v","B":"1","a":"%v","A":"2"}}`,
+			strings.ToLower(symbol)+"@bookTicker", symbol, bid, bid+1)
+	}
+	// tick waits for the sample of the pair to have the bid price
+	tick := func(pair depth.Pair, bid float64) {
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			loader.Tick()
+			if loader.GetDepth(pair).BidPrice == bid {
+				return
+			}
+		}
+		t.Fatalf("no sample of %s at %v", pair, bid)
+	}
+
+	assert.True(t, math.IsNaN(loader.GetDepth("BTC-USDT").BidPrice))
+	quotes <- quote("BTCUSDT", 100)
+	tick("BTC-USDT", 100)
+	assert.Equal(t, depth.Record{BidPrice: 100, BidSize: 1, AskPrice: 101, AskSize: 2}, loader.GetDepth("BTC-USDT"))
+	assert.True(t, math.IsNaN(loader.GetDepth("ETH-USDT").BidPrice))
+	assert.Equal(t, loader.Time(), loader.Time().Truncate(100*time.Millisecond))
+
+	// the quotes are sampled at the start of each minute, the last one before it
+	quotes <- quote("ETHUSDT", 10)
+	quotes <- quote("ETHUSDT", 11)
+	tick("ETH-USDT", 11)
+	assert.Equal(t, 100.0, loader.GetDepth("BTC-USDT").BidPrice)
+
+	// the stream is connected again, keeping the quotes
+	quotes <- ""
+	quotes <- quote("BTCUSDT", 102)
+	tick("BTC-USDT", 102)
+	assert.Len(t, queries, 2)
+	assert.Equal(t, 11.0, loader.GetDepth("ETH-USDT").BidPrice)
+
+	// a closed loader doesn't wait
+	assert.NoError(t, loader.Close())
+	start := time.Now()
+	loader.Tick()
+	assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+	assert.Panics(t, func() {
+		livedepth.NewLiveLoader(depth.MarketKraken)
+	})
+}
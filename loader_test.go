@@ -1,10 +1,18 @@
 package order_book_depth_loader_test
 
 import (
+	"compress/gzip"
+	"fmt"
 	"github.com/bogdantimes/order-book-depth-loader/depth"
 	"github.com/kaz-yamam0t0/go-timeparser/timeparser"
 	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 )
@@ -19,6 +27,80 @@ func ParseOrDie(s string) time.Time {
 	return *rangeStart
 }
 
+// Quote is a single top of book snapshot used to build test fixtures.
+type Quote struct {
+	BidPrice, BidSize, AskPrice, AskSize float64
+}
+
+// WriteFixture writes a depth cache file for the given range, so that Load reads it instead of downloading.
+// The quote function is called for each pair and each minute of the range.
+func WriteFixture(t *testing.T, market depth.Market, pairs []depth.Pair, start, end time.Time, quote func(pair depth.Pair, minute int) Quote) {
+	path := "data/" + string(market) + "/" + start.Format("2006-01-02") + "_" + end.Format("2006-01-02") + "_depth.csv"
+	minutes := int(end.Sub(start).Minutes())
+
+	var b strings.Builder
+	b.WriteString("#")
+	for _, pair := range pairs {
+		b.WriteString("," + pair.String())
+	}
+	b.WriteString("\n")
+	for _, pair := range pairs {
+		b.WriteString(pair.String())
+		for m := 0; m < minutes; m++ {
+			q := quote(pair, m)
+			b.WriteString(fmt.Sprintf(",%v,%v,%v,%v", q.BidPrice, q.BidSize, q.AskPrice, q.AskSize))
+		}
+		b.WriteString("\n")
+	}
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(b.String()), 0644))
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+		_ = os.Remove(path + ".versions")
+	})
+}
+
+// ServeChassis serves the crypto-chassis API for WithBaseURL, with a day of 1 second depth snapshots
+// of each requested pair and day, of which the loader keeps those of each minute.
+// The quote function is called for each pair and each minute of the day.
+func ServeChassis(t *testing.T, quote func(pair depth.Pair, minute int) Quote) string {
+	return ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return quote(pair, minute), true
+	})
+}
+
+// ServeChassisDays is ServeChassis with the day of the quotes, a day having no data if its first quote is not ok.
+func ServeChassisDays(t *testing.T, quote func(pair depth.Pair, day time.Time, minute int) (Quote, bool)) string {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+		switch {
+		case len(parts) == 4 && parts[1] == "market-depth":
+			url := server.URL + "/csv/" + parts[3] + "/" + r.URL.Query().Get("startTime")
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}],"expiration":"300 seconds"}`, url)
+		case len(parts) == 3 && parts[0] == "csv":
+			day, err := time.Parse("2006-01-02", parts[2])
+			assert.NoError(t, err)
+			gz := gzip.NewWriter(w)
+			_, _ = fmt.Fprintln(gz, "time_seconds,bid_price_bid_size,ask_price_ask_size")
+			for m := 0; m < 24*60; m++ {
+				q, ok := quote(depth.Pair(parts[1]), day, m)
+				if !ok {
+					break
+				}
+				_, _ = fmt.Fprintf(gz, "%d,%v_%v,%v_%v\n", day.Unix()+int64(m*60), q.BidPrice, q.BidSize, q.AskPrice, q.AskSize)
+			}
+			_ = gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
 func TestLoader(t *testing.T) {
 	depthLoader := depth.NewCCDepthLoader(depth.MarketBinance)
 
@@ -54,6 +136,301 @@ func TestLoader(t *testing.T) {
 	result = depthLoader.Load([]depth.Pair{}, ParseOrDie("11-24-2022"), ParseOrDie("11-25-2022"))
 	assert.Greater(t, len(result), 3)
 
-	assert.FileExists(t, "data/2022-11-24_2022-11-25_depth.csv")
-	assert.NoError(t, os.Remove("data/2022-11-24_2022-11-25_depth.csv"))
+	assert.FileExists(t, "data/binance/2022-11-24_2022-11-25_depth.csv")
+	assert.NoError(t, os.Remove("data/binance/2022-11-24_2022-11-25_depth.csv"))
+	_ = os.Remove("data/binance/2022-11-24_2022-11-25_depth.csv.idx")
+}
+
+func TestLoadMigratesLegacyCache(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	// move the fixture to where the files were stored before they were kept per market
+	path, legacy := "data/binance/2020-01-01_2020-01-02_depth.csv", "data/2020-01-01_2020-01-02_depth.csv"
+	assert.NoError(t, os.Rename(path, legacy))
+	t.Cleanup(func() {
+		_ = os.Remove(legacy)
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	result := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+	assert.FileExists(t, path)
+	assert.NoFileExists(t, legacy)
+}
+
+func TestLoadNamespace(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{200, 1, 201, 1}
+	})
+	path, namespaced := "data/binance/2020-01-01_2020-01-02_depth.csv", "data/research/binance/2020-01-01_2020-01-02_depth.csv"
+	assert.NoError(t, os.MkdirAll(filepath.Dir(namespaced), 0755))
+	assert.NoError(t, os.Rename(path, namespaced))
+	t.Cleanup(func() {
+		_ = os.RemoveAll("data/research")
+	})
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("research"), depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, 200.0, loader.GetDepth("BTC-BUSD").BidPrice)
+
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, 100.0, loader.GetDepth("BTC-BUSD").BidPrice)
+
+	assert.Panics(t, func() {
+		depth.WithNamespace("../other")
+	})
+}
+
+func TestRecordTime(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, start, loader.CurrentTime())
+	assert.Equal(t, start, loader.GetDepth("BTC-BUSD").Time)
+
+	for i := 0; i < 24*60+30; i++ {
+		loader.Tick()
+	}
+	minute := start.Add(24*time.Hour + 30*time.Minute)
+	assert.Equal(t, minute, loader.CurrentTime())
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, minute, record.Time)
+	assert.Equal(t, float64(101+24*60+30), record.AskPrice)
+}
+
+func TestLoadReadOnly(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithReadOnly(), depth.WithProgress(io.Discard))
+	result := loader.Load(nil, start, end)
+	assert.Len(t, result, 1)
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+	after, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, info.Size(), after.Size())
+
+	assertReadOnlyPanic := func(load func()) {
+		defer func() {
+			err, _ := recover().(error)
+			assert.ErrorIs(t, err, depth.ErrReadOnly)
+		}()
+		load()
+	}
+	assertReadOnlyPanic(func() {
+		loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	})
+	assertReadOnlyPanic(func() {
+		depth.NewCCDepthLoader(depth.MarketKraken, depth.WithReadOnly()).Load(nil, start, end)
+	})
+	assert.NoDirExists(t, "data/kraken")
+}
+
+func TestLoadIndex(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD", "XRP-BUSD"}
+	WriteFixture(t, depth.MarketBinance, pairs, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(len(pair)), 1, 101, 1}
+	})
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+
+	// the first partial load scans the file and writes the sidecar
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	result := loader.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	assert.Len(t, result, 1)
+	assert.FileExists(t, path+".idx")
+	sidecar, err := os.ReadFile(path + ".idx")
+	assert.NoError(t, err)
+	assert.Contains(t, string(sidecar), "ETH-BUSD,")
+
+	// the next ones read the indexed rows only, even when the rest of the file is unreadable
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteAt([]byte(strings.Repeat("x", 20)), int64(strings.Index(string(content), "\nXRP-BUSD")+1))
+	assert.NoError(t, err)
+	info, err := file.Stat()
+	assert.NoError(t, err)
+	_ = file.Close()
+	// keep the modification time of the sidecar
+	assert.NoError(t, os.Chtimes(path, info.ModTime(), fileModTime(t, path+".idx", sidecar)))
+
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	result = loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.Len(t, result, 2)
+	assert.Equal(t, 8.0, loader.GetDepth("ETH-BUSD").BidPrice)
+}
+
+// fileModTime returns the modification time of the data file recorded in its index sidecar.
+func fileModTime(t *testing.T, path string, sidecar []byte) time.Time {
+	header := strings.Split(strings.SplitN(string(sidecar), "\n", 2)[0], ",")
+	assert.Len(t, header, 3, path)
+	ns, err := strconv.ParseInt(header[2], 10, 64)
+	assert.NoError(t, err)
+	return time.Unix(0, ns)
+}
+
+func TestLoadVersions(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{10, 1, 11, float64(minute)}
+	})
+
+	// the file written before the versions were recorded is the version 1
+	experiment := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithVersion(1))
+	versions, err := experiment.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+
+	// a backfill appends a pair in the version 2
+	backfill := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	result := backfill.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.Len(t, result["ETH-BUSD"], 24*60*4)
+	assert.Equal(t, "11", result["ETH-BUSD"][2])
+	versions, err = backfill.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Equal(t, 2, versions[1].Number)
+	assert.Equal(t, []depth.Pair{"ETH-BUSD"}, versions[1].Pairs)
+	assert.Equal(t, map[depth.Pair]int{"ETH-BUSD": 24 * 60}, versions[1].Minutes)
+	assert.Less(t, versions[0].Size, versions[1].Size)
+
+	// the experiment keeps reading the version 1
+	result = experiment.Load(nil, start, end)
+	assert.Len(t, result, 1)
+	assert.NotNil(t, result["BTC-BUSD"])
+	assert.PanicsWithError(t, "the loader is read-only: "+path+" has no data for ETH-BUSD", func() {
+		experiment.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	})
+
+	latest := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithVersion(2))
+	assert.Len(t, latest.Load(nil, start, end), 2)
+	assert.Len(t, latest.Load([]depth.Pair{"ETH-BUSD"}, start, end), 2)
+
+	assert.PanicsWithError(t, "the loader is read-only: "+path+" has no version 3", func() {
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithVersion(3)).Load(nil, start, end)
+	})
+	assert.Panics(t, func() {
+		depth.WithVersion(0)
+	})
+}
+
+func TestLoadVerify(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{10, 1, 11, 1}
+	})
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+		_ = os.Remove(path + ".versions")
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.NoError(t, loader.Verify(start, end))
+	versions, err := loader.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, map[depth.Pair]int{"BTC-BUSD": 24 * 60}, versions[0].Minutes)
+
+	// an unrecorded write is reported, but still loaded
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	assert.NoError(t, err)
+	_, err = file.WriteString("ETH-BUSD,10,1,11,1\n")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+	assert.Error(t, loader.Verify(start, end))
+	depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard)).Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	// a truncated file panics on open
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.NoError(t, os.Truncate(path, info.Size()-100))
+	assert.Error(t, loader.Verify(start, end))
+	assert.Panics(t, func() {
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard)).Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	})
+}
+
+func TestLoadVersionsPairNames(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{10, 1, 11, 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/versions-test") })
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("versions-test"),
+		depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+
+	// the separators of the pairs of the sidecar in a pair name don't shift the pairs after it
+	pairs := []depth.Pair{"BTC;ETH:1", "ETH-BUSD"}
+	loader.Load(pairs, start, end)
+	versions, err := loader.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 1)
+	assert.Equal(t, pairs, versions[0].Pairs)
+	assert.Equal(t, map[depth.Pair]int{"BTC;ETH:1": 24 * 60, "ETH-BUSD": 24 * 60}, versions[0].Minutes)
+	assert.NoError(t, loader.Verify(start, end))
+
+	// the sidecars written with the pairs of a version in a single field are still read
+	path := "data/versions-test/binance/2020-01-01_2020-01-02_depth.csv.versions"
+	assert.NoError(t, os.WriteFile(path, []byte("1,100,2020-01-01T00:00:00Z,\n2,200,2020-01-02T00:00:00Z,BTC:X:60;ETH-BUSD:1440\n"), 0644))
+	versions, err = loader.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Len(t, versions, 2)
+	assert.Empty(t, versions[0].Pairs)
+	assert.Equal(t, []depth.Pair{"BTC:X", "ETH-BUSD"}, versions[1].Pairs)
+	assert.Equal(t, map[depth.Pair]int{"BTC:X": 60, "ETH-BUSD": 24 * 60}, versions[1].Minutes)
+}
+
+// BenchmarkGetDepth compares GetDepth, reading the values parsed once by the load, with parsing the values
+// of each record on every call, like the loader did from the strings read from the file.
+func BenchmarkGetDepth(b *testing.B) {
+	var input strings.Builder
+	input.WriteString("#,BTC-BUSD\nBTC-BUSD")
+	for m := 0; m < 24*60; m++ {
+		input.WriteString(fmt.Sprintf(",%v,1.52092,%v,0.00001", 16544.2+float64(m)/10, 16544.3+float64(m)/10))
+	}
+	input.WriteString("\n")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	records := loader.LoadFrom(strings.NewReader(input.String()), ParseOrDie("01-01-2020"))["BTC-BUSD"]
+
+	b.Run("values", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = loader.GetDepth("BTC-BUSD")
+		}
+	})
+	b.Run("strings", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			values := records[(i%(24*60))*4:]
+			var record depth.Record
+			record.BidPrice, _ = strconv.ParseFloat(values[0], 64)
+			record.BidSize, _ = strconv.ParseFloat(values[1], 64)
+			record.AskPrice, _ = strconv.ParseFloat(values[2], 64)
+			record.AskSize, _ = strconv.ParseFloat(values[3], 64)
+		}
+	})
 }
@@ -0,0 +1,75 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLoadStats(t *testing.T) {
+	chassis := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, pair != "ETH-USDT"
+	})
+	// the first lookup of ETH-BUSD is rate limited
+	var once sync.Once
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limited := false
+		if strings.Contains(r.URL.Path, "market-depth") && strings.Contains(r.URL.Path, "ETH-BUSD") {
+			once.Do(func() { limited = true })
+		}
+		if limited {
+			_, _ = io.WriteString(w, "Too many requests, please try again later.")
+			return
+		}
+		resp, err := http.Get(chassis + r.URL.RequestURI())
+		if !assert.NoError(t, err) {
+			return
+		}
+		defer resp.Body.Close()
+		_, _ = io.Copy(w, resp.Body)
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { _ = os.RemoveAll("data/stats-test") })
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("stats-test"), depth.WithProgress(io.Discard),
+			depth.WithBaseURL(server.URL))
+	}
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD", "ETH-USDT"}
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+
+	loader := newLoader()
+	assert.Empty(t, loader.Stats().Pairs)
+	loader.Load(pairs, start, end)
+	stats := loader.Stats()
+	assert.Positive(t, stats.Duration)
+	assert.Len(t, stats.Pairs, 3)
+	for _, pair := range pairs {
+		assert.Equal(t, 2, stats.Pairs[pair].DaysFetched, pair)
+		assert.Equal(t, 0, stats.Pairs[pair].DaysReused, pair)
+		assert.Positive(t, stats.Pairs[pair].Bytes, pair)
+		assert.Positive(t, stats.Pairs[pair].Duration, pair)
+	}
+	assert.Equal(t, 1, stats.Pairs["ETH-BUSD"].Retries)
+	assert.Equal(t, 0, stats.Pairs["BTC-BUSD"].Retries)
+	// the archives of the days without data are empty
+	assert.Less(t, stats.Pairs["ETH-USDT"].Bytes, stats.Pairs["BTC-BUSD"].Bytes)
+
+	// the days are read from the cache, but the pairs without data are not written, and are downloaded again
+	loader = newLoader()
+	loader.Load(pairs, start, end)
+	stats = loader.Stats()
+	assert.Equal(t, depth.PairStats{DaysReused: 2}, stats.Pairs["BTC-BUSD"])
+	assert.Equal(t, depth.PairStats{DaysReused: 2}, stats.Pairs["ETH-BUSD"])
+	assert.Equal(t, 2, stats.Pairs["ETH-USDT"].DaysFetched)
+
+	// a repeated load reuses the loaded pairs
+	loader.Load(pairs[:1], start, end)
+	assert.Equal(t, map[depth.Pair]depth.PairStats{"BTC-BUSD": {DaysReused: 2}}, loader.Stats().Pairs)
+}
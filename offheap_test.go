@@ -0,0 +1,45 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestOffHeap(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		if pair == "ETH-BUSD" {
+			return Quote{10, 1, 11, 1}
+		}
+		return Quote{float64(100 + minute), 1, float64(101 + minute), 2}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithOffHeap(), depth.WithProgress(io.Discard),
+		depth.WithSeries("mid", depth.Record.Mid))
+	result := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+
+	// the next load returns only its own records, the previous ones are still loaded
+	result = loader.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	assert.Len(t, result, 1)
+	assert.Len(t, result["ETH-BUSD"], 24*60*4)
+
+	loader.Tick()
+	assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").BidPrice)
+	assert.Equal(t, 2.0, loader.GetDepth("BTC-BUSD").AskSize)
+	assert.Equal(t, 11.0, loader.GetDepth("ETH-BUSD").AskPrice)
+	assert.Equal(t, 101.5, loader.Series("mid", "BTC-BUSD")[1])
+	assert.Equal(t, 24*60, loader.Columns("BTC-BUSD").Len())
+	btc := loader.Footprints()["BTC-BUSD"]
+	assert.Equal(t, 24*60*4, btc.Values)
+	assert.Equal(t, int64(24+8*24*60*4+24+8*24*60), btc.Bytes)
+
+	var out strings.Builder
+	assert.NoError(t, loader.Export(&out, []depth.Pair{"BTC-BUSD"}))
+	assert.Contains(t, out.String(), "2020-01-01T00:01:00Z,BTC-BUSD,101,1,102,2\n")
+
+	assert.NoError(t, loader.Close())
+	assert.Empty(t, loader.Footprints())
+}
@@ -0,0 +1,89 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestDeriveCandles(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,102,1,104,1,106,1,102,1,104,1\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	candles := loader.Candles()
+	derived := candles.Derive(nil)["BTC-BUSD"]
+
+	// the candle of a minute closes at the mid price of the next one, and the last minute has none
+	if assert.Len(t, derived, 2) {
+		assert.True(t, ParseOrDie("01-01-2020").Equal(derived[0].Time))
+		assert.Equal(t, []float64{101, 105, 101, 105}, []float64{derived[0].Open, derived[0].High, derived[0].Low, derived[0].Close})
+		assert.Equal(t, []float64{105, 105, 103, 103}, []float64{derived[1].Open, derived[1].High, derived[1].Low, derived[1].Close})
+		assert.True(t, math.IsNaN(derived[1].Volume))
+	}
+
+	// the cursor reads the last closed candle
+	assert.True(t, math.IsNaN(candles.GetCandle("BTC-BUSD").Close))
+	loader.Tick()
+	assert.Equal(t, derived[0].Time, candles.GetCandle("BTC-BUSD").Time)
+	assert.Equal(t, 105.0, candles.GetCandle("BTC-BUSD").Close)
+	loader.Tick()
+	assert.Equal(t, 103.0, candles.GetCandle("BTC-BUSD").Close)
+}
+
+func TestLoadCandles(t *testing.T) {
+	var requests int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/v1/ohlc/binance/BTC-BUSD":
+			assert.Equal(t, "1m", r.URL.Query().Get("interval"))
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}],"expiration":"300 seconds"}`, server.URL+"/csv/BTC-BUSD/"+r.URL.Query().Get("startTime"))
+		case "/csv/BTC-BUSD/2020-01-01":
+			// the minute 00:01 has no trades
+			gz := gzip.NewWriter(w)
+			_, _ = fmt.Fprint(gz, "time_seconds,open,high,low,close,volume,vwap,number_of_trades,twap\n",
+				"1577836800,100,103,99,102,5,101,3,101\n1577836920,102,102,101,101,2,101.5,2,101.5\n")
+			_ = gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { _ = os.RemoveAll("data/ohlcv") })
+
+	opts := []depth.Option{depth.WithBaseURL(server.URL), depth.WithProgress(io.Discard), depth.WithNamespace("ohlcv")}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	candles := loader.Candles()
+	loaded := candles.Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"]
+	assert.Len(t, loaded, 24*60)
+	assert.True(t, start.Equal(loaded[0].Time))
+	assert.Equal(t, depth.Candle{Time: loaded[0].Time, Open: 100, High: 103, Low: 99, Close: 102, Volume: 5}, loaded[0])
+	assert.True(t, math.IsNaN(loaded[1].Open))
+	assert.Equal(t, 0.0, loaded[1].Volume)
+	assert.Equal(t, 101.0, loaded[2].Close)
+
+	// the days are cached
+	loader.Tick()
+	cached := depth.NewCCDepthLoader(depth.MarketBinance, opts...).Candles().Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"]
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, loaded[2], cached[2])
+	assert.Equal(t, loaded[0], candles.GetCandle("BTC-BUSD"))
+
+	assert.Panics(t, func() {
+		candles.Load([]depth.Pair{"BTC-BUSD"}, start, ParseOrDie("01-03-2020"))
+	})
+	assert.Panics(t, func() {
+		depth.NewCCDepthLoader(depth.MarketBinance, opts...).Candles().Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	})
+}
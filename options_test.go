@@ -0,0 +1,63 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingTransport counts the requests sent through it.
+type countingTransport struct {
+	requests int64
+}
+
+func (c *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt64(&c.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestLoaderOptions(t *testing.T) {
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-03-2022")
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	dir := t.TempDir()
+	transport := &countingTransport{}
+	var logs bytes.Buffer
+	loader := depth.NewCCDepthLoader(depth.MarketBinance,
+		depth.WithBaseURL(url),
+		depth.WithDataDir(dir),
+		depth.WithHTTPClient(&http.Client{Transport: transport}),
+		depth.WithConcurrency(1),
+		depth.WithLogger(log.New(&logs, "depth: ", 0)),
+		depth.WithRateLimit(20),
+	)
+	began := time.Now()
+	records := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, records["BTC-BUSD"], 2*24*60*4)
+
+	// a request for the URL of each day, and one for its archive, spaced by the rate limit
+	assert.Equal(t, int64(4), atomic.LoadInt64(&transport.requests))
+	assert.GreaterOrEqual(t, time.Since(began), 150*time.Millisecond)
+	assert.FileExists(t, filepath.Join(dir, "binance", "2022-01-01_2022-01-03_depth.csv"))
+	assert.NoFileExists(t, "data/binance/2022-01-01_2022-01-03_depth.csv")
+	lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+	assert.Equal(t, "depth: Downloading depth for BTC-BUSD 2022-01-01 00:00:00 +0000 UTC", lines[0])
+
+	// the cache of the data directory is read again
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithDataDir(dir), depth.WithReadOnly(), depth.WithProgress(io.Discard))
+	assert.Len(t, loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"], 2*24*60*4)
+
+	assert.Panics(t, func() { depth.WithDataDir("") })
+	assert.Panics(t, func() { depth.WithConcurrency(0) })
+	assert.Panics(t, func() { depth.WithRateLimit(0) })
+	assert.Panics(t, func() { depth.WithHTTPClient(nil) })
+}
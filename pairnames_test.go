@@ -0,0 +1,73 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPairNames(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/pairnames-test") })
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("pairnames-test"), depth.WithDayFiles(),
+			depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	}
+	// the pairs not allowed as file names are escaped in the paths of their days, the same on every platform
+	pairs := []depth.Pair{"BTC:X", "CON"}
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	newLoader().Load(pairs, start, end)
+	assert.FileExists(t, "data/pairnames-test/binance/BTC%3AX/2020-01-01.csv.gz")
+	assert.FileExists(t, "data/pairnames-test/binance/%43ON/2020-01-01.csv.gz")
+
+	records := newLoader().Load(pairs, start, end)
+	for _, pair := range pairs {
+		assert.Len(t, records[pair], 24*60*4, pair)
+	}
+}
+
+func TestPairNamesOutsideDir(t *testing.T) {
+	t.Cleanup(func() { _ = os.RemoveAll("data/pairnames-dir-test") })
+	store := depth.NewDayFileStore("data/pairnames-dir-test/binance")
+	assert.NoError(t, store.Open(depth.DefaultSchema))
+	// the / and the .. of a pair don't take its directory out of the one of the market
+	for _, pair := range []depth.Pair{"../X", ".."} {
+		assert.NoError(t, store.WriteDay(pair, ParseOrDie("01-01-2020"), []string{"100", "1", "101", "1"}))
+	}
+	assert.FileExists(t, "data/pairnames-dir-test/binance/..%2FX/2020-01-01.csv.gz")
+	assert.FileExists(t, "data/pairnames-dir-test/binance/.%2E/2020-01-01.csv.gz")
+	assert.NoDirExists(t, "data/pairnames-dir-test/X")
+	assert.NoFileExists(t, "data/pairnames-dir-test/2020-01-01.csv.gz")
+}
+
+func TestPairNamesMigration(t *testing.T) {
+	var downloads int32
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		atomic.AddInt32(&downloads, 1)
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/pairnames-migration-test") })
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("pairnames-migration-test"),
+			depth.WithDayFiles(), depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	}
+	pairs := []depth.Pair{"BTC:X"}
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	newLoader().Load(pairs, start, end)
+
+	// the directory of the pair named after the pair itself, as the cache had it before the escaping, is moved
+	dir := "data/pairnames-migration-test/binance/"
+	assert.NoError(t, os.Rename(dir+"BTC%3AX", dir+"BTC:X"))
+	atomic.StoreInt32(&downloads, 0)
+	records := newLoader().Load(pairs, start, end)
+	assert.Len(t, records["BTC:X"], 24*60*4)
+	assert.Zero(t, atomic.LoadInt32(&downloads))
+	assert.FileExists(t, dir+"BTC%3AX/2020-01-01.csv.gz")
+	assert.NoDirExists(t, dir+"BTC:X")
+}
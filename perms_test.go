@@ -0,0 +1,55 @@
+//go:build !windows
+
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestFileModes(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/perms-test") })
+	// the modes are set regardless of a strict umask
+	umask := syscall.Umask(0077)
+	defer syscall.Umask(umask)
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("perms-test"), depth.WithProgress(io.Discard),
+		depth.WithBaseURL(url), depth.WithFileModes(0775, 0664), depth.WithFileOwner(os.Getuid(), -1))
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.NoError(t, loader.MarkBad("BTC-BUSD", start, "test"))
+
+	dir := "data/perms-test/binance"
+	for _, path := range []string{"data/perms-test", dir} {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0775), info.Mode().Perm(), path)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "*"))
+	assert.NoError(t, err)
+	assert.GreaterOrEqual(t, len(files), 3)
+	for _, path := range files {
+		info, err := os.Stat(path)
+		assert.NoError(t, err)
+		if info.IsDir() {
+			assert.Equal(t, os.FileMode(0775), info.Mode().Perm(), path)
+		} else {
+			assert.Equal(t, os.FileMode(0664), info.Mode().Perm(), path)
+		}
+	}
+
+	assert.Panics(t, func() {
+		depth.WithFileModes(os.ModeDir|0755, 0644)
+	})
+	assert.Panics(t, func() {
+		depth.WithFileOwner(-2, 0)
+	})
+}
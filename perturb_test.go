@@ -0,0 +1,42 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+func TestPerturb(t *testing.T) {
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(100 + minute), 2, float64(102 + minute), 4}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/perturb") })
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("perturb"))
+	records := loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+
+	perturbed := loader.Perturb(1, depth.DefaultPerturbModel)
+	for minute := 0; minute < 24*60; minute++ {
+		record := perturbed.GetDepth("BTC-BUSD")
+		// the spread is kept, the prices are shifted by up to half of it, and the sizes scaled by up to 20%
+		assert.InDelta(t, 2, record.AskPrice-record.BidPrice, 1e-9)
+		assert.InDelta(t, float64(100+minute), record.BidPrice, 1)
+		assert.InDelta(t, 2, record.BidSize, 0.4)
+		assert.InDelta(t, 4, record.AskSize, 0.8)
+		perturbed.Tick()
+	}
+	assert.NotEqual(t, records["BTC-BUSD"], perturbed.Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"])
+	assert.Equal(t, 100.0, loader.GetDepth("BTC-BUSD").BidPrice)
+
+	// the same seed reproduces the copy, another seed doesn't
+	again := loader.Perturb(1, depth.DefaultPerturbModel)
+	assert.Equal(t, again.GetDepth("ETH-BUSD"), loader.Perturb(1, depth.DefaultPerturbModel).GetDepth("ETH-BUSD"))
+	assert.NotEqual(t, again.GetDepth("ETH-BUSD"), loader.Perturb(2, depth.DefaultPerturbModel).GetDepth("ETH-BUSD"))
+	assert.Equal(t, loader.GetDepth("ETH-BUSD"), loader.Perturb(1, depth.PerturbModel{}).GetDepth("ETH-BUSD"))
+
+	assert.Panics(t, func() {
+		loader.Perturb(1, depth.PerturbModel{SizeJitter: 1})
+	})
+}
@@ -0,0 +1,107 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// markPriceKlines is a response of the Binance markPriceKlines endpoint for the minutes 2, 3 and 5
+// of 2020-01-01, the kline of the 4th minute is missing.
+const markPriceKlines = `[
+[1577836920000,"7195.24","7196.10","7194.50","7195.80","0",1577836979999,"0",60,"0","0","0"],
+[1577836980000,"7195.80","7197.00","7195.10","7196.90","0",1577837039999,"0",60,"0","0","0"],
+[1577837100000,"7198.10","7199.00","7197.50","7198.60","0",1577837159999,"0",60,"0","0","0"]
+]`
+
+func TestMarkPriceLoader(t *testing.T) {
+	var queries []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		queries = append(queries, r.URL.Path+"?"+r.URL.RawQuery)
+		fmt.Fprint(w, markPriceKlines)
+	}))
+	defer server.Close()
+
+	start := ParseOrDie("01-01-2020")
+	loader := depth.NewMarkPriceLoader(depth.MarketBinanceUsdsFutures,
+		depth.WithPriceBaseURL(server.URL), depth.WithPriceProgress(io.Discard))
+	prices := loader.Load([]depth.Pair{"BTC-USDT"}, start, start.Add(7*time.Minute))
+
+	assert.Equal(t, []string{"/fapi/v1/markPriceKlines?symbol=BTCUSDT&interval=1m&limit=1500&startTime=1577836800000&endTime=1577837219999"}, queries)
+	series := prices["BTC-USDT"]
+	assert.Len(t, series, 7)
+	// no price before the first kline
+	assert.True(t, math.IsNaN(series[0]))
+	assert.True(t, math.IsNaN(series[1]))
+	assert.Equal(t, []float64{7195.24, 7195.80, 7195.80, 7198.10, 7198.10}, series[2:])
+}
+
+func TestIndexPriceLoaderError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/dapi/v1/indexPriceKlines", r.URL.Path)
+		assert.Equal(t, "BTCUSD", r.URL.Query().Get("pair"))
+		http.Error(w, `{"code":-1121,"msg":"Invalid symbol."}`, http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	start := ParseOrDie("01-01-2020")
+	loader := depth.NewIndexPriceLoader(depth.MarketBinanceCoinFutures,
+		depth.WithPriceBaseURL(server.URL), depth.WithPriceProgress(io.Discard))
+	assert.Panics(t, func() {
+		loader.Load([]depth.Pair{"BTC-USD"}, start, start.Add(time.Minute))
+	})
+}
+
+func TestMarkPriceLoaderRateLimit(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the first two requests are rate limited
+		if atomic.AddInt32(&requests, 1) <= 2 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"code":-1003,"msg":"Too many requests."}`, http.StatusTooManyRequests)
+			return
+		}
+		fmt.Fprint(w, markPriceKlines)
+	}))
+	defer server.Close()
+
+	start := ParseOrDie("01-01-2020")
+	var progress bytes.Buffer
+	loader := depth.NewMarkPriceLoader(depth.MarketBinanceUsdsFutures, depth.WithPriceBaseURL(server.URL),
+		depth.WithPriceProgress(&progress), depth.WithPriceHTTPClient(server.Client()))
+	prices := loader.Load([]depth.Pair{"BTC-USDT"}, start, start.Add(7*time.Minute))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+	assert.Equal(t, 7195.24, prices["BTC-USDT"][2])
+	assert.Equal(t, 2, strings.Count(progress.String(), "Rate limited"))
+}
+
+func TestMarkPriceLoaderRateLimitRetries(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, `{"code":-1003,"msg":"Too many requests."}`, http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	// the retries of a server rate limiting every request are bounded
+	start := ParseOrDie("01-01-2020")
+	loader := depth.NewMarkPriceLoader(depth.MarketBinanceUsdsFutures, depth.WithPriceBaseURL(server.URL),
+		depth.WithPriceProgress(io.Discard), depth.WithPriceHTTPClient(server.Client()))
+	assert.Panics(t, func() {
+		loader.Load([]depth.Pair{"BTC-USDT"}, start, start.Add(7*time.Minute))
+	})
+	assert.Equal(t, int32(6), atomic.LoadInt32(&requests))
+	assert.Panics(t, func() {
+		depth.WithPriceHTTPClient(nil)
+	})
+}
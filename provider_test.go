@@ -0,0 +1,71 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+// archiveProvider serves the days of an in-memory archive, which has no data on its missing days.
+type archiveProvider struct {
+	mu      sync.Mutex
+	missing map[string]bool
+	fetched []string
+}
+
+func (p *archiveProvider) FetchDay(ctx context.Context, market depth.Market, pair depth.Pair, date time.Time) ([]depth.Record, error) {
+	p.mu.Lock()
+	p.fetched = append(p.fetched, string(market)+"/"+string(pair)+"/"+date.Format("2006-01-02"))
+	p.mu.Unlock()
+	if p.missing[date.Format("2006-01-02")] {
+		return nil, nil
+	}
+	if pair == "ERR-BUSD" {
+		return nil, errors.New("archive is down")
+	}
+	records := make([]depth.Record, 24*60)
+	for m := range records {
+		records[m] = depth.Record{BidPrice: float64(date.Day()), BidSize: 0.5, AskPrice: float64(date.Day()) + 0.25, AskSize: 2}
+	}
+	return records, nil
+}
+
+func TestProvider(t *testing.T) {
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-04-2022")
+	t.Cleanup(func() { _ = os.RemoveAll("data/provider") })
+	provider := &archiveProvider{missing: map[string]bool{"2022-01-02": true}}
+	newLoader := func() *depth.CCDepthLoader {
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithNamespace("provider"),
+			depth.WithProvider(provider), depth.WithAlignment(depth.AlignPad))
+	}
+	loader := newLoader()
+	records := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, records["BTC-BUSD"], 3*24*60*4)
+	assert.ElementsMatch(t, []string{"binance/BTC-BUSD/2022-01-01", "binance/BTC-BUSD/2022-01-02", "binance/BTC-BUSD/2022-01-03"}, provider.fetched)
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, []float64{1, 0.5, 1.25, 2}, []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize})
+
+	// the days are cached
+	provider.fetched = nil
+	records = newLoader().Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, records["BTC-BUSD"], 3*24*60*4)
+	assert.Equal(t, "3.25", records["BTC-BUSD"][2*24*60*4+2])
+	assert.Empty(t, provider.fetched)
+
+	// the missing day is found by the availability probe
+	assert.Equal(t, []depth.DateRange{{Start: start, End: start.AddDate(0, 0, 1)}, {Start: start.AddDate(0, 0, 2), End: end}},
+		newLoader().Availability("ETH-BUSD", start, end))
+
+	assert.PanicsWithError(t, "failed days: ERR-BUSD on 2022-01-01: archive is down", func() {
+		newLoader().Load([]depth.Pair{"ERR-BUSD"}, start, start.AddDate(0, 0, 1))
+	})
+	assert.Panics(t, func() {
+		depth.WithProvider(nil)
+	})
+}
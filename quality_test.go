@@ -0,0 +1,47 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestQualityReport(t *testing.T) {
+	// the second day has an hour of gaps, filled with the last record, and a crossed minute
+	var row strings.Builder
+	row.WriteString("#,BTC-BUSD\nBTC-BUSD")
+	for m := 0; m < 2*24*60-30; m++ {
+		switch {
+		case m >= 24*60+60 && m < 24*60+120:
+			row.WriteString(",100,1,101,1")
+		case m == 24*60+200:
+			row.WriteString(",102,1,101,1")
+		default:
+			row.WriteString(",100," + strings.Repeat("1", 1+m%2) + ",101,1")
+		}
+	}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.LoadFrom(strings.NewReader(row.String()+"\n"), ParseOrDie("01-01-2020"))
+
+	report := loader.Quality(nil)
+	assert.Len(t, report, 2)
+	assert.Equal(t, 24*60, report[0].Minutes)
+	assert.Equal(t, 0, report[0].Stale)
+	assert.Equal(t, int64(8*4*24*60), report[0].Bytes)
+	assert.Equal(t, loader.DayHash("BTC-BUSD", ParseOrDie("01-01-2020")), report[0].Hash)
+	assert.Equal(t, 30, report[1].Missing)
+	// the minute after the gaps has the same record too
+	assert.Equal(t, 60, report[1].Stale)
+	assert.Equal(t, 1, report[1].Crossed)
+	assert.Empty(t, report[1].Hash)
+
+	var out bytes.Buffer
+	assert.NoError(t, loader.WriteQualityReport(&out, nil))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Equal(t, "pair,day,minutes,missing,stale,crossed,bad,bytes,hash", lines[0])
+	assert.Equal(t, "BTC-BUSD,2020-01-02,1410,30,60,1,false,0,", lines[2])
+	assert.True(t, strings.HasPrefix(lines[1], "BTC-BUSD,2020-01-01,1440,0,0,0,false,46080,"+report[0].Hash))
+}
@@ -0,0 +1,26 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestLoadRaw(t *testing.T) {
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 2}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("raw-test"), depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	snapshots, err := loader.LoadRaw(context.Background(), "BTC-BUSD", ParseOrDie("01-01-2020"))
+	assert.NoError(t, err)
+	assert.Len(t, snapshots, 24*60)
+	assert.True(t, snapshots[2].Time.Equal(ParseOrDie("01-01-2020").Add(120e9)))
+	assert.Equal(t, 103.0, snapshots[2].Record.AskPrice)
+	assert.NoDirExists(t, "data/raw-test")
+
+	_, err = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithReadOnly(), depth.WithBaseURL(url)).
+		LoadRaw(context.Background(), "BTC-BUSD", ParseOrDie("01-01-2020"))
+	assert.ErrorIs(t, err, depth.ErrReadOnly)
+}
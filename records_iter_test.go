@@ -0,0 +1,42 @@
+//go:build go1.23
+
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestRecords(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithWarmup(time.Minute))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	minutes := 0
+	for ts, record := range loader.Records("BTC-BUSD") {
+		assert.Equal(t, start.Add(time.Duration(minutes+1)*time.Minute), ts)
+		assert.Equal(t, float64(102+minutes), record.AskPrice)
+		minutes++
+	}
+	assert.Equal(t, 24*60-1, minutes)
+
+	// the loop can stop early, and the cursor of the loader doesn't move
+	minutes = 0
+	for range loader.Records("BTC-BUSD") {
+		minutes++
+		if minutes == 10 {
+			break
+		}
+	}
+	assert.Equal(t, 10, minutes)
+	assert.Equal(t, start.Add(time.Minute), loader.CurrentTime())
+	for range loader.Records("ETH-BUSD") {
+		t.Fatal("ETH-BUSD is not loaded")
+	}
+}
@@ -0,0 +1,19 @@
+module github.com/bogdantimes/order-book-depth-loader/redisdepth
+
+go 1.19
+
+require (
+	github.com/alicebob/miniredis/v2 v2.33.0
+	github.com/bogdantimes/order-book-depth-loader v0.1.0
+	github.com/redis/go-redis/v9 v9.7.0
+	github.com/stretchr/testify v1.12.1
+)
+
+require (
+	github.com/alicebob/gopher-json v0.0.0-20200520072559-a9ecdc9d1d3a // indirect
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/life4/genesis v1.1.0 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
+	go.yaml.in/yaml/v3 v3.0.5 // indirect
+)
@@ -0,0 +1,49 @@
+package redisdepth_test
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/bogdantimes/order-book-depth-loader/redisdepth"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRedisSink(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4,104,5,105,6\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+	ctx := context.Background()
+
+	subscription := client.Subscribe(ctx, redisdepth.Key("BTC-BUSD"))
+	defer subscription.Close()
+	_, err := subscription.Receive(ctx)
+	assert.NoError(t, err)
+
+	sink := redisdepth.NewSink(client, 2)
+	assert.NoError(t, redisdepth.Replay(ctx, loader, []depth.Pair{"BTC-BUSD"}, sink))
+
+	latest, err := client.LRange(ctx, redisdepth.Key("BTC-BUSD"), 0, -1).Result()
+	assert.NoError(t, err)
+	assert.Len(t, latest, 2)
+	var tick redisdepth.Tick
+	assert.NoError(t, json.Unmarshal([]byte(latest[0]), &tick))
+	assert.Equal(t, depth.Pair("BTC-BUSD"), tick.Pair)
+	assert.Equal(t, 104.0, tick.BidPrice)
+	assert.True(t, time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC).Add(2*time.Minute).Equal(tick.Time))
+
+	for _, bid := range []float64{100, 102, 104} {
+		message, err := subscription.ReceiveMessage(ctx)
+		assert.NoError(t, err)
+		assert.NoError(t, json.Unmarshal([]byte(message.Payload), &tick))
+		assert.Equal(t, bid, tick.BidPrice)
+	}
+}
@@ -0,0 +1,96 @@
+// Package redisdepth writes the depth ticks into Redis, keeping the latest minutes of each pair
+// in a list and publishing every tick on a channel, so that Redis-based trading infrastructure
+// can consume a replay the same way it consumes live data.
+package redisdepth
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/redis/go-redis/v9"
+	"sort"
+	"time"
+)
+
+// Prefix is the prefix of the list keys and channels, followed by the pair, e.g. depth:BTC-BUSD.
+const Prefix = "depth:"
+
+// Tick is the JSON message stored and published for a pair at a minute:
+//
+//	{"time":"2022-11-24T00:00:00Z","pair":"BTC-BUSD","bid_price":16544.2,"bid_size":0.5,"ask_price":16544.3,"ask_size":1.2}
+type Tick struct {
+	Time time.Time  `json:"time"`
+	Pair depth.Pair `json:"pair"`
+	depth.Record
+}
+
+// Sink writes ticks into Redis.
+type Sink struct {
+	client redis.UniversalClient
+	keep   int64
+}
+
+// NewSink returns a sink keeping the latest keep minutes of each pair.
+func NewSink(client redis.UniversalClient, keep int) *Sink {
+	return &Sink{client: client, keep: int64(keep)}
+}
+
+// Key returns the key of the list and the name of the channel of the pair.
+func Key(pair depth.Pair) string {
+	return Prefix + pair.String()
+}
+
+// Write pushes the records of the snapshot to the head of the pair lists, trims the lists
+// to the latest minutes, and publishes the records on the pair channels.
+func (s *Sink) Write(ctx context.Context, snapshot depth.TickSnapshot) error {
+	pairs := make([]depth.Pair, 0, len(snapshot.Records))
+	for pair := range snapshot.Records {
+		pairs = append(pairs, pair)
+	}
+	sort.Slice(pairs, func(i, j int) bool { return pairs[i] < pairs[j] })
+
+	pipe := s.client.Pipeline()
+	for _, pair := range pairs {
+		message, err := json.Marshal(Tick{Time: snapshot.Time, Pair: pair, Record: snapshot.Records[pair]})
+		if err != nil {
+			return err
+		}
+		key := Key(pair)
+		pipe.LPush(ctx, key, message)
+		pipe.LTrim(ctx, key, 0, s.keep-1)
+		pipe.Publish(ctx, key, message)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Replay writes the loaded records of the given pairs to the sink, one minute at a time,
+// and returns when it is done or the context is cancelled.
+func Replay(ctx context.Context, loader depth.ColumnSource, pairs []depth.Pair, sink *Sink) error {
+	columns := make([]depth.Columns, len(pairs))
+	length := 0
+	for i, pair := range pairs {
+		columns[i] = loader.Columns(pair)
+		if columns[i].Len() > length {
+			length = columns[i].Len()
+		}
+	}
+	for minute := 0; minute < length; minute++ {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		snapshot := depth.TickSnapshot{Records: make(map[depth.Pair]depth.Record, len(pairs))}
+		for i, pair := range pairs {
+			c := columns[i]
+			if minute >= c.Len() {
+				continue
+			}
+			snapshot.Time = c.Time[minute].UTC()
+			snapshot.Records[pair] = depth.Record{BidPrice: c.BidPrice[minute], BidSize: c.BidSize[minute], AskPrice: c.AskPrice[minute], AskSize: c.AskSize[minute]}
+		}
+		if err := sink.Write(ctx, snapshot); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemoteStore(t *testing.T) {
+	var downloads int64
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if minute == 0 {
+			atomic.AddInt64(&downloads, 1)
+		}
+		// BTC-BUSD has no data on 2020-01-02
+		if day.Day() == 2 {
+			return Quote{}, false
+		}
+		return Quote{float64(100 * day.Day()), 1, float64(100*day.Day() + minute), 1}, true
+	})
+	t.Cleanup(func() {
+		for _, dir := range []string{"data/remote-test", "data/remote-test-a", "data/remote-test-b"} {
+			_ = os.RemoveAll(dir)
+		}
+	})
+	central := httptest.NewServer(http.StripPrefix("/binance", depth.StoreHandler(depth.NewDayFileStore("data/remote-test/central/binance"))))
+	t.Cleanup(central.Close)
+	newWorker := func(name string) *depth.CCDepthLoader {
+		store := depth.NewRemoteStore(central.URL+"/binance", depth.NewDayFileStore("data/remote-test-"+name+"/binance"), central.Client())
+		return depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("remote-test-"+name), depth.WithStore(store),
+			depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	}
+	pairs := []depth.Pair{"BTC-BUSD"}
+
+	// the first worker downloads the days from the vendor, and uploads them
+	result := newWorker("a").Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+	assert.Equal(t, int64(2), downloads)
+	assert.FileExists(t, "data/remote-test/central/binance/BTC-BUSD/2020-01-01.csv.gz")
+
+	// the second one fetches them from the central store, the day without data included
+	loader := newWorker("b")
+	result = loader.Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Equal(t, int64(3), downloads)
+	assert.FileExists(t, "data/remote-test-b/binance/BTC-BUSD/2020-01-01.csv.gz")
+	loader.Tick()
+	assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	// the first one only fetches the day downloaded by the second one
+	newWorker("a").Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020"))
+	assert.Equal(t, int64(3), downloads)
+
+	resp, err := http.Get(central.URL + "/binance/ETH-USDT/2020-01-01?fields=bid_price,bid_size,ask_price,ask_size")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+	_ = resp.Body.Close()
+	resp, err = http.Get(central.URL + "/binance/BTC-BUSD/2020-01-01?fields=mid")
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusConflict, resp.StatusCode)
+	_ = resp.Body.Close()
+	req, _ := http.NewRequest(http.MethodPut, central.URL+"/binance/BTC-BUSD/2020-01-05?fields=mid", strings.NewReader("1,2\n"))
+	resp, err = http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+	assert.NotEqual(t, http.StatusNoContent, resp.StatusCode)
+	_ = resp.Body.Close()
+}
@@ -0,0 +1,65 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenamePair(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		if pair == "BTC-BUSD" {
+			return Quote{100, 1, 101, 1}
+		}
+		return Quote{10, 1, 11, 1}
+	})
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+		_ = os.Remove(path + ".versions")
+		_ = os.RemoveAll("data/binance/blocks")
+		_ = os.Remove("data/binance/2020-01-01_2020-01-02_depth.blocks")
+		_ = os.Remove("data/binance/tombstones.csv")
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	loader.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithBlocks()).
+		Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.NoError(t, loader.MarkBad("BTC-BUSD", start, "outage"))
+
+	assert.NoError(t, loader.RenamePair("BTC-BUSD", "BTC-FDUSD"))
+	assert.Equal(t, 100.0, loader.GetDepth("BTC-FDUSD").BidPrice)
+	assert.NoError(t, loader.Verify(start, end))
+	versions, err := loader.Versions(start, end)
+	assert.NoError(t, err)
+	assert.Equal(t, []depth.Pair{"BTC-FDUSD"}, versions[0].Pairs)
+	tombstones, err := loader.Tombstones()
+	assert.NoError(t, err)
+	assert.Equal(t, depth.Pair("BTC-FDUSD"), tombstones[0].Pair)
+	assert.NoError(t, loader.Restore("BTC-FDUSD", start))
+
+	// the renamed pair is read from the file, the index and the manifest, without downloading it again
+	for _, loader := range []*depth.CCDepthLoader{
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly()),
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly(), depth.WithBlocks()),
+	} {
+		result := loader.Load([]depth.Pair{"BTC-FDUSD"}, start, end)
+		assert.Len(t, result["BTC-FDUSD"], 24*60*4)
+		assert.Equal(t, 100.0, loader.GetDepth("BTC-FDUSD").BidPrice)
+	}
+	versioned := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithVersion(2))
+	assert.Equal(t, "10", versioned.Load([]depth.Pair{"ETH-BUSD"}, start, end)["ETH-BUSD"][0])
+	_, err = os.Stat(filepath.Join("data/binance", "2020-01-01_2020-01-02_depth.csv.tmp"))
+	assert.True(t, os.IsNotExist(err))
+
+	// a pair can't be renamed to a pair with data
+	assert.Error(t, loader.RenamePair("ETH-BUSD", "BTC-FDUSD"))
+	assert.ErrorIs(t, depth.NewCCDepthLoader(depth.MarketBinance, depth.WithReadOnly()).RenamePair("ETH-BUSD", "ETH-USDT"), depth.ErrReadOnly)
+}
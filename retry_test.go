@@ -0,0 +1,63 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestRetryFailed(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, true
+	})
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020")
+	for name, opts := range map[string][]depth.Option{
+		"files":    nil,
+		"dayfiles": {depth.WithDayFiles()},
+	} {
+		t.Run(name, func(t *testing.T) {
+			t.Cleanup(func() { _ = os.RemoveAll("data/retry-test") })
+			var mu sync.Mutex
+			downloads := map[string]int{}
+			// the second day of ETH-USDT fails the first time only
+			hook := depth.WithDownloadHook(func(pair depth.Pair, date time.Time, records []depth.Record) error {
+				mu.Lock()
+				defer mu.Unlock()
+				key := pair.String() + " " + date.Format("2006-01-02")
+				downloads[key]++
+				if key == "ETH-USDT 2020-01-02" && downloads[key] == 1 {
+					return errors.New("archive is down")
+				}
+				return nil
+			})
+			opts := append([]depth.Option{depth.WithNamespace("retry-test"), depth.WithProgress(io.Discard),
+				depth.WithBaseURL(url), hook}, opts...)
+			loader := depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+
+			records, err := loader.LoadContext(context.Background(), []depth.Pair{"BTC-BUSD", "ETH-USDT"}, start, end)
+			var failed *depth.FailedDaysError
+			assert.True(t, errors.As(err, &failed))
+			assert.Len(t, failed.Failures, 1)
+			assert.Equal(t, depth.Pair("ETH-USDT"), failed.Failures[0].Pair)
+			assert.True(t, failed.Failures[0].Day.Equal(start.AddDate(0, 0, 1)))
+			assert.Equal(t, []depth.Pair{"ETH-USDT"}, failed.Pairs())
+			assert.Len(t, records["BTC-BUSD"], 3*24*60*4)
+			assert.Empty(t, records["ETH-USDT"])
+
+			records, err = failed.RetryFailed(context.Background())
+			assert.NoError(t, err)
+			assert.Len(t, records["ETH-USDT"], 3*24*60*4)
+			assert.Equal(t, map[string]int{
+				"BTC-BUSD 2020-01-01": 1, "BTC-BUSD 2020-01-02": 1, "BTC-BUSD 2020-01-03": 1,
+				"ETH-USDT 2020-01-01": 1, "ETH-USDT 2020-01-02": 2, "ETH-USDT 2020-01-03": 1,
+			}, downloads)
+			assert.Equal(t, 101.0, loader.GetDepth("ETH-USDT").AskPrice)
+		})
+	}
+}
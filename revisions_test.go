@@ -0,0 +1,42 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestDetectRevisions(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-04-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		// the vendor revised the second day since it was cached
+		if minute >= 24*60 && minute < 2*24*60 {
+			return Quote{99, 1, 101, 1}
+		}
+		return Quote{100, 1, 101, 1}
+	})
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	revisions := loader.DetectRevisions(nil, 0)
+	assert.Len(t, revisions, 1)
+	assert.True(t, ParseOrDie("01-02-2020").Equal(revisions[0].Day))
+	assert.Equal(t, loader.DayHash("BTC-BUSD", ParseOrDie("01-02-2020")), revisions[0].CachedHash)
+	assert.Equal(t, loader.DayHash("BTC-BUSD", ParseOrDie("01-01-2020")), revisions[0].VendorHash)
+	assert.NotEqual(t, revisions[0].CachedHash, revisions[0].VendorHash)
+
+	// the sample checks the first and second days of the three
+	assert.Len(t, loader.DetectRevisions(nil, 2), 1)
+	assert.Empty(t, loader.DetectRevisions(nil, 1))
+	assert.Empty(t, loader.DayHash("BTC-BUSD", end))
+
+	// the hash doesn't depend on where the values are kept
+	offHeap := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithOffHeap())
+	defer offHeap.Close()
+	offHeap.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, loader.DayHash("BTC-BUSD", start), offHeap.DayHash("BTC-BUSD", start))
+}
@@ -0,0 +1,97 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunLengths(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	// the book changes once an hour
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100 + float64(minute/60), 1, 101 + float64(minute/60), 1}
+	})
+	t.Cleanup(func() {
+		_ = os.Remove(path)
+		_ = os.Remove(path + ".idx")
+		_ = os.Remove(path + ".versions")
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithRunLengths())
+	result := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	// only the changed minutes are kept
+	assert.Len(t, result["BTC-BUSD"], 24*4)
+	assert.Equal(t, 24*60, loader.Footprints()["BTC-BUSD"].Minutes)
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "\nBTC-BUSD,100,1,101,1,*59,101,1,102,1,*59,")
+	assert.Less(t, len(content), 24*30)
+
+	// any loader reads the runs, and expands them
+	for _, loader := range []*depth.CCDepthLoader{
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard)),
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithOffHeap()),
+	} {
+		loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+		for i := 0; i < 61; i++ {
+			loader.Tick()
+		}
+		assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").BidPrice)
+
+		var out bytes.Buffer
+		assert.NoError(t, loader.Export(&out, nil))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		assert.Len(t, lines, 24*60+1)
+		assert.Equal(t, "2020-01-01T23:59:00Z,BTC-BUSD,123,1,124,1", lines[24*60])
+		assert.NoError(t, loader.Close())
+	}
+
+	assert.Panics(t, func() {
+		depth.NewCCDepthLoader(depth.MarketBinance).LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,100,1,101,*2,1\n"), start)
+	})
+}
+
+func TestNextChange(t *testing.T) {
+	// BTC-BUSD changes at the minute 2, ETH-BUSD at the minute 3
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,1,*1,102,1,103,1,*2\nETH-BUSD,10,1,11,1,10,1,11,1,10,1,11,1,12,1,13,1,12,1,13,1\n"
+	for _, loader := range []*depth.CCDepthLoader{
+		depth.NewCCDepthLoader(depth.MarketBinance),
+		depth.NewCCDepthLoader(depth.MarketBinance, depth.WithOffHeap()),
+	} {
+		loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+		assert.True(t, loader.NextChange())
+		assert.Equal(t, 102.0, loader.GetDepth("BTC-BUSD").BidPrice)
+		assert.Equal(t, 10.0, loader.GetDepth("ETH-BUSD").BidPrice)
+		assert.True(t, loader.NextChange())
+		assert.Equal(t, 12.0, loader.GetDepth("ETH-BUSD").BidPrice)
+		assert.False(t, loader.NextChange())
+		assert.NoError(t, loader.Close())
+	}
+}
+
+func TestMoveThreshold(t *testing.T) {
+	// the bid moves by 0.5, then by 1 since the minute 0, then the ask size by 2
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,1,100.5,1,101,1,101,1,101,1,101,1,101,3\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithMoveThreshold(1, 2))
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.True(t, loader.NextChange())
+	assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").BidPrice)
+	assert.True(t, loader.NextChange())
+	assert.Equal(t, 3.0, loader.GetDepth("BTC-BUSD").AskSize)
+	assert.False(t, loader.NextChange())
+
+	// a price threshold only ignores the size moves
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithMoveThreshold(0.5, 0))
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.True(t, loader.NextChange())
+	assert.True(t, loader.NextChange())
+	assert.False(t, loader.NextChange())
+
+	assert.Panics(t, func() { depth.WithMoveThreshold(-1, 0) })
+}
@@ -0,0 +1,56 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestSampler(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/csv" {
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}],"expiration":"300 seconds"}`, server.URL+"/csv")
+			return
+		}
+		day := ParseOrDie("01-01-2020").Unix()
+		gz := gzip.NewWriter(w)
+		_, _ = fmt.Fprintln(gz, "time_seconds,bid_price_bid_size,ask_price_ask_size")
+		for m := 0; m < 24*60; m++ {
+			for _, second := range []int{0, 20, 40} {
+				// the minute 5 has no snapshot at its start, and the minute 7 none at all
+				if m == 7 || m == 5 && second == 0 {
+					continue
+				}
+				_, _ = fmt.Fprintf(gz, "%d,%d_1,%d_1\n", day+int64(m*60+second), 100*(1+second/20)+m, 1000+m)
+			}
+		}
+		_ = gz.Close()
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() {
+		_ = os.RemoveAll("data/sampler-snapshot")
+		_ = os.RemoveAll("data/sampler-median")
+	})
+	load := func(namespace string, sampler depth.Sampler) []float64 {
+		loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace(namespace), depth.WithSampler(sampler),
+			depth.WithProgress(io.Discard), depth.WithBaseURL(server.URL))
+		loader.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"))
+		var bids []float64
+		for m := 0; m < 9; m++ {
+			bids = append(bids, loader.GetDepth("BTC-BUSD").BidPrice)
+			loader.Tick()
+		}
+		return bids
+	}
+
+	assert.Equal(t, []float64{100, 101, 102, 103, 104, 104, 106, 106, 108}, load("sampler-snapshot", depth.SnapshotSampler))
+	assert.Equal(t, []float64{200, 201, 202, 203, 204, 255, 206, 206, 208}, load("sampler-median", depth.MedianSampler))
+	assert.Panics(t, func() { depth.WithSampler(nil) })
+}
@@ -0,0 +1,68 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestSchema(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	path := "data/binance/2020-01-01_2020-01-02_depth.csv"
+	content := "#,BTC-BUSD\n#fields,mid,spread\nBTC-BUSD" + strings.Repeat(",100,2", 24*60) + "\n"
+	assert.NoError(t, os.MkdirAll("data/binance", 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithSchema(depth.FieldMid, depth.FieldSpread))
+	result := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, result["BTC-BUSD"], 24*60*2)
+
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, 99.0, record.BidPrice)
+	assert.Equal(t, 101.0, record.AskPrice)
+	assert.Equal(t, 100.0, record.Mid())
+
+	assert.Panics(t, func() {
+		depth.NewCCDepthLoader(depth.MarketBinance).Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	})
+}
+
+func TestSchemaValidate(t *testing.T) {
+	assert.NoError(t, depth.DefaultSchema.Validate())
+	assert.NoError(t, depth.Schema{depth.FieldMid, depth.FieldBidSize}.Validate())
+	assert.EqualError(t, depth.Schema{}.Validate(), "the schema has no fields")
+	assert.EqualError(t, depth.Schema{depth.FieldMid, "vwap"}.Validate(), "unknown schema field #1: vwap")
+	assert.EqualError(t, depth.Schema{depth.FieldMid, depth.FieldMid}.Validate(), "duplicate schema field: mid")
+
+	// the option fails before anything is written
+	assert.Panics(t, func() {
+		depth.WithSchema()
+	})
+	assert.Panics(t, func() {
+		depth.WithSchema(depth.FieldMid, "vwap")
+	})
+}
+
+func TestSchemaLongLines(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-08-2020")
+	path := "data/binance/2020-01-01_2020-01-08_depth.csv"
+	// the header and the rows are longer than the 64 KB lines of a bufio.Scanner
+	var pairs strings.Builder
+	for i := 0; i < 10000; i++ {
+		pairs.WriteString(",P" + strings.Repeat("X", i%5) + "-BUSD")
+	}
+	content := "#" + pairs.String() + ",BTC-BUSD\n#fields,mid,spread\nBTC-BUSD" + strings.Repeat(",100,2", 7*24*60) + "\n"
+	assert.NoError(t, os.MkdirAll("data/binance", 0755))
+	assert.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	defer os.Remove(path)
+	defer os.Remove(path + ".idx")
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithSchema(depth.FieldMid, depth.FieldSpread), depth.WithReadOnly())
+	result := loader.Load(nil, start, end)
+	assert.Len(t, result["BTC-BUSD"], 7*24*60*2)
+	assert.Equal(t, 99.0, loader.GetDepth("BTC-BUSD").BidPrice)
+}
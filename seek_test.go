@@ -0,0 +1,52 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestSeek(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	var fed []int
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard),
+		depth.WithConsumer(func(pair depth.Pair, minute int, record depth.Record) {
+			fed = append(fed, minute)
+		}))
+	assert.ErrorIs(t, loader.Seek(start), depth.ErrOutOfRange)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, []int{0}, fed)
+
+	// the seconds of the time are those of its minute
+	at := start.Add(25*time.Hour + 3*time.Minute)
+	assert.NoError(t, loader.Seek(at.Add(30*time.Second)))
+	assert.Equal(t, at, loader.CurrentTime())
+	assert.Equal(t, float64(101+25*60+3), loader.GetDepth("BTC-BUSD").AskPrice)
+	assert.Len(t, fed, 25*60+4)
+	loader.Tick()
+	assert.Equal(t, float64(101+25*60+4), loader.GetDepth("BTC-BUSD").AskPrice)
+
+	// moving back feeds the consumers no minutes
+	assert.NoError(t, loader.Seek(start.Add(time.Hour)))
+	assert.Equal(t, float64(101+60), loader.GetDepth("BTC-BUSD").AskPrice)
+	assert.Len(t, fed, 25*60+5)
+
+	record, err := loader.GetDepthAt("BTC-BUSD", end.Add(-time.Minute))
+	assert.NoError(t, err)
+	assert.Equal(t, float64(101+2*24*60-1), record.AskPrice)
+	assert.Equal(t, end.Add(-time.Minute), record.Time)
+	assert.Equal(t, start.Add(time.Hour), loader.CurrentTime())
+
+	assert.ErrorIs(t, loader.Seek(end), depth.ErrOutOfRange)
+	assert.ErrorIs(t, loader.Seek(start.Add(-time.Minute)), depth.ErrOutOfRange)
+	_, err = loader.GetDepthAt("BTC-BUSD", end)
+	assert.ErrorIs(t, err, depth.ErrOutOfRange)
+	_, err = loader.GetDepthAt("ETH-BUSD", start)
+	assert.ErrorIs(t, err, depth.ErrOutOfRange)
+	assert.Equal(t, start.Add(time.Hour), loader.CurrentTime())
+}
@@ -0,0 +1,78 @@
+package order_book_depth_loader_test
+
+import (
+	"errors"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// assertCorrupted checks that the load panics with ErrCorrupted.
+func assertCorrupted(t *testing.T, load func()) {
+	defer func() {
+		err, _ := recover().(error)
+		assert.True(t, errors.Is(err, depth.ErrCorrupted), "expected a corrupted file, got %v", err)
+	}()
+	load()
+}
+
+func TestSelfCheck(t *testing.T) {
+	start, end := ParseOrDie("01-01-2021"), ParseOrDie("01-02-2021")
+	path := "data/binance/2021-01-01_2021-01-02_depth.csv"
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(depth.Pair, int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	// a value of the row of ETH-BUSD, which is not parsed when only BTC-BUSD is loaded
+	assert.NoError(t, os.WriteFile(path, []byte(strings.Replace(string(content), "ETH-BUSD,100", "ETH-BUSD,1x0", 1)), 0644))
+
+	load := func(level depth.SelfCheck) func() {
+		return func() {
+			depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly(), depth.WithSelfCheck(level)).
+				Load([]depth.Pair{"BTC-BUSD"}, start, end)
+		}
+	}
+	assert.NotPanics(t, load(depth.SelfCheckNone))
+	assert.NotPanics(t, load(depth.SelfCheckManifest))
+	assertCorrupted(t, load(depth.SelfCheckFull))
+
+	// a file truncated below its last version
+	assert.NoError(t, os.WriteFile(path, content, 0644))
+	assert.NoError(t, os.WriteFile(path+".versions", []byte("1,"+strconv.Itoa(len(content)+100)+",2021-01-02T00:00:00Z,BTC-BUSD:1440;ETH-BUSD:1440\n"), 0644))
+	assert.NotPanics(t, load(depth.SelfCheckNone))
+	assertCorrupted(t, load(depth.SelfCheckManifest))
+	assertCorrupted(t, load(depth.SelfCheckFull))
+
+	assert.Panics(t, func() {
+		depth.WithSelfCheck("paranoid")
+	})
+}
+
+func TestSelfCheckBlocks(t *testing.T) {
+	start, end := ParseOrDie("01-01-2021"), ParseOrDie("01-02-2021")
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/selfcheck") })
+	load := func(level depth.SelfCheck) func() {
+		return func() {
+			depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url),
+				depth.WithNamespace("selfcheck"), depth.WithBlocks(), depth.WithSelfCheck(level)).
+				Load([]depth.Pair{"BTC-BUSD"}, start, end)
+		}
+	}
+	assert.NotPanics(t, load(depth.SelfCheckFull))
+
+	blocks, err := filepath.Glob("data/selfcheck/binance/blocks/*/*")
+	assert.NoError(t, err)
+	assert.Len(t, blocks, 1)
+	assert.NoError(t, os.WriteFile(blocks[0], []byte("100,1,102,1"), 0644))
+	assert.NotPanics(t, load(depth.SelfCheckManifest))
+	assertCorrupted(t, load(depth.SelfCheckFull))
+}
@@ -0,0 +1,80 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestSeries(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(minute), 1, float64(minute) + 2, 3}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance,
+		depth.WithSeries("mid", depth.Record.Mid),
+		depth.WithSeries("imbalance", depth.Record.Imbalance),
+	)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	mid := loader.Series("mid", "BTC-BUSD")
+	assert.Len(t, mid, 24*60)
+	assert.Equal(t, 1.0, mid[0])
+	assert.Equal(t, 11.0, mid[10])
+	assert.Equal(t, -0.5, loader.Series("imbalance", "BTC-BUSD")[0])
+	assert.Nil(t, loader.Series("mid", "ETH-BUSD"))
+	assert.Panics(t, func() {
+		loader.Series("spread", "BTC-BUSD")
+	})
+
+	loader.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	assert.Len(t, loader.Series("mid", "ETH-BUSD"), 24*60)
+}
+
+func TestSeriesOnly(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(minute), 1, float64(minute) + 2, 3}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard),
+		depth.WithSeries("mid", depth.Record.Mid), depth.WithSeriesOnly())
+	result := loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Len(t, result["BTC-BUSD"], 24*60*4)
+	assert.Panics(t, func() {
+		loader.GetDepth("BTC-BUSD")
+	})
+
+	// the series of the previous loads are kept, and only the series are in memory
+	result = loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+	assert.Len(t, result, 1)
+	assert.Equal(t, 11.0, loader.Series("mid", "BTC-BUSD")[10])
+	assert.Equal(t, 11.0, loader.Series("mid", "ETH-BUSD")[10])
+	footprint := loader.Footprints()["ETH-BUSD"]
+	assert.Equal(t, 24*60, footprint.Minutes)
+	assert.Equal(t, 0, footprint.Values)
+	assert.Equal(t, int64(24+8*24*60), footprint.Bytes)
+}
+
+func TestFairPrice(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	// the bid has 3 times the size of the ask, so that the imbalance is 0.5
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 3, 104, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithFairPrice(0.5))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	fair := loader.Series(depth.FairPriceSeries, "BTC-BUSD")
+	assert.Len(t, fair, 24*60)
+	assert.Equal(t, 102.5, fair[0])
+
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, 103.0, record.FairPrice(1))
+	assert.Equal(t, record.Mid(), record.FairPrice(0))
+	filter, err := depth.ParseFilter("microprice > 102.9")
+	assert.NoError(t, err)
+	assert.True(t, filter(record))
+}
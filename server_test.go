@@ -0,0 +1,239 @@
+package order_book_depth_loader_test
+
+import (
+	"bufio"
+	"encoding/json"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestServeUnixSocket(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	socket := filepath.Join(t.TempDir(), "depth.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- loader.Serve(listener, nil)
+	}()
+
+	for client := 0; client < 2; client++ {
+		conn, err := net.Dial("unix", socket)
+		assert.NoError(t, err)
+		scanner := bufio.NewScanner(conn)
+		var snapshots []depth.TickSnapshot
+		for scanner.Scan() {
+			var snapshot depth.TickSnapshot
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &snapshot))
+			snapshots = append(snapshots, snapshot)
+		}
+		_ = conn.Close()
+
+		assert.Len(t, snapshots, 2)
+		assert.Equal(t, 102.0, snapshots[1].Records["BTC-BUSD"].BidPrice)
+		assert.Equal(t, 11.0, snapshots[0].Records["ETH-BUSD"].AskPrice)
+		assert.Equal(t, 1, snapshots[1].Time.Minute())
+	}
+
+	assert.NoError(t, listener.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestServeStep(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	socket := filepath.Join(t.TempDir(), "depth.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- loader.Serve(listener, nil, depth.WithStep())
+	}()
+
+	conn, err := net.Dial("unix", socket)
+	assert.NoError(t, err)
+	reader := bufio.NewReader(conn)
+	for minute := 0; minute < 2; minute++ {
+		// each snapshot is written only when requested
+		_, err = conn.Write([]byte("\n"))
+		assert.NoError(t, err)
+		line, err := reader.ReadBytes('\n')
+		assert.NoError(t, err)
+		var snapshot depth.TickSnapshot
+		assert.NoError(t, json.Unmarshal(line, &snapshot))
+		assert.Equal(t, minute, snapshot.Time.Minute())
+	}
+	_ = conn.Close()
+
+	assert.NoError(t, listener.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestServeChangesOnly(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2,*2,102,3,103,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	socket := filepath.Join(t.TempDir(), "depth.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- loader.Serve(listener, nil, depth.WithChangesOnly())
+	}()
+
+	conn, err := net.Dial("unix", socket)
+	assert.NoError(t, err)
+	scanner := bufio.NewScanner(conn)
+	var minutes []int
+	for scanner.Scan() {
+		var snapshot depth.TickSnapshot
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &snapshot))
+		minutes = append(minutes, snapshot.Time.Minute())
+	}
+	_ = conn.Close()
+	// the unchanged minutes 1 and 2 are skipped
+	assert.Equal(t, []int{0, 3}, minutes)
+
+	assert.NoError(t, listener.Close())
+	assert.NoError(t, <-done)
+}
+
+func TestServeBuffer(t *testing.T) {
+	// the replay is larger than the socket buffers, so that a client not reading makes the buffer overflow
+	minutes := 20000
+	input := "#,BTC-BUSD\nBTC-BUSD" + strings.Repeat(",100,1,101,2", minutes) + "\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	for _, overflow := range []depth.Overflow{depth.Park, depth.DropNewest, depth.DropOldest} {
+		socket := filepath.Join(t.TempDir(), "depth.sock")
+		listener, err := net.Listen("unix", socket)
+		assert.NoError(t, err)
+		done := make(chan error)
+		go func() {
+			done <- loader.Serve(listener, nil, depth.WithBuffer(10, overflow))
+		}()
+
+		conn, err := net.Dial("unix", socket)
+		assert.NoError(t, err)
+		time.Sleep(200 * time.Millisecond)
+		scanner := bufio.NewScanner(conn)
+		received, dropped := 0, 0
+		var last depth.TickSnapshot
+		for scanner.Scan() {
+			last = depth.TickSnapshot{}
+			assert.NoError(t, json.Unmarshal(scanner.Bytes(), &last))
+			received++
+			dropped += last.Dropped
+		}
+		_ = conn.Close()
+
+		// each snapshot is either received, or counted as dropped
+		assert.Equal(t, minutes, received+dropped, overflow)
+		if overflow == depth.Park {
+			assert.Equal(t, 0, dropped)
+		} else {
+			assert.Greater(t, dropped, 0, overflow)
+		}
+		if overflow == depth.DropOldest {
+			// the last snapshot is never dropped
+			assert.Equal(t, minutes-1, int(last.Time.Sub(ParseOrDie("01-01-2020")).Minutes()))
+		}
+		assert.NoError(t, listener.Close())
+		assert.NoError(t, <-done)
+	}
+
+	assert.Panics(t, func() { depth.WithBuffer(0, depth.Park) })
+	assert.Panics(t, func() { depth.WithBuffer(1, "spill") })
+}
+
+func TestServeLatency(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD" + strings.Repeat(",100,1,101,2", 5) + "\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	socket := filepath.Join(t.TempDir(), "depth.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	done := make(chan error)
+	go func() {
+		done <- loader.Serve(listener, nil, depth.WithPace(20*time.Millisecond), depth.WithLatency(200*time.Millisecond, 10*time.Millisecond, 1))
+	}()
+
+	start := time.Now()
+	conn, err := net.Dial("unix", socket)
+	assert.NoError(t, err)
+	scanner := bufio.NewScanner(conn)
+	var arrivals []time.Duration
+	for minute := 0; scanner.Scan(); minute++ {
+		arrivals = append(arrivals, time.Since(start))
+		var snapshot depth.TickSnapshot
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &snapshot))
+		assert.Equal(t, minute, snapshot.Time.Minute())
+	}
+	_ = conn.Close()
+
+	// each minute arrives late, but the latency doesn't slow down the pace of the replay
+	if assert.Len(t, arrivals, 5) {
+		assert.GreaterOrEqual(t, arrivals[0], 200*time.Millisecond)
+		assert.Less(t, arrivals[4]-arrivals[0], 4*200*time.Millisecond)
+		assert.GreaterOrEqual(t, arrivals[4]-arrivals[0], 4*20*time.Millisecond-10*time.Millisecond)
+	}
+	assert.NoError(t, listener.Close())
+	assert.NoError(t, <-done)
+
+	assert.Panics(t, func() { depth.WithLatency(-time.Millisecond, 0, 1) })
+}
+
+func TestServeTimeSource(t *testing.T) {
+	input := "#,BTC-BUSD\nBTC-BUSD" + strings.Repeat(",100,1,101,2", 3) + "\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+
+	socket := filepath.Join(t.TempDir(), "depth.sock")
+	listener, err := net.Listen("unix", socket)
+	assert.NoError(t, err)
+	source := depth.NewManualTime(ParseOrDie("01-01-2020"))
+	done := make(chan error)
+	go func() {
+		done <- loader.Serve(listener, nil, depth.WithPace(time.Hour), depth.WithTimeSource(source))
+	}()
+
+	conn, err := net.Dial("unix", socket)
+	assert.NoError(t, err)
+	scanner := bufio.NewScanner(conn)
+	// each snapshot after the first one waits for the source to be advanced by the pace
+	for minute := 0; minute < 3; minute++ {
+		if minute > 0 {
+			for source.Waiters() == 0 {
+				time.Sleep(time.Millisecond)
+			}
+			source.Advance(time.Hour)
+		}
+		assert.True(t, scanner.Scan())
+		var snapshot depth.TickSnapshot
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &snapshot))
+		assert.Equal(t, minute, snapshot.Time.Minute())
+	}
+	assert.False(t, scanner.Scan())
+	_ = conn.Close()
+
+	assert.NoError(t, listener.Close())
+	assert.NoError(t, <-done)
+
+	accelerated := depth.NewAcceleratedTime(ParseOrDie("01-01-2020"), 3600)
+	<-accelerated.After(time.Hour)
+	assert.False(t, accelerated.Now().Before(ParseOrDie("01-01-2020").Add(time.Hour)))
+	assert.Panics(t, func() { depth.NewAcceleratedTime(time.Now(), 0) })
+}
@@ -0,0 +1,56 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSplice(t *testing.T) {
+	// the market is flat, but on the 5th, when it crashes
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if day.Day() == 5 {
+			return Quote{200 - float64(minute)/10, 1, 201 - float64(minute)/10, 1}, true
+		}
+		return Quote{100, 1, 101, 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/splice") })
+	t.Cleanup(func() { _ = os.RemoveAll("data/splice-stress") })
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("splice"))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2022"), ParseOrDie("01-04-2022"))
+	stress := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("splice-stress"))
+	stress.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-05-2022"), ParseOrDie("01-06-2022"))
+
+	crash := depth.Splice{Source: stress, Start: ParseOrDie("01-05-2022"), End: ParseOrDie("01-05-2022").Add(6 * time.Hour), At: ParseOrDie("01-02-2022")}
+	spliced := loader.Splice(crash)
+	mids := make([]float64, 3*24*60)
+	for m := range mids {
+		mids[m] = spliced.GetDepth("BTC-BUSD").Mid()
+		spliced.Tick()
+	}
+	// the window starts and the timeline continues at the mid prices before them
+	ratio := 100.5 / 200.5
+	assert.Equal(t, 100.5, mids[24*60-1])
+	assert.InDelta(t, 100.5, mids[24*60], 1e-9)
+	assert.InDelta(t, (200.5-35.9)*ratio, mids[24*60+359], 1e-9)
+	assert.InDelta(t, (200.5-35.9)*ratio, mids[24*60+360], 1e-9)
+	assert.InDelta(t, (200.5-35.9)*ratio, mids[3*24*60-1], 1e-9)
+	assert.Equal(t, 100.5, loader.GetDepth("BTC-BUSD").Mid())
+
+	// a window past the end of the time range is cut
+	cut := loader.Splice(depth.Splice{Source: stress, Start: crash.Start, End: crash.End, At: ParseOrDie("01-04-2022").Add(-time.Hour)})
+	for m := 0; m < 3*24*60-1; m++ {
+		cut.Tick()
+	}
+	assert.InDelta(t, (200.5-5.9)*ratio, cut.GetDepth("BTC-BUSD").Mid(), 1e-9)
+
+	assert.Panics(t, func() {
+		loader.Splice(depth.Splice{Source: stress, Start: crash.Start, End: ParseOrDie("01-07-2022"), At: crash.At})
+	})
+	assert.Panics(t, func() {
+		loader.Splice(depth.Splice{Source: stress, Start: crash.Start, End: crash.End, At: ParseOrDie("01-05-2022")})
+	})
+}
@@ -0,0 +1,35 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"testing"
+)
+
+func TestRollingVol(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		// mid alternates between 100 and 110
+		mid := 100.0
+		if minute%2 == 1 {
+			mid = 110
+		}
+		return Quote{mid - 0.5, 1, mid + 0.5, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	assert.True(t, math.IsNaN(loader.RollingVol("BTC-BUSD", 10)))
+
+	for i := 0; i < 10; i++ {
+		loader.Tick()
+	}
+	r := math.Log(110.0 / 100.0)
+	// 10 returns alternating +r and -r have a zero mean
+	expected := math.Sqrt(10 * r * r / 9)
+	assert.InDelta(t, expected, loader.RollingVol("BTC-BUSD", 10), 1e-12)
+	assert.InDelta(t, expected, loader.RollingVol("BTC-BUSD", 100), 1e-12)
+	assert.InDelta(t, math.Sqrt(4*r*r/3), loader.RollingVol("BTC-BUSD", 4), 1e-12)
+}
@@ -0,0 +1,81 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore is a Store keeping the days in memory.
+type memoryStore struct {
+	schema depth.Schema
+	days   map[depth.Pair]map[time.Time][]string
+}
+
+func (s *memoryStore) Open(schema depth.Schema) error {
+	s.schema = schema
+	if s.days == nil {
+		s.days = make(map[depth.Pair]map[time.Time][]string)
+	}
+	return nil
+}
+
+func (s *memoryStore) Has(pair depth.Pair, day time.Time) (bool, error) {
+	_, ok := s.days[pair][day]
+	return ok, nil
+}
+
+func (s *memoryStore) WriteDay(pair depth.Pair, day time.Time, values []string) error {
+	if s.days[pair] == nil {
+		s.days[pair] = make(map[time.Time][]string)
+	}
+	s.days[pair][day] = values
+	return nil
+}
+
+func (s *memoryStore) ReadRange(pair depth.Pair, startDate time.Time, endDate time.Time) ([][]string, error) {
+	var days [][]string
+	for day := startDate; day.Before(endDate); day = day.AddDate(0, 0, 1) {
+		days = append(days, s.days[pair][day])
+	}
+	return days, nil
+}
+
+func TestLoadStore(t *testing.T) {
+	var downloads int64
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		if minute == 0 {
+			atomic.AddInt64(&downloads, 1)
+		}
+		return Quote{float64(100 * day.Day()), 1, float64(100*day.Day() + minute), 1}, true
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/store-test") })
+	store := &memoryStore{}
+	newLoader := func(opts ...depth.Option) *depth.CCDepthLoader {
+		opts = append(opts, depth.WithNamespace("store-test"), depth.WithStore(store), depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+		return depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	}
+	pairs := []depth.Pair{"BTC-BUSD"}
+
+	result := newLoader().Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020"))
+	assert.Len(t, result["BTC-BUSD"], 2*24*60*4)
+	assert.Equal(t, int64(2), downloads)
+	assert.Len(t, store.days["BTC-BUSD"], 2)
+	assert.NoDirExists(t, "data/store-test/binance")
+
+	// the overlapping time range downloads only its new day
+	loader := newLoader()
+	loader.Load(pairs, ParseOrDie("01-02-2020"), ParseOrDie("01-04-2020"))
+	assert.Equal(t, int64(3), downloads)
+	loader.Tick()
+	assert.Equal(t, 201.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	assert.Panics(t, func() {
+		newLoader(depth.WithReadOnly()).Load(pairs, ParseOrDie("01-01-2020"), ParseOrDie("01-05-2020"))
+	})
+	assert.Equal(t, int64(3), downloads)
+}
@@ -0,0 +1,75 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestLoadFromAndExportJSONL(t *testing.T) {
+	input := "#,BTC-BUSD,ETH-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4\nETH-BUSD,10,1,11,2,12,3,13,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	result := loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.Len(t, result, 2)
+	assert.Equal(t, 101.0, loader.GetDepth("BTC-BUSD").AskPrice)
+
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, []depth.Pair{"ETH-BUSD"}, depth.WithFormat(depth.FormatJSONL)))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var row map[string]interface{}
+	assert.NoError(t, json.Unmarshal([]byte(lines[1]), &row))
+	assert.Equal(t, "ETH-BUSD", row["pair"])
+	assert.Equal(t, 12.0, row["bid_price"])
+	assert.Equal(t, 4.0, row["ask_size"])
+	assert.Contains(t, row["time"], "00:01:00")
+
+	assert.Error(t, loader.Export(&out, nil, depth.WithFormat("xml")))
+}
+
+func TestLoadFromSchemaHeader(t *testing.T) {
+	// a file stored with WithSchema(depth.FieldMid, depth.FieldSpread)
+	input := "#,BTC-BUSD\n#fields,mid,spread\nBTC-BUSD,100,2,102,4\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance)
+	result := loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	assert.Len(t, result["BTC-BUSD"], 4)
+	record := loader.GetDepth("BTC-BUSD")
+	assert.Equal(t, 99.0, record.BidPrice)
+	assert.Equal(t, 101.0, record.AskPrice)
+
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, nil))
+	assert.Equal(t, "time,pair,bid_price,bid_size,ask_price,ask_size\n"+
+		"2020-01-01T00:00:00Z,BTC-BUSD,99,0,101,0\n"+
+		"2020-01-01T00:01:00Z,BTC-BUSD,100,0,104,0\n", out.String())
+
+	assert.Panics(t, func() {
+		loader.LoadFrom(strings.NewReader("#,BTC-BUSD\n#fields,vwap\nBTC-BUSD,100\n"), ParseOrDie("01-01-2020"))
+	})
+}
+
+func TestLoadFromParseWorkers(t *testing.T) {
+	var input strings.Builder
+	input.WriteString("#,pairs\n")
+	for i := 0; i < 20; i++ {
+		input.WriteString(fmt.Sprintf("P%d-BUSD,%d,1,%d,1,%d,1,%d,1\n", i, i, i+1, i+2, i+3))
+	}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithParseWorkers(4))
+	result := loader.LoadFrom(strings.NewReader(input.String()), ParseOrDie("01-01-2020"))
+	assert.Len(t, result, 20)
+	loader.Tick()
+	assert.Equal(t, 12.0, loader.GetDepth("P10-BUSD").BidPrice)
+
+	// a malformed row panics in the caller
+	assert.Panics(t, func() {
+		loader.LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,\"100,1,101,1\n"), ParseOrDie("01-01-2020"))
+	})
+	assert.Panics(t, func() {
+		depth.WithParseWorkers(0)
+	})
+}
@@ -0,0 +1,82 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStream(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, float64(101 + minute), 1}
+	})
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+
+	minutes := 0
+	for snapshot := range loader.Stream(context.Background(), nil) {
+		assert.True(t, start.Add(time.Duration(minutes)*time.Minute).Equal(snapshot.Time))
+		assert.Len(t, snapshot.Records, 2)
+		assert.Equal(t, float64(101+minutes), snapshot.Records["ETH-BUSD"].AskPrice)
+		minutes++
+	}
+	assert.Equal(t, 24*60, minutes)
+
+	// the stream stops once the context is done, and the cursor of the loader doesn't move
+	ctx, cancel := context.WithCancel(context.Background())
+	snapshots := loader.Stream(ctx, []depth.Pair{"BTC-BUSD"})
+	first := <-snapshots
+	assert.Len(t, first.Records, 1)
+	assert.Equal(t, float64(101), first.Records["BTC-BUSD"].AskPrice)
+	cancel()
+	for range snapshots {
+	}
+	assert.Equal(t, start, loader.CurrentTime())
+}
+
+func TestStreamWarmup(t *testing.T) {
+	start := ParseOrDie("01-01-2020")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithWarmup(time.Minute))
+	loader.LoadFrom(strings.NewReader("#,BTC-BUSD\nBTC-BUSD,100,1,101,1,102,1,103,1,104,1,105,1\n"), start)
+
+	// the stream starts past the warmup, like a Cursor
+	var snapshots []depth.TickSnapshot
+	for snapshot := range loader.Stream(context.Background(), nil) {
+		snapshots = append(snapshots, snapshot)
+	}
+	assert.Len(t, snapshots, 2)
+	assert.True(t, start.Add(time.Minute).Equal(snapshots[0].Time))
+	assert.Equal(t, float64(103), snapshots[0].Records["BTC-BUSD"].AskPrice)
+	assert.Equal(t, loader.NewCursor().GetDepth("BTC-BUSD"), snapshots[0].Records["BTC-BUSD"])
+}
+
+func TestStreamMissingRecords(t *testing.T) {
+	url := ServeChassisDays(t, func(pair depth.Pair, day time.Time, minute int) (Quote, bool) {
+		return Quote{100, 1, 101, 1}, pair != "ETH-BUSD" || day.Day() == 1
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/stream-test") })
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithNamespace("stream-test"), depth.WithDayFiles(),
+		depth.WithProgress(io.Discard), depth.WithBaseURL(url))
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	assert.NoError(t, loader.MarkBad("BTC-BUSD", start, "exchange outage"))
+	loader.Load([]depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end)
+
+	// every pair has a record of each minute, the NaN one of its bad day, and of the minutes past its end
+	minutes := 0
+	for snapshot := range loader.Stream(context.Background(), nil) {
+		assert.Len(t, snapshot.Records, 2)
+		btc, eth := snapshot.Records["BTC-BUSD"], snapshot.Records["ETH-BUSD"]
+		assert.True(t, snapshot.Time.Equal(btc.Time) && snapshot.Time.Equal(eth.Time))
+		assert.Equal(t, minutes < 24*60, math.IsNaN(btc.BidPrice), minutes)
+		assert.Equal(t, minutes >= 24*60, math.IsNaN(eth.AskPrice), minutes)
+		minutes++
+	}
+	assert.Equal(t, 2*24*60, minutes)
+}
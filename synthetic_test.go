@@ -0,0 +1,66 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestSynthetic(t *testing.T) {
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		// the mid price alternates by 1%, the spread is 0.2%, and the imbalance is positive for the first half of the day
+		mid := 100.0
+		if minute%2 == 1 {
+			mid = 101
+		}
+		if minute < 12*60 {
+			return Quote{mid * 0.999, 3, mid * 1.001, 1}
+		}
+		return Quote{mid * 0.999, 1, mid * 1.001, 3}
+	})
+	t.Cleanup(func() { _ = os.RemoveAll("data/synthetic") })
+	start, end := ParseOrDie("01-01-2022"), ParseOrDie("01-02-2022")
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithBaseURL(url), depth.WithNamespace("synthetic"))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	model := loader.Calibrate("BTC-BUSD")
+	assert.Equal(t, 100.0, model.Mid)
+	assert.InDelta(t, math.Log(1.01), model.Volatility, 1e-3)
+	assert.InDelta(t, 0.002, model.Spreads[50], 1e-9)
+	assert.InDelta(t, 4, model.Sizes[50], 1e-9)
+	assert.InDelta(t, 0, model.ImbalanceMean, 1e-9)
+	assert.Greater(t, model.ImbalanceAutocorrelation, 0.99)
+
+	pairs := []depth.Pair{"BTC-BUSD", "ETH-BUSD"}
+	synthetic := depth.NewSynthetic(1, model)
+	var _ depth.Loader = synthetic
+	records := synthetic.Load(pairs, start, start.Add(time.Hour))
+	assert.Len(t, records["BTC-BUSD"], 4*60)
+	assert.NotContains(t, records, depth.Pair("ETH-BUSD"))
+	assert.True(t, math.IsNaN(synthetic.GetDepth("ETH-BUSD").BidPrice))
+
+	// the cursor replays the loaded records, and goes on past the end of the time range
+	again := depth.NewSynthetic(1, model)
+	for minute := 0; minute < 100000; minute++ {
+		record := synthetic.GetDepth("BTC-BUSD")
+		if minute < 60 {
+			assert.Equal(t, records["BTC-BUSD"][4*minute], strconv.FormatFloat(record.BidPrice, 'f', -1, 64))
+		}
+		assert.Equal(t, record.BidPrice, again.GetDepth("BTC-BUSD").BidPrice)
+		if !assert.Less(t, record.BidPrice, record.AskPrice) || !assert.Greater(t, record.BidSize, 0.0) {
+			break
+		}
+		synthetic.Tick()
+		again.Tick()
+	}
+	assert.NotEqual(t, records["BTC-BUSD"], depth.NewSynthetic(2, model).Load(pairs, start, start.Add(time.Hour))["BTC-BUSD"])
+
+	assert.Panics(t, func() {
+		loader.Calibrate("ETH-BUSD")
+	})
+}
@@ -0,0 +1,85 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"context"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// tardisQuotes is the CSV of a Tardis.dev quotes dataset of 2020-01-01, with two updates before the minute 00:01,
+// and one at 00:02:00 sharp, without an ask.
+const tardisQuotes = `exchange,symbol,timestamp,local_timestamp,ask_amount,ask_price,bid_price,bid_amount
+binance,BTCUSDT,1577836800100000,1577836800200000,2,7201,7200,1
+binance,BTCUSDT,1577836830000000,1577836830100000,4,7203,7202,3
+binance,BTCUSDT,1577836920000000,1577836920100000,,,7204,5
+`
+
+func TestTardisDepthLoader(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		paths = append(paths, r.URL.Path)
+		if r.URL.Path != "/v1/binance/quotes/2020/01/01/BTCUSDT.csv.gz" {
+			http.NotFound(w, r)
+			return
+		}
+		gz := gzip.NewWriter(w)
+		_, _ = io.WriteString(gz, tardisQuotes)
+		_ = gz.Close()
+	}))
+	defer server.Close()
+	t.Cleanup(func() { _ = os.RemoveAll("data/tardis") })
+
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	loader := depth.NewTardisDepthLoader(depth.MarketBinance, "key", depth.WithProgress(io.Discard),
+		depth.WithProvider(depth.NewTardisProvider("key", depth.WithTardisBaseURL(server.URL))))
+	records := loader.Load([]depth.Pair{"BTC-USDT"}, start, end)
+	assert.Len(t, records["BTC-USDT"], 24*60*4)
+	assert.FileExists(t, "data/tardis/binance/2020-01-01_2020-01-02_depth.csv")
+
+	quote := func() []float64 {
+		record := loader.GetDepth("BTC-USDT")
+		return []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize}
+	}
+	assert.Equal(t, []float64{7200, 1, 7201, 2}, quote())
+	loader.Tick()
+	assert.Equal(t, []float64{7202, 3, 7203, 4}, quote())
+	loader.Tick()
+	assert.Equal(t, []float64{7204, 5, 0, 0}, quote())
+
+	// the symbols follow the format of the exchange
+	ctx := context.Background()
+	for market, path := range map[depth.Market]string{
+		depth.MarketKraken:             "/v1/kraken/quotes/2020/01/01/XBT-USD.csv.gz",
+		depth.MarketBinanceCoinFutures: "/v1/binance-delivery/quotes/2020/01/01/BTCUSD_PERP.csv.gz",
+		depth.MarketDeribit:            "/v1/deribit/quotes/2020/01/01/BTC-PERPETUAL.csv.gz",
+	} {
+		paths = nil
+		records, err := depth.NewTardisProvider("key", depth.WithTardisBaseURL(server.URL)).FetchDay(ctx, market, "BTC-USD", start)
+		assert.NoError(t, err)
+		assert.Empty(t, records)
+		assert.Equal(t, []string{path}, paths)
+	}
+	paths = nil
+	_, err := depth.NewTardisProvider("key", depth.WithTardisBaseURL(server.URL), depth.WithTardisSymbols(map[depth.Pair]string{"BTC-USD": "FI_XBTUSD_200327"})).
+		FetchDay(ctx, depth.MarketKrakenFutures, "BTC-USD", start)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/v1/cryptofacilities/quotes/2020/01/01/FI_XBTUSD_200327.csv.gz"}, paths)
+
+	_, err = depth.NewTardisProvider("", depth.WithTardisBaseURL(server.URL)).FetchDay(ctx, depth.MarketBinance, "BTC-USDT", start)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "401 Unauthorized")
+	}
+	assert.Panics(t, func() {
+		depth.NewTardisDepthLoader("unknown", "key")
+	})
+}
@@ -0,0 +1,98 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpreadEstimators(t *testing.T) {
+	at := depth.Record{BidPrice: 99, BidSize: 1, AskPrice: 101, AskSize: 1}
+	later := depth.Record{BidPrice: 100, BidSize: 1, AskPrice: 102, AskSize: 1}
+
+	assert.Equal(t, 100.0, at.Mid())
+	assert.InDelta(t, 0.02, at.EffectiveSpread(101, depth.Buy), 1e-12)
+	assert.InDelta(t, 0.02, at.EffectiveSpread(99, depth.Sell), 1e-12)
+
+	realized := depth.RealizedSpread(101, depth.Buy, at, later)
+	impact := depth.PriceImpact(depth.Buy, at, later)
+	assert.InDelta(t, 0.0, realized, 1e-12)
+	assert.InDelta(t, at.EffectiveSpread(101, depth.Buy), realized+impact, 1e-12)
+}
+
+func TestNotional(t *testing.T) {
+	record := depth.Record{BidPrice: 100, BidSize: 0.5, AskPrice: 101, AskSize: 2}
+	assert.Equal(t, 50.0, record.BidNotional())
+	assert.Equal(t, 202.0, record.AskNotional())
+	assert.Equal(t, 252.0, record.TouchNotional())
+
+	filter, err := depth.ParseFilter("bid_notional < 100 && touch_notional > 250")
+	assert.NoError(t, err)
+	assert.True(t, filter(record))
+}
+
+func TestTradeSpreads(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/trade/binance/BTC-BUSD":
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}],"expiration":"300 seconds"}`, server.URL+"/csv/BTC-BUSD/"+r.URL.Query().Get("startTime"))
+		case "/csv/BTC-BUSD/2020-01-01":
+			// the takers sell more than they buy in the minute 00:00, buy in the minute 00:02, and as much in 00:03
+			gz := gzip.NewWriter(w)
+			_, _ = fmt.Fprint(gz, "time_seconds,time_nanoseconds,price,size,is_buyer_maker,trade_id\n",
+				"1577836800,1000,100,1,0,1\n1577836859,0,102,3,1,2\n1577836920,0,99,2,false,3\n",
+				"1577836980,0,100,1,0,4\n1577836990,0,100,1,1,5\n")
+			_ = gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { _ = os.RemoveAll("data/tca-test") })
+
+	// the mid prices are 100.5, 102.5, 104.5 and 106.5
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,1,102,1,103,1,104,1,105,1,106,1,107,1\n"
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithBaseURL(server.URL), depth.WithProgress(io.Discard),
+		depth.WithNamespace("tca-test"))
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	trades := loader.Trades()
+	trades.Load([]depth.Pair{"BTC-BUSD"}, ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020"))
+
+	spreads := trades.TradeSpreads("BTC-BUSD", time.Minute)
+	assert.Len(t, spreads, 24*60)
+	first := spreads[0]
+	assert.True(t, ParseOrDie("01-01-2020").Equal(first.Time))
+	assert.Equal(t, depth.Sell, first.Side)
+	assert.Equal(t, 101.5, first.VWAP)
+	assert.InDelta(t, -2*(101.5-100.5)/100.5, first.Effective, 1e-12)
+	assert.InDelta(t, -2*(101.5-102.5)/100.5, first.Realized, 1e-12)
+	assert.InDelta(t, first.Effective, first.Realized+first.Impact, 1e-12)
+
+	// a minute without trades, or with as much bought as sold, has no spread
+	assert.True(t, math.IsNaN(spreads[1].Effective))
+	assert.Equal(t, depth.Buy, spreads[2].Side)
+	assert.InDelta(t, 2*(99-104.5)/104.5, spreads[2].Effective, 1e-12)
+	assert.InDelta(t, 2*(99-106.5)/104.5, spreads[2].Realized, 1e-12)
+	assert.Equal(t, depth.Side(0), spreads[3].Side)
+	assert.True(t, math.IsNaN(spreads[3].Effective))
+
+	// a minute whose later record is past the depth records has no realized spread nor price impact
+	spreads = trades.TradeSpreads("BTC-BUSD", 2*time.Minute)
+	assert.False(t, math.IsNaN(spreads[2].Effective))
+	assert.True(t, math.IsNaN(spreads[2].Realized))
+	assert.True(t, math.IsNaN(spreads[2].Impact))
+
+	assert.Panics(t, func() {
+		trades.TradeSpreads("BTC-BUSD", 30*time.Second)
+	})
+}
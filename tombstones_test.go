@@ -0,0 +1,57 @@
+package order_book_depth_loader_test
+
+import (
+	"bytes"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTombstones(t *testing.T) {
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-03-2020")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{100, 1, 101, 1}
+	})
+	t.Cleanup(func() { _ = os.Remove("data/binance/tombstones.csv") })
+	url := ServeChassis(t, func(pair depth.Pair, minute int) Quote {
+		return Quote{50, 1, 51, 1}
+	})
+
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard))
+	assert.NoError(t, loader.MarkBad("BTC-BUSD", ParseOrDie("01-02-2020"), "exchange outage, no trading"))
+	tombstones, err := loader.Tombstones()
+	assert.NoError(t, err)
+	assert.Len(t, tombstones, 1)
+	assert.Equal(t, depth.Pair("BTC-BUSD"), tombstones[0].Pair)
+	assert.True(t, ParseOrDie("01-02-2020").Equal(tombstones[0].Day))
+	assert.Equal(t, "exchange outage, no trading", tombstones[0].Reason)
+
+	// the bad day is not downloaded again, and is excluded from the exports
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	for i := 0; i < 24*60; i++ {
+		loader.Tick()
+	}
+	assert.True(t, math.IsNaN(loader.GetDepth("BTC-BUSD").BidPrice))
+	var out bytes.Buffer
+	assert.NoError(t, loader.Export(&out, nil))
+	assert.Equal(t, 1+24*60, strings.Count(out.String(), "\n"))
+
+	// the refetched day replaces the bad one
+	refetch := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithRefetchBad(), depth.WithBaseURL(url))
+	result := refetch.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, "100", result["BTC-BUSD"][0])
+	assert.Equal(t, "50", result["BTC-BUSD"][24*60*4])
+	tombstones, err = refetch.Tombstones()
+	assert.NoError(t, err)
+	assert.Empty(t, tombstones)
+
+	loader = depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly())
+	result = loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, "50", result["BTC-BUSD"][24*60*4])
+	assert.Equal(t, "100", result["BTC-BUSD"][24*60*4-4])
+	assert.ErrorIs(t, loader.MarkBad("BTC-BUSD", start, "vendor error"), depth.ErrReadOnly)
+}
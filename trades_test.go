@@ -0,0 +1,70 @@
+package order_book_depth_loader_test
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestTrades(t *testing.T) {
+	var requests int32
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		switch r.URL.Path {
+		case "/v1/trade/binance/BTC-BUSD":
+			_, _ = fmt.Fprintf(w, `{"urls":[{"url":%q}],"expiration":"300 seconds"}`, server.URL+"/csv/BTC-BUSD/"+r.URL.Query().Get("startTime"))
+		case "/csv/BTC-BUSD/2020-01-01":
+			// two trades in the minute 00:00, and one in the minute 00:02
+			gz := gzip.NewWriter(w)
+			_, _ = fmt.Fprint(gz, "time_seconds,time_nanoseconds,price,size,is_buyer_maker,trade_id\n",
+				"1577836800,1000,100,1,0,1\n1577836859,0,102,3,1,2\n1577836920,0,99,2,false,3\n")
+			_ = gz.Close()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { _ = os.RemoveAll("data/trades") })
+
+	input := "#,BTC-BUSD\nBTC-BUSD,100,1,101,2,102,3,103,4,104,5,105,6\n"
+	opts := []depth.Option{depth.WithBaseURL(server.URL), depth.WithProgress(io.Discard), depth.WithNamespace("trades")}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, opts...)
+	loader.LoadFrom(strings.NewReader(input), ParseOrDie("01-01-2020"))
+	trades := loader.Trades()
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	minutes := trades.Load([]depth.Pair{"BTC-BUSD"}, start, end)["BTC-BUSD"]
+	assert.Len(t, minutes, 24*60)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+
+	// the trades are iterated with the cursor of the depth loader
+	assert.Equal(t, depth.TradeMinute{Volume: 4, BuyVolume: 1, VWAP: 101.5, Count: 2}, trades.GetTrades("BTC-BUSD"))
+	loader.Tick()
+	assert.Equal(t, 0, trades.GetTrades("BTC-BUSD").Count)
+	assert.True(t, math.IsNaN(trades.GetTrades("BTC-BUSD").VWAP))
+	loader.Tick()
+	assert.Equal(t, 104.0, loader.GetDepth("BTC-BUSD").BidPrice)
+	assert.Equal(t, depth.TradeMinute{Volume: 2, BuyVolume: 2, VWAP: 99, Count: 1}, trades.GetTrades("BTC-BUSD"))
+	assert.True(t, math.IsNaN(trades.GetTrades("ETH-BUSD").VWAP))
+
+	// the days are cached
+	cached := depth.NewCCDepthLoader(depth.MarketBinance, opts...).Trades().Load([]depth.Pair{"BTC-BUSD"}, start, end)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&requests))
+	assert.Equal(t, minutes[2], cached["BTC-BUSD"][2])
+
+	assert.Panics(t, func() {
+		trades.Load([]depth.Pair{"BTC-BUSD"}, start, ParseOrDie("01-03-2020"))
+	})
+	assert.Panics(t, func() {
+		depth.NewCCDepthLoader(depth.MarketBinance, opts...).Trades().Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	})
+}
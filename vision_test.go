@@ -0,0 +1,72 @@
+package order_book_depth_loader_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+// bookTicker is the CSV of a Binance bookTicker archive of 2020-01-01, with an update before the day, two updates
+// before the minute 00:01, the last one out of order, and one at 00:02:00 sharp.
+const bookTicker = `update_id,best_bid_price,best_bid_qty,best_ask_price,best_ask_qty,transaction_time,event_time
+1,7199,1,7200,2,1577836799000,1577836799001
+2,7200,1,7201,2,1577836800500,1577836800501
+4,7202,3,7203,4,1577836860000,1577836860001
+3,7201,1,7202,2,1577836830000,1577836830001
+5,7204,5,7205,6,1577836920000,1577836920001
+`
+
+func TestBinanceVisionProvider(t *testing.T) {
+	archive := zipFile(t, "BTCUSDT-bookTicker-2020-01-01.csv", bookTicker)
+	sum := sha256.Sum256(archive)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/data/futures/um/daily/bookTicker/BTCUSDT/BTCUSDT-bookTicker-2020-01-01.zip",
+			"/data/futures/um/daily/bookTicker/ETHUSDT/ETHUSDT-bookTicker-2020-01-01.zip":
+			_, _ = w.Write(archive)
+		case "/data/futures/um/daily/bookTicker/BTCUSDT/BTCUSDT-bookTicker-2020-01-01.zip.CHECKSUM":
+			_, _ = w.Write([]byte(hex.EncodeToString(sum[:]) + "  BTCUSDT-bookTicker-2020-01-01.zip\n"))
+		case "/data/futures/um/daily/bookTicker/ETHUSDT/ETHUSDT-bookTicker-2020-01-01.zip.CHECKSUM":
+			_, _ = w.Write([]byte("0000  ETHUSDT-bookTicker-2020-01-01.zip\n"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+	t.Cleanup(func() { _ = os.RemoveAll("data/vision") })
+
+	start, end := ParseOrDie("01-01-2020"), ParseOrDie("01-02-2020")
+	provider := depth.NewBinanceVisionProvider(depth.WithVisionBaseURL(server.URL))
+	loader := depth.NewCCDepthLoader(depth.MarketBinanceUsdsFutures, depth.WithProgress(io.Discard),
+		depth.WithNamespace("vision"), depth.WithProvider(provider))
+	records := loader.Load([]depth.Pair{"BTC-USDT"}, start, end)
+	assert.Len(t, records["BTC-USDT"], 24*60*4)
+
+	// each minute has the last update at or before its start
+	quote := func() []float64 {
+		record := loader.GetDepth("BTC-USDT")
+		return []float64{record.BidPrice, record.BidSize, record.AskPrice, record.AskSize}
+	}
+	assert.Equal(t, []float64{7199, 1, 7200, 2}, quote())
+	loader.Tick()
+	assert.Equal(t, []float64{7202, 3, 7203, 4}, quote())
+	loader.Tick()
+	assert.Equal(t, []float64{7204, 5, 7205, 6}, quote())
+	loader.Tick()
+	assert.Equal(t, []float64{7204, 5, 7205, 6}, quote())
+
+	// the days without an archive have no data
+	assert.Empty(t, loader.Availability("BNB-USDT", start, end))
+
+	_, err := provider.FetchDay(context.Background(), depth.MarketBinanceUsdsFutures, "ETH-USDT", start)
+	assert.ErrorIs(t, err, depth.ErrCorrupted)
+	_, err = provider.FetchDay(context.Background(), depth.MarketBinance, "BTC-USDT", start)
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package order_book_depth_loader_test
+
+import (
+	"github.com/bogdantimes/order-book-depth-loader/depth"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWarmup(t *testing.T) {
+	start, end := ParseOrDie("01-01-2021"), ParseOrDie("01-02-2021")
+	WriteFixture(t, depth.MarketBinance, []depth.Pair{"BTC-BUSD", "ETH-BUSD"}, start, end, func(pair depth.Pair, minute int) Quote {
+		return Quote{float64(100 + minute%7), 1, float64(101 + minute%7), 1}
+	})
+	fed := map[depth.Pair][]int{}
+	loader := depth.NewCCDepthLoader(depth.MarketBinance, depth.WithProgress(io.Discard), depth.WithReadOnly(),
+		depth.WithWarmup(time.Hour+30*time.Second),
+		depth.WithConsumer(func(pair depth.Pair, minute int, record depth.Record) {
+			assert.Equal(t, float64(100+minute%7), record.BidPrice)
+			fed[pair] = append(fed[pair], minute)
+		}))
+	loader.Load([]depth.Pair{"BTC-BUSD"}, start, end)
+
+	// the cursor starts past the warmup, which was fed to the consumer
+	assert.Equal(t, float64(100+60%7), loader.GetDepth("BTC-BUSD").BidPrice)
+	assert.Len(t, fed["BTC-BUSD"], 61)
+	assert.Equal(t, 60, fed["BTC-BUSD"][60])
+	assert.False(t, math.IsNaN(loader.RollingVol("BTC-BUSD", 30)))
+
+	// loading another pair keeps the cursor, the pair is fed up to the cursor
+	loader.Tick()
+	loader.Load([]depth.Pair{"ETH-BUSD"}, start, end)
+	assert.Len(t, fed["ETH-BUSD"], 62)
+	assert.Len(t, fed["BTC-BUSD"], 62)
+	assert.Equal(t, float64(100+61%7), loader.GetDepth("ETH-BUSD").BidPrice)
+	loader.Tick()
+	assert.Equal(t, []int{0, 1, 2}, fed["BTC-BUSD"][:3])
+	assert.Equal(t, 62, fed["BTC-BUSD"][len(fed["BTC-BUSD"])-1])
+	assert.Equal(t, 62, fed["ETH-BUSD"][len(fed["ETH-BUSD"])-1])
+
+	assert.Panics(t, func() {
+		depth.WithWarmup(-time.Minute)
+	})
+}